@@ -0,0 +1,65 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// cshared exposes the stack trace parser as a C ABI, so tooling written in
+// other languages can reuse the exact same parser without running pp.
+//
+// Build it as a shared library; a C header is generated alongside:
+//
+//	go build -buildmode=c-shared -o libpanicparse.so ./cmd/cshared
+//
+// The exported functions are:
+//
+//	char* pp_parse(const char* data, size_t len);
+//	void pp_free(char* p);
+//
+// pp_parse parses all the stack traces found in data and returns a NUL
+// terminated JSON document:
+//
+//	{"snapshots": [...], "error": "..."}
+//
+// "snapshots" is the list of stack.Snapshot found, serialized as-is. "error"
+// is omitted when parsing succeeded.
+//
+// Memory ownership: data is only read during the call and stays owned by the
+// caller. The returned string is owned by the caller and must be released
+// with pp_free, not with the caller's own allocator.
+//
+// Example in Python:
+//
+//	lib = ctypes.CDLL("./libpanicparse.so")
+//	lib.pp_parse.restype = ctypes.c_void_p
+//	lib.pp_free.argtypes = [ctypes.c_void_p]
+//	p = lib.pp_parse(data, len(data))
+//	result = json.loads(ctypes.string_at(p))
+//	lib.pp_free(p)
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+//export pp_parse
+func pp_parse(data *C.char, n C.size_t) *C.char {
+	// Copy the data, so the caller's buffer is not referenced once the call
+	// returns. C.GoBytes is not used since it takes a C.int, which would
+	// truncate inputs of 2 GiB or more.
+	b := make([]byte, int(n))
+	copy(b, unsafe.Slice((*byte)(unsafe.Pointer(data)), int(n)))
+	return C.CString(string(parse(b)))
+}
+
+//export pp_free
+func pp_free(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
+
+// main is required by -buildmode=c-shared but is never called.
+func main() {
+}
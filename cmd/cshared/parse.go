@@ -0,0 +1,51 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build cgo
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// result is the JSON document returned by pp_parse.
+type result struct {
+	Snapshots []*stack.Snapshot `json:"snapshots"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// parse parses all the stack traces in data and returns the JSON encoded
+// result.
+//
+// It never fails; errors are reported in the document itself.
+func parse(data []byte) []byte {
+	res := result{Snapshots: []*stack.Snapshot{}}
+	opts := stack.DefaultOpts()
+	opts.ResolveModules = true
+	r := io.Reader(bytes.NewReader(data))
+	for {
+		s, suffix, err := stack.ScanSnapshot(r, io.Discard, opts)
+		if s != nil {
+			res.Snapshots = append(res.Snapshots, s)
+		}
+		if err != nil {
+			if err != io.EOF {
+				res.Error = err.Error()
+			}
+			break
+		}
+		r = io.MultiReader(bytes.NewReader(suffix), r)
+	}
+	b, err := json.Marshal(&res)
+	if err != nil {
+		// Should not happen, all the types in package stack can be serialized.
+		b, _ = json.Marshal(&result{Snapshots: []*stack.Snapshot{}, Error: err.Error()})
+	}
+	return b
+}
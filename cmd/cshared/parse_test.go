@@ -0,0 +1,40 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build cgo
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	var res struct {
+		Snapshots []struct {
+			Goroutines []interface{}
+		}
+		Error *string
+	}
+	if err := json.Unmarshal(parse(internaltest.StaticPanicwebOutput()), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Error != nil {
+		t.Fatal(*res.Error)
+	}
+	if len(res.Snapshots) != 1 || len(res.Snapshots[0].Goroutines) == 0 {
+		t.Fatalf("unexpected %#v", res.Snapshots)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	t.Parallel()
+	if got := string(parse([]byte("junk\n"))); got != `{"snapshots":[]}` {
+		t.Fatalf("unexpected %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build go1.23
+
+// The panics in this file use language features more recent than the go
+// version in go.mod. The build constraint enables them for this file only.
+
+package main
+
+import "iter"
+
+func init() {
+	types["coroutine"] = struct {
+		desc string
+		f    func()
+	}{
+		"panic while an iterator pulled with iter.Pull is suspended in a runtime coroutine",
+		func() {
+			panicCoroutine()
+		},
+	}
+	types["generic"] = struct {
+		desc string
+		f    func()
+	}{
+		"panic in a method of a generic type called from a generic function",
+		func() {
+			panicGeneric("test")
+		},
+	}
+	types["iterator"] = struct {
+		desc string
+		f    func()
+	}{
+		"panic in the body of a range-over-func loop",
+		func() {
+			panicIterator()
+		},
+	}
+}
+
+// genericStack is a generic type so its methods are instantiated.
+type genericStack[T any] struct {
+	items []T
+}
+
+//go:noinline
+func (s *genericStack[T]) pop() T {
+	if len(s.items) == 0 {
+		panic("pop on empty stack")
+	}
+	v := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return v
+}
+
+//go:noinline
+func panicGeneric[T any](v T) {
+	s := genericStack[T]{items: []T{v}}
+	s.pop()
+	s.pop()
+}
+
+// countSeq yields the integers from 0 to n-1.
+func countSeq(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+//go:noinline
+func panicIterator() {
+	for i := range countSeq(3) {
+		if i == 1 {
+			panic("test")
+		}
+	}
+}
+
+//go:noinline
+func panicCoroutine() {
+	// The iterator runs in its own goroutine, which is blocked in the
+	// "coroutine" state while it is not pulled.
+	next, _ := iter.Pull(countSeq(3))
+	next()
+	panic("test")
+}
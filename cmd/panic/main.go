@@ -80,13 +80,13 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if len(os.Args) == 3 && os.Args[1] == "-all-traceback" {
+		os.Exit(allTraceback(os.Stdout, os.Args[2]))
+	}
 	usage()
 	os.Exit(1)
 }
 
-// Mocked in test.
-var stdErr io.Writer = os.Stderr
-
 // Utility functions.
 
 func panicint(i int) {
@@ -446,6 +446,8 @@ ensure output on different version of the Go toolchain can be successfully
 parsed.
 
 Set GOTRACEBACK before running this tool to see how it affects the panic output.
+Use "panic -all-traceback <way>" to run the way once per GOTRACEBACK value,
+each output in its own delimited section.
 
 Built with: ` + runtime.Version() + `
 
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"testing"
@@ -51,3 +52,22 @@ func TestMain(t *testing.T) {
 		})
 	}
 }
+
+func TestAllTracebackUnknown(t *testing.T) {
+	// Not parallel since it mocks stdErr.
+	b := bytes.Buffer{}
+	stdErr = &b
+	defer func() {
+		stdErr = os.Stderr
+	}()
+	out := bytes.Buffer{}
+	if code := allTraceback(&out, "unknown"); code != 1 {
+		t.Fatalf("unexpected exit code %d", code)
+	}
+	if got := b.String(); got != "unknown panic style \"unknown\"\n" {
+		t.Fatalf("unexpected %q", got)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("unexpected %q", out.String())
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// Mocked in test.
+var stdErr io.Writer = os.Stderr
+
+// tracebackLevels are the GOTRACEBACK values used by -all-traceback.
+var tracebackLevels = []string{"none", "single", "all", "system", "crash"}
+
+// sectionPrefix starts each delimited section printed by -all-traceback. The
+// line is followed by the GOTRACEBACK value.
+const sectionPrefix = "===== GOTRACEBACK="
+
+// allTraceback reruns the panic style name once per GOTRACEBACK value and
+// prints each combined output as a delimited section.
+//
+// Returns the process exit code.
+func allTraceback(w io.Writer, name string) int {
+	if _, ok := types[name]; !ok {
+		fmt.Fprintf(stdErr, "unknown panic style %q\n", name)
+		return 1
+	}
+	for _, l := range tracebackLevels {
+		/* #nosec G204 */
+		c := exec.Command(os.Args[0], name)
+		c.Env = append(os.Environ(), "GOTRACEBACK="+l)
+		out, err := c.CombinedOutput()
+		code := 0
+		if err != nil {
+			code = -1
+			if e, ok := err.(*exec.ExitError); ok {
+				code = e.ExitCode()
+			}
+		}
+		fmt.Fprintf(w, "%s%s\n", sectionPrefix, l)
+		_, _ = w.Write(out)
+		fmt.Fprintf(w, "===== exit code %d\n", code)
+	}
+	return 0
+}
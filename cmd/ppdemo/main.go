@@ -0,0 +1,224 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// ppdemo demonstrates how to use the stack package as a library.
+//
+// It exercises the public API: scanning, aggregation with each similarity
+// mode, filtering, JSON export, HTML export and webstack. Each mode is
+// implemented in a short function that can be used as a starting point.
+//
+// Examples:
+//
+//	go test ./... 2>&1 | ppdemo -mode json
+//	ppdemo -mode html -similarity exactlines stack.txt > out.html
+//	ppdemo -mode serve -addr localhost:6060
+//
+// When no input is provided, a snapshot of ppdemo's own goroutines is used.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/webstack"
+)
+
+// similarities maps the -similarity flag values to stack.Similarity.
+var similarities = map[string]stack.Similarity{
+	"exactflags": stack.ExactFlags,
+	"exactlines": stack.ExactLines,
+	"anypointer": stack.AnyPointer,
+	"anyvalue":   stack.AnyValue,
+}
+
+// config is the processing configuration derived from the flags.
+type config struct {
+	mode       string
+	similarity stack.Similarity
+	filter     *regexp.Regexp
+	opts       *stack.Opts
+}
+
+// run reads a stack trace from in and writes the result to out.
+func run(in io.Reader, out io.Writer, c *config) error {
+	if c.mode == "first" {
+		return writeFirst(in, out, c)
+	}
+	// Anything before the stack trace, like log lines, is discarded.
+	s, _, err := stack.ScanSnapshot(in, io.Discard, c.opts)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if s == nil {
+		return errors.New("no stack trace found")
+	}
+	filterSnapshot(s, c.filter)
+	if len(s.Goroutines) == 0 {
+		return errors.New("no goroutine left after filtering")
+	}
+	// Bucketing should only be done if no data race was detected.
+	if s.IsRace() {
+		if c.mode == "html" {
+			return s.ToHTML(out, "")
+		}
+		return writeJSON(out, s)
+	}
+	a := s.Aggregate(c.similarity)
+	switch c.mode {
+	case "text":
+		return writeText(out, a)
+	case "json":
+		return writeJSON(out, a)
+	case "html":
+		return a.ToHTML(out, "")
+	case "tree":
+		return a.ToHTMLTree(out, "")
+	default:
+		return fmt.Errorf("unknown mode %q", c.mode)
+	}
+}
+
+// filterSnapshot only keeps the goroutines with a call matching re.
+func filterSnapshot(s *stack.Snapshot, re *regexp.Regexp) {
+	if re == nil {
+		return
+	}
+	out := s.Goroutines[:0]
+	for _, g := range s.Goroutines {
+		for i := range g.Stack.Calls {
+			if re.MatchString(g.Stack.Calls[i].Func.Complete) {
+				out = append(out, g)
+				break
+			}
+		}
+	}
+	s.Goroutines = out
+}
+
+// writeText prints a minimal text representation of each bucket.
+func writeText(out io.Writer, a *stack.Aggregated) error {
+	for _, b := range a.Buckets {
+		if _, err := fmt.Fprintf(out, "%d: %s %v\n", len(b.IDs), b.State, b.IDs); err != nil {
+			return err
+		}
+		for i := range b.Stack.Calls {
+			c := &b.Stack.Calls[i]
+			if _, err := fmt.Fprintf(out, "    %s:%d %s(%s)\n", c.SrcName, c.Line, c.Func.Name, &c.Args); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeJSON serializes the whole object. All the types in package stack can
+// be serialized as JSON as-is.
+func writeJSON(out io.Writer, v interface{}) error {
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+// writeFirst only parses the panicking goroutine.
+func writeFirst(in io.Reader, out io.Writer, c *config) error {
+	g, err := stack.FirstGoroutine(in, c.opts)
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return errors.New("no stack trace found")
+	}
+	return writeJSON(out, g)
+}
+
+// serve starts a web server exposing webstack.SnapshotHandler.
+func serve(addr string) error {
+	http.HandleFunc("/", webstack.SnapshotHandler)
+	log.Printf("Serving on http://%s/ ; try ?similarity=anyvalue or ?view=tree", addr)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           http.DefaultServeMux,
+		ReadHeaderTimeout: 2 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+// selfSnapshot returns the stack trace of all the goroutines of this process.
+func selfSnapshot() io.Reader {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return bytes.NewReader(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func mainImpl() error {
+	mode := flag.String("mode", "text", "Output mode: text, json, html, tree, first or serve")
+	simil := flag.String("similarity", "anypointer", "Aggregation similarity: exactflags, exactlines, anypointer or anyvalue")
+	filterFlag := flag.String("filter", "", "Regexp to only keep goroutines with a matching function, ex: -filter 'net/http'")
+	noGuess := flag.Bool("noguess", false, "Do not guess GOROOT and GOPATH and do not parse the sources")
+	addr := flag.String("addr", "localhost:6060", "Address to listen to with -mode serve")
+	flag.Parse()
+
+	if *mode == "serve" {
+		return serve(*addr)
+	}
+	c := config{mode: *mode, opts: stack.DefaultOpts()}
+	var ok bool
+	if c.similarity, ok = similarities[*simil]; !ok {
+		return fmt.Errorf("unknown similarity %q", *simil)
+	}
+	if *filterFlag != "" {
+		var err error
+		if c.filter, err = regexp.Compile(*filterFlag); err != nil {
+			return err
+		}
+	}
+	if *noGuess {
+		c.opts.GuessPaths = false
+		c.opts.AnalyzeSources = false
+	}
+
+	var in io.Reader
+	switch flag.NArg() {
+	case 0:
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+			in = os.Stdin
+		} else {
+			in = selfSnapshot()
+		}
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+		/* #nosec G307 */
+		defer f.Close()
+		in = f
+	default:
+		return errors.New("pipe from stdin or specify a single file")
+	}
+	return run(in, os.Stdout, &c)
+}
+
+func main() {
+	if err := mainImpl(); err != nil {
+		fmt.Fprintf(os.Stderr, "ppdemo: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []string{"text", "json", "html", "tree", "first"} {
+		for name, s := range similarities {
+			mode := mode
+			s := s
+			t.Run(mode+"-"+name, func(t *testing.T) {
+				t.Parallel()
+				out := bytes.Buffer{}
+				c := config{mode: mode, similarity: s, opts: noGuess()}
+				if err := run(bytes.NewReader(internaltest.StaticPanicwebOutput()), &out, &c); err != nil {
+					t.Fatal(err)
+				}
+				if out.Len() == 0 {
+					t.Fatal("expected output")
+				}
+				if mode == "json" || mode == "first" {
+					var v interface{}
+					if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRunFilter(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	c := config{mode: "text", similarity: stack.AnyValue, filter: regexp.MustCompile(`^main\.main$`), opts: noGuess()}
+	if err := run(bytes.NewReader(internaltest.StaticPanicwebOutput()), &out, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "1: chan receive [1]\n") {
+		t.Fatalf("unexpected %q", out.String())
+	}
+	c.filter = regexp.MustCompile(`notpresent`)
+	if err := run(bytes.NewReader(internaltest.StaticPanicwebOutput()), &out, &c); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRunErr(t *testing.T) {
+	t.Parallel()
+	c := config{mode: "text", opts: noGuess()}
+	if err := run(strings.NewReader("nothing"), &bytes.Buffer{}, &c); err == nil {
+		t.Fatal("expected error")
+	}
+	c.mode = "bad"
+	if err := run(selfSnapshot(), &bytes.Buffer{}, &c); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func noGuess() *stack.Opts {
+	o := stack.DefaultOpts()
+	o.GuessPaths = false
+	o.AnalyzeSources = false
+	return o
+}
@@ -0,0 +1,28 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"fmt"
+	"plugin"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+)
+
+// loadAnalyzers opens the comma separated Go plugins. They are expected to
+// call analyzer.Register in their init() function.
+func loadAnalyzers(paths string) error {
+	for _, p := range strings.Split(paths, ",") {
+		before := len(analyzer.Names())
+		if _, err := plugin.Open(p); err != nil {
+			return fmt.Errorf("failed to load analyzer: %w", err)
+		}
+		if len(analyzer.Names()) == before {
+			return fmt.Errorf("analyzer %s didn't call analyzer.Register", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"testing"
+)
+
+func TestLoadAnalyzersErr(t *testing.T) {
+	t.Parallel()
+	if err := loadAnalyzers("does-not-exist.so"); err == nil {
+		t.Fatal("expected error")
+	}
+}
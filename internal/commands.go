@@ -0,0 +1,436 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maruel/panicparse/v2/internal/timestamp"
+	"github.com/maruel/panicparse/v2/pp"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+)
+
+// command is a pp subcommand, e.g. "pp serve".
+type command struct {
+	name string
+	// args is the synopsis of the arguments.
+	args string
+	help string
+	// argWords are the values accepted as arguments, for shell completion.
+	// Files are completed when empty.
+	argWords []string
+	// setup registers the flags of the command on fs and returns the function
+	// to run once fs is parsed.
+	setup func(fs *flag.FlagSet) func() error
+}
+
+// commands is the list of subcommands. Running pp without a command is the
+// same as "pp parse".
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:  "parse",
+			args:  "[flags] [file]",
+			help:  "Process the stack traces in file or stdin; the default",
+			setup: parseCommand,
+		},
+		{
+			name:  "serve",
+			args:  "[flags] [file]",
+			help:  "Serve the last stack trace in file or stdin as HTML; the file is parsed again on each request",
+			setup: serveCommand,
+		},
+		{
+			name:  "diff",
+			args:  "[flags] <old> <new>",
+			help:  "Print the goroutine buckets that appeared, disappeared or changed size between two dumps, and the goroutines that persisted",
+			setup: diffCommand,
+		},
+		{
+			name:  "attach",
+			args:  "[flags] <url>",
+			help:  "Fetch and process the goroutines of a live process from its net/http/pprof endpoint",
+			setup: attachCommand,
+		},
+		{
+			name:  "watch",
+			args:  "[flags] <command> [args...]",
+			help:  "Run the command and process its output; its goroutines are collected every -interval and, on POSIX, on SIGUSR1",
+			setup: watchCommand,
+		},
+		{
+			name:     "completion",
+			args:     "bash|zsh|fish",
+			help:     "Print the shell completion script",
+			argWords: []string{"bash", "zsh", "fish"},
+			setup:    completionCommand,
+		},
+		{
+			name:  "self-test",
+			help:  "Verify that traces of the Go toolchain in PATH can be parsed",
+			setup: selfTestCommand,
+		},
+	}
+}
+
+// findCommand returns the command named name or nil.
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// run parses args and runs the command.
+func (c *command) run(args []string) error {
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage of %s %s:\n", os.Args[0], c.name)
+		fmt.Fprintf(out, "  %s %s %s\n\n%s.\n", os.Args[0], c.name, c.args, c.help)
+		if c.hasFlags() {
+			fmt.Fprintf(out, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	run := c.setup(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return run()
+}
+
+// flags returns the flags of the command.
+func (c *command) flags() []*flag.Flag {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.setup(fs)
+	var out []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		out = append(out, f)
+	})
+	return out
+}
+
+func (c *command) hasFlags() bool {
+	return len(c.flags()) != 0
+}
+
+// writeCommands prints the list of commands for the usage.
+func writeCommands(out io.Writer) {
+	fmt.Fprintf(out, "Commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-11s %s\n", c.name, c.help)
+	}
+}
+
+// scanSnapshots returns all the snapshots found in r.
+//
+// The capture time of each snapshot is set from the last timestamp found in
+// the preceding lines, if any.
+func scanSnapshots(r io.Reader, opts *stack.Opts) ([]*stack.Snapshot, error) {
+	var out []*stack.Snapshot
+	tw := timestamp.NewWriter(io.Discard)
+	for {
+		s, suffix, err := stack.ScanSnapshot(r, tw, opts)
+		if s != nil {
+			if s.CapturedAt.IsZero() {
+				s.CapturedAt = tw.Last
+			}
+			out = append(out, s)
+		}
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		r = io.MultiReader(bytes.NewReader(suffix), r)
+	}
+}
+
+// lastSnapshot returns the last snapshot found in r.
+func lastSnapshot(r io.Reader, opts *stack.Opts) (*stack.Snapshot, error) {
+	all, err := scanSnapshots(r, opts)
+	if len(all) == 0 {
+		if err == nil {
+			err = errors.New("no stack trace found")
+		}
+		return nil, err
+	}
+	return all[len(all)-1], err
+}
+
+// similarity returns the similarity to use to aggregate the goroutines.
+func similarity(aggressive bool) stack.Similarity {
+	if aggressive {
+		return stack.AnyValue
+	}
+	return stack.AnyPointer
+}
+
+// serve
+
+func serveCommand(fs *flag.FlagSet) func() error {
+	addr := fs.String("http", "localhost:6060", "Address to listen to")
+	aggressive := fs.Bool("aggressive", false, "Aggressive deduplication including non pointers")
+	track := fs.Bool("track", false, "Annotate each bucket with how many goroutines existed in the previous snapshot of the input and how many are new")
+	return func() error {
+		var load func() (io.ReadCloser, time.Time, error)
+		switch fs.NArg() {
+		case 0:
+			b, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			load = func() (io.ReadCloser, time.Time, error) {
+				return io.NopCloser(bytes.NewReader(b)), now, nil
+			}
+		case 1:
+			name := fs.Arg(0)
+			load = func() (io.ReadCloser, time.Time, error) {
+				/* #nosec G304 */
+				f, err := os.Open(name)
+				if err != nil {
+					return nil, time.Time{}, err
+				}
+				var mtime time.Time
+				if fi, err := f.Stat(); err == nil {
+					mtime = fi.ModTime()
+				}
+				return f, mtime, nil
+			}
+		default:
+			return errors.New("serve accepts at most one file")
+		}
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Serving on http://%s/\n", ln.Addr())
+		/* #nosec G114 */
+		return http.Serve(ln, &snapshotServer{load: load, similarity: similarity(*aggressive), track: *track})
+	}
+}
+
+// snapshotServer serves the last snapshot found in the input as HTML.
+type snapshotServer struct {
+	// load returns the input and when it was last modified. The modification
+	// time is used as the capture time when the input has no timestamp.
+	load       func() (io.ReadCloser, time.Time, error)
+	similarity stack.Similarity
+	// track compares the last snapshot with the one before it, if any.
+	track bool
+}
+
+func (s *snapshotServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f, mtime, err := s.load()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	all, err := scanSnapshots(f, stack.DefaultOpts())
+	if len(all) == 0 {
+		if err == nil {
+			err = errors.New("no stack trace found")
+		}
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	c := all[len(all)-1]
+	if c.CapturedAt.IsZero() {
+		c.CapturedAt = mtime
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if c.IsRace() {
+		err = c.ToHTML(w, "")
+	} else {
+		a := c.Aggregate(s.similarity)
+		if s.track && len(all) > 1 && !all[len(all)-2].IsRace() {
+			a.Track(all[len(all)-2])
+		}
+		a.Analyze(nil)
+		err = a.ToHTML(w, "")
+	}
+	if err != nil {
+		log.Printf("failed to render: %v", err)
+	}
+}
+
+// diff
+
+func diffCommand(fs *flag.FlagSet) func() error {
+	aggressive := fs.Bool("aggressive", false, "Aggressive deduplication including non pointers")
+	persisted := fs.Bool("persisted", false, "Also print the buckets of the goroutines that are in both dumps with the same stack, e.g. leaked goroutines")
+	return func() error {
+		if fs.NArg() != 2 {
+			return errors.New("diff requires two files")
+		}
+		var c [2]*stack.Snapshot
+		var a [2]*stack.Aggregated
+		for i := range a {
+			/* #nosec G304 */
+			f, err := os.Open(fs.Arg(i))
+			if err != nil {
+				return err
+			}
+			opts := stack.DefaultOpts()
+			opts.AnalyzeSources = false
+			s, err := lastSnapshot(f, opts)
+			_ = f.Close()
+			if s == nil {
+				return fmt.Errorf("%s: %w", fs.Arg(i), err)
+			}
+			c[i] = s
+			a[i] = s.Aggregate(similarity(*aggressive))
+		}
+		if err := writeDiff(os.Stdout, a[0], a[1]); err != nil {
+			return err
+		}
+		return writeGoroutineDiff(os.Stdout, stack.Diff(c[0], c[1], similarity(*aggressive)), similarity(*aggressive), *persisted)
+	}
+}
+
+// writeDiff prints the buckets that appeared, disappeared or changed size
+// between prev and cur.
+//
+// Buckets are matched by their state and signature hash.
+func writeDiff(out io.Writer, prev, cur *stack.Aggregated) error {
+	before := map[string]*stack.Bucket{}
+	for _, b := range prev.Buckets {
+		before[diffKey(b)] = b
+	}
+	unchanged := 0
+	var lines []string
+	for _, b := range cur.Buckets {
+		h := diffKey(b)
+		o := before[h]
+		delete(before, h)
+		switch {
+		case o == nil:
+			lines = append(lines, fmt.Sprintf("+ %d %s", len(b.IDs), diffHeader(b)))
+		case len(o.IDs) != len(b.IDs):
+			lines = append(lines, fmt.Sprintf("~ %d -> %d %s", len(o.IDs), len(b.IDs), diffHeader(b)))
+		default:
+			unchanged++
+		}
+	}
+	var gone []string
+	for _, b := range prev.Buckets {
+		if _, ok := before[diffKey(b)]; ok {
+			gone = append(gone, fmt.Sprintf("- %d %s", len(b.IDs), diffHeader(b)))
+		}
+	}
+	sort.Strings(gone)
+	lines = append(lines, gone...)
+	lines = append(lines, fmt.Sprintf("%d unchanged buckets", unchanged))
+	_, err := io.WriteString(out, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// writeGoroutineDiff prints how many goroutines persisted, moved, appeared or
+// disappeared between the two dumps, matched by goroutine ID.
+//
+// When persisted is true, it also prints the buckets of the goroutines that
+// persisted.
+func writeGoroutineDiff(out io.Writer, d *stack.SnapshotDiff, s stack.Similarity, persisted bool) error {
+	lines := []string{fmt.Sprintf("goroutines: %d persisted, %d moved, %d appeared, %d disappeared", len(d.Persisted), len(d.Moved), len(d.Appeared), len(d.Disappeared))}
+	if persisted && len(d.Persisted) != 0 {
+		a := (&stack.Snapshot{Goroutines: d.Persisted}).Aggregate(s)
+		for _, b := range a.Buckets {
+			lines = append(lines, fmt.Sprintf("= %d %s", len(b.IDs), diffHeader(b)))
+		}
+	}
+	_, err := io.WriteString(out, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func diffKey(b *stack.Bucket) string {
+	return b.State + "\n" + b.Signature.Hash()
+}
+
+// diffHeader returns the state and the top call of the bucket.
+func diffHeader(b *stack.Bucket) string {
+	s := "[" + b.State + "]"
+	if len(b.Stack.Calls) != 0 {
+		c := &b.Stack.Calls[0]
+		s += fmt.Sprintf(" %s %s:%d", c.Func.Complete, c.SrcName, c.Line)
+	}
+	return s
+}
+
+// attach
+
+func attachCommand(fs *flag.FlagSet) func() error {
+	timeout := fs.Duration("timeout", time.Minute, "Maximum time to fetch the goroutines")
+	aggressive := fs.Bool("aggressive", false, "Aggressive deduplication including non pointers")
+	noColor := fs.Bool("no-color", !isatty.IsTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb", "Disable coloring")
+	return func() error {
+		if fs.NArg() != 1 {
+			return errors.New("attach requires an URL")
+		}
+		u := goroutineURL(fs.Arg(0))
+		c := http.Client{Timeout: *timeout}
+		resp, err := c.Get(u)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s returned %s", u, resp.Status)
+		}
+		var out io.Writer = os.Stdout
+		o := pp.DefaultRunOptions()
+		o.Aggressive = *aggressive
+		o.Color = !*noColor
+		if o.Color {
+			out = colorable.NewColorableStdout()
+		}
+		return pp.Run(o, resp.Body, out)
+	}
+}
+
+// goroutineURL returns the URL of the full goroutine dump for a net/http/pprof
+// endpoint. A host:port is accepted.
+func goroutineURL(s string) string {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	if u, err := url.Parse(s); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/debug/pprof/goroutine"
+		u.RawQuery = "debug=2"
+		return u.String()
+	}
+	return s
+}
+
+// self-test
+
+func selfTestCommand(fs *flag.FlagSet) func() error {
+	return func() error {
+		goBin, _ := exec.LookPath("go")
+		return selfTest(os.Stdout, goBin)
+	}
+}
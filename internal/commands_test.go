@@ -0,0 +1,180 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+const diffOld = "goroutine 1 [running]:\n" +
+	"main.main()\n" +
+	"\t/home/user/go/src/main.go:12 +0x20\n" +
+	"\n" +
+	"goroutine 6 [chan receive]:\n" +
+	"main.worker()\n" +
+	"\t/home/user/go/src/main.go:20 +0x40\n" +
+	"\n" +
+	"goroutine 7 [select]:\n" +
+	"main.other()\n" +
+	"\t/home/user/go/src/main.go:30 +0x40\n"
+
+const diffNew = "goroutine 1 [running]:\n" +
+	"main.main()\n" +
+	"\t/home/user/go/src/main.go:12 +0x20\n" +
+	"\n" +
+	"goroutine 6 [chan receive]:\n" +
+	"main.worker()\n" +
+	"\t/home/user/go/src/main.go:20 +0x40\n" +
+	"\n" +
+	"goroutine 8 [chan receive]:\n" +
+	"main.worker()\n" +
+	"\t/home/user/go/src/main.go:20 +0x40\n" +
+	"\n" +
+	"goroutine 9 [IO wait]:\n" +
+	"main.reader()\n" +
+	"\t/home/user/go/src/main.go:40 +0x40\n"
+
+func TestFindCommand(t *testing.T) {
+	t.Parallel()
+	for _, c := range commands {
+		if findCommand(c.name) != c {
+			t.Errorf("%s not found", c.name)
+		}
+	}
+	if findCommand("-v") != nil || findCommand("stack.txt") != nil {
+		t.Fatal("unexpected command")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	t.Parallel()
+	var a [2]*stack.Aggregated
+	for i, in := range []string{diffOld, diffNew} {
+		s, err := lastSnapshot(strings.NewReader(in), &stack.Opts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a[i] = s.Aggregate(stack.AnyPointer)
+	}
+	out := bytes.Buffer{}
+	if err := writeDiff(&out, a[0], a[1]); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"+ 1 [IO wait] main.reader main.go:40\n" +
+		"~ 1 -> 2 [chan receive] main.worker main.go:20\n" +
+		"- 1 [select] main.other main.go:30\n" +
+		"1 unchanged buckets\n"
+	compareString(t, want, out.String())
+}
+
+func TestWriteGoroutineDiff(t *testing.T) {
+	t.Parallel()
+	var c [2]*stack.Snapshot
+	for i, in := range []string{diffOld, diffNew} {
+		s, err := lastSnapshot(strings.NewReader(in), &stack.Opts{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c[i] = s
+	}
+	out := bytes.Buffer{}
+	if err := writeGoroutineDiff(&out, stack.Diff(c[0], c[1], stack.AnyPointer), stack.AnyPointer, true); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"goroutines: 2 persisted, 0 moved, 2 appeared, 1 disappeared\n" +
+		"= 1 [running] main.main main.go:12\n" +
+		"= 1 [chan receive] main.worker main.go:20\n"
+	compareString(t, want, out.String())
+}
+
+func TestSnapshotServer(t *testing.T) {
+	t.Parallel()
+	s := &snapshotServer{
+		load: func() (io.ReadCloser, time.Time, error) {
+			in := "junk\n\n" + diffOld + "exit status 2\n2026-01-02T03:04:05Z restarting\n" + diffNew
+			return io.NopCloser(strings.NewReader(in)), time.Time{}, nil
+		},
+		similarity: stack.AnyPointer,
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected %d: %s", w.Code, w.Body.String())
+	}
+	// Only the last snapshot is served.
+	if body := w.Body.String(); !strings.Contains(body, "reader") || strings.Contains(body, "other") {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+	// The capture time is the timestamp preceding the snapshot.
+	if body := w.Body.String(); !strings.Contains(body, fmt.Sprintf("data-ts=\"%d\"", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC).Unix())) {
+		t.Fatalf("missing capture time:\n%s", body)
+	}
+	if body := w.Body.String(); strings.Contains(body, "existed") {
+		t.Fatalf("unexpected tracking:\n%s", body)
+	}
+
+	// The last snapshot is compared with the previous one.
+	s.track = true
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, "existed") {
+		t.Fatalf("missing tracking:\n%s", body)
+	}
+
+	s.load = func() (io.ReadCloser, time.Time, error) {
+		return io.NopCloser(strings.NewReader("no trace\n")), time.Time{}, nil
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected %d", w.Code)
+	}
+}
+
+func TestGoroutineURL(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in, want string
+	}{
+		{"localhost:6060", "http://localhost:6060/debug/pprof/goroutine?debug=2"},
+		{"http://localhost:6060/", "http://localhost:6060/debug/pprof/goroutine?debug=2"},
+		{"https://host/custom?debug=2", "https://host/custom?debug=2"},
+	}
+	for i, line := range data {
+		if got := goroutineURL(line.in); got != line.want {
+			t.Errorf("#%d: want %q, got %q", i, line.want, got)
+		}
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	t.Parallel()
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out := bytes.Buffer{}
+		if err := writeCompletion(&out, shell, "pp"); err != nil {
+			t.Fatal(err)
+		}
+		s := out.String()
+		for _, w := range []string{"serve", "self-test", "aggressive", "wait-complete", "http", "bash zsh fish"} {
+			if !strings.Contains(s, w) {
+				t.Errorf("%s: missing %q in:\n%s", shell, w, s)
+			}
+		}
+	}
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh", "pp"); err == nil {
+		t.Fatal("expected error")
+	}
+}
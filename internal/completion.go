@@ -0,0 +1,121 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func completionCommand(fs *flag.FlagSet) func() error {
+	return func() error {
+		if fs.NArg() != 1 {
+			return errors.New("completion requires one of bash, zsh or fish")
+		}
+		name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+		return writeCompletion(os.Stdout, fs.Arg(0), name)
+	}
+}
+
+// writeCompletion writes the completion script for shell, for the executable
+// name.
+func writeCompletion(out io.Writer, shell, name string) error {
+	switch shell {
+	case "bash":
+		_, err := io.WriteString(out, bashCompletion(name))
+		return err
+	case "zsh":
+		_, err := fmt.Fprintf(out, "#compdef %s\n\nautoload -U +X bashcompinit && bashcompinit\n\n%s", name, bashCompletion(name))
+		return err
+	case "fish":
+		_, err := io.WriteString(out, fishCompletion(name))
+		return err
+	default:
+		return fmt.Errorf("unsupported shell %q; use one of bash, zsh or fish", shell)
+	}
+}
+
+func flagNames(flags []*flag.Flag) string {
+	var out []string
+	for _, f := range flags {
+		out = append(out, "-"+f.Name)
+	}
+	return strings.Join(out, " ")
+}
+
+func bashCompletion(name string) string {
+	fn := "_" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	var names []string
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "# bash completion for %s, generated by \"%s completion bash\".\n", name, name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" cmd=\"\" flags=\"\" words=\"\"\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -gt 1 ]; then\n\t\tcmd=\"${COMP_WORDS[1]}\"\n\tfi\n")
+	b.WriteString("\tcase \"$cmd\" in\n")
+	for _, c := range commands {
+		names = append(names, c.name)
+		if c.name == "parse" {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s)\n\t\tflags=\"%s\"\n\t\twords=\"%s\"\n\t\t;;\n", c.name, flagNames(c.flags()), strings.Join(c.argWords, " "))
+	}
+	fmt.Fprintf(&b, "\t*)\n\t\tflags=\"%s\"\n\t\t;;\n", flagNames(findCommand("parse").flags()))
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("\telif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -f -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString("\telif [ -n \"$words\" ]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("\telse\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o filenames -F %s %s\n", fn, name)
+	return b.String()
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func fishCompletion(name string) string {
+	var others []string
+	for _, c := range commands {
+		if c.name != "parse" {
+			others = append(others, c.name)
+		}
+	}
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "# fish completion for %s, generated by \"%s completion fish\".\n", name, name)
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", name, c.name, fishQuote(c.help))
+	}
+	for _, c := range commands {
+		// The flags of parse are also the ones of pp without a command.
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == "parse" {
+			cond = "not __fish_seen_subcommand_from " + strings.Join(others, " ")
+		}
+		for _, f := range c.flags() {
+			fmt.Fprintf(&b, "complete -c %s -n %s -o %s -d %s\n", name, fishQuote(cond), f.Name, fishQuote(f.Usage))
+		}
+		if len(c.argWords) != 0 {
+			fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s\n", name, fishQuote(cond), fishQuote(strings.Join(c.argWords, " ")))
+		}
+	}
+	return b.String()
+}
@@ -101,6 +101,44 @@ func PanicOutputs() map[string][]byte {
 	return out
 }
 
+// PanicTracebackOutputs returns the output of the panic subcommand name for
+// each GOTRACEBACK value, as printed by "panic -all-traceback", keyed by the
+// GOTRACEBACK value.
+//
+// panic is built with inlining disabled.
+//
+// The function panics if any internal error occurs.
+func PanicTracebackOutputs(name string) map[string][]byte {
+	p := build("panic", false)
+	if p == "" {
+		panic("building panic failed")
+	}
+	defer func() {
+		if err := os.Remove(p); err != nil {
+			panic(err)
+		}
+	}()
+	const sectionPrefix = "===== GOTRACEBACK="
+	const exitPrefix = "===== exit code "
+	out := map[string][]byte{}
+	level := ""
+	for _, l := range strings.SplitAfter(string(execRun(p, "-all-traceback", name)), "\n") {
+		switch {
+		case strings.HasPrefix(l, sectionPrefix):
+			level = strings.TrimSpace(l[len(sectionPrefix):])
+			out[level] = []byte{}
+		case strings.HasPrefix(l, exitPrefix):
+			level = ""
+		case level != "":
+			out[level] = append(out[level], l...)
+		}
+	}
+	if len(out) == 0 {
+		panic(fmt.Sprintf("no output for -all-traceback %s", name))
+	}
+	return out
+}
+
 // StaticPanicwebOutput returns a constant version of panicweb output for use
 // in benchmarks.
 func StaticPanicwebOutput() []byte {
@@ -2,18 +2,7 @@
 // Use of this source code is governed under the Apache License, Version 2.0
 // that can be found in the LICENSE file.
 
-// Package internal implements panicparse
-//
-// It is mostly useful on servers will large number of identical goroutines,
-// making the crash dump harder to read than strictly necessary.
-//
-// Colors:
-//   - Magenta: first goroutine to be listed.
-//   - Yellow: main package.
-//   - Green: standard library.
-//   - Red: other packages.
-//
-// Bright colors are used for exported symbols.
+// Package internal implements the pp command line tool on top of package pp.
 package internal
 
 import (
@@ -21,294 +10,356 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"html/template"
 	"io"
 	"log"
 	"os"
 	"os/signal"
-	"regexp"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/maruel/panicparse/v2/internal/timestamp"
+	"github.com/maruel/panicparse/v2/pp"
 	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/coredump"
+	"github.com/maruel/panicparse/v2/stack/render"
 	"github.com/mattn/go-colorable"
 	"github.com/mattn/go-isatty"
-	"github.com/mgutz/ansi"
 )
 
-// resetFG is similar to ansi.Reset except that it doesn't reset the
-// background color, only the foreground color and the style.
-//
-// That much for the "ansi" abstraction layer...
-const resetFG = ansi.DefaultFG + "\033[m"
-
-// defaultPalette is the default recommended palette.
-var defaultPalette = Palette{
-	EOLReset:                    resetFG,
-	RoutineFirst:                ansi.ColorCode("magenta+b"),
-	CreatedBy:                   ansi.LightBlack,
-	Race:                        ansi.LightRed,
-	Package:                     ansi.ColorCode("default+b"),
-	SrcFile:                     resetFG,
-	FuncMain:                    ansi.ColorCode("yellow+b"),
-	FuncLocationUnknown:         ansi.White,
-	FuncLocationUnknownExported: ansi.ColorCode("white+b"),
-	FuncGoMod:                   ansi.Red,
-	FuncGoModExported:           ansi.ColorCode("red+b"),
-	FuncGOPATH:                  ansi.Cyan,
-	FuncGOPATHExported:          ansi.ColorCode("cyan+b"),
-	FuncGoPkg:                   ansi.Blue,
-	FuncGoPkgExported:           ansi.ColorCode("blue+b"),
-	FuncStdLib:                  ansi.Green,
-	FuncStdLibExported:          ansi.ColorCode("green+b"),
-	Arguments:                   resetFG,
-}
-
-func writeBucketsToConsole(out io.Writer, p *Palette, a *stack.Aggregated, pf pathFormat, needsEnv bool, filter, match *regexp.Regexp) error {
-	if needsEnv {
-		_, _ = io.WriteString(out, "\nTo see all goroutines, visit https://github.com/maruel/panicparse#gotraceback\n\n")
-	}
-	srcLen, pkgLen := calcBucketsLengths(a, pf)
-	multi := len(a.Buckets) > 1
-	for _, e := range a.Buckets {
-		header := p.BucketHeader(e, pf, multi)
-		if filter != nil && filter.MatchString(header) {
-			continue
-		}
-		if match != nil && !match.MatchString(header) {
-			continue
-		}
-		_, _ = io.WriteString(out, header)
-		_, _ = io.WriteString(out, p.StackLines(&e.Signature, srcLen, pkgLen, pf))
-	}
-	return nil
-}
-
-func writeGoroutinesToConsole(out io.Writer, p *Palette, s *stack.Snapshot, pf pathFormat, needsEnv bool, filter, match *regexp.Regexp) error {
-	if needsEnv {
-		_, _ = io.WriteString(out, "\nTo see all goroutines, visit https://github.com/maruel/panicparse#gotraceback\n\n")
-	}
-	srcLen, pkgLen := calcGoroutinesLengths(s, pf)
-	multi := len(s.Goroutines) > 1
-	for _, e := range s.Goroutines {
-		header := p.GoroutineHeader(e, pf, multi)
-		if filter != nil && filter.MatchString(header) {
-			continue
-		}
-		if match != nil && !match.MatchString(header) {
-			continue
-		}
-		_, _ = io.WriteString(out, header)
-		_, _ = io.WriteString(out, p.StackLines(&e.Signature, srcLen, pkgLen, pf))
-	}
-	return nil
-}
+// shareTokenEnv is the environment variable with the token to authenticate to
+// the -share endpoint. It is not a flag so it doesn't leak in the process
+// list.
+const shareTokenEnv = "PANICPARSE_SHARE_TOKEN"
 
-type toHTMLer interface {
-	ToHTML(io.Writer, template.HTML) error
-}
+// ErrPanicFound is returned by Main when -only-first is used and a stack
+// trace was found.
+var ErrPanicFound = pp.ErrPanicFound
 
-func toHTML(h toHTMLer, p string, needsEnv bool) error {
-	/* #nosec G304 */
-	f, err := os.Create(p)
-	if err != nil {
-		return err
-	}
-	var footer template.HTML
-	if needsEnv {
-		footer = "To see all goroutines, visit <a href=https://github.com/maruel/panicparse#gotraceback>github.com/maruel/panicparse</a>"
-	}
-	err = h.ToHTML(f, footer)
-	if err2 := f.Close(); err == nil {
-		err = err2
-	}
-	return err
-}
-
-func processInner(out io.Writer, p *Palette, s stack.Similarity, pf pathFormat, html string, filter, match *regexp.Regexp, c *stack.Snapshot, first bool) error {
-	log.Printf("GOROOT=%s", c.RemoteGOROOT)
-	log.Printf("GOPATH=%s", c.RemoteGOPATHs)
-	needsEnv := len(c.Goroutines) == 1 && showBanner()
-	// Bucketing should only be done if no data race was detected.
-	if !c.IsRace() {
-		a := c.Aggregate(s)
-		if html == "" {
-			return writeBucketsToConsole(out, p, a, pf, needsEnv, filter, match)
+// Main is implemented here so both 'pp' and 'panicparse' executables can be
+// compiled. This is to work around the Perl Package manager 'pp' that is
+// preinstalled on some OSes.
+func Main() error {
+	if len(os.Args) > 1 {
+		if c := findCommand(os.Args[1]); c != nil {
+			return c.run(os.Args[2:])
 		}
-		return toHTML(a, html, needsEnv)
 	}
-	// It's a data race.
-	if html == "" {
-		return writeGoroutinesToConsole(out, p, c, pf, needsEnv, filter, match)
-	}
-	return toHTML(c, html, needsEnv)
+	run := parseCommand(flag.CommandLine)
+	flag.Parse()
+	return run()
 }
 
-// process copies stdin to stdout and processes any "panic: " line found.
+// parseCommand registers the flags to process stack traces on fs and returns
+// the function to run once fs is parsed.
 //
-// If html is used, a stack trace is written to this file instead.
-func process(in io.Reader, out io.Writer, p *Palette, s stack.Similarity, pf pathFormat, parse, rebase bool, html string, filter, match *regexp.Regexp) error {
-	opts := stack.DefaultOpts()
-	if !rebase {
-		opts.GuessPaths = false
-		opts.AnalyzeSources = false
-	}
-	if !parse {
-		opts.AnalyzeSources = false
-	}
-	for first := true; ; first = false {
-		c, suffix, err := stack.ScanSnapshot(in, out, opts)
-		if c != nil {
-			// Process it even if an error occurred.
-			if err1 := processInner(out, p, s, pf, html, filter, match, c, first); err == nil {
-				err = err1
-			}
-		}
-		if err == nil {
-			// This means the whole buffer was not read, loop again.
-			in = io.MultiReader(bytes.NewReader(suffix), in)
-			continue
-		}
-		if len(suffix) != 0 {
-			if _, err1 := out.Write(suffix); err == nil {
-				err = err1
-			}
-		}
-		if err == io.EOF {
-			return nil
-		}
-		// Parts of the input will be lost.
-		return err
-	}
-}
-
-func showBanner() bool {
-	gtb := os.Getenv("GOTRACEBACK")
-	return gtb == "" || gtb == "single"
-}
-
-// Main is implemented here so both 'pp' and 'panicparse' executables can be
-// compiled. This is to work around the Perl Package manager 'pp' that is
-// preinstalled on some OSes.
-func Main() error {
-	aggressive := flag.Bool("aggressive", false, "Aggressive deduplication including non pointers")
-	parse := flag.Bool("parse", true, "Parses source files to deduct types; use -parse=false to work around bugs in source parser")
-	rebase := flag.Bool("rebase", true, "Guess GOROOT and GOPATH")
-	verboseFlag := flag.Bool("v", false, "Enables verbose logging output")
-	filterFlag := flag.String("f", "", "Regexp to filter out headers that match, ex: -f 'IO wait|syscall'")
-	matchFlag := flag.String("m", "", "Regexp to filter by only headers that match, ex: -m 'semacquire'")
+// It is the default command, used when pp is run without a command.
+func parseCommand(fs *flag.FlagSet) func() error {
+	aggressive := fs.Bool("aggressive", false, "Aggressive deduplication including non pointers")
+	parse := fs.Bool("parse", true, "Parses source files to deduct types; use -parse=false to work around bugs in source parser")
+	rebase := fs.Bool("rebase", true, "Guess GOROOT and GOPATH")
+	verboseFlag := fs.Bool("v", false, "Enables verbose logging output")
+	filterFlag := fs.String("f", "", "Regexp to filter out headers that match, ex: -f 'IO wait|syscall'")
+	matchFlag := fs.String("m", "", "Regexp to filter by only headers that match, ex: -m 'semacquire'")
+	grepFlag := fs.String("grep", "", "Regexp to only print the calls with a matching function name or source location, with one call of context, ex: -grep 'sql\\.'")
+	mLabelFlag := fs.String("m-label", "", "Only show goroutines with these pprof labels, ex: -m-label team=payments,tier=1; requires a goroutine profile (debug=1)")
+	mIDFlag := fs.Int("m-id", -1, "Only show goroutines running on this OS thread (m) id; requires GOTRACEBACK=system or higher")
 	// Console only.
-	fullPathArg := flag.Bool("full-path", false, "Print full sources path")
-	relPathArg := flag.Bool("rel-path", false, "Print sources path relative to GOROOT or GOPATH; implies -rebase")
-	noColor := flag.Bool("no-color", !isatty.IsTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb", "Disable coloring")
-	forceColor := flag.Bool("force-color", false, "Forcibly enable coloring when with stdout is redirected")
+	console := fs.Bool("console", false, "Also print to the console when writing the snapshots to files with -html, -json, -dot, -sarif or -otlp")
+	fullPathArg := fs.Bool("full-path", false, "Print full sources path")
+	relPathArg := fs.Bool("rel-path", false, "Print sources path relative to GOROOT or GOPATH; implies -rebase")
+	noColor := fs.Bool("no-color", !isatty.IsTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb", "Disable coloring")
+	forceColor := fs.Bool("force-color", false, "Forcibly enable coloring when with stdout is redirected")
+	themeFlag := fs.String("theme", "default", "Color theme; one of "+strings.Join(pp.ThemeNames(), ", "))
+	styleFlag := fs.String("style", "", "Override the style of elements, ex: -style 'FuncMain=yellow+bu,Race=+i'; attributes are b for bold, u for underline, i for inverse")
+	onlyFirst := fs.Bool("only-first", false, "Stop after the first stack trace and exit with code 2, like a Go panic, instead of passing through the rest of the input")
+	annotateDefer := fs.Bool("defer", false, "Annotate calls executed as part of a deferred function, e.g. in recover/re-panic patterns or after runtime.Goexit")
+	verboseHeaders := fs.Bool("verbose-headers", false, "Print the raw runtime values of the goroutine headers, like gp, m and mp; requires GOTRACEBACK=system or higher")
+	noStdlibArgs := fs.Bool("no-stdlib-args", false, "Do not print the arguments of calls in the standard library")
+	argsDepth := fs.Int("args-depth", 0, "Maximum nesting level of struct arguments printed; deeper ones are elided, 0 means no limit")
+	argsElements := fs.Int("args-elements", 0, "Maximum number of fields printed per struct argument; the rest are elided, 0 means no limit")
+	markUncertain := fs.Bool("mark-uncertain", false, "Append ? to the arguments rebuilt from the sources whose value is uncertain, e.g. passed in a register")
+	minCount := fs.Int("min-count", 0, "Only show the buckets with at least this number of goroutines, the others are collapsed into a single \"other\" bucket")
+	analyzers := fs.String("analyzer", "", "Comma separated Go plugins to load that register custom analyzers, ex: -analyzer ./custom.so; see package stack/analyzer")
+	blameFlag := fs.Bool("blame", false, "Annotate the call that panicked with the last git commit that modified the line; requires the sources locally")
+	blameAll := fs.Bool("blame-all", false, "Like -blame but annotate all the calls outside the standard library")
+	binary := fs.String("binary", "", "Executable that generated the stack trace, to expand the inlined calls with its DWARF line table and resolve the frames without symbol; must not be stripped")
+	goVersion := fs.String("go-version", "", "Go version that produced the stack trace, ex: -go-version go1.22.3; when it differs from the local one, the matching standard library sources are looked up in the toolchains installed by golang.org/dl or cached by the go command, see -goroot-mirror")
+	gorootMirror := fs.String("goroot-mirror", "", "URL to download the Go source archives from when the standard library sources matching -go-version, or the dialect of the stack trace, are not found locally, ex: -goroot-mirror https://dl.google.com/go; they are cached in the user cache directory")
+	showM := fs.Bool("show-m", false, "Print the OS thread (m) ids in the headers; requires GOTRACEBACK=system or higher")
+	createdBy := fs.Bool("created-by", false, "Only print the unique sites that created the goroutines, sorted by the number of goroutines they created, regardless of their current stack")
+	threads := fs.Bool("threads", false, "Only print the goroutines grouped by OS thread (m), the threads pinned by a goroutine locked with runtime.LockOSThread first, to debug thread exhaustion; requires GOTRACEBACK=system or higher")
+	tree := fs.Bool("tree", false, "Print the buckets as a tree of which goroutine created which instead of a flat list; requires go1.21+ traces")
+	packages := fs.Bool("packages", false, "Only print the packages found in the stacks, with how many goroutines and calls are in each, as an inventory of the subsystems active or blocked")
+	showTotals := fs.Bool("show-totals", false, "Prefix each header with its index and print how many goroutines and buckets were shown out of the total, for context when -f, -m, -m-id or -m-label hide some")
+	keepDuplicates := fs.Bool("keep-duplicates", false, "Process a snapshot identical to the previous one instead of skipping it; duplicates are normally caused by log pipelines delivering the same lines twice")
+	deterministic := fs.Bool("deterministic", false, "Zero the pointer values and renumber the goroutines in a stable order, so two runs that panic the same way produce byte-identical output, e.g. to diff CI logs")
+	track := fs.Bool("track", false, "When the input has successive snapshots of the same process, annotate each bucket with how many goroutines existed in the previous snapshot and how many are new")
 	// HTML only.
-	html := flag.String("html", "", "Output an HTML file")
+	html := fs.String("html", "", "Output an HTML file; the console output is skipped unless -console is also specified")
+	htmlTree := fs.Bool("html-tree", false, "With -html, organize goroutines as a tree of which goroutine created which; requires go1.21+ traces")
+	htmlModules := fs.Bool("html-modules", false, "With -html, show the module version of each call and whether it is a direct or indirect dependency, as found in the local go.mod files")
+	// JSON only.
+	jsonFlag := fs.String("json", "", "Output a JSON file of the buckets; the console output is skipped unless -console is also specified")
+	// GraphViz only.
+	dot := fs.String("dot", "", "Output a GraphViz dot file of the created-by and wait-for relationships between buckets")
+	// Crash reports only.
+	sarif := fs.String("sarif", "", "Output the goroutine that panicked, or the ones in a data race, as a SARIF log file for code scanning UIs; the paths are relative to the current directory")
+	otlp := fs.String("otlp", "", "Output the goroutine that panicked, or the ones in a data race, as an OpenTelemetry log record file in the OTLP/JSON encoding, to POST to a collector's /v1/logs endpoint")
+	// SIEM only.
+	siem := fs.String("siem", "", "Output one SIEM event per panic instead of the stack traces; one of cef or leef")
+	siemHost := fs.String("siem-host", "", "Host name to report in SIEM events")
+	// NDJSON only.
+	ndjson := fs.Bool("ndjson", false, "Output one JSON object per line for each frame of each goroutine, including its module path and version, for ingestion in analytics databases; the rest of the input is discarded")
+	// Share only.
+	share := fs.String("share", "", "Upload each snapshot, anonymized, as JSON and HTML with HTTP PUT requests to this URL and print the resulting URLs; the token in $"+shareTokenEnv+", if set, is sent as a bearer token")
+	// Input.
+	sinceFlag := fs.String("since", "", "Skip input up to the first line with a timestamp at or after this time, ex: -since 2025-03-01T20:00:00")
+	waitCompleteFlag := fs.Duration("wait-complete", 0, "Wait up to this long for the input file to be completely written, e.g. by a crash handler, before processing it, ex: -wait-complete 10s")
+	teeRaw := fs.String("tee-raw", "", "Append the input, as read, to this file, so the original stack traces are archived along the processed output, ex: -tee-raw raw.log")
+	teeRawMaxSizeFlag := fs.String("tee-raw-max-size", "", "With -tee-raw, rotate the file when it grows larger than this, keeping the previous ones with a .1 to .3 suffix, ex: -tee-raw-max-size 100M")
+	eventsFD := fs.Int("events-fd", 0, "Write the lifecycle events (scanning-started, snapshot-parsed, snapshot-rendered, passthrough-resumed, error) as one JSON object per line to this file descriptor, for programs running pp in a pipe, ex: -events-fd 3, or 2 for stderr")
+	maxMemoryFlag := fs.String("max-memory", "", "Keep at most this much parsed goroutines in memory and spill the rest to a temporary file, to process dumps larger than the RAM, ex: -max-memory 1G; the rest of the input is discarded")
+	tailBytesFlag := fs.String("tail-bytes", "", "Only process the last bytes of the input file, ex: -tail-bytes 50M")
+	coreFlag := fs.String("core", "", "Extract the goroutines from this core file instead of reading a stack trace; requires -binary; only linux/amd64 is supported")
+	// Watchdog only.
+	watchdogFlag := fs.Duration("watchdog", 0, "When reading from stdin, capture a goroutine dump if the piped program produces no output for this long, ex: -watchdog 5m")
+	watchdogURL := fs.String("watchdog-url", "", "With -watchdog, fetch the goroutine dump from this pprof URL instead of sending SIGQUIT to the piped program, ex: -watchdog-url http://localhost:6060/debug/pprof/goroutine?debug=2")
 
 	var out io.Writer = os.Stdout
-	p := &defaultPalette
+	p := pp.Theme("default")
 
-	flag.CommandLine.Usage = func() {
+	fs.Usage = func() {
 		out = os.Stderr
 		if *noColor && !*forceColor {
-			p = &Palette{}
+			p = &render.Palette{}
 		} else {
 			out = colorable.NewColorableStderr()
 		}
 		fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
-		flag.CommandLine.SetOutput(out)
-		flag.CommandLine.PrintDefaults()
+		fmt.Fprintf(out, "  %s [flags] [file]\n", os.Args[0])
+		fmt.Fprintf(out, "  %s <command> [flags] [args]\n\n", os.Args[0])
+		writeCommands(out)
+		fmt.Fprintf(out, "\nFlags of %s and %s parse:\n", os.Args[0], os.Args[0])
+		fs.SetOutput(out)
+		fs.PrintDefaults()
 		fmt.Fprintf(out, "\nLegend:\n")
 		fmt.Fprintf(out, "  Type             Exported    Private\n")
 		fmt.Fprintf(out, "  main             %smain.Foo()%s  %smain.foo()%s\n",
-			p.funcColor(stack.LocationUnknown, true, false), p.EOLReset,
-			p.funcColor(stack.LocationUnknown, true, true), p.EOLReset)
+			p.FuncColor(stack.LocationUnknown, true, false), p.EOLReset,
+			p.FuncColor(stack.LocationUnknown, true, true), p.EOLReset)
 		fmt.Fprintf(out, "  <unknown>        %spkg.Foo()%s   %spkg.foo()%s\n",
-			p.funcColor(stack.LocationUnknown, false, false), p.EOLReset,
-			p.funcColor(stack.LocationUnknown, false, true), p.EOLReset)
+			p.FuncColor(stack.LocationUnknown, false, false), p.EOLReset,
+			p.FuncColor(stack.LocationUnknown, false, true), p.EOLReset)
 		fmt.Fprintf(out, "  go.mod           %spkg.Foo()%s   %spkg.foo()%s\n",
-			p.funcColor(stack.GoMod, false, false), p.EOLReset,
-			p.funcColor(stack.GoMod, false, true), p.EOLReset)
+			p.FuncColor(stack.GoMod, false, false), p.EOLReset,
+			p.FuncColor(stack.GoMod, false, true), p.EOLReset)
 		fmt.Fprintf(out, "  $GOPATH/src      %spkg.Foo()%s   %spkg.foo()%s\n",
-			p.funcColor(stack.GOPATH, false, false), p.EOLReset,
-			p.funcColor(stack.GOPATH, false, true), p.EOLReset)
+			p.FuncColor(stack.GOPATH, false, false), p.EOLReset,
+			p.FuncColor(stack.GOPATH, false, true), p.EOLReset)
 		fmt.Fprintf(out, "  $GOPATH/pkg/mod  %spkg.Foo()%s   %spkg.foo()%s\n",
-			p.funcColor(stack.GoPkg, false, false), p.EOLReset,
-			p.funcColor(stack.GoPkg, false, true), p.EOLReset)
+			p.FuncColor(stack.GoPkg, false, false), p.EOLReset,
+			p.FuncColor(stack.GoPkg, false, true), p.EOLReset)
 		fmt.Fprintf(out, "  $GOROOT/src      %spkg.Foo()%s   %spkg.Foo()%s\n",
-			p.funcColor(stack.Stdlib, false, false), p.EOLReset,
-			p.funcColor(stack.Stdlib, false, true), p.EOLReset)
+			p.FuncColor(stack.Stdlib, false, false), p.EOLReset,
+			p.FuncColor(stack.Stdlib, false, true), p.EOLReset)
+		fmt.Fprintf(out, "  Panicking        %smain.foo()%s  %scalls above panic()%s\n",
+			p.FuncPanicking, p.EOLReset, p.FuncAbovePanic, p.EOLReset)
 	}
-	flag.Parse()
 
-	log.SetFlags(log.Lmicroseconds)
-	if !*verboseFlag {
-		log.SetOutput(io.Discard)
-	}
+	return func() error {
 
-	var err error
-	var filter *regexp.Regexp
-	if *filterFlag != "" {
-		if filter, err = regexp.Compile(*filterFlag); err != nil {
-			return err
+		if *analyzers != "" {
+			if err := loadAnalyzers(*analyzers); err != nil {
+				return err
+			}
 		}
-	}
 
-	var match *regexp.Regexp
-	if *matchFlag != "" {
-		if match, err = regexp.Compile(*matchFlag); err != nil {
-			return err
+		log.SetFlags(log.Lmicroseconds)
+		if !*verboseFlag {
+			log.SetOutput(io.Discard)
 		}
-	}
 
-	s := stack.AnyPointer
-	if *aggressive {
-		s = stack.AnyValue
-	}
-
-	if *html == "" {
-		if *noColor && !*forceColor {
-			p = &Palette{}
-		} else {
+		r := pp.RunOptions{
+			Aggressive:     *aggressive,
+			Parse:          *parse,
+			Rebase:         *rebase,
+			Filter:         *filterFlag,
+			Match:          *matchFlag,
+			Grep:           *grepFlag,
+			MLabel:         *mLabelFlag,
+			MID:            *mIDFlag,
+			FullPath:       *fullPathArg,
+			RelPath:        *relPathArg,
+			Color:          !*noColor || *forceColor,
+			Theme:          *themeFlag,
+			Style:          *styleFlag,
+			OnlyFirst:      *onlyFirst,
+			Defer:          *annotateDefer,
+			VerboseHeaders: *verboseHeaders,
+			NoStdlibArgs:   *noStdlibArgs,
+			ArgsDepth:      *argsDepth,
+			ArgsElements:   *argsElements,
+			MarkUncertain:  *markUncertain,
+			MinCount:       *minCount,
+			Blame:          *blameFlag,
+			BlameAll:       *blameAll,
+			Binary:         *binary,
+			GoVersion:      *goVersion,
+			GOROOTMirror:   *gorootMirror,
+			ShowM:          *showM,
+			Track:          *track,
+			ShowTotals:     *showTotals,
+			KeepDuplicates: *keepDuplicates,
+			Deterministic:  *deterministic,
+			CreatedBy:      *createdBy,
+			Packages:       *packages,
+			Tree:           *tree,
+			Threads:        *threads,
+			HTML:           *html,
+			HTMLTree:       *htmlTree,
+			HTMLModules:    *htmlModules,
+			JSON:           *jsonFlag,
+			Dot:            *dot,
+			SARIF:          *sarif,
+			OTLP:           *otlp,
+			Console:        *console,
+			SIEM:           *siem,
+			SIEMHost:       *siemHost,
+			NDJSON:         *ndjson,
+			Share:          *share,
+			ShareToken:     os.Getenv(shareTokenEnv),
+			TeeRaw:         *teeRaw,
+		}
+		if *teeRawMaxSizeFlag != "" {
+			var err error
+			if r.TeeRawMaxSize, err = parseSize(*teeRawMaxSizeFlag); err != nil {
+				return err
+			}
+		}
+		if *eventsFD != 0 {
+			f := os.NewFile(uintptr(*eventsFD), "events")
+			if f == nil {
+				return fmt.Errorf("invalid -events-fd value %d", *eventsFD)
+			}
+			r.Events = f
+		}
+		if *maxMemoryFlag != "" {
+			var err error
+			if r.MaxMemory, err = parseSize(*maxMemoryFlag); err != nil {
+				return err
+			}
+		}
+		// Validate the options before reading the input.
+		err := r.Validate()
+		if err != nil {
+			return err
+		}
+		if r.Color && (r.Console || (r.HTML == "" && r.JSON == "" && r.Dot == "")) {
 			out = colorable.NewColorableStdout()
 		}
-	}
 
-	var in *os.File
-	switch flag.NArg() {
-	case 0:
-		in = os.Stdin
-		// Explicitly silence SIGQUIT, as it is useful to gather the stack dump
-		// from the piped command.
-		signals := make(chan os.Signal, 1)
-		go func() {
-			for {
-				<-signals
+		var since time.Time
+		if *sinceFlag != "" {
+			var ok bool
+			if since, ok = timestamp.Parse([]byte(*sinceFlag)); !ok {
+				return fmt.Errorf("invalid -since value %q", *sinceFlag)
 			}
-		}()
-		signal.Notify(signals, os.Interrupt, syscall.SIGQUIT)
+		}
+		var tailBytes int64
+		if *tailBytesFlag != "" {
+			if tailBytes, err = parseSize(*tailBytesFlag); err != nil {
+				return err
+			}
+		}
 
-	case 1:
-		// Do not handle SIGQUIT when passed a file to process.
-		name := flag.Arg(0)
-		/* #nosec G304 */
-		if in, err = os.Open(name); err != nil {
-			return fmt.Errorf("did you mean to specify a valid stack dump file name? %w", err)
+		if *coreFlag != "" {
+			if fs.NArg() != 0 {
+				return errors.New("-core cannot be used with an input file")
+			}
+			if *binary == "" {
+				return errors.New("-core requires -binary")
+			}
+			b := bytes.Buffer{}
+			if err = coredump.Write(&b, *coreFlag, *binary); err != nil {
+				return err
+			}
+			return pp.Run(&r, &b, out)
 		}
-		/* #nosec G307 */
-		defer in.Close()
 
-	default:
-		return errors.New("pipe from stdin or specify a single file")
-	}
-	pf := basePath
-	if *fullPathArg {
-		if *relPathArg {
-			return errors.New("can't use both -full-path and -rel-path")
+		var in io.Reader
+		switch fs.NArg() {
+		case 0:
+			if tailBytes != 0 {
+				return errors.New("-tail-bytes requires a file")
+			}
+			if *waitCompleteFlag > 0 {
+				return errors.New("-wait-complete requires a file")
+			}
+			// Explicitly silence SIGQUIT, as it is useful to gather the stack dump
+			// from the piped command. It must be done before reading stdin, since
+			// the signal can be sent while skipping to -since.
+			signals := make(chan os.Signal, 1)
+			go func() {
+				for {
+					<-signals
+				}
+			}()
+			signal.Notify(signals, os.Interrupt, syscall.SIGQUIT)
+			in = os.Stdin
+			r.Follow = true
+			if !since.IsZero() {
+				if in, err = skipUntil(in, since); err != nil {
+					return err
+				}
+			}
+			if *watchdogFlag > 0 {
+				capture := captureSIGQUIT(*watchdogFlag)
+				if *watchdogURL != "" {
+					capture = captureURL(*watchdogURL, *watchdogFlag)
+				}
+				in = newWatchdog(in, *watchdogFlag, capture)
+			}
+
+		case 1:
+			if *watchdogFlag > 0 {
+				return errors.New("-watchdog requires reading from stdin")
+			}
+			// Do not handle SIGQUIT when passed a file to process.
+			name := fs.Arg(0)
+			if *waitCompleteFlag > 0 {
+				if _, err = waitComplete(name, *waitCompleteFlag, waitPoll); err != nil {
+					return fmt.Errorf("did you mean to specify a valid stack dump file name? %w", err)
+				}
+			}
+			/* #nosec G304 */
+			f, err := os.Open(name)
+			if err != nil {
+				return fmt.Errorf("did you mean to specify a valid stack dump file name? %w", err)
+			}
+			/* #nosec G307 */
+			defer f.Close()
+			in = f
+			if tailBytes != 0 {
+				if err = seekTail(f, tailBytes); err != nil {
+					return err
+				}
+			}
+			if !since.IsZero() {
+				if tailBytes != 0 {
+					// Only look in the tail.
+					in, err = skipUntil(f, since)
+				} else {
+					in, err = seekSince(f, since)
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+		default:
+			return errors.New("pipe from stdin or specify a single file")
 		}
-		pf = fullPath
-	} else if *relPathArg {
-		pf = relPath
-		*rebase = true
+		return pp.Run(&r, in, out)
 	}
-	return process(in, out, p, s, pf, *parse, *rebase, *html, filter, match)
 }
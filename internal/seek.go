@@ -0,0 +1,157 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/maruel/panicparse/v2/internal/timestamp"
+)
+
+// parseSize parses a size in bytes with an optional K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	if l := len(s); l != 0 {
+		switch s[l-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		}
+		if mult != 1 {
+			s = s[:l-1]
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return v * mult, nil
+}
+
+// seekTail seeks f so that only the last n bytes are read.
+//
+// The position is moved forward to the next line boundary so the first line
+// read is never a partial line.
+func seekTail(f io.ReadSeeker, n int64) error {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if n >= size {
+		_, err = f.Seek(0, io.SeekStart)
+		return err
+	}
+	return seekLine(f, size-n)
+}
+
+// seekLine seeks f to the start of the first line at or after offset.
+func seekLine(f io.ReadSeeker, offset int64) error {
+	if offset == 0 {
+		_, err := f.Seek(0, io.SeekStart)
+		return err
+	}
+	// Look at the previous byte to know if offset is already at a line start.
+	if _, err := f.Seek(offset-1, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	skipped, err := r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	_, err = f.Seek(offset-1+int64(len(skipped)), io.SeekStart)
+	return err
+}
+
+// errNoTimestamp is returned when no line with a timestamp was found.
+var errNoTimestamp = errors.New("no timestamp found")
+
+// nextTimestamp returns the timestamp of the first timestamped line starting
+// at or after offset.
+//
+// It stops after reading limit bytes.
+func nextTimestamp(f io.ReadSeeker, offset, limit int64) (time.Time, error) {
+	if err := seekLine(f, offset); err != nil {
+		return time.Time{}, err
+	}
+	r := bufio.NewReader(io.LimitReader(f, limit))
+	for {
+		line, err := r.ReadSlice('\n')
+		if t, ok := timestamp.Parse(line); ok {
+			return t, nil
+		}
+		if err == bufio.ErrBufferFull {
+			// Skip the rest of the overly long line.
+			for err == bufio.ErrBufferFull {
+				_, err = r.ReadSlice('\n')
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = errNoTimestamp
+			}
+			return time.Time{}, err
+		}
+	}
+}
+
+// seekSince seeks f to the first line with a timestamp at or after since.
+//
+// It does a binary search over the file so it is fast even on multi-GB files,
+// assuming timestamps are monotonic. Lines without timestamp, like a stack
+// dump, are considered to be part of the preceding timestamped line.
+func seekSince(f io.ReadSeeker, since time.Time) (io.Reader, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	const window = 64 * 1024
+	lo, hi := int64(0), size
+	for hi-lo > window {
+		mid := lo + (hi-lo)/2
+		t, err := nextTimestamp(f, mid, hi-mid)
+		if err == errNoTimestamp || (err == nil && !t.Before(since)) {
+			hi = mid
+		} else if err == nil {
+			lo = mid
+		} else {
+			return nil, err
+		}
+	}
+	if err := seekLine(f, lo); err != nil {
+		return nil, err
+	}
+	return skipUntil(f, since)
+}
+
+// skipUntil discards lines from r until one with a timestamp at or after
+// since is found.
+//
+// It returns a reader starting at this line. It returns an empty reader if no
+// such line was found.
+func skipUntil(r io.Reader, since time.Time) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if t, ok := timestamp.Parse(line); ok && !t.Before(since) {
+			return io.MultiReader(bytes.NewReader(line), br), nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return &bytes.Reader{}, nil
+			}
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want int64
+	}{
+		{"12", 12},
+		{"2k", 2 << 10},
+		{"50M", 50 << 20},
+		{"1G", 1 << 30},
+	}
+	for _, line := range data {
+		got, err := parseSize(line.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != line.want {
+			t.Fatalf("%q: want %d, got %d", line.in, line.want, got)
+		}
+	}
+	for _, s := range []string{"", "M", "-1", "1T"} {
+		if _, err := parseSize(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+func TestSeekTail(t *testing.T) {
+	t.Parallel()
+	r := strings.NewReader("aaa\nbbb\nccc\n")
+	if err := seekTail(r, 6); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	compareString(t, "ccc\n", string(b))
+	if err := seekTail(r, 100); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(r)
+	compareString(t, "aaa\nbbb\nccc\n", string(b))
+}
+
+func TestSeekSince(t *testing.T) {
+	t.Parallel()
+	// Generate a file large enough to trigger the binary search, with a stack
+	// dump without timestamps in the middle.
+	buf := bytes.Buffer{}
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local)
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&buf, "%s line %d\n", start.Add(time.Duration(i)*time.Second).Format("2006-01-02T15:04:05"), i)
+		if i == 15000 {
+			buf.WriteString("panic: boo\n\ngoroutine 1 [running]:\nmain.main()\n\t/a/main.go:3 +0x1\n")
+		}
+	}
+	r, err := seekSince(bytes.NewReader(buf.Bytes()), start.Add(15000*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	want := "2025-03-01T04:10:00 line 15000\npanic: boo\n"
+	if !strings.HasPrefix(string(b), want) {
+		t.Fatalf("unexpected %q", b[:len(want)])
+	}
+
+	// After the end.
+	r, err = seekSince(bytes.NewReader(buf.Bytes()), start.Add(time.Hour*24))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = io.ReadAll(r); len(b) != 0 {
+		t.Fatalf("unexpected %q", b)
+	}
+}
+
+func TestSkipUntil(t *testing.T) {
+	t.Parallel()
+	in := "2025-03-01T19:00:00 a\nfoo\n2025-03-01T20:00:00 b\nbar\n"
+	r, err := skipUntil(strings.NewReader(in), time.Date(2025, 3, 1, 20, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(r)
+	compareString(t, "2025-03-01T20:00:00 b\nbar\n", string(b))
+}
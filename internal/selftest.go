@@ -0,0 +1,131 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// crasherSrc is the program compiled by selfTest. crasherLine is the line of
+// the panic() call in it.
+const crasherSrc = `package main
+
+func crash(s string) {
+	panic(s)
+}
+
+func main() {
+	crash("pp self-test")
+}
+`
+
+const crasherLine = 4
+
+// selfTest verifies that the stack traces produced by the Go toolchain goBin
+// can be parsed. When goBin is empty, it verifies the output of runtime.Stack
+// of the current process instead.
+//
+// It writes a one line report to out and returns an error on failure.
+func selfTest(out io.Writer, goBin string) error {
+	version := runtime.Version() + " (in-process)"
+	var err error
+	if goBin != "" {
+		var v []byte
+		/* #nosec G204 */
+		if v, err = exec.Command(goBin, "version").Output(); err != nil {
+			return fmt.Errorf("self-test: %w", err)
+		}
+		version = strings.TrimSpace(string(v))
+		err = selfTestCrasher(goBin)
+	} else {
+		err = selfTestInProcess()
+	}
+	if err != nil {
+		fmt.Fprintf(out, "FAIL: %s\n", version)
+		return fmt.Errorf("self-test: %w", err)
+	}
+	fmt.Fprintf(out, "PASS: %s\n", version)
+	return nil
+}
+
+// selfTestCrasher builds and runs crasherSrc with goBin, then parses its
+// output.
+func selfTestCrasher(goBin string) error {
+	d, err := os.MkdirTemp("", "pp-self-test")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(d)
+	src := filepath.Join(d, "main.go")
+	if err = os.WriteFile(src, []byte(crasherSrc), 0o600); err != nil {
+		return err
+	}
+	/* #nosec G204 */
+	cmd := exec.Command(goBin, "run", src)
+	cmd.Dir = d
+	cmd.Env = append(os.Environ(), "GOTRACEBACK=all")
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err == nil {
+		return errors.New("the crasher didn't crash")
+	}
+	s, err := parseSelfTest(stderr.Bytes())
+	if err != nil {
+		return err
+	}
+	for _, g := range s.Goroutines {
+		if !g.First {
+			continue
+		}
+		for _, c := range g.Stack.Calls {
+			if c.Func.Complete == "main.crash" {
+				if c.Line != crasherLine {
+					return fmt.Errorf("main.crash() parsed at line %d, expected %d", c.Line, crasherLine)
+				}
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("main.crash() not found in the parsed output:\n%s", stderr.Bytes())
+}
+
+// selfTestInProcess parses the output of runtime.Stack of the current
+// process.
+func selfTestInProcess() error {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	s, err := parseSelfTest(buf)
+	if err != nil {
+		return err
+	}
+	for _, c := range s.Goroutines[0].Stack.Calls {
+		if c.Func.Name == "selfTestInProcess" {
+			return nil
+		}
+	}
+	return fmt.Errorf("selfTestInProcess() not found in the parsed output:\n%s", buf)
+}
+
+// parseSelfTest parses a single snapshot with at least one goroutine.
+func parseSelfTest(b []byte) (*stack.Snapshot, error) {
+	s, _, err := stack.ScanSnapshot(bytes.NewReader(b), io.Discard, stack.DefaultOpts())
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if s == nil || len(s.Goroutines) == 0 {
+		return nil, fmt.Errorf("no goroutine found in:\n%s", b)
+	}
+	return s, nil
+}
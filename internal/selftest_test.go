@@ -0,0 +1,37 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSelfTestInProcess(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	if err := selfTest(&out, ""); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "PASS: "+runtime.Version()+" (in-process)\n", out.String())
+}
+
+func TestSelfTestCrasher(t *testing.T) {
+	t.Parallel()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found in PATH")
+	}
+	out := bytes.Buffer{}
+	if err := selfTest(&out, goBin); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "PASS: go version ") {
+		t.Fatalf("unexpected %q", out.String())
+	}
+}
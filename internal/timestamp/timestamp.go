@@ -0,0 +1,98 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package timestamp parses the timestamps printed at the start of log lines.
+package timestamp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// layouts are the log line prefixes recognized.
+//
+// Fractional seconds are implicitly accepted by time.Parse.
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Parse parses a timestamp prefix at the start of line.
+//
+// It tolerates a leading "[" as used by some loggers. Timestamps without a
+// timezone are interpreted as local time.
+func Parse(line []byte) (time.Time, bool) {
+	line = bytes.TrimLeft(line, "[")
+	// A timestamp starts with the year.
+	if len(line) < 10 || line[0] < '0' || line[0] > '9' {
+		return time.Time{}, false
+	}
+	// Only look at the first two space separated words.
+	end := len(line)
+	if i := bytes.IndexByte(line, ' '); i != -1 {
+		end = i
+		if j := bytes.IndexAny(line[i+1:], " ]\t\r\n"); j != -1 {
+			end = i + 1 + j
+		} else {
+			end = len(line)
+		}
+	}
+	s := strings.TrimRight(string(line[:end]), "]\r\n")
+	for _, l := range layouts {
+		// Try the two words, then the first word only.
+		if t, err := time.ParseInLocation(l, s, time.Local); err == nil {
+			return t, true
+		}
+		if i := strings.IndexByte(s, ' '); i != -1 {
+			if t, err := time.ParseInLocation(l, strings.TrimRight(s[:i], "]"), time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Writer forwards to w and remembers the last timestamp seen at the start of
+// a line.
+//
+// It is used to know when a snapshot was captured, from the log lines printed
+// just before it.
+type Writer struct {
+	// Last is the last timestamp seen, if any.
+	Last time.Time
+
+	w io.Writer
+	// bol is true when the next write starts a line.
+	bol bool
+}
+
+// NewWriter returns a Writer that forwards to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, bol: true}
+}
+
+func (t *Writer) Write(p []byte) (int, error) {
+	for rest := p; len(rest) != 0; {
+		i := bytes.IndexByte(rest, '\n')
+		if t.bol {
+			if ts, ok := Parse(rest); ok {
+				t.Last = ts
+			}
+		}
+		if i == -1 {
+			t.bol = false
+			break
+		}
+		t.bol = true
+		rest = rest[i+1:]
+	}
+	return t.w.Write(p)
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package timestamp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	want := time.Date(2025, 3, 1, 20, 0, 0, 0, time.Local)
+	data := []struct {
+		in string
+		ok bool
+	}{
+		{"2025-03-01T20:00:00", true},
+		{"2025-03-01T20:00:00.123 foo", true},
+		{"2025-03-01 20:00:00 foo", true},
+		{"2025/03/01 20:00:00 panic: foo", true},
+		{"[2025-03-01 20:00:00] foo", true},
+		{"goroutine 1 [running]:", false},
+		{"\tmain.go:12 +0x20", false},
+		{"", false},
+	}
+	for i, line := range data {
+		got, ok := Parse([]byte(line.in))
+		if ok != line.ok {
+			t.Fatalf("#%d: %q: got %t", i, line.in, ok)
+		}
+		if ok && !got.Truncate(time.Second).Equal(want) {
+			t.Fatalf("#%d: %q: got %s", i, line.in, got)
+		}
+	}
+	got, ok := Parse([]byte("2025-03-01T20:00:00Z"))
+	if !ok || !got.Equal(time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected %s", got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+	b := bytes.Buffer{}
+	w := NewWriter(&b)
+	// Timestamps in the middle of a line are ignored, even across writes.
+	for _, s := range []string{"2025-03-01T19:00:00Z a\nfoo ", "2025-03-01T21:00:00Z\n2025-03-01T", "20:00:00Z b\n"} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC); !w.Last.Equal(want) {
+		t.Fatalf("got %s; want %s", w.Last, want)
+	}
+	if _, err := io.WriteString(w, "2025-03-01T20:00:00Z c\n"); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC); !w.Last.Equal(want) {
+		t.Fatalf("got %s; want %s", w.Last, want)
+	}
+	if want := "2025-03-01T19:00:00Z a\nfoo 2025-03-01T21:00:00Z\n2025-03-01T20:00:00Z b\n2025-03-01T20:00:00Z c\n"; b.String() != want {
+		t.Fatalf("got %q; want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"time"
+)
+
+// waitPoll is how often the file is checked while waiting for it to be
+// completely written.
+const waitPoll = 200 * time.Millisecond
+
+// waitTail is how many bytes at the end of the file are looked at to
+// determine if the last goroutine is complete.
+const waitTail = 64 << 10
+
+var reGoroutineStart = regexp.MustCompile(`(?m)^goroutine \d+ .*\[.*\]:\r?$`)
+
+// waitComplete waits until the file name looks completely written, that is
+// its last goroutine is complete and its size didn't change since the
+// previous check, or until timeout expires.
+//
+// This is meant for files written by a crash handler that pp may read before
+// the handler is done. Returns true if the file is complete, false on timeout.
+func waitComplete(name string, timeout, poll time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	last := int64(-1)
+	for {
+		size, complete, err := checkComplete(name)
+		if err != nil {
+			return false, err
+		}
+		if complete && size == last {
+			return true, nil
+		}
+		if !time.Now().Add(poll).Before(deadline) {
+			log.Printf("%s is still incomplete after %s", name, timeout)
+			return false, nil
+		}
+		last = size
+		time.Sleep(poll)
+	}
+}
+
+// checkComplete returns the size of the file and if its last goroutine is
+// complete.
+func checkComplete(name string) (int64, bool, error) {
+	/* #nosec G304 */
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, false, err
+	}
+	/* #nosec G307 */
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false, err
+	}
+	offset := size - waitTail
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return 0, false, err
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, !incompleteDump(tail), nil
+}
+
+// incompleteDump returns true if data, the end of a dump, stops in the middle
+// of a line or of a goroutine block.
+//
+// A goroutine block is incomplete when it is only a header or when its last
+// line is a function call, which is always followed by its source file line.
+// Data without any goroutine is only considered incomplete when its last line
+// is partial.
+func incompleteDump(data []byte) bool {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		return true
+	}
+	loc := reGoroutineStart.FindAllIndex(data, -1)
+	if len(loc) == 0 {
+		return false
+	}
+	block := data[loc[len(loc)-1][1]:]
+	// The block ends at the first empty line.
+	if i := bytes.Index(block, []byte("\n\n")); i != -1 {
+		block = block[:i+1]
+	} else if i := bytes.Index(block, []byte("\n\r\n")); i != -1 {
+		block = block[:i+1]
+	}
+	lines := bytes.Split(bytes.TrimRight(block, "\r\n"), []byte("\n"))
+	l := bytes.TrimRight(lines[len(lines)-1], "\r")
+	if len(l) == 0 {
+		// Only the header was written.
+		return true
+	}
+	// A function call must be followed by its source file line.
+	return l[0] != '\t' && (bytes.HasSuffix(l, []byte(")")) || bytes.HasPrefix(l, []byte("created by ")))
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const completeDump = "panic: oh no\n" +
+	"\n" +
+	"goroutine 1 [running]:\n" +
+	"main.main()\n" +
+	"\t/home/user/go/src/main.go:12 +0x20\n" +
+	"\n" +
+	"goroutine 6 [chan receive]:\n" +
+	"main.worker(0xc000010000)\n" +
+	"\t/home/user/go/src/main.go:20 +0x40\n" +
+	"created by main.main in goroutine 1\n" +
+	"\t/home/user/go/src/main.go:10 +0x18\n"
+
+func TestIncompleteDump(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want bool
+	}{
+		{"", true},
+		{"some log\n", false},
+		{"some lo", true},
+		{completeDump, false},
+		{completeDump + "exit status 2\n", false},
+		{completeDump + "\ngoroutine 7 [select]:\n", true},
+		{completeDump + "\ngoroutine 7 [select]:\nmain.other()\n", true},
+		{completeDump + "\ngoroutine 7 [select]:\nmain.other()\n\t/home/user/go/src/main.go:3", true},
+		{completeDump + "\ngoroutine 7 [select]:\nmain.other()\n\t/home/user/go/src/main.go:30 +0x1\n", false},
+		{completeDump + "\ngoroutine 7 [select]:\nmain.other()\n\t/home/user/go/src/main.go:30 +0x1\ncreated by main.main in goroutine 1\n", true},
+		{completeDump + "\ngoroutine 7 [select]:\nmain.other(...)\n\tmain.go:30\n...additional frames elided...\n", false},
+	}
+	for i, line := range data {
+		if got := incompleteDump([]byte(line.in)); got != line.want {
+			t.Errorf("#%d: want %t, got %t", i, line.want, got)
+		}
+	}
+}
+
+func TestWaitComplete(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "dump.txt")
+	if err := os.WriteFile(name, []byte(completeDump[:60]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		done <- os.WriteFile(name, []byte(completeDump), 0600)
+	}()
+	ok, err := waitComplete(name, 10*time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected the file to be complete")
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Still incomplete.
+	if err := os.WriteFile(name, []byte(completeDump[:60]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err = waitComplete(name, 50*time.Millisecond, 10*time.Millisecond); err != nil || ok {
+		t.Fatalf("expected timeout, got %t, %v", ok, err)
+	}
+
+	if _, err = waitComplete(filepath.Join(t.TempDir(), "missing"), time.Second, 10*time.Millisecond); err == nil {
+		t.Fatal("expected error")
+	}
+}
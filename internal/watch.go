@@ -0,0 +1,129 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/maruel/panicparse/v2/pp"
+	"github.com/mattn/go-colorable"
+	"github.com/mattn/go-isatty"
+)
+
+func watchCommand(fs *flag.FlagSet) func() error {
+	interval := fs.Duration("interval", 0, "Collect the goroutines of the command periodically, ex: -interval 10m")
+	u := fs.String("url", "", "Fetch the goroutines from this net/http/pprof endpoint of the command instead of sending SIGQUIT, which keeps it running, ex: -url localhost:6060")
+	aggressive := fs.Bool("aggressive", false, "Aggressive deduplication including non pointers")
+	noColor := fs.Bool("no-color", !isatty.IsTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb", "Disable coloring")
+	return func() error {
+		if fs.NArg() == 0 {
+			return errors.New("watch requires a command to run")
+		}
+		var out io.Writer = os.Stdout
+		r := pp.DefaultRunOptions()
+		r.Aggressive = *aggressive
+		r.Color = !*noColor
+		r.Follow = true
+		if r.Color {
+			out = colorable.NewColorableStdout()
+		}
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+		cmd.Stdin = os.Stdin
+		capture := sigquitCmd(cmd)
+		if *u != "" {
+			capture = func() []byte {
+				return fetchGoroutines("watch", goroutineURL(*u))
+			}
+		}
+		// The terminal sends Ctrl-C and Ctrl-\ to the command too. Ignore them so
+		// the output is processed until the command exits.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGQUIT)
+		defer signal.Stop(signals)
+		// captureSignals trigger a capture on demand.
+		trigger := make(chan os.Signal, 1)
+		if len(captureSignals) != 0 {
+			signal.Notify(trigger, captureSignals...)
+			defer signal.Stop(trigger)
+		}
+		go func() {
+			for range signals {
+			}
+		}()
+		return watch(cmd, *interval, trigger, capture, out, r)
+	}
+}
+
+// sigquitCmd returns a function that sends SIGQUIT to the command, so it
+// prints its goroutines. A Go program exits afterward unless it handles the
+// signal.
+func sigquitCmd(cmd *exec.Cmd) func() []byte {
+	return func() []byte {
+		if err := cmd.Process.Signal(syscall.SIGQUIT); err != nil {
+			return []byte(fmt.Sprintf("\npp: watch: %v\n", err))
+		}
+		return nil
+	}
+}
+
+// watch runs cmd and processes its stdout and stderr until it exits.
+//
+// capture is called every interval, if not 0, and on each value received on
+// trigger. The data it returns is inserted in the output of the command.
+func watch(cmd *exec.Cmd, interval time.Duration, trigger <-chan os.Signal, capture func() []byte, out io.Writer, r *pp.RunOptions) error {
+	pr, pw := io.Pipe()
+	// io.PipeWriter serializes the writes so the captures are not interleaved
+	// with a write of the command.
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick:
+			case <-trigger:
+			}
+			if b := capture(); len(b) != 0 {
+				_, _ = pw.Write(b)
+			}
+		}
+	}()
+	exit := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		close(done)
+		_ = pw.Close()
+		exit <- err
+	}()
+	err := pp.Run(r, pr, out)
+	// Drain in case processing stopped early, so the command is not blocked.
+	_, _ = io.Copy(io.Discard, pr)
+	if err1 := <-exit; err == nil {
+		err = err1
+	}
+	return err
+}
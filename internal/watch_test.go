@@ -0,0 +1,45 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maruel/panicparse/v2/pp"
+)
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGQUIT is not supported")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestWatchHelper$")
+	cmd.Env = append(os.Environ(), "PANICPARSE_WATCH_HELPER=1")
+	out := bytes.Buffer{}
+	r := pp.RunOptions{Theme: "default", MID: -1}
+	// The helper exits after printing its goroutines.
+	if err := watch(cmd, 500*time.Millisecond, nil, sigquitCmd(cmd), &out, &r); err == nil {
+		t.Fatal("expected the helper to exit with an error")
+	}
+	if got := out.String(); !strings.Contains(got, "ready\n") || !strings.Contains(got, "TestWatchHelper(") {
+		t.Fatalf("goroutine not found:\n%s", got)
+	}
+}
+
+// TestWatchHelper is the command run by TestWatch.
+func TestWatchHelper(t *testing.T) {
+	if os.Getenv("PANICPARSE_WATCH_HELPER") != "1" {
+		t.Skip("only run by TestWatch")
+	}
+	fmt.Println("ready")
+	time.Sleep(time.Minute)
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// watchdog is an io.Reader that injects a goroutine dump in the stream when
+// the underlying reader doesn't produce any data for a while.
+//
+// This is used to diagnose a piped program that silently hangs instead of
+// panicking.
+type watchdog struct {
+	d       time.Duration
+	capture func() []byte
+	chunks  chan chunk
+	timer   *time.Timer
+	buf     []byte
+	err     error
+}
+
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// newWatchdog returns a reader that reads from r and calls capture after d
+// without data. The data returned by capture is inserted in the stream.
+//
+// capture is called at most once per silent period.
+func newWatchdog(r io.Reader, d time.Duration, capture func() []byte) io.Reader {
+	w := &watchdog{
+		d:       d,
+		capture: capture,
+		chunks:  make(chan chunk),
+		timer:   time.NewTimer(d),
+	}
+	go w.readLoop(r)
+	return w
+}
+
+func (w *watchdog) Read(p []byte) (int, error) {
+	for len(w.buf) == 0 {
+		if w.err != nil {
+			w.timer.Stop()
+			return 0, w.err
+		}
+		select {
+		case c := <-w.chunks:
+			w.buf = c.b
+			w.err = c.err
+			if !w.timer.Stop() {
+				select {
+				case <-w.timer.C:
+				default:
+				}
+			}
+			w.timer.Reset(w.d)
+		case <-w.timer.C:
+			// The timer is only rearmed once data is received.
+			w.buf = w.capture()
+		}
+	}
+	n := copy(p, w.buf)
+	w.buf = w.buf[n:]
+	return n, nil
+}
+
+// readLoop reads r in the background, so Read can wait on both the data and
+// the timer.
+func (w *watchdog) readLoop(r io.Reader) {
+	for {
+		b := make([]byte, 4096)
+		n, err := r.Read(b)
+		w.chunks <- chunk{b[:n], err}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// captureURL returns a function that fetches a goroutine dump from url, e.g.
+// http://localhost:6060/debug/pprof/goroutine?debug=2.
+func captureURL(url string, d time.Duration) func() []byte {
+	return func() []byte {
+		out := []byte(fmt.Sprintf("\npp: no output for %s, fetching %s\n", d, url))
+		return append(out, fetchGoroutines("watchdog", url)...)
+	}
+}
+
+// fetchGoroutines returns the goroutine dump served at url followed by a new
+// line, or a message prefixed with name describing why it failed.
+func fetchGoroutines(name, url string) []byte {
+	c := http.Client{Timeout: time.Minute}
+	resp, err := c.Get(url)
+	if err != nil {
+		return []byte(fmt.Sprintf("pp: %s: %v\n", name, err))
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err != nil {
+		return []byte(fmt.Sprintf("pp: %s: %v\n", name, err))
+	}
+	return append(b, '\n')
+}
+
+// captureSIGQUIT returns a function that sends SIGQUIT to the processes
+// writing to stdin, so the Go program piped into pp prints its goroutines and
+// exits.
+func captureSIGQUIT(d time.Duration) func() []byte {
+	return func() []byte {
+		out := []byte(fmt.Sprintf("\npp: no output for %s, sending SIGQUIT\n", d))
+		if err := sigquitPiped(); err != nil {
+			return append(out, fmt.Sprintf("pp: watchdog: %v\n", err)...)
+		}
+		return out
+	}
+}
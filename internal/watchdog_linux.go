@@ -0,0 +1,98 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// sigquitPiped sends SIGQUIT to the processes writing to pp's stdin, e.g.
+// the program piped into pp.
+//
+// The other processes, like the shell or the other commands of the pipeline,
+// are not signaled.
+func sigquitPiped() error {
+	pids, err := pipeWriters(os.Stdin)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return errors.New("no process is writing to stdin")
+	}
+	for _, pid := range pids {
+		if err = syscall.Kill(pid, syscall.SIGQUIT); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pipeWriters returns the processes other than pp which have the write end
+// of the pipe f open.
+//
+// It looks for the pipe in the file descriptors listed in /proc. The
+// processes of other users are skipped since their file descriptors can't be
+// listed.
+func pipeWriters(f *os.File) ([]int, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if fi.Mode()&os.ModeNamedPipe == 0 || !ok {
+		return nil, fmt.Errorf("%s is not a pipe", f.Name())
+	}
+	target := fmt.Sprintf("pipe:[%d]", st.Ino)
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var out []int
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join("/proc", p.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if l, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err != nil || l != target {
+				continue
+			}
+			if isWriteOnly(filepath.Join(dir, "fdinfo", fd.Name())) {
+				out = append(out, pid)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// isWriteOnly returns true if the flags line of the fdinfo file denotes a
+// file descriptor opened with O_WRONLY.
+func isWriteOnly(fdinfo string) bool {
+	/* #nosec G304 */
+	b, err := os.ReadFile(fdinfo)
+	if err != nil {
+		return false
+	}
+	for _, l := range bytes.Split(b, []byte("\n")) {
+		if v := bytes.TrimPrefix(l, []byte("flags:")); len(v) != len(l) {
+			flags, err := strconv.ParseUint(string(bytes.TrimSpace(v)), 8, 32)
+			return err == nil && flags&syscall.O_ACCMODE == syscall.O_WRONLY
+		}
+	}
+	return false
+}
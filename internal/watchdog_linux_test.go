@@ -0,0 +1,54 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestPipeWriters(t *testing.T) {
+	t.Parallel()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// The test process itself is never listed.
+	pids, err := pipeWriters(r)
+	_ = w.Close()
+	if err != nil || len(pids) != 0 {
+		t.Fatalf("%v, %v", pids, err)
+	}
+
+	r2, w2, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	cmd := exec.Command("sleep", "60")
+	cmd.Stdout = w2
+	if err = cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	_ = w2.Close()
+	if pids, err = pipeWriters(r2); err != nil || len(pids) != 1 || pids[0] != cmd.Process.Pid {
+		t.Fatalf("want [%d], got %v, %v", cmd.Process.Pid, pids, err)
+	}
+
+	f, err := os.CreateTemp(t.TempDir(), "pp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = pipeWriters(f); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build !linux
+
+package internal
+
+import (
+	"errors"
+)
+
+func sigquitPiped() error {
+	return errors.New("finding the piped program is only supported on linux; use -watchdog-url or pp watch")
+}
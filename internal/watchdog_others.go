@@ -0,0 +1,14 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+
+package internal
+
+import (
+	"os"
+)
+
+// captureSignals is empty since there's no user defined signal.
+var captureSignals []os.Signal
@@ -0,0 +1,59 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Parallel()
+	r, w := io.Pipe()
+	calls := 0
+	wd := newWatchdog(r, 10*time.Millisecond, func() []byte {
+		calls++
+		return []byte("dump\n")
+	})
+	go func() {
+		_, _ = w.Write([]byte("hello\n"))
+		// Stay silent long enough for the watchdog to fire; it must only fire
+		// once.
+		time.Sleep(100 * time.Millisecond)
+		_, _ = w.Write([]byte("bye\n"))
+		_ = w.Close()
+	}()
+	b, err := io.ReadAll(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "hello\ndump\nbye\n", string(b))
+	if calls != 1 {
+		t.Fatalf("unexpected %d calls", calls)
+	}
+}
+
+func TestCaptureURL(t *testing.T) {
+	t.Parallel()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/pprof/goroutine" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("goroutine 1 [running]:"))
+	}))
+	defer s.Close()
+	got := string(captureURL(s.URL+"/debug/pprof/goroutine", time.Minute)())
+	want := "\npp: no output for 1m0s, fetching " + s.URL + "/debug/pprof/goroutine\ngoroutine 1 [running]:\n"
+	compareString(t, want, got)
+	got = string(captureURL(s.URL+"/missing", time.Minute)())
+	if !strings.Contains(got, "pp: watchdog: "+s.URL+"/missing returned 404 Not Found\n") {
+		t.Fatalf("unexpected %q", got)
+	}
+}
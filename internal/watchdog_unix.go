@@ -0,0 +1,16 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+// captureSignals are the signals that make "pp watch" collect the goroutines
+// of the command.
+var captureSignals = []os.Signal{syscall.SIGUSR1}
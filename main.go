@@ -9,6 +9,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -17,6 +18,10 @@ import (
 
 func main() {
 	if err := internal.Main(); err != nil {
+		if errors.Is(err, internal.ErrPanicFound) {
+			// Same exit code as a Go panic.
+			os.Exit(2)
+		}
 		fmt.Fprintf(os.Stderr, "Failed: %s\n", err)
 		os.Exit(1)
 	}
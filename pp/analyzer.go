@@ -0,0 +1,38 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// writeFindingsToConsole prints the findings of the analyzers, if any.
+func writeFindingsToConsole(out io.Writer, p *render.Palette, findings []analyzer.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+	_, _ = io.WriteString(out, "Findings:\n")
+	for i := range findings {
+		_, _ = io.WriteString(out, "  "+p.Race+findings[i].String()+p.EOLReset+"\n")
+	}
+	_, _ = io.WriteString(out, "\n")
+}
+
+// findingsHTML returns the findings of the analyzers as an HTML list.
+func findingsHTML(findings []analyzer.Finding) template.HTML {
+	if len(findings) == 0 {
+		return ""
+	}
+	out := "<h2>Findings</h2><ul>"
+	for i := range findings {
+		out += "<li>" + template.HTMLEscapeString(findings[i].String()) + "</li>"
+	}
+	/* #nosec G203 */
+	return template.HTML(out + "</ul>")
+}
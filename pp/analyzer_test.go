@@ -0,0 +1,26 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+)
+
+func TestFindings(t *testing.T) {
+	t.Parallel()
+	findings := []analyzer.Finding{
+		{Analyzer: "db", Message: "holding <lock>", IDs: []int{3}},
+	}
+	out := bytes.Buffer{}
+	writeFindingsToConsole(&out, testPalette, findings)
+	compareString(t, "Findings:\n  [db] holding <lock> (goroutines [3])A\n\n", out.String())
+	out.Reset()
+	writeFindingsToConsole(&out, testPalette, nil)
+	compareString(t, "", out.String())
+	compareString(t, "<h2>Findings</h2><ul><li>[db] holding &lt;lock&gt; (goroutines [3])</li></ul>", string(findingsHTML(findings)))
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// blamer annotates calls with the last commit that modified the line, by
+// running git blame.
+//
+// The results are cached, including the failures, so git is only run once
+// per line.
+type blamer struct {
+	// git is the git executable.
+	git string
+
+	mu    sync.Mutex
+	lines map[string]string
+}
+
+func newBlamer(git string) *blamer {
+	return &blamer{git: git, lines: map[string]string{}}
+}
+
+// blame returns "<hash> <author> <date>" for the call or an empty string if
+// the local source file is unknown or not in a git checkout.
+func (b *blamer) blame(c *stack.Call) string {
+	if c.LocalSrcPath == "" || c.Line <= 0 {
+		return ""
+	}
+	key := c.LocalSrcPath + ":" + strconv.Itoa(c.Line)
+	b.mu.Lock()
+	s, ok := b.lines[key]
+	b.mu.Unlock()
+	if ok {
+		return s
+	}
+	l := strconv.Itoa(c.Line)
+	/* #nosec G204 */
+	cmd := exec.Command(b.git, "blame", "--porcelain", "-L", l+","+l, "--", filepath.Base(c.LocalSrcPath))
+	cmd.Dir = filepath.Dir(c.LocalSrcPath)
+	if out, err := cmd.Output(); err == nil {
+		s = parseBlame(out)
+	}
+	b.mu.Lock()
+	b.lines[key] = s
+	b.mu.Unlock()
+	return s
+}
+
+// parseBlame parses the output of git blame --porcelain for a single line.
+func parseBlame(out []byte) string {
+	var hash, author, date string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for first := true; s.Scan(); first = false {
+		l := s.Text()
+		if first {
+			if i := strings.IndexByte(l, ' '); i >= 8 {
+				hash = l[:8]
+			}
+			continue
+		}
+		if strings.HasPrefix(l, "author ") {
+			author = l[len("author "):]
+		} else if strings.HasPrefix(l, "author-time ") {
+			if t, err := strconv.ParseInt(l[len("author-time "):], 10, 64); err == nil {
+				date = time.Unix(t, 0).UTC().Format("2006-01-02")
+			}
+		}
+	}
+	if hash == "" {
+		return ""
+	}
+	if strings.Trim(hash, "0") == "" {
+		// The line is not committed yet.
+		return "not committed yet"
+	}
+	return strings.TrimSpace(hash + " " + author + " " + date)
+}
@@ -0,0 +1,38 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"testing"
+)
+
+func TestParseBlame(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want string
+	}{
+		{
+			"aa38adc1d5a3e4b1c6f7e8d9c0b1a2f3e4d5c6b7 74 74 1\n" +
+				"author Jane Doe\n" +
+				"author-mail <jane@example.com>\n" +
+				"author-time 1760572800\n" +
+				"author-tz +0000\n" +
+				"\tpanic(\"simple\")\n",
+			"aa38adc1 Jane Doe 2025-10-16",
+		},
+		{
+			"0000000000000000000000000000000000000000 74 74 1\n" +
+				"author Not Committed Yet\n",
+			"not committed yet",
+		},
+		{"", ""},
+	}
+	for i, line := range data {
+		if got := parseBlame([]byte(line.in)); got != line.want {
+			t.Fatalf("#%d: %q != %q", i, line.want, got)
+		}
+	}
+}
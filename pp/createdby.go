@@ -0,0 +1,86 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// creationSite is a unique "created by" call and the number of live
+// goroutines it created.
+type creationSite struct {
+	call  *stack.Call
+	count int
+}
+
+// creationSites returns the unique creation sites of the goroutines in c,
+// sorted by the number of goroutines they created, most first.
+//
+// Only the call that created the goroutine is considered, not the current
+// stack. Goroutines without a creator, like the main goroutine, are ignored.
+func creationSites(c *stack.Snapshot) []creationSite {
+	m := map[string]int{}
+	var out []creationSite
+	for _, g := range c.Goroutines {
+		if len(g.CreatedBy.Calls) == 0 {
+			continue
+		}
+		call := &g.CreatedBy.Calls[0]
+		k := call.Func.Complete + "\n" + call.RemoteSrcPath + ":" + strconv.Itoa(call.Line)
+		if i, ok := m[k]; ok {
+			out[i].count++
+			continue
+		}
+		m[k] = len(out)
+		out = append(out, creationSite{call: call, count: 1})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		if out[i].call.Func.Complete != out[j].call.Func.Complete {
+			return out[i].call.Func.Complete < out[j].call.Func.Complete
+		}
+		return out[i].call.Line < out[j].call.Line
+	})
+	return out
+}
+
+// writeCreatedByToConsole prints one line per creation site with the number
+// of goroutines it created, the import path, the function and the source
+// location.
+func writeCreatedByToConsole(out io.Writer, p *render.Palette, pf render.PathFormat, c *stack.Snapshot) error {
+	sites := creationSites(c)
+	cntLen, pkgLen, fnLen := 0, 0, 0
+	for _, s := range sites {
+		if l := len(strconv.Itoa(s.count)); l > cntLen {
+			cntLen = l
+		}
+		if l := len(s.call.ImportPath); l > pkgLen {
+			pkgLen = l
+		}
+		if l := len(s.call.Func.Name); l > fnLen {
+			fnLen = l
+		}
+	}
+	for _, s := range sites {
+		if _, err := fmt.Fprintf(
+			out, "%s%*d %s%-*s %s%-*s %s%s%s\n",
+			p.CreatedBy, cntLen, s.count,
+			p.Package, pkgLen, s.call.ImportPath,
+			p.FunctionColor(s.call), fnLen, s.call.Func.Name,
+			p.SrcFile, pf.FormatCall(s.call),
+			p.EOLReset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessCreatedBy(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, createdBy: true}
+	if err := process(bytes.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := "junk\n" +
+		"14 net/http                                           (*Server).Serve                   server.go:2933\n" +
+		"14 net/http                                           (*connReader).startBackgroundRead server.go:674\n" +
+		"13 github.com/maruel/panicparse/cmd/panicweb/internal GetAsync                          internal.go:25\n" +
+		"13 net/http                                           (*Transport).dialConn             transport.go:1647\n" +
+		"13 net/http                                           (*Transport).dialConn             transport.go:1648\n" +
+		" 1 main                                               main                              main.go:50\n" +
+		" 1 main                                               main                              main.go:63\n" +
+		" 1 main                                               main                              main.go:73\n"
+	compareString(t, want, out.String())
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// makeDeterministic removes from the snapshot what differs between two runs
+// that panicked the same way, so their output can be diffed: the pointer
+// values, the runtime addresses and the goroutine IDs.
+//
+// The goroutines are sorted by their signature, with the one that crashed
+// first, then renumbered from 1. The arguments rebuilt from the sources are
+// dropped, as they embed the pointer values and depend on the sources being
+// available. Since the pointers are zeroed, the heuristics based on them, like
+// the deadlock detection, find nothing.
+func makeDeterministic(c *stack.Snapshot) {
+	c.NamedPointers = nil
+	keys := make(map[*stack.Goroutine]string, len(c.Goroutines))
+	for _, g := range c.Goroutines {
+		g.GP = 0
+		g.M = 0
+		g.MP = 0
+		g.RuntimeInfo = nil
+		g.RaceAddr = 0
+		zeroPointers(&g.Stack)
+		zeroPointers(&g.CreatedBy)
+		keys[g] = signatureKey(&g.Signature)
+	}
+	sort.SliceStable(c.Goroutines, func(i, j int) bool {
+		l, r := c.Goroutines[i], c.Goroutines[j]
+		if l.First != r.First {
+			return l.First
+		}
+		return keys[l] < keys[r]
+	})
+	ids := make(map[int]int, len(c.Goroutines))
+	for i, g := range c.Goroutines {
+		ids[g.ID] = i + 1
+	}
+	for _, g := range c.Goroutines {
+		g.ID = ids[g.ID]
+		// 0 when the creator is not in the snapshot.
+		g.CreatedByID = ids[g.CreatedByID]
+	}
+}
+
+func zeroPointers(s *stack.Stack) {
+	for i := range s.Calls {
+		zeroArgs(&s.Calls[i].Args)
+	}
+}
+
+func zeroArgs(a *stack.Args) {
+	a.Processed = nil
+	for i := range a.Values {
+		v := &a.Values[i]
+		v.Name = ""
+		if v.IsPtr {
+			v.Value = 0
+			v.IsPtr = false
+		}
+		zeroArgs(&v.Fields)
+	}
+}
+
+// signatureKey returns a string that sorts the goroutines by their state and
+// calls.
+func signatureKey(s *stack.Signature) string {
+	var b strings.Builder
+	b.WriteString(s.State)
+	for _, st := range []*stack.Stack{&s.Stack, &s.CreatedBy} {
+		for i := range st.Calls {
+			c := &st.Calls[i]
+			b.WriteString("\n" + c.Func.Complete + "(" + c.Args.String() + ") " + c.RemoteSrcPath + ":" + strconv.Itoa(c.Line))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"crypto/sha256"
+	"hash"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// duplicateWindow is the maximum time between two identical snapshots for
+// the second one to be considered a duplicate.
+//
+// Log pipelines with at-least-once delivery replay the same lines, so the
+// timestamps of a duplicated dump are normally identical. Periodic dumps of an
+// idle process can be identical too but they are further apart.
+const duplicateWindow = 5 * time.Second
+
+// isDuplicate returns true if c is the same dump as prev, as determined by the
+// goroutines they contain and their CapturedAt timestamps.
+//
+// Both snapshots must have a timestamp and be within duplicateWindow.
+// Without timestamps, identical dumps in a row are normally a process crashing
+// in a loop, so they are not duplicates unless their raw text is identical,
+// see rawInput.
+func isDuplicate(c, prev *stack.Snapshot) bool {
+	if prev == nil || len(c.Goroutines) == 0 || len(c.Goroutines) != len(prev.Goroutines) {
+		return false
+	}
+	if c.CapturedAt.IsZero() || prev.CapturedAt.IsZero() {
+		return false
+	}
+	if d := c.CapturedAt.Sub(prev.CapturedAt); d > duplicateWindow || d < -duplicateWindow {
+		return false
+	}
+	for i, g := range c.Goroutines {
+		p := prev.Goroutines[i]
+		if g.ID != p.ID || g.First != p.First || g.RaceWrite != p.RaceWrite || g.RaceAddr != p.RaceAddr || !g.Signature.EqualTo(&p.Signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxUnread is the number of bytes rawInput keeps before hashing them. It is
+// larger than what stack.ScanSnapshot can return as unread.
+const maxUnread = 64 * 1024
+
+// rawInput hashes the input as it is read, to compare the raw text of a
+// snapshot, including the lines printed before it since the previous one,
+// with the raw text of the previous snapshot.
+//
+// A log pipeline replaying lines delivers the exact same text twice while a
+// process crashing in a loop is normally different, e.g. by the lines it
+// printed before crashing.
+type rawInput struct {
+	h       hash.Hash
+	pending []byte
+}
+
+func newRawInput() *rawInput {
+	return &rawInput{h: sha256.New()}
+}
+
+func (r *rawInput) Write(p []byte) (int, error) {
+	r.pending = append(r.pending, p...)
+	if n := len(r.pending) - maxUnread; n > 0 {
+		_, _ = r.h.Write(r.pending[:n])
+		r.pending = append(r.pending[:0], r.pending[n:]...)
+	}
+	return len(p), nil
+}
+
+// sum returns the hash of the bytes read since the last call, excluding the
+// last unread ones which will be part of the next call.
+func (r *rawInput) sum(unread int) [sha256.Size]byte {
+	n := len(r.pending) - unread
+	if n < 0 {
+		n = 0
+	}
+	_, _ = r.h.Write(r.pending[:n])
+	r.pending = append(r.pending[:0], r.pending[n:]...)
+	var s [sha256.Size]byte
+	r.h.Sum(s[:0])
+	r.h.Reset()
+	return s
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	newSnapshot := func(state string, at time.Time) *stack.Snapshot {
+		return &stack.Snapshot{
+			Goroutines: []*stack.Goroutine{
+				{ID: 1, First: true, Signature: stack.Signature{State: state}},
+			},
+			CapturedAt: at,
+		}
+	}
+	data := []struct {
+		name string
+		prev *stack.Snapshot
+		want bool
+	}{
+		{"NoPrevious", nil, false},
+		{"Same", newSnapshot("running", now), true},
+		{"SameNoTimestamp", newSnapshot("running", time.Time{}), false},
+		{"State", newSnapshot("chan receive", now), false},
+		{"WithinWindow", newSnapshot("running", now.Add(-duplicateWindow)), true},
+		{"OutsideWindow", newSnapshot("running", now.Add(-duplicateWindow-time.Second)), false},
+		{"Empty", &stack.Snapshot{CapturedAt: now}, false},
+	}
+	for i, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			if got := isDuplicate(newSnapshot("running", now), line.prev); got != line.want {
+				t.Fatalf("#%d: got %t", i, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// The lifecycle events written by eventWriter.
+const (
+	eventScanningStarted    = "scanning-started"
+	eventSnapshotParsed     = "snapshot-parsed"
+	eventSnapshotRendered   = "snapshot-rendered"
+	eventPassthroughResumed = "passthrough-resumed"
+	eventError              = "error"
+)
+
+// event is one line of the -events-fd output.
+//
+// It is meant for the programs that run pp in a pipe to know what it is doing
+// without parsing its output.
+type event struct {
+	Event string `json:"event"`
+	// Snapshot is the index of the snapshot in the input, starting at 0. It is
+	// only set for the snapshot events.
+	Snapshot *int `json:"snapshot,omitempty"`
+
+	// The summary of the snapshot, only set for snapshot-parsed.
+	Goroutines      int    `json:"goroutines,omitempty"`
+	Race            bool   `json:"race,omitempty"`
+	PanicValue      string `json:"panic_value,omitempty"`
+	LikelyTruncated bool   `json:"likely_truncated,omitempty"`
+
+	// Error is only set for error.
+	Error string `json:"error,omitempty"`
+}
+
+// eventWriter writes one JSON object per line for each event.
+//
+// A nil eventWriter discards the events. Write errors are ignored, as the
+// events must not interrupt the processing of the input.
+type eventWriter struct {
+	e *json.Encoder
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	if w == nil {
+		return nil
+	}
+	return &eventWriter{e: json.NewEncoder(w)}
+}
+
+func (e *eventWriter) emit(ev *event) {
+	if e != nil {
+		_ = e.e.Encode(ev)
+	}
+}
+
+func (e *eventWriter) scanningStarted() {
+	e.emit(&event{Event: eventScanningStarted})
+}
+
+func (e *eventWriter) snapshotParsed(c *stack.Snapshot, index int) {
+	e.emit(&event{
+		Event:           eventSnapshotParsed,
+		Snapshot:        &index,
+		Goroutines:      len(c.Goroutines),
+		Race:            c.IsRace(),
+		PanicValue:      c.PanicValue,
+		LikelyTruncated: c.LikelyTruncated,
+	})
+}
+
+func (e *eventWriter) snapshotRendered(index int) {
+	e.emit(&event{Event: eventSnapshotRendered, Snapshot: &index})
+}
+
+func (e *eventWriter) passthroughResumed() {
+	e.emit(&event{Event: eventPassthroughResumed})
+}
+
+func (e *eventWriter) error(err error) {
+	e.emit(&event{Event: eventError, Error: err.Error()})
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestProcessEvents(t *testing.T) {
+	t.Parallel()
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	in = append(in, "more junk\n"...)
+	in = append(in, internaltest.StaticPanicwebOutput()...)
+	events := bytes.Buffer{}
+	r := DefaultRunOptions()
+	r.KeepDuplicates = true
+	r.Events = &events
+	if err := Run(r, bytes.NewReader(in), &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+		e := event{}
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatal(err)
+		}
+		s := e.Event
+		if e.Snapshot != nil {
+			s += " #" + strconv.Itoa(*e.Snapshot)
+		}
+		if e.Event == eventSnapshotParsed && e.Goroutines == 0 {
+			t.Fatalf("missing summary: %s", l)
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		eventScanningStarted,
+		eventSnapshotParsed + " #0",
+		eventSnapshotRendered + " #0",
+		eventPassthroughResumed,
+		eventSnapshotParsed + " #1",
+		eventSnapshotRendered + " #1",
+	}
+	compareString(t, strings.Join(want, "\n"), strings.Join(got, "\n"))
+}
+
+func TestProcessEventsError(t *testing.T) {
+	t.Parallel()
+	events := bytes.Buffer{}
+	r := DefaultRunOptions()
+	r.Events = &events
+	in := io.MultiReader(bytes.NewReader(internaltest.StaticPanicwebOutput()), iotest.ErrReader(errors.New("boom")))
+	if err := Run(r, in, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error")
+	}
+	lines := strings.Split(strings.TrimSpace(events.String()), "\n")
+	e := event{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Event != eventError || e.Error != "boom" {
+		t.Fatalf("unexpected last event: %s", lines[len(lines)-1])
+	}
+}
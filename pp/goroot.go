@@ -0,0 +1,279 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// gorootFinder finds the standard library sources of a Go version, to
+// analyze a stack trace produced by another version than the local one. It
+// implements stack.Opts.FindGOROOT.
+//
+// It looks in the local toolchain caches first, then downloads the sources
+// from mirror, if set.
+type gorootFinder struct {
+	// sdk is where golang.org/dl installs the toolchains, e.g. ~/sdk/go1.22.3.
+	sdk string
+	// modCache is the module cache, where the go command caches the
+	// toolchains it switches to, e.g.
+	// golang.org/toolchain@v0.0.1-go1.22.3.linux-amd64.
+	modCache string
+	// cache is where the downloaded sources are kept.
+	cache string
+	// mirror is the URL serving the source archives, e.g.
+	// https://dl.google.com/go/go1.22.3.src.tar.gz. No download is done when
+	// empty.
+	mirror string
+	client *http.Client
+}
+
+func newGOROOTFinder(mirror string, gopaths []string) *gorootFinder {
+	f := &gorootFinder{mirror: strings.TrimSuffix(mirror, "/"), client: &http.Client{Timeout: 10 * time.Minute}}
+	if home, err := os.UserHomeDir(); err == nil {
+		f.sdk = filepath.Join(home, "sdk")
+	}
+	if f.modCache = os.Getenv("GOMODCACHE"); f.modCache == "" && len(gopaths) != 0 {
+		f.modCache = filepath.Join(gopaths[0], "pkg", "mod")
+	}
+	if d, err := os.UserCacheDir(); err == nil {
+		f.cache = filepath.Join(d, "panicparse", "goroot")
+	}
+	return f
+}
+
+// find returns the GOROOT with the sources of version, with "/" as path
+// separator.
+//
+// version can be a language version like "go1.22", then the most recent
+// release found locally is used, or the first one is downloaded.
+func (f *gorootFinder) find(version string) (string, error) {
+	if strings.ContainsAny(version, `/\`) || !strings.HasPrefix(version, "go") {
+		return "", fmt.Errorf("invalid Go version %q", version)
+	}
+	var candidates []string
+	for _, d := range []struct{ base, name string }{
+		{f.sdk, "%s"},
+		{f.modCache, "golang.org/toolchain@v0.0.1-%s." + runtime.GOOS + "-" + runtime.GOARCH},
+		{f.cache, "%s"},
+	} {
+		if d.base == "" {
+			continue
+		}
+		pattern := filepath.Join(d.base, filepath.FromSlash(d.name))
+		candidates = append(candidates, fmt.Sprintf(pattern, version))
+		if isLanguageVersion(version) {
+			m, _ := filepath.Glob(fmt.Sprintf(pattern, version+".*"))
+			candidates = append(candidates, m...)
+		}
+	}
+	// A toolchain without VERSION file has a patch of -1.
+	best, bestPatch := "", -2
+	for _, c := range candidates {
+		if fi, err := os.Stat(filepath.Join(c, "src", "runtime")); err != nil || !fi.IsDir() {
+			continue
+		}
+		if p := goPatch(stack.GoVersionOf(filepath.ToSlash(c))); p > bestPatch {
+			best, bestPatch = c, p
+		}
+	}
+	if best != "" {
+		return filepath.ToSlash(best), nil
+	}
+	if f.mirror == "" || f.cache == "" {
+		return "", errors.New("not found in the local toolchain caches; use -goroot-mirror to download them")
+	}
+	release := version
+	if isLanguageVersion(version) {
+		if m, _ := strconv.Atoi(strings.TrimPrefix(version, "go1.")); m >= 21 {
+			// Since go1.21, the first release is go1.N.0.
+			release += ".0"
+		}
+	}
+	dst := filepath.Join(f.cache, release)
+	if err := f.download(release, dst); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(dst), nil
+}
+
+// download extracts the standard library sources of the release from the
+// mirror into dst.
+//
+// The archive is verified against the SHA-256 checksum published next to it,
+// e.g. https://dl.google.com/go/go1.22.3.src.tar.gz.sha256, before being
+// moved into the cache.
+func (f *gorootFinder) download(release, dst string) error {
+	url := f.mirror + "/" + release + ".src.tar.gz"
+	want, err := f.checksum(url + ".sha256")
+	if err != nil {
+		return err
+	}
+	log.Printf("Downloading %s", url)
+	resp, err := f.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err = os.MkdirAll(f.cache, 0o700); err != nil {
+		return err
+	}
+	// Extract in a temporary directory first so a partial or corrupted
+	// download is never used.
+	tmp, err := os.MkdirTemp(f.cache, release+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	h := sha256.New()
+	r := io.TeeReader(resp.Body, h)
+	if err = extractGOROOT(r, tmp); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	// Hash the rest of the archive, after the end of the tar stream.
+	if _, err = io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%s: SHA-256 mismatch, got %s, want %s", url, got, want)
+	}
+	if err = os.Rename(tmp, dst); err != nil && stack.GoVersionOf(filepath.ToSlash(dst)) == "" {
+		return err
+	}
+	// Another process may have won the race, which is fine.
+	return nil
+}
+
+// checksum returns the hex encoded SHA-256 checksum served at url, in the
+// format of the .sha256 files published along the Go archives: the checksum
+// optionally followed by the file name.
+func (f *gorootFinder) checksum(url string) (string, error) {
+	resp, err := f.client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s; the checksum is required to verify the download", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 || len(fields[0]) != 2*sha256.Size {
+		return "", fmt.Errorf("%s: invalid SHA-256 checksum", url)
+	}
+	if _, err = hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("%s: invalid SHA-256 checksum", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// extractGOROOT extracts the VERSION file and the src directory of a Go
+// source archive into dst.
+func extractGOROOT(r io.Reader, dst string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	found := false
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(h.Name)
+		if !strings.HasPrefix(name, "go/") {
+			continue
+		}
+		name = name[len("go/"):]
+		if name != "VERSION" && !strings.HasPrefix(name, "src/") {
+			continue
+		}
+		p := filepath.Join(dst, filepath.FromSlash(name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(p, 0o700)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(p), 0o700); err == nil {
+				err = writeFile(p, tr)
+			}
+			found = found || name == "VERSION"
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return errors.New("not a Go source archive")
+	}
+	return nil
+}
+
+func writeFile(p string, r io.Reader) error {
+	/* #nosec G304 */
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// isLanguageVersion returns true for a version like "go1.22", without the
+// patch number.
+func isLanguageVersion(v string) bool {
+	if !strings.HasPrefix(v, "go1.") {
+		return false
+	}
+	_, err := strconv.Atoi(v[len("go1."):])
+	return err == nil
+}
+
+// goPatch returns the patch number of a release like "go1.22.3", 0 for a
+// release like "go1.20" and -1 for a pre-release or an unknown version.
+func goPatch(v string) int {
+	parts := strings.Split(v, ".")
+	switch len(parts) {
+	case 2:
+		if _, err := strconv.Atoi(parts[1]); err == nil {
+			return 0
+		}
+	case 3:
+		if p, err := strconv.Atoi(parts[2]); err == nil {
+			return p
+		}
+	}
+	return -1
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGOROOTFinder_Local(t *testing.T) {
+	t.Parallel()
+	sdk := t.TempDir()
+	for _, v := range []string{"go1.22.1", "go1.22.3", "go1.23.0"} {
+		newToolchain(t, filepath.Join(sdk, v), v)
+	}
+	f := &gorootFinder{sdk: sdk}
+	data := []struct {
+		version string
+		want    string
+	}{
+		// The most recent release of the language version.
+		{"go1.22", "go1.22.3"},
+		{"go1.22.1", "go1.22.1"},
+		{"go1.23", "go1.23.0"},
+	}
+	for _, line := range data {
+		got, err := f.find(line.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.ToSlash(filepath.Join(sdk, line.want)); got != want {
+			t.Fatalf("find(%q) = %q, want %q", line.version, got, want)
+		}
+	}
+	if _, err := f.find("go1.21.0"); err == nil || err.Error() != "not found in the local toolchain caches; use -goroot-mirror to download them" {
+		t.Fatal(err)
+	}
+	if _, err := f.find("../go1.22.1"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGOROOTFinder_Download(t *testing.T) {
+	t.Parallel()
+	archive := newArchive(t, map[string]string{
+		"go/VERSION":           "go1.23.0\ntime 2024-08-13T17:00:00Z\n",
+		"go/src/runtime/pp.go": "package runtime\n",
+		"go/misc/ignored.txt":  "ignored",
+		"go/../escape.txt":     "ignored",
+	})
+	h := sha256.Sum256(archive)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch req.URL.Path {
+		case "/dl/go1.23.0.src.tar.gz", "/dl/go1.23.1.src.tar.gz":
+			_, _ = w.Write(archive)
+		case "/dl/go1.23.0.src.tar.gz.sha256":
+			_, _ = w.Write([]byte(hex.EncodeToString(h[:]) + "  go1.23.0.src.tar.gz\n"))
+		case "/dl/go1.23.1.src.tar.gz.sha256":
+			// Corrupted.
+			_, _ = w.Write([]byte(strings.Repeat("0", 64)))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer ts.Close()
+	cache := t.TempDir()
+	f := &gorootFinder{cache: cache, mirror: ts.URL + "/dl", client: ts.Client()}
+	for i := 0; i < 2; i++ {
+		// The language version is the first release.
+		got, err := f.find("go1.23")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.ToSlash(filepath.Join(cache, "go1.23.0")); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	// The checksum then the archive, the second one is served from the cache.
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("%d requests", n)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "go1.23.0", "src", "runtime", "pp.go")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(cache, "go1.23.0", "misc"), filepath.Join(cache, "escape.txt")} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	if _, err := f.find("go1.24.1"); err == nil || err.Error() != ts.URL+"/dl/go1.24.1.src.tar.gz.sha256 returned 404 Not Found; the checksum is required to verify the download" {
+		t.Fatal(err)
+	}
+	// An archive not matching its checksum is not kept.
+	if _, err := f.find("go1.23.1"); err == nil || !strings.Contains(err.Error(), "SHA-256 mismatch") {
+		t.Fatal(err)
+	}
+	if entries, err := os.ReadDir(cache); err != nil || len(entries) != 1 {
+		t.Fatalf("%v, %v", entries, err)
+	}
+}
+
+func TestGoPatch(t *testing.T) {
+	t.Parallel()
+	data := map[string]int{"go1.22.3": 3, "go1.20": 0, "go1.23rc1": -1, "devel": -1, "": -1}
+	for v, want := range data {
+		if got := goPatch(v); got != want {
+			t.Errorf("goPatch(%q) = %d, want %d", v, got, want)
+		}
+	}
+}
+
+// newToolchain creates a GOROOT with a VERSION file and a src/runtime
+// directory.
+func newToolchain(t *testing.T, dir, version string) {
+	if err := os.MkdirAll(filepath.Join(dir, "src", "runtime"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "VERSION"), []byte(version), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newArchive returns a .tar.gz with files.
+func newArchive(t *testing.T, files map[string]string) []byte {
+	b := bytes.Buffer{}
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
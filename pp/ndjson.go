@@ -0,0 +1,91 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// frameRow is one row of the NDJSON output, one per frame of each goroutine.
+//
+// The columns are flat to be directly ingested in analytics databases like
+// BigQuery or ClickHouse.
+type frameRow struct {
+	// Snapshot is the index of the snapshot in the input, starting at 0.
+	Snapshot      int    `json:"snapshot"`
+	Goroutine     int    `json:"goroutine"`
+	State         string `json:"state"`
+	SignatureHash string `json:"signature_hash"`
+	// Frame is the index of the call in the stack, 0 being the innermost.
+	Frame      int    `json:"frame"`
+	Func       string `json:"func"`
+	ImportPath string `json:"import_path"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Location   string `json:"location"`
+	// Module is the path of the go module the call belongs to, when it could
+	// be resolved from the module cache path or the local go.mod files.
+	Module string `json:"module"`
+	// ModuleVersion is empty for a main module.
+	ModuleVersion string `json:"module_version"`
+	// Dependency is one of "main module", "direct dependency" or "indirect
+	// dependency", see stack.Module.Dependency.
+	Dependency string `json:"dependency"`
+	// Args is the arguments rebuilt from the sources, as printed on the
+	// console. It is empty when the sources were not processed.
+	Args string `json:"args"`
+	// ArgsConfidence is the comma separated confidence of each argument in
+	// Args, each one of "exact", "inferred" or "uncertain".
+	ArgsConfidence string `json:"args_confidence"`
+}
+
+// writeNDJSON writes one JSON object per line for each frame of each
+// goroutine in the snapshot.
+func writeNDJSON(out io.Writer, c *stack.Snapshot, index int) error {
+	e := json.NewEncoder(out)
+	for _, g := range c.Goroutines {
+		h := g.Hash()
+		for i := range g.Stack.Calls {
+			call := &g.Stack.Calls[i]
+			r := frameRow{
+				Snapshot:      index,
+				Goroutine:     g.ID,
+				State:         g.State,
+				SignatureHash: h,
+				Frame:         i,
+				Func:          call.Func.Complete,
+				ImportPath:    call.ImportPath,
+				File:          call.RemoteSrcPath,
+				Line:          call.Line,
+				Location:      call.Location.String(),
+			}
+			if m := call.Module; m != nil {
+				r.Module = m.Path
+				r.ModuleVersion = m.Version
+				r.Dependency = m.Dependency()
+			}
+			if len(call.Args.Processed) != 0 {
+				r.Args = call.Args.String()
+				c := make([]string, len(call.Args.Confidence))
+				for j, v := range call.Args.Confidence {
+					b, err := v.MarshalText()
+					if err != nil {
+						return err
+					}
+					c[j] = string(b)
+				}
+				r.ArgsConfidence = strings.Join(c, ",")
+			}
+			if err := e.Encode(&r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessNDJSON(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	in = append(in, internaltest.StaticPanicwebOutput()...)
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, ndjson: true}
+	if err := process(bytes.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines)%2 != 0 {
+		t.Fatalf("expected the same number of rows for both snapshots, got %d", len(lines))
+	}
+	var first, last frameRow
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	want := frameRow{
+		Snapshot:      0,
+		Goroutine:     135,
+		State:         "running",
+		SignatureHash: first.SignatureHash,
+		Frame:         0,
+		Func:          "runtime/pprof.writeGoroutineStacks",
+		ImportPath:    "runtime/pprof",
+		File:          "/goroot/src/runtime/pprof/pprof.go",
+		Line:          665,
+		Location:      "LocationUnknown",
+	}
+	if first != want {
+		t.Fatalf("unexpected %#v", first)
+	}
+	if last.Snapshot != 1 || len(first.SignatureHash) != 16 {
+		t.Fatalf("unexpected %#v", last)
+	}
+}
+
+func TestWriteNDJSONModule(t *testing.T) {
+	t.Parallel()
+	c := &stack.Snapshot{
+		Goroutines: []*stack.Goroutine{
+			{
+				Signature: stack.Signature{
+					State: "running",
+					Stack: stack.Stack{
+						Calls: []stack.Call{
+							{
+								Func:          stack.Func{Complete: "example.com/dep.Foo"},
+								RemoteSrcPath: "/gopath/pkg/mod/example.com/dep@v1.2.3/foo.go",
+								Line:          12,
+								Location:      stack.GoPkg,
+								Module:        &stack.Module{Path: "example.com/dep", Version: "v1.2.3", Direct: true},
+								Args: stack.Args{
+									Processed:  []string{"1", "string(nil)"},
+									Confidence: []stack.Confidence{stack.Exact, stack.Uncertain},
+								},
+							},
+						},
+					},
+				},
+				ID: 1,
+			},
+		},
+	}
+	out := bytes.Buffer{}
+	if err := writeNDJSON(&out, c, 0); err != nil {
+		t.Fatal(err)
+	}
+	var got frameRow
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Module != "example.com/dep" || got.ModuleVersion != "v1.2.3" || got.Dependency != "direct dependency" {
+		t.Fatalf("unexpected %#v", got)
+	}
+	if got.Args != "1, string(nil)" || got.ArgsConfidence != "exact,uncertain" {
+		t.Fatalf("unexpected %#v", got)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// locationNames is the short name of each stack.Location, as in the usage.
+var locationNames = map[stack.Location]string{
+	stack.LocationUnknown: "<unknown>",
+	stack.GoMod:           "go.mod",
+	stack.GOPATH:          "$GOPATH/src",
+	stack.GoPkg:           "$GOPATH/pkg/mod",
+	stack.Stdlib:          "$GOROOT/src",
+}
+
+// writePackagesToConsole prints one line per package found in the stacks of
+// c with the number of goroutines and calls in it, its location and its
+// import path.
+func writePackagesToConsole(out io.Writer, p *render.Palette, c *stack.Snapshot) error {
+	pkgs := c.Packages()
+	gLen, fLen, lLen := len("goroutines"), len("frames"), len("location")
+	for i := range pkgs {
+		if l := len(strconv.Itoa(pkgs[i].Goroutines)); l > gLen {
+			gLen = l
+		}
+		if l := len(strconv.Itoa(pkgs[i].Frames)); l > fLen {
+			fLen = l
+		}
+		if l := len(locationNames[pkgs[i].Location]); l > lLen {
+			lLen = l
+		}
+	}
+	if _, err := fmt.Fprintf(out, "%*s %*s %-*s %s\n", gLen, "goroutines", fLen, "frames", lLen, "location", "package"); err != nil {
+		return err
+	}
+	for i := range pkgs {
+		if _, err := fmt.Fprintf(
+			out, "%*d %*d %-*s %s%s%s\n",
+			gLen, pkgs[i].Goroutines,
+			fLen, pkgs[i].Frames,
+			lLen, locationNames[pkgs[i].Location],
+			p.Package, pkgs[i].ImportPath,
+			p.EOLReset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
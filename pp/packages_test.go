@@ -0,0 +1,42 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessPackages(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, packages: true}
+	if err := process(bytes.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	// The paths are not rebased, so the location is not known.
+	want := "junk\n" +
+		"goroutines frames location  package\n" +
+		"        68    150 <unknown> net/http\n" +
+		"        28    112 <unknown> internal/poll\n" +
+		"        28     57 <unknown> net\n" +
+		"        26     26 <unknown> github.com/maruel/panicparse/cmd/panicweb/internal\n" +
+		"        13     13 <unknown> bufio\n" +
+		"        13     13 <unknown> bytes\n" +
+		"        13     13 <unknown> io\n" +
+		"        13     26 <unknown> io/ioutil\n" +
+		"         3      4 <unknown> main\n" +
+		"         1      1 <unknown> github.com/mattn/go-colorable\n" +
+		"         1      1 <unknown> golang.org/x/sys/unix\n" +
+		"         1      2 <unknown> net/http/pprof\n" +
+		"         1      3 <unknown> runtime/pprof\n" +
+		"         1      1 <unknown> syscall\n"
+	compareString(t, want, out.String())
+}
@@ -0,0 +1,617 @@
+// Copyright 2015 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package pp processes stack traces exactly like the pp command does, so
+// other executables can embed it instead of running pp.
+//
+// It is mostly useful on servers will large number of identical goroutines,
+// making the crash dump harder to read than strictly necessary.
+//
+// Colors:
+//   - Magenta: first goroutine to be listed.
+//   - Yellow: main package.
+//   - Green: standard library.
+//   - Red: other packages.
+//
+// Bright colors are used for exported symbols.
+package pp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maruel/panicparse/v2/internal/timestamp"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+	"github.com/maruel/panicparse/v2/stack/render"
+	"github.com/mgutz/ansi"
+)
+
+// resetFG is similar to ansi.Reset except that it doesn't reset the
+// background color, only the foreground color and the style.
+//
+// That much for the "ansi" abstraction layer...
+const resetFG = ansi.DefaultFG + "\033[m"
+
+// defaultPalette is the default recommended palette.
+var defaultPalette = render.Palette{
+	EOLReset:                    resetFG,
+	RoutineFirst:                ansi.ColorCode("magenta+b"),
+	CreatedBy:                   ansi.LightBlack,
+	Race:                        ansi.LightRed,
+	Deadlock:                    ansi.ColorCode("yellow+b:red"),
+	Package:                     ansi.ColorCode("default+b"),
+	SrcFile:                     resetFG,
+	FuncMain:                    ansi.ColorCode("yellow+b"),
+	FuncLocationUnknown:         ansi.White,
+	FuncLocationUnknownExported: ansi.ColorCode("white+b"),
+	FuncGoMod:                   ansi.Red,
+	FuncGoModExported:           ansi.ColorCode("red+b"),
+	FuncGOPATH:                  ansi.Cyan,
+	FuncGOPATHExported:          ansi.ColorCode("cyan+b"),
+	FuncGoPkg:                   ansi.Blue,
+	FuncGoPkgExported:           ansi.ColorCode("blue+b"),
+	FuncStdLib:                  ansi.Green,
+	FuncStdLibExported:          ansi.ColorCode("green+b"),
+	Arguments:                   resetFG,
+	FuncPanicking:               ansi.ColorCode("white+b:red"),
+	FuncAbovePanic:              ansi.Magenta,
+}
+
+// processOpts are the options to process and print out a stack trace.
+type processOpts struct {
+	palette    *render.Palette
+	similarity stack.Similarity
+	pf         render.PathFormat
+	// parse enables parsing the sources to deduct types.
+	parse bool
+	// rebase enables guessing GOROOT and GOPATH.
+	rebase bool
+	// html is the file to write the HTML output to.
+	html string
+	// htmlTree organizes the goroutines by creator in the HTML output.
+	htmlTree bool
+	// htmlModules annotates the calls in the HTML output with their module.
+	htmlModules bool
+	// json is the file to write the JSON output to.
+	json string
+	// dot is the file to write a GraphViz graph to.
+	dot string
+	// sarif is the file to write the crash to as a SARIF log.
+	sarif string
+	// otlp is the file to write the crash to as an OTLP log record.
+	otlp string
+	// sinks are the outputs each snapshot is written to. The console is used
+	// when empty.
+	sinks  []sink
+	filter *regexp.Regexp
+	match  *regexp.Regexp
+	// grep only prints the calls matching this regexp, with context.
+	grep *regexp.Regexp
+	// showM prints the OS thread (m) ids in the headers.
+	showM bool
+	// verboseHeaders prints the raw runtime values of the goroutine headers.
+	verboseHeaders bool
+	// onlyFirst stops processing after the first snapshot.
+	onlyFirst bool
+	// lo are the options to print the calls.
+	lo render.LineOpts
+	// mID only keeps goroutines running on this OS thread when not -1.
+	mID int
+	// mLabels only keeps goroutines with these pprof labels.
+	mLabels map[string]string
+	// siem is the SIEM event format to output instead of the stack traces, if
+	// any.
+	siem string
+	// siemHost is the host name to report in SIEM events.
+	siemHost string
+	// ndjson outputs one JSON object per frame instead of the stack traces.
+	// The rest of the input is not passed through.
+	ndjson bool
+	// createdBy prints the creation sites of the goroutines instead of the
+	// stack traces.
+	createdBy bool
+	// packages prints the packages found in the stacks instead of the stack
+	// traces.
+	packages bool
+	// threads prints the goroutines grouped by OS thread instead of the
+	// buckets.
+	threads bool
+	// tree prints the buckets organized by which goroutine created them.
+	tree bool
+	// minCount collapses the buckets with less goroutines into a single one.
+	minCount int
+	// binary is the executable that crashed, to expand the inlined calls.
+	binary string
+	// goVersion is the Go version that produced the stack trace, if known.
+	goVersion string
+	// gorootMirror is the URL to download the standard library sources from
+	// when they don't match the local version.
+	gorootMirror string
+	// teeRaw is the file to append the input to, as read.
+	teeRaw string
+	// teeRawMaxSize is the size at which teeRaw is rotated. 0 means never.
+	teeRawMaxSize int64
+	// events receives the lifecycle events, if set.
+	events *eventWriter
+	// track annotates the buckets with the goroutines that existed in the
+	// previous snapshot of the input.
+	track bool
+	// showTotals prints the index of each bucket and how many goroutines are
+	// shown out of the total.
+	showTotals bool
+	// keepDuplicates processes a snapshot identical to the previous one
+	// instead of skipping it.
+	keepDuplicates bool
+	// share uploads the anonymized snapshots instead of printing them, if set.
+	share uploader
+	// deterministic zeroes the pointers and renumbers the goroutines so two
+	// identical crashes produce the same output.
+	deterministic bool
+	// maxMemory is the budget in bytes of the goroutines kept in memory; the
+	// rest is spilled to disk. 0 means no limit.
+	maxMemory int64
+	// follow is set when the input is a live stream, e.g. stdin or the output
+	// of pp watch, to print how long ago each snapshot was captured.
+	follow bool
+}
+
+func writeBucketsToConsole(out io.Writer, o *processOpts, a *stack.Aggregated, needsEnv bool) error {
+	if needsEnv {
+		_, _ = io.WriteString(out, "\nTo see all goroutines, visit https://github.com/maruel/panicparse#gotraceback\n\n")
+	}
+	p := o.palette
+	if s := a.StackOverflowSummary(); s != "" {
+		_, _ = io.WriteString(out, p.Race+s+p.EOLReset+"\n\n")
+	}
+	srcLen, pkgLen := render.Measure(a, o.pf)
+	multi := len(a.Buckets) > 1
+	shownBuckets, shownGoroutines := 0, 0
+	for i, e := range a.Buckets {
+		var ms []int
+		if o.showM {
+			ms = bucketThreads(a.Snapshot, e)
+		}
+		header := p.BucketHeader(e, o.pf, multi, ms)
+		if o.filter != nil && o.filter.MatchString(header) {
+			continue
+		}
+		if o.match != nil && !o.match.MatchString(header) {
+			continue
+		}
+		if o.showTotals {
+			shownBuckets++
+			shownGoroutines += len(e.IDs)
+			fmt.Fprintf(out, "bucket %d/%d: ", i+1, len(a.Buckets))
+		}
+		_, _ = io.WriteString(out, header)
+		if o.verboseHeaders {
+			writeRuntimeInfo(out, p, a.Snapshot, e)
+		}
+		_, _ = io.WriteString(out, p.StackLines(&e.Signature, srcLen, pkgLen, o.pf, &o.lo, e.First))
+	}
+	if a.Previous != nil {
+		fmt.Fprintf(out, "%d goroutines gone since the previous snapshot\n", len(a.Gone))
+	}
+	if o.showTotals {
+		fmt.Fprintf(out, "%d of %d goroutines shown, %d of %d buckets\n", shownGoroutines, a.TotalGoroutines, shownBuckets, a.TotalBuckets)
+	}
+	return nil
+}
+
+// writeRuntimeInfo prints the raw runtime header values of each goroutine in
+// the bucket.
+func writeRuntimeInfo(out io.Writer, p *render.Palette, s *stack.Snapshot, b *stack.Bucket) {
+	ids := make(map[int]bool, len(b.IDs))
+	for _, id := range b.IDs {
+		ids[id] = true
+	}
+	for _, g := range s.Goroutines {
+		if ids[g.ID] {
+			_, _ = io.WriteString(out, p.RuntimeInfoLine(g))
+		}
+	}
+}
+
+// writeGoroutinesToConsole prints each goroutine of s.
+//
+// total is the number of goroutines before filtering by thread or labels.
+func writeGoroutinesToConsole(out io.Writer, o *processOpts, s *stack.Snapshot, total int, needsEnv bool) error {
+	if needsEnv {
+		_, _ = io.WriteString(out, "\nTo see all goroutines, visit https://github.com/maruel/panicparse#gotraceback\n\n")
+	}
+	p := o.palette
+	srcLen, pkgLen := render.MeasureGoroutines(s, o.pf)
+	multi := len(s.Goroutines) > 1
+	shown := 0
+	for i, e := range s.Goroutines {
+		header := p.GoroutineHeader(e, o.pf, multi, o.showM, o.verboseHeaders)
+		if o.filter != nil && o.filter.MatchString(header) {
+			continue
+		}
+		if o.match != nil && !o.match.MatchString(header) {
+			continue
+		}
+		if o.showTotals {
+			shown++
+			fmt.Fprintf(out, "goroutine %d/%d: ", i+1, len(s.Goroutines))
+		}
+		_, _ = io.WriteString(out, header)
+		_, _ = io.WriteString(out, p.StackLines(&e.Signature, srcLen, pkgLen, o.pf, &o.lo, e.First))
+	}
+	if o.showTotals {
+		fmt.Fprintf(out, "%d of %d goroutines shown\n", shown, total)
+	}
+	return nil
+}
+
+// writeGrepToConsole only prints the calls matching o.grep, once per bucket,
+// along with the goroutine count and IDs.
+func writeGrepToConsole(out io.Writer, o *processOpts, a *stack.Aggregated) error {
+	p := o.palette
+	srcLen, pkgLen := render.Measure(a, o.pf)
+	multi := len(a.Buckets) > 1
+	for _, e := range a.Buckets {
+		lines := p.GrepLines(&e.Signature, o.grep, srcLen, pkgLen, o.pf, &o.lo)
+		if lines == "" {
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "%s%d: %s [goroutine %s]%s\n", p.RoutineColor(e.First, multi), len(e.IDs), e.State, joinIDs(e.IDs), p.EOLReset)
+		_, _ = io.WriteString(out, lines)
+	}
+	return nil
+}
+
+// raceBuckets returns one bucket per goroutine, as goroutines in a data race
+// must not be aggregated.
+func raceBuckets(s *stack.Snapshot) []*stack.Bucket {
+	out := make([]*stack.Bucket, len(s.Goroutines))
+	for i, g := range s.Goroutines {
+		out[i] = &stack.Bucket{Signature: g.Signature, IDs: []int{g.ID}, First: g.First}
+	}
+	return out
+}
+
+// joinIDs returns the goroutine IDs as a comma separated list, eliding after
+// a few.
+func joinIDs(ids []int) string {
+	const max = 10
+	var s []string
+	for i, id := range ids {
+		if i == max {
+			s = append(s, "…")
+			break
+		}
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+// bucketThreads returns the sorted OS thread ids the goroutines in the bucket
+// are running on.
+func bucketThreads(s *stack.Snapshot, b *stack.Bucket) []int {
+	ids := make(map[int]bool, len(b.IDs))
+	for _, id := range b.IDs {
+		ids[id] = true
+	}
+	seen := map[int]bool{}
+	var ms []int
+	for _, g := range s.Goroutines {
+		if ids[g.ID] && g.MP != 0 && !seen[g.M] {
+			seen[g.M] = true
+			ms = append(ms, g.M)
+		}
+	}
+	sort.Ints(ms)
+	return ms
+}
+
+// filterThread only keeps the goroutines running on OS thread m.
+func filterThread(s *stack.Snapshot, m int) {
+	out := s.Goroutines[:0]
+	for _, g := range s.Goroutines {
+		if g.MP != 0 && g.M == m {
+			out = append(out, g)
+		}
+	}
+	s.Goroutines = out
+}
+
+// filterLabels only keeps the goroutines with all the labels.
+func filterLabels(s *stack.Snapshot, labels map[string]string) {
+	out := s.Goroutines[:0]
+	for _, g := range s.Goroutines {
+		keep := true
+		for k, v := range labels {
+			if w, ok := g.Labels[k]; !ok || w != v {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, g)
+		}
+	}
+	s.Goroutines = out
+}
+
+// parseLabels parses "key=value,key2=value2".
+func parseLabels(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", kv)
+		}
+		out[kv[:i]] = kv[i+1:]
+	}
+	return out, nil
+}
+
+type toHTMLer interface {
+	ToHTML(io.Writer, template.HTML) error
+}
+
+// treeHTML renders the goroutine creation tree.
+type treeHTML struct {
+	*stack.Aggregated
+}
+
+func (t treeHTML) ToHTML(w io.Writer, footer template.HTML) error {
+	return t.ToHTMLTree(w, footer)
+}
+
+func toDot(a *stack.Aggregated, p string) error {
+	/* #nosec G304 */
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = a.ToDot(f)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func toHTML(h toHTMLer, p string, needsEnv bool, findings []analyzer.Finding) error {
+	/* #nosec G304 */
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	footer := findingsHTML(findings)
+	if needsEnv {
+		footer += "To see all goroutines, visit <a href=https://github.com/maruel/panicparse#gotraceback>github.com/maruel/panicparse</a>"
+	}
+	err = h.ToHTML(f, footer)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// processInner processes the snapshot c, which is the index-th found in the
+// input.
+//
+// prev is the previous snapshot found in the same input, if any. It is used
+// to tell which goroutines already existed.
+func processInner(out io.Writer, o *processOpts, c, prev *stack.Snapshot, index int) error {
+	log.Printf("GOROOT=%s", c.RemoteGOROOT)
+	log.Printf("GOPATH=%s", c.RemoteGOPATHs)
+	if c.LocalGOROOT != "" {
+		log.Printf("Standard library sources from %s", c.LocalGOROOT)
+	}
+	for _, w := range c.Warnings {
+		log.Printf("Warning: %s", w)
+	}
+	needsEnv := len(c.Goroutines) == 1 && showBanner()
+	total := len(c.Goroutines)
+	if o.mID != -1 {
+		if filterThread(c, o.mID); len(c.Goroutines) == 0 {
+			return nil
+		}
+	}
+	if len(o.mLabels) != 0 {
+		if filterLabels(c, o.mLabels); len(c.Goroutines) == 0 {
+			return nil
+		}
+	}
+	if o.deterministic {
+		makeDeterministic(c)
+	}
+	if o.siem != "" {
+		return writeSIEM(out, o.siem, newSIEMEvent(c, o.siemHost))
+	}
+	if o.ndjson {
+		return writeNDJSON(out, c, index)
+	}
+	if o.createdBy {
+		return writeCreatedByToConsole(out, o.palette, o.pf, c)
+	}
+	if o.packages {
+		return writePackagesToConsole(out, o.palette, c)
+	}
+	if o.threads {
+		return writeThreadsToConsole(out, o, c)
+	}
+	findings := analyzer.Run(c)
+	if o.share != nil {
+		return shareSnapshot(out, o.share, c, o.similarity, findings)
+	}
+	r := &output{c: c, total: total, needsEnv: needsEnv, findings: findings}
+	// Bucketing should only be done if no data race was detected.
+	if !c.IsRace() {
+		r.a = c.Aggregate(o.similarity)
+		r.a.TotalGoroutines = total
+		if o.minCount > 1 {
+			r.a = r.a.Prune(o.minCount, nil)
+		}
+		if o.track && prev != nil && !prev.IsRace() {
+			r.a.Track(prev)
+		}
+		r.a.Analyze(nil)
+	}
+	return writeOutput(out, o, r)
+}
+
+// processAggregated processes a snapshot aggregated without its goroutines,
+// as done by processSpill.
+func processAggregated(out io.Writer, o *processOpts, a *stack.Aggregated) error {
+	if o.minCount > 1 {
+		a = a.Prune(o.minCount, nil)
+	}
+	a.Analyze(nil)
+	return writeOutput(out, o, &output{c: a.Snapshot, a: a, total: a.TotalGoroutines})
+}
+
+// writeOutput writes the processed snapshot to each sink.
+func writeOutput(out io.Writer, o *processOpts, r *output) error {
+	sinks := o.sinks
+	if len(sinks) == 0 {
+		sinks = []sink{consoleSink}
+	}
+	var err error
+	for _, s := range sinks {
+		if err1 := s(out, o, r); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// capturedAgo returns how long before now t was, rounded to the second.
+func capturedAgo(t, now time.Time) time.Duration {
+	d := now.Sub(t).Truncate(time.Second)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// ErrPanicFound is returned by Run when RunOptions.OnlyFirst is set and a stack
+// trace was found.
+var ErrPanicFound = errors.New("panic found")
+
+// process copies stdin to stdout and processes any "panic: " line found.
+//
+// If o.html is used, a stack trace is written to this file instead.
+func process(in io.Reader, out io.Writer, o *processOpts) (err error) {
+	o.events.scanningStarted()
+	defer func() {
+		if err != nil && err != ErrPanicFound {
+			o.events.error(err)
+		}
+	}()
+	if o.teeRaw != "" {
+		tee, err := openRotatingFile(o.teeRaw, o.teeRawMaxSize)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err2 := tee.Close(); err == nil {
+				err = err2
+			}
+		}()
+		in = io.TeeReader(in, tee)
+	}
+	opts := stack.DefaultOpts()
+	if !o.rebase {
+		opts.GuessPaths = false
+		opts.AnalyzeSources = false
+	}
+	if !o.parse {
+		opts.AnalyzeSources = false
+	}
+	opts.ArgsLimits = o.lo.ArgsLimits
+	opts.Binary = o.binary
+	opts.GoVersion = o.goVersion
+	opts.FindGOROOT = newGOROOTFinder(o.gorootMirror, opts.LocalGOPATHs).find
+	opts.ResolveModules = (o.htmlModules || o.ndjson) && opts.GuessPaths
+	if o.maxMemory > 0 {
+		return processSpill(in, out, o, opts)
+	}
+	// Anything that is not a stack trace is passed through, unless the output
+	// is meant to be machine readable.
+	passthrough := out
+	if o.ndjson {
+		passthrough = io.Discard
+	}
+	tw := timestamp.NewWriter(passthrough)
+	raw := newRawInput()
+	in = io.TeeReader(in, raw)
+	// prev is the previous snapshot as processed, last is as parsed.
+	var prev, last *stack.Snapshot
+	var lastRaw [sha256.Size]byte
+	for index := 0; ; {
+		c, suffix, err := stack.ScanSnapshot(in, tw, opts)
+		if c != nil {
+			if c.CapturedAt.IsZero() {
+				c.CapturedAt = tw.Last
+			}
+			sum := raw.sum(len(suffix))
+			sameRaw := last != nil && sum == lastRaw
+			lastRaw = sum
+			if !o.keepDuplicates && (sameRaw || isDuplicate(c, last)) {
+				log.Printf("Skipping duplicate snapshot #%d", index)
+				if _, err1 := io.WriteString(passthrough, "(duplicate of previous dump)\n"); err == nil {
+					err = err1
+				}
+			} else {
+				// processInner filters the goroutines in place.
+				s := *c
+				s.Goroutines = append([]*stack.Goroutine(nil), c.Goroutines...)
+				last = &s
+				o.events.snapshotParsed(c, index)
+				// Process it even if an error occurred.
+				err1 := processInner(out, o, c, prev, index)
+				if err1 == nil {
+					o.events.snapshotRendered(index)
+				} else if err == nil {
+					err = err1
+				}
+				prev = c
+				index++
+				if o.onlyFirst && (err == nil || err == io.EOF) {
+					// Do not pass through the rest of the stream.
+					return ErrPanicFound
+				}
+			}
+		}
+		if err == nil {
+			if c != nil {
+				o.events.passthroughResumed()
+			}
+			// This means the whole buffer was not read, loop again.
+			in = io.MultiReader(bytes.NewReader(suffix), in)
+			continue
+		}
+		if len(suffix) != 0 {
+			if _, err1 := passthrough.Write(suffix); err == nil {
+				err = err1
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		// Parts of the input will be lost.
+		return err
+	}
+}
+
+func showBanner() bool {
+	gtb := os.Getenv("GOTRACEBACK")
+	return gtb == "" || gtb == "single"
+}
@@ -0,0 +1,405 @@
+// Copyright 2015 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+	d, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []struct {
+		name    string
+		palette *render.Palette
+		simil   stack.Similarity
+		path    render.PathFormat
+		filter  *regexp.Regexp
+		match   *regexp.Regexp
+		want    string
+	}{
+		{
+			name:    "BasePath",
+			palette: testPalette,
+			simil:   stack.AnyPointer,
+			path:    render.BasePath,
+			want:    "GOTRACEBACK=all\npanic: simple\n\nC1: runningA\n    Emain Fmain.go:74 GmainR()A\nParsed as go1 dialect\n",
+		},
+		{
+			name:    "FullPath",
+			palette: testPalette,
+			simil:   stack.AnyValue,
+			path:    render.FullPath,
+			// "/" is used even on Windows.
+			want: fmt.Sprintf("GOTRACEBACK=all\npanic: simple\n\nC1: runningA\n    Emain F%s:74 GmainR()A\nParsed as go1 dialect\n", strings.Replace(filepath.Join(filepath.Dir(d), "cmd", "panic", "main.go"), "\\", "/", -1)),
+		},
+		{
+			name:    "NoColor",
+			palette: &render.Palette{},
+			simil:   stack.AnyValue,
+			path:    render.BasePath,
+			want:    "GOTRACEBACK=all\npanic: simple\n\n1: running\n    main main.go:74 main()\nParsed as go1 dialect\n",
+		},
+		{
+			name:    "Match",
+			palette: testPalette,
+			simil:   stack.AnyValue,
+			path:    render.BasePath,
+			match:   regexp.MustCompile(`notpresent`),
+			want:    "GOTRACEBACK=all\npanic: simple\n\nParsed as go1 dialect\n",
+		},
+		{
+			name:    "Filter",
+			palette: testPalette,
+			simil:   stack.AnyValue,
+			path:    render.BasePath,
+			filter:  regexp.MustCompile(`notpresent`),
+			want:    "GOTRACEBACK=all\npanic: simple\n\nC1: runningA\n    Emain Fmain.go:74 GmainR()A\nParsed as go1 dialect\n",
+		},
+	}
+	for i, line := range data {
+		line := line
+		t.Run(fmt.Sprintf("%d-%s", i, line.name), func(t *testing.T) {
+			t.Parallel()
+			out := bytes.Buffer{}
+			r := bytes.NewReader(internaltest.PanicOutputs()["simple"])
+			o := processOpts{
+				palette:    line.palette,
+				similarity: line.simil,
+				pf:         line.path,
+				rebase:     true,
+				filter:     line.filter,
+				match:      line.match,
+				mID:        -1,
+			}
+			if err := process(r, &out, &o); err != nil {
+				t.Fatal(err)
+			}
+			compareString(t, line.want, out.String())
+		})
+	}
+}
+
+func TestThreads(t *testing.T) {
+	t.Parallel()
+	s := &stack.Snapshot{
+		Goroutines: []*stack.Goroutine{
+			{ID: 1, M: 3, MP: 0x1000},
+			{ID: 2},
+			{ID: 3, M: 0, MP: 0x2000},
+			{ID: 4, M: 3, MP: 0x1000},
+		},
+	}
+	b := &stack.Bucket{IDs: []int{1, 2, 3, 4}}
+	if diff := cmp.Diff([]int{0, 3}, bucketThreads(s, b)); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	filterThread(s, 3)
+	if len(s.Goroutines) != 2 || s.Goroutines[0].ID != 1 || s.Goroutines[1].ID != 4 {
+		t.Fatalf("unexpected %v", s.Goroutines)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+	if _, err := parseLabels("team"); err == nil {
+		t.Fatal("expected error")
+	}
+	labels, err := parseLabels("team=payments,tier=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stack.Snapshot{
+		Goroutines: []*stack.Goroutine{
+			{ID: 1, Signature: stack.Signature{Labels: map[string]string{"team": "payments", "tier": "1"}}},
+			{ID: 2, Signature: stack.Signature{Labels: map[string]string{"team": "payments"}}},
+			{ID: 3},
+		},
+	}
+	filterLabels(s, labels)
+	if len(s.Goroutines) != 1 || s.Goroutines[0].ID != 1 {
+		t.Fatalf("unexpected %v", s.Goroutines)
+	}
+}
+
+func TestProcessOnlyFirst(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	in := bytes.Buffer{}
+	in.WriteString("Ya\n")
+	in.Write(internaltest.PanicOutputs()["simple"])
+	in.WriteString("Ye\n")
+	in.Write(internaltest.PanicOutputs()["int"])
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, rebase: true, mID: -1, onlyFirst: true}
+	if err := process(&in, &out, &o); err != ErrPanicFound {
+		t.Fatal(err)
+	}
+	want := "Ya\nGOTRACEBACK=all\npanic: simple\n\n1: running\n    main main.go:74 main()\nParsed as go1 dialect\n"
+	compareString(t, want, out.String())
+}
+
+func TestProcessFollow(t *testing.T) {
+	t.Parallel()
+	dump := strings.Join([]string{
+		"2025-03-01T20:00:00Z server started",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:5 +0x13",
+		"",
+	}, "\n")
+	for _, follow := range []bool{false, true} {
+		out := bytes.Buffer{}
+		o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, follow: follow}
+		if err := process(strings.NewReader(dump), &out, &o); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(out.String(), "\nCaptured "); got != follow {
+			t.Fatalf("follow=%t:\n%s", follow, out.String())
+		}
+	}
+}
+
+func TestProcessShowTotals(t *testing.T) {
+	t.Parallel()
+	in := bytes.NewBufferString(strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.foo()",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 3 [chan receive]:",
+		"main.foo()",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 4 [select]:",
+		"main.bar()",
+		"\t/a/main.go:12 +0x13",
+		"",
+	}, "\n"))
+	out := bytes.Buffer{}
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, match: regexp.MustCompile(`chan receive`), showTotals: true}
+	if err := process(in, &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := "bucket 3/3: 2: chan receive\n    main main.go:9  foo()\n" +
+		"2 of 4 goroutines shown, 1 of 3 buckets\n" +
+		"Parsed as go1 dialect\n"
+	compareString(t, want, out.String())
+}
+
+func TestProcessDeterministic(t *testing.T) {
+	t.Parallel()
+	run := func(dump []string) string {
+		out := bytes.Buffer{}
+		o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, deterministic: true}
+		if err := process(bytes.NewBufferString(strings.Join(dump, "\n")), &out, &o); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	// Same crash, with different pointers, goroutine IDs and goroutine order.
+	a := run([]string{
+		"panic: bleh",
+		"",
+		"goroutine 1 [running]:",
+		"main.main(0xc000010000)",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.foo(0xc000020000, 0x1)",
+		"\t/a/main.go:9 +0x13",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:4 +0x13",
+		"",
+		"goroutine 8 [select]:",
+		"main.bar()",
+		"\t/a/main.go:12 +0x13",
+		"",
+	})
+	b := run([]string{
+		"panic: bleh",
+		"",
+		"goroutine 1 [running]:",
+		"main.main(0xc000090000)",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 21 [select]:",
+		"main.bar()",
+		"\t/a/main.go:12 +0x13",
+		"",
+		"goroutine 18 [chan receive]:",
+		"main.foo(0xc000030000, 0x1)",
+		"\t/a/main.go:9 +0x13",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:4 +0x13",
+		"",
+	})
+	compareString(t, a, b)
+	if strings.Contains(a, "0xc0000") {
+		t.Fatalf("unexpected pointer:\n%s", a)
+	}
+}
+
+func TestProcessDuplicate(t *testing.T) {
+	t.Parallel()
+	dump := strings.Join([]string{
+		"panic: bleh",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:5 +0x13",
+		"",
+	}, "\n")
+	in := bytes.NewBufferString(dump + dump + "done\n")
+	out := bytes.Buffer{}
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1}
+	if err := process(in, &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := "panic: bleh\n\n" +
+		"1: running\n    main main.go:5 main()\n" +
+		"Parsed as go1 dialect\n" +
+		"panic: bleh\n\n" +
+		"(duplicate of previous dump)\n" +
+		"done\n"
+	compareString(t, want, out.String())
+
+	// A process crashing in a loop without timestamps is not a duplicate.
+	in = bytes.NewBufferString("run 1\n" + dump + "run 2\n" + dump)
+	out.Reset()
+	if err := process(in, &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want = "run 1\npanic: bleh\n\n" +
+		"1: running\n    main main.go:5 main()\n" +
+		"Parsed as go1 dialect\n" +
+		"run 2\npanic: bleh\n\n" +
+		"1: running\n    main main.go:5 main()\n" +
+		"Parsed as go1 dialect\n"
+	compareString(t, want, out.String())
+
+	// Unless asked otherwise.
+	in = bytes.NewBufferString(dump + dump)
+	out.Reset()
+	o.keepDuplicates = true
+	if err := process(in, &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want = "panic: bleh\n\n" +
+		"1: running\n    main main.go:5 main()\n" +
+		"Parsed as go1 dialect\n" +
+		"panic: bleh\n\n" +
+		"1: running\n    main main.go:5 main()\n" +
+		"Parsed as go1 dialect\n"
+	compareString(t, want, out.String())
+}
+
+func TestProcessTwoSnapshots(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	in := bytes.Buffer{}
+	in.WriteString("Ya\n")
+	in.Write(internaltest.PanicOutputs()["simple"])
+	in.WriteString("Ye\n")
+	in.Write(internaltest.PanicOutputs()["int"])
+	in.WriteString("Yo\n")
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, rebase: true, mID: -1}
+	err := process(&in, &out, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// This is a change detector on main.go.
+	want := ("Ya\n" +
+		"GOTRACEBACK=all\n" +
+		"panic: simple\n\n" +
+		"1: running\n" +
+		"    main main.go:74 main()\n" +
+		"Parsed as go1 dialect\n" +
+		"Ye\n" +
+		"GOTRACEBACK=all\n" +
+		"panic: 42\n\n" +
+		"1: running\n" +
+		"    main main.go:93  panicint(0x2a)\n" +
+		"    main main.go:315 glob..func9()\n" +
+		"    main main.go:76  main()\n" +
+		"Parsed as go1 dialect\n" +
+		"Yo\n")
+	compareString(t, want, out.String())
+}
+
+func TestCapturedAgo(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+	if got := capturedAgo(now.Add(-35500*time.Millisecond), now); got != 35*time.Second {
+		t.Fatal(got)
+	}
+	// Clock skew.
+	if got := capturedAgo(now.Add(time.Second), now); got != 0 {
+		t.Fatal(got)
+	}
+}
+
+//
+
+var testPalette = &render.Palette{
+	EOLReset:                    "A",
+	RoutineFirst:                "B",
+	Routine:                     "C",
+	CreatedBy:                   "D",
+	Package:                     "E",
+	SrcFile:                     "F",
+	FuncMain:                    "G",
+	FuncLocationUnknown:         "H",
+	FuncLocationUnknownExported: "I",
+	FuncGoMod:                   "J",
+	FuncGoModExported:           "K",
+	FuncGOPATH:                  "L",
+	FuncGOPATHExported:          "M",
+	FuncGoPkg:                   "N",
+	FuncGoPkgExported:           "O",
+	FuncStdLib:                  "P",
+	FuncStdLibExported:          "Q",
+	Arguments:                   "R",
+	FuncPanicking:               "S",
+	FuncAbovePanic:              "T",
+}
+
+func compareString(t *testing.T, want, got string) {
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Helper()
+		t.Fatalf("Mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	// Set the environment variable so the stack doesn't include the info header.
+	os.Setenv("GOTRACEBACK", "all")
+	os.Exit(m.Run())
+}
@@ -0,0 +1,335 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// RunOptions are the options to process stack traces, one field per flag of
+// pp.
+//
+// The flags that select the input (-since, -tail-bytes, -wait-complete,
+// -watchdog) are not included since the caller provides the input. Neither
+// are -v and -analyzer, since they change the global state of the process.
+//
+// Use DefaultRunOptions to get the same defaults as pp.
+type RunOptions struct {
+	// Aggressive deduplicates the goroutines including non pointer arguments.
+	Aggressive bool
+	// Parse parses the source files to deduct the types of the arguments.
+	Parse bool
+	// Rebase guesses GOROOT and GOPATH.
+	Rebase bool
+
+	// Filter is a regexp; the buckets with a matching header are skipped.
+	Filter string
+	// Match is a regexp; only the buckets with a matching header are printed.
+	Match string
+	// Grep is a regexp; only the calls with a matching function name or source
+	// location are printed, with one call of context.
+	Grep string
+	// MLabel only keeps the goroutines with these pprof labels, formatted as
+	// "key=value,key2=value2".
+	MLabel string
+	// MID only keeps the goroutines running on this OS thread (m) id. -1
+	// disables the filter.
+	MID int
+
+	// FullPath prints the full path of the source files.
+	FullPath bool
+	// RelPath prints the path of the source files relative to GOROOT or
+	// GOPATH. It implies Rebase.
+	RelPath bool
+	// Color enables ANSI colors on the console output. The caller is
+	// responsible to pass a writer that supports them.
+	Color bool
+	// Theme is the name of the color theme, one of ThemeNames().
+	Theme string
+	// Style overrides the style of elements, e.g. "FuncMain=yellow+bu".
+	Style string
+	// OnlyFirst stops after the first stack trace and returns ErrPanicFound.
+	OnlyFirst bool
+	// Defer annotates the calls executed as part of a deferred function.
+	Defer bool
+	// VerboseHeaders prints the raw runtime values of the goroutine headers.
+	VerboseHeaders bool
+	// NoStdlibArgs doesn't print the arguments of calls in the standard
+	// library.
+	NoStdlibArgs bool
+	// ArgsDepth is the maximum nesting level of struct arguments printed. 0
+	// means no limit.
+	ArgsDepth int
+	// ArgsElements is the maximum number of fields printed per struct
+	// argument. 0 means no limit.
+	ArgsElements int
+	// MarkUncertain appends "?" to the arguments rebuilt from the sources
+	// whose value is uncertain.
+	MarkUncertain bool
+	// MinCount collapses the buckets with less goroutines into a single one.
+	MinCount int
+	// Blame annotates the call that panicked with the last git commit that
+	// modified the line.
+	Blame bool
+	// BlameAll is like Blame but for all the calls outside the standard
+	// library.
+	BlameAll bool
+	// Binary is the executable that generated the stack trace, to expand the
+	// inlined calls and resolve the calls that only have a program counter.
+	Binary string
+	// GoVersion is the Go version that produced the stack trace, e.g.
+	// "go1.22.3", to analyze the calls with the matching standard library
+	// sources.
+	GoVersion string
+	// GOROOTMirror is the URL to download the Go source archives from when the
+	// matching standard library sources are not found locally, e.g.
+	// "https://dl.google.com/go".
+	GOROOTMirror string
+	// ShowM prints the OS thread (m) ids in the headers.
+	ShowM bool
+	// Track annotates each bucket with how many goroutines existed in the
+	// previous snapshot of the input.
+	Track bool
+	// ShowTotals prefixes each header with its index and prints how many
+	// goroutines and buckets were shown out of the total.
+	ShowTotals bool
+	// KeepDuplicates processes a snapshot identical to the previous one
+	// instead of skipping it.
+	KeepDuplicates bool
+	// Deterministic zeroes the pointer values and renumbers the goroutines in
+	// a stable order, so two runs that panic the same way produce the same
+	// output.
+	Deterministic bool
+	// MaxMemory is the budget in bytes of the parsed goroutines kept in
+	// memory. The goroutines over budget are spilled to a temporary file and
+	// only the buckets are kept; the rest of the input is discarded. 0 means
+	// no limit.
+	MaxMemory int64
+	// CreatedBy only prints the unique sites that created the goroutines.
+	CreatedBy bool
+	// Packages only prints the packages found in the stacks.
+	Packages bool
+	// Threads only prints the goroutines grouped by OS thread, the threads
+	// pinned by a locked goroutine first.
+	Threads bool
+	// Tree prints the buckets as a tree of which goroutine created which.
+	Tree bool
+
+	// HTML is the file to write the HTML output to.
+	HTML string
+	// HTMLTree organizes the goroutines as a tree in the HTML output.
+	HTMLTree bool
+	// HTMLModules shows the module version of the calls in the HTML output and
+	// whether it is a direct or indirect dependency. It requires Rebase.
+	HTMLModules bool
+	// JSON is the file to write the buckets as JSON to.
+	JSON string
+	// Dot is the file to write a GraphViz graph to.
+	Dot string
+	// SARIF is the file to write the crash to as a SARIF log.
+	SARIF string
+	// OTLP is the file to write the crash to as an OpenTelemetry log record.
+	OTLP string
+	// Console also prints to the console when HTML, JSON, Dot, SARIF or OTLP
+	// is set. The console is always printed to otherwise.
+	Console bool
+	// SIEM outputs one SIEM event per panic instead of the stack traces; one
+	// of "cef" or "leef".
+	SIEM string
+	// SIEMHost is the host name to report in SIEM events.
+	SIEMHost string
+	// NDJSON outputs one JSON object per line for each frame of each
+	// goroutine. The rest of the input is discarded.
+	NDJSON bool
+	// Share uploads each snapshot, anonymized, as JSON and HTML with HTTP PUT
+	// requests to this URL and prints the resulting URLs instead of the stack
+	// traces.
+	Share string
+	// ShareToken is sent as a bearer token with the Share requests, if set.
+	ShareToken string
+	// TeeRaw is the file to append the input to, as read, so the original
+	// stack traces are archived along the processed output.
+	TeeRaw string
+	// TeeRawMaxSize is the size in bytes at which TeeRaw is rotated. The
+	// previous files are kept with a ".1" to ".3" suffix. 0 means never.
+	TeeRawMaxSize int64
+	// Follow is set when the input is a live stream, e.g. the standard input
+	// of pp, to print how long ago each snapshot was captured.
+	Follow bool
+	// Events receives the lifecycle events as one JSON object per line, if
+	// set, so a program running pp in a pipe can follow its progress without
+	// parsing the output. It is the file descriptor passed to -events-fd.
+	Events io.Writer
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// DefaultRunOptions returns the default options, the same as pp without any
+// flag except that colors are disabled.
+func DefaultRunOptions() *RunOptions {
+	return &RunOptions{
+		Parse:  true,
+		Rebase: true,
+		MID:    -1,
+		Theme:  "default",
+	}
+}
+
+// Run processes the stack traces found in in and writes the result to out,
+// exactly like pp does. Anything that is not a stack trace is passed through.
+//
+// It is meant for other executables to embed pp. opts can be nil.
+func Run(opts *RunOptions, in io.Reader, out io.Writer) error {
+	if opts == nil {
+		opts = DefaultRunOptions()
+	}
+	o, err := opts.processOpts()
+	if err != nil {
+		return err
+	}
+	return process(in, out, o)
+}
+
+// Validate returns an error if the options are invalid, without processing
+// anything.
+//
+// Run validates the options too; Validate is for callers that want to report
+// invalid options before opening the input.
+func (r *RunOptions) Validate() error {
+	_, err := r.processOpts()
+	return err
+}
+
+// processOpts validates the options and converts them.
+func (r *RunOptions) processOpts() (*processOpts, error) {
+	theme, ok := themes[r.Theme]
+	if !ok {
+		return nil, fmt.Errorf("invalid -theme value %q", r.Theme)
+	}
+	palette := *theme
+	if err := setStyles(&palette, r.Style); err != nil {
+		return nil, err
+	}
+	similarity := stack.AnyPointer
+	if r.Aggressive {
+		similarity = stack.AnyValue
+	}
+	o := &processOpts{
+		palette:        &palette,
+		similarity:     similarity,
+		pf:             render.BasePath,
+		parse:          r.Parse,
+		rebase:         r.Rebase,
+		html:           r.HTML,
+		htmlTree:       r.HTMLTree,
+		htmlModules:    r.HTMLModules,
+		json:           r.JSON,
+		dot:            r.Dot,
+		sarif:          r.SARIF,
+		otlp:           r.OTLP,
+		showM:          r.ShowM,
+		verboseHeaders: r.VerboseHeaders,
+		onlyFirst:      r.OnlyFirst,
+		mID:            r.MID,
+		siem:           r.SIEM,
+		siemHost:       r.SIEMHost,
+		ndjson:         r.NDJSON,
+		createdBy:      r.CreatedBy,
+		packages:       r.Packages,
+		tree:           r.Tree,
+		threads:        r.Threads,
+		minCount:       r.MinCount,
+		binary:         r.Binary,
+		goVersion:      r.GoVersion,
+		gorootMirror:   r.GOROOTMirror,
+		track:          r.Track,
+		showTotals:     r.ShowTotals,
+		keepDuplicates: r.KeepDuplicates,
+		deterministic:  r.Deterministic,
+		maxMemory:      r.MaxMemory,
+		follow:         r.Follow,
+		teeRaw:         r.TeeRaw,
+		teeRawMaxSize:  r.TeeRawMaxSize,
+		events:         newEventWriter(r.Events),
+		lo: render.LineOpts{
+			AnnotateDefer: r.Defer,
+			NoStdlibArgs:  r.NoStdlibArgs,
+			MarkUncertain: r.MarkUncertain,
+			ArgsLimits:    stack.ArgsLimits{MaxDepth: r.ArgsDepth, MaxElements: r.ArgsElements},
+		},
+	}
+	if !r.Color {
+		o.palette = &render.Palette{}
+	}
+	o.sinks = newSinks(o, r.Console)
+	var err error
+	if o.filter, err = compileRegexp(r.Filter); err != nil {
+		return nil, err
+	}
+	if o.match, err = compileRegexp(r.Match); err != nil {
+		return nil, err
+	}
+	if o.grep, err = compileRegexp(r.Grep); err != nil {
+		return nil, err
+	}
+	if r.MLabel != "" {
+		if o.mLabels, err = parseLabels(r.MLabel); err != nil {
+			return nil, err
+		}
+	}
+	if r.Share != "" {
+		if o.share, err = newHTTPPut(r.Share, r.ShareToken); err != nil {
+			return nil, err
+		}
+	}
+	switch r.SIEM {
+	case "", "cef", "leef":
+	default:
+		return nil, fmt.Errorf("invalid -siem value %q", r.SIEM)
+	}
+	if r.MaxMemory > 0 && (r.NDJSON || r.SIEM != "" || r.CreatedBy || r.Packages || r.Threads || r.Tree || r.SARIF != "" || r.OTLP != "" || r.Share != "" || r.Track || r.MID != -1 || r.MLabel != "" || r.ShowM || r.VerboseHeaders) {
+		return nil, errMaxMemory
+	}
+	if r.Tree && r.Grep != "" {
+		return nil, errors.New("can't use both -tree and -grep")
+	}
+	if r.TeeRawMaxSize != 0 && r.TeeRaw == "" {
+		return nil, errors.New("-tee-raw-max-size requires -tee-raw")
+	}
+	if r.FullPath {
+		if r.RelPath {
+			return nil, errors.New("can't use both -full-path and -rel-path")
+		}
+		o.pf = render.FullPath
+	} else if r.RelPath {
+		o.pf = render.RelPath
+		o.rebase = true
+	}
+	if r.Blame || r.BlameAll {
+		git, err := exec.LookPath("git")
+		if err != nil {
+			return nil, fmt.Errorf("-blame requires git: %w", err)
+		}
+		o.lo.Blame = newBlamer(git).blame
+		o.lo.BlameAll = r.BlameAll
+	}
+	return o, nil
+}
+
+// compileRegexp returns nil when s is empty.
+func compileRegexp(s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return regexp.Compile(s)
+}
@@ -0,0 +1,65 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	got := bytes.Buffer{}
+	if err := Run(nil, bytes.NewReader(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	// Colors are disabled by default and the input is passed through.
+	if !bytes.HasPrefix(got.Bytes(), []byte("junk\n")) || bytes.Contains(got.Bytes(), []byte("\x1b[")) || !bytes.Contains(got.Bytes(), []byte("[Created by http.(*connReader).startBackgroundRead @ server.go:674]")) {
+		t.Fatalf("unexpected output:\n%s", got.String())
+	}
+
+	r := DefaultRunOptions()
+	r.CreatedBy = true
+	got.Reset()
+	if err := Run(r, bytes.NewReader(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(got.Bytes(), []byte("junk\n14 net/http ")) {
+		t.Fatalf("unexpected output:\n%s", got.String())
+	}
+}
+
+func TestRunErr(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		r    RunOptions
+		want string
+	}{
+		{"filter", RunOptions{Theme: "default", Filter: "("}, "error parsing regexp: missing closing ): `(`"},
+		{"theme", RunOptions{Theme: "foo"}, "invalid -theme value \"foo\""},
+		{"siem", RunOptions{Theme: "default", SIEM: "foo"}, "invalid -siem value \"foo\""},
+		{"label", RunOptions{Theme: "default", MLabel: "foo"}, "invalid label \"foo\", expected key=value"},
+		{"path", RunOptions{Theme: "default", FullPath: true, RelPath: true}, "can't use both -full-path and -rel-path"},
+		{"tree", RunOptions{Theme: "default", Tree: true, Grep: "foo"}, "can't use both -tree and -grep"},
+		{"teeRaw", RunOptions{Theme: "default", TeeRawMaxSize: 10}, "-tee-raw-max-size requires -tee-raw"},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			err := Run(&line.r, bytes.NewReader(nil), &bytes.Buffer{})
+			if err == nil || err.Error() != line.want {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err := line.r.Validate(); err == nil || err.Error() != line.want {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
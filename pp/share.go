@@ -0,0 +1,182 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+)
+
+// uploader stores a file and returns the URL to view it.
+type uploader interface {
+	upload(name, contentType string, data []byte) (string, error)
+}
+
+// httpPut uploads each file with an HTTP PUT request to base/name.
+//
+// It works with any paste service or object store accepting PUT requests,
+// e.g. a pre-authenticated bucket URL.
+type httpPut struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func newHTTPPut(base, token string) (*httpPut, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid -share value %q, expected an http or https URL", base)
+	}
+	return &httpPut{
+		base:   strings.TrimSuffix(base, "/"),
+		token:  token,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// upload returns the Location header of the response if set, the URL the
+// file was uploaded to otherwise.
+func (h *httpPut) upload(name, contentType string, data []byte) (string, error) {
+	req, err := http.NewRequest("PUT", h.base+"/"+name, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("failed to upload %s: %s", name, resp.Status)
+	}
+	if l, err := resp.Location(); err == nil {
+		return l.String(), nil
+	}
+	return req.URL.String(), nil
+}
+
+// shareSnapshot anonymizes the snapshot, uploads it as JSON and rendered as
+// HTML, and prints the URLs.
+//
+// The files are named after the hash of the JSON, so sharing the same
+// snapshot twice results in the same URLs.
+func shareSnapshot(out io.Writer, up uploader, c *stack.Snapshot, s stack.Similarity, findings []analyzer.Finding) error {
+	c = anonymize(c)
+	j, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(j)
+	name := "panicparse-" + hex.EncodeToString(h[:8])
+	b := bytes.Buffer{}
+	var r toHTMLer = c
+	if !c.IsRace() {
+		r = c.Aggregate(s)
+	}
+	if err = r.ToHTML(&b, findingsHTML(findings)); err != nil {
+		return err
+	}
+	u, err := up.upload(name+".json", "application/json", j)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "JSON: %s\n", u)
+	if u, err = up.upload(name+".html", "text/html; charset=utf-8", b.Bytes()); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "HTML: %s\n", u)
+	return nil
+}
+
+// anonymize returns a copy of the snapshot with only the fields known to not
+// identify the host, its users or the data being processed: the goroutine
+// states, the function names and the source file names. Everything else is
+// dropped, e.g. the local paths, the argument values, the panic value, the
+// pprof labels, the unrecognized header annotations and the warnings.
+//
+// It is an allowlist so the fields added to package stack later are not
+// shared until reviewed here.
+//
+// The source paths are replaced with the path relative to GOROOT, GOPATH or
+// the go module when known, otherwise with the last directory and file name.
+func anonymize(c *stack.Snapshot) *stack.Snapshot {
+	out := &stack.Snapshot{
+		Goroutines:      make([]*stack.Goroutine, 0, len(c.Goroutines)),
+		StackOverflow:   c.StackOverflow,
+		LikelyTruncated: c.LikelyTruncated,
+		DialectVersion:  c.DialectVersion,
+		CapturedAt:      c.CapturedAt,
+	}
+	for _, g := range c.Goroutines {
+		out.Goroutines = append(out.Goroutines, &stack.Goroutine{
+			Signature: stack.Signature{
+				State:     g.State,
+				CreatedBy: anonymizeStack(&g.CreatedBy),
+				SleepMin:  g.SleepMin,
+				SleepMax:  g.SleepMax,
+				Stack:     anonymizeStack(&g.Stack),
+				Locked:    g.Locked,
+			},
+			ID:            g.ID,
+			First:         g.First,
+			CreatedByID:   g.CreatedByID,
+			RaceWrite:     g.RaceWrite,
+			RaceAddr:      g.RaceAddr,
+			OnSystemStack: g.OnSystemStack,
+		})
+	}
+	return out
+}
+
+func anonymizeStack(s *stack.Stack) stack.Stack {
+	out := stack.Stack{
+		Calls:           make([]stack.Call, 0, len(s.Calls)),
+		Elided:          s.Elided,
+		ElidedFrames:    s.ElidedFrames,
+		Folded:          s.Folded,
+		TrailingRuntime: s.TrailingRuntime,
+		Unavailable:     s.Unavailable,
+	}
+	for i := range s.Calls {
+		c := &s.Calls[i]
+		a := stack.Call{
+			Func:          c.Func,
+			Args:          stack.Args{Elided: len(c.Args.Values) != 0 || c.Args.Elided},
+			RemoteSrcPath: c.DirSrc,
+			Line:          c.Line,
+			SrcName:       c.SrcName,
+			DirSrc:        c.DirSrc,
+			RelSrcPath:    c.RelSrcPath,
+			ImportPath:    c.ImportPath,
+			Location:      c.Location,
+			Inlined:       c.Inlined,
+			PC:            c.PC,
+		}
+		if c.RelSrcPath != "" {
+			a.RemoteSrcPath = c.RelSrcPath
+		}
+		if c.Module != nil {
+			a.Module = &stack.Module{Path: c.Module.Path, Version: c.Module.Version, Main: c.Module.Main, Direct: c.Module.Direct}
+		}
+		out.Calls = append(out.Calls, a)
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestShareSnapshot(t *testing.T) {
+	t.Parallel()
+	mu := sync.Mutex{}
+	got := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "PUT" || req.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		got[req.URL.Path] = req.Header.Get("Content-Type") + "\n" + string(b)
+		mu.Unlock()
+		if strings.HasSuffix(req.URL.Path, ".html") {
+			w.Header().Set("Location", "/view"+req.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	in := "panic: boom\n\ngoroutine 1 [running]:\nmain.main(0xc000012345)\n\t/home/alice/src/secret/main.go:10 +0x1\n"
+	c, _, err := stack.ScanSnapshot(strings.NewReader(in), io.Discard, stack.DefaultOpts())
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	c.Goroutines[0].Labels = map[string]string{"tenant": "acme"}
+	up, err := newHTTPPut(ts.URL+"/paste/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err = shareSnapshot(&out, up, c, stack.AnyPointer, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "JSON: "+ts.URL+"/paste/panicparse-") || !strings.HasSuffix(lines[0], ".json") || !strings.HasPrefix(lines[1], "HTML: "+ts.URL+"/view/paste/panicparse-") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("unexpected uploads %v", got)
+	}
+	for p, v := range got {
+		if strings.HasSuffix(p, ".json") && !strings.HasPrefix(v, "application/json\n") {
+			t.Fatalf("%s: unexpected %q", p, v)
+		}
+		for _, s := range []string{"alice", "0xc000012345", "acme"} {
+			if strings.Contains(v, s) {
+				t.Fatalf("%s: %q was not anonymized:\n%s", p, s, v)
+			}
+		}
+		if !strings.Contains(v, "main.go") {
+			t.Fatalf("%s: missing the file name:\n%s", p, v)
+		}
+	}
+
+	// Bad token.
+	up.token = "wrong"
+	if err = shareSnapshot(&out, up, c, stack.AnyPointer, nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatal(err)
+	}
+}
+
+func TestShareSnapshotAllowlist(t *testing.T) {
+	t.Parallel()
+	// The fields shared by design. Every other string field must be dropped.
+	shared := map[string]bool{
+		"Snapshot.DialectVersion": true,
+		"Signature.State":         true,
+		"Func.Complete":           true,
+		"Func.ImportPath":         true,
+		"Func.DirName":            true,
+		"Func.Name":               true,
+		"Call.SrcName":            true,
+		"Call.DirSrc":             true,
+		"Call.RelSrcPath":         true,
+		"Call.ImportPath":         true,
+		"Module.Path":             true,
+		"Module.Version":          true,
+	}
+	in := "panic: boom\n\ngoroutine 1 [running]:\nmain.main(0xc000012345)\n\t/home/alice/src/secret/main.go:10 +0x1\ncreated by main.init in goroutine 2\n\t/home/alice/src/secret/main.go:5 +0x1\n"
+	c, _, err := stack.ScanSnapshot(strings.NewReader(in), io.Discard, stack.DefaultOpts())
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	// Plant a unique path in every string field reachable from the snapshot.
+	planted := map[string]string{}
+	plant(reflect.ValueOf(c).Elem(), "Snapshot", planted, 0)
+	if len(planted) < 30 {
+		t.Fatalf("only planted %d values", len(planted))
+	}
+	up := &fakeUploader{got: map[string]string{}}
+	out := bytes.Buffer{}
+	if err = shareSnapshot(&out, up, c, stack.AnyPointer, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(up.got) != 2 {
+		t.Fatalf("unexpected uploads %v", up.got)
+	}
+	for name, v := range up.got {
+		for p, f := range planted {
+			if !shared[f] && strings.Contains(v, p) {
+				t.Errorf("%s: %s was shared", name, f)
+			}
+		}
+	}
+}
+
+type fakeUploader struct {
+	got map[string]string
+}
+
+func (f *fakeUploader) upload(name, contentType string, data []byte) (string, error) {
+	f.got[name] = string(data)
+	return name, nil
+}
+
+// plant sets every exported string field, slice item and map entry reachable
+// from v to a unique path, allocating the nil pointers and empty slices on
+// the way, and records the field each path was set in.
+func plant(v reflect.Value, field string, planted map[string]string, depth int) {
+	next := func() string {
+		p := fmt.Sprintf("/home/alice/s3cr3t%dx", len(planted))
+		planted[p] = field
+		return p
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(next())
+	case reflect.Ptr:
+		if v.IsNil() {
+			if depth > 10 {
+				return
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		plant(v.Elem(), field, planted, depth+1)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" || f.Type == reflect.TypeOf(time.Time{}) {
+				continue
+			}
+			name := v.Type().Name() + "." + f.Name
+			if f.Anonymous {
+				name = field
+			}
+			plant(v.Field(i), name, planted, depth+1)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			if depth > 10 {
+				return
+			}
+			v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		}
+		for i := 0; i < v.Len(); i++ {
+			plant(v.Index(i), field, planted, depth+1)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		k := reflect.New(v.Type().Key()).Elem()
+		e := reflect.New(v.Type().Elem()).Elem()
+		plant(k, field, planted, depth+1)
+		plant(e, field, planted, depth+1)
+		v.SetMapIndex(k, e)
+	}
+}
+
+func TestNewHTTPPutErr(t *testing.T) {
+	t.Parallel()
+	for _, u := range []string{"paste", "ftp://example.com", "https://"} {
+		if _, err := newHTTPPut(u, ""); err == nil {
+			t.Fatalf("%s: expected error", u)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// siemVersion is the product version reported in SIEM events.
+const siemVersion = "2"
+
+// siemEvent is the normalized fields of a panic reported to a SIEM.
+type siemEvent struct {
+	hash       string
+	culprit    string
+	state      string
+	goroutines int
+	host       string
+}
+
+// newSIEMEvent creates the event for a snapshot.
+//
+// The signature is the one of the first goroutine, normally the one that
+// panicked.
+func newSIEMEvent(c *stack.Snapshot, host string) *siemEvent {
+	g := c.Goroutines[0]
+	return &siemEvent{
+		hash:       g.Hash(),
+		culprit:    culprit(&g.Signature),
+		state:      g.State,
+		goroutines: len(c.Goroutines),
+		host:       host,
+	}
+}
+
+// culprit returns the first function call that is not in the standard
+// library, or the top of the stack if there is none.
+func culprit(s *stack.Signature) string {
+	for i := range s.Stack.Calls {
+		if s.Stack.Calls[i].Location != stack.Stdlib {
+			return s.Stack.Calls[i].Func.Complete
+		}
+	}
+	if len(s.Stack.Calls) != 0 {
+		return s.Stack.Calls[0].Func.Complete
+	}
+	return ""
+}
+
+// writeSIEM writes one event in the requested format.
+//
+// format must be either "cef" or "leef".
+func writeSIEM(out io.Writer, format string, e *siemEvent) error {
+	var s string
+	switch format {
+	case "cef":
+		s = e.cef()
+	case "leef":
+		s = e.leef()
+	default:
+		return fmt.Errorf("unknown SIEM format %q", format)
+	}
+	_, err := io.WriteString(out, s+"\n")
+	return err
+}
+
+// cef formats the event in ArcSight Common Event Format.
+func (e *siemEvent) cef() string {
+	hdr := strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	ext := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	f := []string{
+		"cs1Label=signatureHash",
+		"cs1=" + ext.Replace(e.hash),
+		"cs2Label=culprit",
+		"cs2=" + ext.Replace(e.culprit),
+		"cs3Label=state",
+		"cs3=" + ext.Replace(e.state),
+		"cnt=" + strconv.Itoa(e.goroutines),
+	}
+	if e.host != "" {
+		f = append(f, "dvchost="+ext.Replace(e.host))
+	}
+	return "CEF:0|panicparse|pp|" + siemVersion + "|" + hdr.Replace(e.hash) + "|" + hdr.Replace("panic in "+e.culprit) + "|10|" + strings.Join(f, " ")
+}
+
+// leef formats the event in IBM QRadar Log Event Extended Format 1.0.
+func (e *siemEvent) leef() string {
+	hdr := strings.NewReplacer(`|`, `\|`)
+	// Attributes are tab separated.
+	ext := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	f := []string{
+		"cat=panic",
+		"sev=10",
+		"signatureHash=" + ext.Replace(e.hash),
+		"culprit=" + ext.Replace(e.culprit),
+		"state=" + ext.Replace(e.state),
+		"goroutines=" + strconv.Itoa(e.goroutines),
+	}
+	if e.host != "" {
+		f = append(f, "devName="+ext.Replace(e.host))
+	}
+	return "LEEF:1.0|panicparse|pp|" + siemVersion + "|" + hdr.Replace(e.hash) + "|" + strings.Join(f, "\t")
+}
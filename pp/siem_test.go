@@ -0,0 +1,46 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestSIEM(t *testing.T) {
+	t.Parallel()
+	e := &siemEvent{
+		hash:       "0123456789abcdef",
+		culprit:    "main.a|b=c",
+		state:      "running",
+		goroutines: 3,
+		host:       "host1",
+	}
+	compareString(t, "CEF:0|panicparse|pp|2|0123456789abcdef|panic in main.a\\|b=c|10|cs1Label=signatureHash cs1=0123456789abcdef cs2Label=culprit cs2=main.a|b\\=c cs3Label=state cs3=running cnt=3 dvchost=host1", e.cef())
+	compareString(t, "LEEF:1.0|panicparse|pp|2|0123456789abcdef|cat=panic\tsev=10\tsignatureHash=0123456789abcdef\tculprit=main.a|b=c\tstate=running\tgoroutines=3\tdevName=host1", e.leef())
+	if err := writeSIEM(&bytes.Buffer{}, "syslog", e); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestProcessSIEM(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	r := bytes.NewReader(internaltest.PanicOutputs()["simple"])
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, rebase: true, mID: -1, siem: "cef", siemHost: "h"}
+	if err := process(r, &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	last := lines[len(lines)-1]
+	if !strings.HasPrefix(last, "CEF:0|panicparse|pp|2|") || !strings.Contains(last, "cs2=main.main ") || !strings.HasSuffix(last, " cnt=1 dvchost=h") {
+		t.Fatalf("unexpected %q", last)
+	}
+}
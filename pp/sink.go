@@ -0,0 +1,179 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/analyzer"
+	"github.com/maruel/panicparse/v2/stack/export"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// output is a processed snapshot, as passed to each sink.
+type output struct {
+	c *stack.Snapshot
+	// a is the aggregated snapshot. It is nil for a data race, since the
+	// goroutines are not bucketed.
+	a *stack.Aggregated
+	// total is the number of goroutines before filtering.
+	total    int
+	needsEnv bool
+	findings []analyzer.Finding
+}
+
+// sink writes one representation of a processed snapshot.
+//
+// out is the console.
+type sink func(out io.Writer, o *processOpts, r *output) error
+
+// newSinks returns the sinks to write each snapshot to.
+//
+// The console is written to when console is true or when there is no other
+// output, so the file outputs don't require parsing the input multiple times.
+func newSinks(o *processOpts, console bool) []sink {
+	var out []sink
+	if o.html != "" {
+		out = append(out, htmlSink)
+	}
+	if o.json != "" {
+		out = append(out, jsonSink)
+	}
+	if o.dot != "" {
+		out = append(out, dotSink)
+	}
+	if o.sarif != "" {
+		out = append(out, sarifSink)
+	}
+	if o.otlp != "" {
+		out = append(out, otlpSink)
+	}
+	if console || len(out) == 0 {
+		out = append(out, consoleSink)
+	}
+	return out
+}
+
+func consoleSink(out io.Writer, o *processOpts, r *output) error {
+	if o.follow && !r.c.CapturedAt.IsZero() {
+		fmt.Fprintf(out, "Captured %s ago\n", capturedAgo(r.c.CapturedAt, time.Now()))
+	}
+	if r.c.PanicValue != "" {
+		// The line was consumed by the parser, print it back.
+		fmt.Fprintf(out, "panic: %s\n", r.c.PanicValue)
+	}
+	writeFindingsToConsole(out, o.palette, r.findings)
+	writeDeadlocksToConsole(out, o.palette, r.c.FindDeadlocks())
+	err := writeConsole(out, o, r)
+	if err == nil && r.c.DialectVersion != "" {
+		// Like the HTML footer, to help triage parsing issues.
+		fmt.Fprintf(out, "Parsed as %s dialect\n", r.c.DialectVersion)
+	}
+	return err
+}
+
+// writeConsole prints the goroutines in the format selected by o.
+func writeConsole(out io.Writer, o *processOpts, r *output) error {
+	if r.a != nil {
+		if o.grep != nil {
+			return writeGrepToConsole(out, o, r.a)
+		}
+		if o.tree {
+			return writeTreeToConsole(out, o, r.a, r.needsEnv)
+		}
+		return writeBucketsToConsole(out, o, r.a, r.needsEnv)
+	}
+	// It's a data race.
+	if o.grep != nil {
+		// Each goroutine is its own bucket.
+		return writeGrepToConsole(out, o, &stack.Aggregated{Snapshot: r.c, Buckets: raceBuckets(r.c)})
+	}
+	return writeGoroutinesToConsole(out, o, r.c, r.total, r.needsEnv)
+}
+
+func htmlSink(out io.Writer, o *processOpts, r *output) error {
+	if r.a == nil {
+		return toHTML(r.c, o.html, r.needsEnv, r.findings)
+	}
+	if o.htmlTree {
+		return toHTML(treeHTML{r.a}, o.html, r.needsEnv, r.findings)
+	}
+	return toHTML(r.a, o.html, r.needsEnv, r.findings)
+}
+
+// jsonSink writes the aggregated snapshot, or the snapshot itself for a data
+// race.
+func jsonSink(out io.Writer, o *processOpts, r *output) error {
+	var v interface{} = r.c
+	if r.a != nil {
+		v = r.a
+	}
+	/* #nosec G304 */
+	f, err := os.Create(o.json)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(f)
+	e.SetIndent("", "  ")
+	err = e.Encode(v)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func dotSink(out io.Writer, o *processOpts, r *output) error {
+	if r.a == nil {
+		log.Printf("Skipping -dot for a data race")
+		return nil
+	}
+	return toDot(r.a, o.dot)
+}
+
+func sarifSink(out io.Writer, o *processOpts, r *output) error {
+	// Code scanning is run from the root of the repository.
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return exportFile(o.sarif, export.WriteSARIF, r.c, &export.Opts{SrcRoot: filepath.ToSlash(wd)})
+}
+
+func otlpSink(out io.Writer, o *processOpts, r *output) error {
+	return exportFile(o.otlp, export.WriteOTLP, r.c, &export.Opts{})
+}
+
+// exportFile writes c to the file p with the export function write.
+func exportFile(p string, write func(io.Writer, *stack.Snapshot, *export.Opts) error, c *stack.Snapshot, opts *export.Opts) error {
+	/* #nosec G304 */
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = write(f, c, opts)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// writeDeadlocksToConsole prints the probable deadlock cycles, if any.
+func writeDeadlocksToConsole(out io.Writer, p *render.Palette, deadlocks []stack.Deadlock) {
+	if len(deadlocks) == 0 {
+		return
+	}
+	_, _ = io.WriteString(out, "Probable deadlocks:\n")
+	for i := range deadlocks {
+		_, _ = io.WriteString(out, "  "+p.Deadlock+deadlocks[i].String()+p.EOLReset+"\n")
+	}
+	_, _ = io.WriteString(out, "\n")
+}
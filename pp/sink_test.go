@@ -0,0 +1,87 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessSinks(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	dump := "panic: bleh\n\ngoroutine 1 [running]:\nmain.main()\n\t/a/main.go:5 +0x13\n"
+	r := DefaultRunOptions()
+	r.Rebase = false
+	r.HTML = filepath.Join(dir, "report.html")
+	r.JSON = filepath.Join(dir, "report.json")
+	r.Dot = filepath.Join(dir, "report.dot")
+	r.SARIF = filepath.Join(dir, "report.sarif")
+	r.OTLP = filepath.Join(dir, "report.otlp.json")
+	r.Console = true
+	o, err := r.processOpts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Buffer{}
+	if err = process(strings.NewReader(dump), &out, o); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "panic: bleh\n\n1: running\n    main main.go:5 main()\nParsed as go1 dialect\n", out.String())
+	for _, p := range []string{r.HTML, r.JSON, r.Dot, r.SARIF, r.OTLP} {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(b, []byte("main")) {
+			t.Fatalf("%s: unexpected content:\n%s", p, b)
+		}
+	}
+	b, _ := os.ReadFile(r.JSON)
+	a := struct{ Buckets []json.RawMessage }{}
+	if err = json.Unmarshal(b, &a); err != nil || len(a.Buckets) != 1 {
+		t.Fatalf("unexpected %v %d", err, len(a.Buckets))
+	}
+	b, _ = os.ReadFile(r.SARIF)
+	if !bytes.Contains(b, []byte(`"uri": "file:///a/main.go"`)) {
+		t.Fatalf("unexpected SARIF:\n%s", b)
+	}
+	b, _ = os.ReadFile(r.OTLP)
+	if !bytes.Contains(b, []byte(`{"key":"exception.type","value":{"stringValue":"panic"}}`)) {
+		t.Fatalf("unexpected OTLP:\n%s", b)
+	}
+
+	// The console is skipped by default when writing to a file, except for
+	// what is not a stack trace.
+	r.Console = false
+	if o, err = r.processOpts(); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err = process(strings.NewReader(dump), &out, o); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "panic: bleh\n\n", out.String())
+}
+
+func TestWriteDeadlocksToConsole(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	writeDeadlocksToConsole(&out, &render.Palette{Deadlock: "D", EOLReset: "A"}, nil)
+	compareString(t, "", out.String())
+	d := []stack.Deadlock{{IDs: []int{6, 7}, Objects: []uint64{0x10, 0x20}}}
+	writeDeadlocksToConsole(&out, &render.Palette{Deadlock: "D", EOLReset: "A"}, d)
+	want := "Probable deadlocks:\n" +
+		"  Dgoroutine 6 waits on 0x10 referenced by goroutine 7, goroutine 7 waits on 0x20 referenced by goroutine 6A\n" +
+		"\n"
+	compareString(t, want, out.String())
+}
@@ -0,0 +1,200 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// spillStore keeps the goroutines of a snapshot in memory up to a budget in
+// bytes and serializes the rest to a temporary file.
+type spillStore struct {
+	budget int64
+	used   int64
+	mem    []*stack.Goroutine
+	// f is the temporary file, created on the first goroutine over budget.
+	f       *os.File
+	enc     *gob.Encoder
+	spilled int
+}
+
+// add stores the goroutine.
+func (s *spillStore) add(g *stack.Goroutine) error {
+	if s.spilled == 0 {
+		if n := goroutineSize(g); s.used+n <= s.budget {
+			s.used += n
+			s.mem = append(s.mem, g)
+			return nil
+		}
+	}
+	if s.f == nil {
+		f, err := os.CreateTemp("", "panicparse-spill-*")
+		if err != nil {
+			return err
+		}
+		log.Printf("Spilling goroutines to %s", f.Name())
+		s.f = f
+	}
+	if s.enc == nil {
+		s.enc = gob.NewEncoder(s.f)
+	}
+	s.spilled++
+	return s.enc.Encode(g)
+}
+
+// len returns the number of goroutines stored.
+func (s *spillStore) len() int {
+	return len(s.mem) + s.spilled
+}
+
+// replay calls fn for each goroutine stored, in order.
+func (s *spillStore) replay(fn func(g *stack.Goroutine)) error {
+	for _, g := range s.mem {
+		fn(g)
+	}
+	if s.spilled == 0 {
+		return nil
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(s.f)
+	for i := 0; i < s.spilled; i++ {
+		g := &stack.Goroutine{}
+		if err := dec.Decode(g); err != nil {
+			return err
+		}
+		fn(g)
+	}
+	// Continue writing at the end.
+	_, err := s.f.Seek(0, io.SeekEnd)
+	return err
+}
+
+// reset empties the store for the next snapshot, keeping the temporary file.
+func (s *spillStore) reset() error {
+	s.used = 0
+	s.mem = nil
+	s.spilled = 0
+	s.enc = nil
+	if s.f == nil {
+		return nil
+	}
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.f.Seek(0, io.SeekStart)
+	return err
+}
+
+// close deletes the temporary file, if any.
+func (s *spillStore) close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	if err2 := os.Remove(s.f.Name()); err == nil {
+		err = err2
+	}
+	s.f = nil
+	return err
+}
+
+// goroutineSize estimates the memory used by a parsed goroutine.
+//
+// It errs on the large side, since the allocator overhead is not known.
+func goroutineSize(g *stack.Goroutine) int64 {
+	n := 256 + len(g.State)
+	for _, st := range []*stack.Stack{&g.Stack, &g.CreatedBy} {
+		for i := range st.Calls {
+			c := &st.Calls[i]
+			n += 192 + len(c.Func.Complete) + len(c.RemoteSrcPath) + len(c.LocalSrcPath) + len(c.RelSrcPath) + len(c.DirSrc) + len(c.SrcName) + len(c.ImportPath) + 48*len(c.Args.Values)
+		}
+	}
+	return int64(n)
+}
+
+// processSpill processes the snapshots found in in while keeping at most
+// o.maxMemory bytes of goroutines in memory.
+//
+// A snapshot that fits in the budget is processed as usual. Otherwise the
+// goroutines over budget are spilled to a temporary file and the snapshot is
+// aggregated in two passes: the goroutines are first merged by signature hash
+// with a stack.Aggregator, which only keeps the buckets, then the buckets are
+// rendered.
+//
+// The rest of the input is discarded.
+func processSpill(in io.Reader, out io.Writer, o *processOpts, opts *stack.Opts) error {
+	gs, err := stack.NewGoroutineScanner(in, opts)
+	if err != nil {
+		return err
+	}
+	st := &spillStore{budget: o.maxMemory}
+	defer func() {
+		_ = st.close()
+	}()
+	index := 0
+	flush := func() error {
+		if st.len() == 0 {
+			return nil
+		}
+		c := &stack.Snapshot{LocalGOROOT: opts.LocalGOROOT, LocalGOPATHs: opts.LocalGOPATHs}
+		var err error
+		if st.spilled == 0 {
+			c.Goroutines = st.mem
+			o.events.snapshotParsed(c, index)
+			err = processInner(out, o, c, nil, index)
+		} else {
+			log.Printf("Snapshot #%d: %d goroutines spilled", index, st.spilled)
+			o.events.emit(&event{Event: eventSnapshotParsed, Snapshot: &index, Goroutines: st.len()})
+			ag := stack.NewAggregator(o.similarity)
+			if err = st.replay(ag.Add); err == nil {
+				err = processAggregated(out, o, ag.Aggregated(c))
+			}
+		}
+		if err == nil {
+			o.events.snapshotRendered(index)
+		}
+		if err1 := st.reset(); err == nil {
+			err = err1
+		}
+		index++
+		if o.onlyFirst && err == nil {
+			err = ErrPanicFound
+		}
+		return err
+	}
+	for {
+		g, err := gs.Next()
+		if err != nil {
+			if err1 := flush(); err1 != nil {
+				return err1
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if g.First {
+			// A new snapshot starts.
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if err := st.add(g); err != nil {
+			return err
+		}
+	}
+}
+
+// errMaxMemory is returned by processOpts when -max-memory is used with an
+// option that requires all the goroutines of a snapshot.
+var errMaxMemory = errors.New("-max-memory cannot be used with -ndjson, -siem, -created-by, -packages, -threads, -tree, -sarif, -otlp, -share, -track, -m-id, -m-label, -show-m or -verbose-headers")
@@ -0,0 +1,93 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestSpillStore(t *testing.T) {
+	t.Parallel()
+	g := func(id int) *stack.Goroutine {
+		return &stack.Goroutine{
+			Signature: stack.Signature{
+				State: "chan receive",
+				Stack: stack.Stack{Calls: []stack.Call{{Line: id, Args: stack.Args{Values: []stack.Arg{{Value: 0xc000010000, IsPtr: true}}}}}},
+			},
+			ID: id,
+		}
+	}
+	// Only the first goroutine fits.
+	st := &spillStore{budget: goroutineSize(g(1))}
+	defer func() {
+		if err := st.close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for snapshot := 0; snapshot < 2; snapshot++ {
+		for id := 1; id <= 3; id++ {
+			if err := st.add(g(id)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(st.mem) != 1 || st.spilled != 2 || st.len() != 3 {
+			t.Fatalf("%d in memory, %d spilled", len(st.mem), st.spilled)
+		}
+		var got []int
+		err := st.replay(func(g *stack.Goroutine) {
+			if g.Stack.Calls[0].Line != g.ID || g.Stack.Calls[0].Args.Values[0].Value != 0xc000010000 {
+				t.Errorf("unexpected goroutine %d", g.ID)
+			}
+			got = append(got, g.ID)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareString(t, "[1 2 3]", fmt.Sprint(got))
+		if err := st.reset(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessMaxMemory(t *testing.T) {
+	t.Parallel()
+	dump := strings.Join([]string{
+		"panic: bleh",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.foo(0xc000010000)",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 3 [chan receive]:",
+		"main.foo(0xc000020000)",
+		"\t/a/main.go:9 +0x13",
+		"",
+	}, "\n")
+	run := func(maxMemory int64) string {
+		out := bytes.Buffer{}
+		o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, maxMemory: maxMemory}
+		if err := process(bytes.NewBufferString(dump), &out, &o); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	// The panic value is not kept by the goroutine scanner.
+	want := "1: running\n    main main.go:5 main()\n2: chan receive\n    main main.go:9 foo(*)\n"
+	// Fits in memory.
+	compareString(t, want, run(1<<20))
+	// Spilled.
+	compareString(t, want, run(1))
+}
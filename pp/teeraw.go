@@ -0,0 +1,102 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// teeRawBackups is the number of rotated files kept by rotatingFile.
+const teeRawBackups = 3
+
+// rotatingFile appends to a file, rotating it when it grows larger than max
+// bytes: the file is renamed with a ".1" suffix, the previous ".1" to ".2"
+// and so on up to teeRawBackups.
+//
+// It rotates only at the start of a line, so a line is never split across
+// two files. A stack trace can be.
+type rotatingFile struct {
+	path string
+	// max is the size to rotate at. 0 means never.
+	max  int64
+	f    *os.File
+	size int64
+	// bol is true when the next byte written starts a line.
+	bol bool
+}
+
+func openRotatingFile(path string, max int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, max: max, bol: true}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	/* #nosec G302 G304 */
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	return nil
+}
+
+// Write implements io.Writer.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) != 0 {
+		if r.max > 0 && r.bol && r.size >= r.max {
+			if err := r.rotate(); err != nil {
+				return written, err
+			}
+		}
+		// Write up to the end of the line, so the size is checked again at the
+		// start of the next one.
+		chunk := p
+		if i := bytes.IndexByte(p, '\n'); i != -1 {
+			chunk = p[:i+1]
+		}
+		n, err := r.f.Write(chunk)
+		written += n
+		r.size += int64(n)
+		if n != 0 {
+			r.bol = chunk[n-1] == '\n'
+		}
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Close closes the current file.
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	for i := teeRawBackups - 1; i > 0; i-- {
+		// The older files may not exist yet.
+		_ = os.Rename(r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+	p := filepath.Join(t.TempDir(), "raw.log")
+	if err := os.WriteFile(p, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := openRotatingFile(p, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The existing content is kept and counted. Lines are never split, even
+	// across writes.
+	for _, s := range []string{"a\nbb", "b\ncc\n", "d\ne\nf\ng\nh\n", "i\nj\nk\nl\nm\nn\no\np\n"} {
+		if n, err := r.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatal(n, err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"":   "o\np\n",
+		".1": "k\nl\nm\nn\n",
+		".2": "g\nh\ni\nj\n",
+		".3": "cc\nd\ne\nf\n",
+		// Older files are deleted.
+		".4": "",
+	}
+	for suffix, w := range want {
+		b, _ := os.ReadFile(p + suffix)
+		compareString(t, w, string(b))
+	}
+}
+
+func TestProcessTeeRaw(t *testing.T) {
+	t.Parallel()
+	in := append([]byte("junk\n"), internaltest.StaticPanicwebOutput()...)
+	r := DefaultRunOptions()
+	r.TeeRaw = filepath.Join(t.TempDir(), "raw.log")
+	got := bytes.Buffer{}
+	if err := Run(r, bytes.NewReader(in), &got); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got.Bytes(), in) {
+		t.Fatal("expected the stack trace to be processed")
+	}
+	// The input is archived as is.
+	b, err := os.ReadFile(r.TeeRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, in) {
+		t.Fatalf("unexpected content:\n%s", b)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack/render"
+	"github.com/mgutz/ansi"
+)
+
+// colorblindPalette avoids relying on red versus green and uses styles to
+// tell apart the elements that would otherwise only differ by hue.
+var colorblindPalette = render.Palette{
+	EOLReset:                    resetFG,
+	RoutineFirst:                ansi.ColorCode("blue+bu"),
+	CreatedBy:                   ansi.LightBlack,
+	Race:                        ansi.ColorCode("yellow+bi"),
+	Package:                     ansi.ColorCode("default+b"),
+	SrcFile:                     resetFG,
+	FuncMain:                    ansi.ColorCode("white+bu"),
+	FuncLocationUnknown:         ansi.White,
+	FuncLocationUnknownExported: ansi.ColorCode("white+b"),
+	FuncGoMod:                   ansi.Yellow,
+	FuncGoModExported:           ansi.ColorCode("yellow+b"),
+	FuncGOPATH:                  ansi.Magenta,
+	FuncGOPATHExported:          ansi.ColorCode("magenta+b"),
+	FuncGoPkg:                   ansi.Blue,
+	FuncGoPkgExported:           ansi.ColorCode("blue+b"),
+	FuncStdLib:                  ansi.LightBlack,
+	FuncStdLibExported:          ansi.ColorCode("black+bh"),
+	Arguments:                   resetFG,
+	FuncPanicking:               ansi.ColorCode("yellow+bi"),
+	FuncAbovePanic:              ansi.ColorCode("blue+u"),
+}
+
+// themes are the built-in palettes selectable with RunOptions.Theme.
+var themes = map[string]*render.Palette{
+	"default":    &defaultPalette,
+	"colorblind": &colorblindPalette,
+}
+
+// ThemeNames returns the sorted names of the built-in themes, the valid values
+// of RunOptions.Theme.
+func ThemeNames() []string {
+	out := make([]string, 0, len(themes))
+	for k := range themes {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Theme returns a copy of the palette of the built-in theme name, or nil if
+// there is none.
+func Theme(name string) *render.Palette {
+	p, ok := themes[name]
+	if !ok {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// setStyles overrides the style of elements of the palette p.
+//
+// spec is a comma separated list of element=style, where element is the case
+// insensitive name of a render.Palette field and style is in the format of
+// github.com/mgutz/ansi, e.g. "FuncMain=yellow+bu,Race=+i". The attributes are
+// b for bold, u for underline, i for inverse and h for high intensity.
+func setStyles(p *render.Palette, spec string) error {
+	if spec == "" {
+		return nil
+	}
+	v := reflect.ValueOf(p).Elem()
+	for _, item := range strings.Split(spec, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid style %q, expected element=style", item)
+		}
+		name := strings.TrimSpace(kv[0])
+		f := v.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if !f.IsValid() || strings.EqualFold(name, "EOLReset") {
+			return fmt.Errorf("invalid style element %q", name)
+		}
+		f.SetString(ansi.ColorCode(strings.TrimSpace(kv[1])))
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"testing"
+
+	"github.com/mgutz/ansi"
+)
+
+func TestSetStyles(t *testing.T) {
+	t.Parallel()
+	p := defaultPalette
+	if err := setStyles(&p, " funcmain = yellow+u ,Race=+i"); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, ansi.ColorCode("yellow+u"), p.FuncMain)
+	compareString(t, ansi.ColorCode("+i"), p.Race)
+	compareString(t, defaultPalette.FuncStdLib, p.FuncStdLib)
+
+	for _, spec := range []string{"FuncMain", "Nope=red", "EOLReset=red"} {
+		if err := setStyles(&p, spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestThemes(t *testing.T) {
+	t.Parallel()
+	// The color blind theme must not use red nor green.
+	for _, c := range []string{ansi.Red, ansi.LightRed, ansi.Green, ansi.LightGreen} {
+		if colorblindPalette.FuncGoMod == c || colorblindPalette.FuncStdLib == c || colorblindPalette.Race == c {
+			t.Fatalf("unexpected color %q", c)
+		}
+	}
+	if got := ThemeNames(); len(got) != 2 || got[0] != "colorblind" || got[1] != "default" {
+		t.Fatalf("unexpected %v", got)
+	}
+	if p := Theme("default"); p == nil || *p != defaultPalette {
+		t.Fatalf("unexpected %v", p)
+	}
+	if p := Theme("foo"); p != nil {
+		t.Fatalf("unexpected %v", p)
+	}
+}
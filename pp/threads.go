@@ -0,0 +1,52 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// writeThreadsToConsole prints the goroutines grouped by OS thread, the
+// threads pinned by a locked goroutine first, then how many threads are
+// pinned.
+func writeThreadsToConsole(out io.Writer, o *processOpts, c *stack.Snapshot) error {
+	threads := c.Threads()
+	if len(threads) == 0 {
+		_, err := io.WriteString(out, "No goroutine on a known OS thread; requires GOTRACEBACK=system or higher\n")
+		return err
+	}
+	p := o.palette
+	srcLen, pkgLen := render.MeasureGoroutines(c, o.pf)
+	pinned := 0
+	for i := range threads {
+		t := &threads[i]
+		m := "?"
+		if t.M != -1 {
+			m = strconv.Itoa(t.M)
+		}
+		extra := ""
+		if t.Locked {
+			pinned++
+			var ids []string
+			for _, g := range t.LockedBy() {
+				ids = append(ids, strconv.Itoa(g.ID))
+			}
+			extra = " pinned by goroutine " + strings.Join(ids, ", ")
+		}
+		fmt.Fprintf(out, "%sm=%s%s:%s\n", p.Routine, m, extra, p.EOLReset)
+		for _, g := range t.Goroutines {
+			_, _ = io.WriteString(out, indentLines(p.GoroutineHeader(g, o.pf, true, false, o.verboseHeaders), "  "))
+			_, _ = io.WriteString(out, indentLines(p.StackLines(&g.Signature, srcLen, pkgLen, o.pf, &o.lo, g.First), "  "))
+		}
+	}
+	_, err := fmt.Fprintf(out, "%d of %d threads pinned by a locked goroutine\n", pinned, len(threads))
+	return err
+}
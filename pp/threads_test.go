@@ -0,0 +1,58 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessThreads(t *testing.T) {
+	t.Parallel()
+	in := "" +
+		"goroutine 1 gp=0xc000002380 m=0 mp=0x5b1b20 [running]:\n" +
+		"main.main()\n" +
+		"\t/a/main.go:5 +0x13\n" +
+		"\n" +
+		"goroutine 7 gp=0xc000007000 m=3 mp=0xc000080008 [syscall, locked to thread]:\n" +
+		"main.cgoCall()\n" +
+		"\t/a/main.go:20 +0x13\n" +
+		"\n" +
+		"goroutine 8 gp=0xc000007380 m=nil [chan receive, locked to thread]:\n" +
+		"main.render()\n" +
+		"\t/a/main.go:30 +0x13\n" +
+		"\n"
+	out := bytes.Buffer{}
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, threads: true}
+	if err := process(strings.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := "m=3 pinned by goroutine 7:\n" +
+		"  7: syscall [locked]\n" +
+		"      main main.go:20 cgoCall()\n" +
+		"m=? pinned by goroutine 8:\n" +
+		"  8: chan receive [locked]\n" +
+		"      main main.go:30 render()\n" +
+		"m=0:\n" +
+		"  1: running\n" +
+		"      main main.go:5  main()\n" +
+		"2 of 3 threads pinned by a locked goroutine\n"
+	compareString(t, want, out.String())
+}
+
+func TestProcessThreadsUnknown(t *testing.T) {
+	t.Parallel()
+	out := bytes.Buffer{}
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, threads: true}
+	in := "goroutine 1 [running]:\nmain.main()\n\t/a/main.go:5 +0x13\n\n"
+	if err := process(strings.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, "No goroutine on a known OS thread; requires GOTRACEBACK=system or higher\n", out.String())
+}
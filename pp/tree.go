@@ -0,0 +1,83 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+// writeTreeToConsole prints the buckets organized by which goroutine created
+// them, each level indented by two more spaces.
+//
+// A bucket whose goroutines were created by different parents is printed
+// once under each of them. The -f and -m filters hide a node but not its
+// children.
+func writeTreeToConsole(out io.Writer, o *processOpts, a *stack.Aggregated, needsEnv bool) error {
+	if needsEnv {
+		_, _ = io.WriteString(out, "\nTo see all goroutines, visit https://github.com/maruel/panicparse#gotraceback\n\n")
+	}
+	srcLen, pkgLen := render.Measure(a, o.pf)
+	multi := len(a.Buckets) > 1
+	var write func(nodes []*stack.CreationNode, indent string)
+	write = func(nodes []*stack.CreationNode, indent string) {
+		for _, n := range nodes {
+			b := treeBucket(n)
+			header := o.palette.BucketHeader(b, o.pf, multi, nil)
+			if (o.filter == nil || !o.filter.MatchString(header)) && (o.match == nil || o.match.MatchString(header)) {
+				if n.Total != len(n.IDs) {
+					// Insert before the trailing reset and newline.
+					header = strings.TrimSuffix(header, o.palette.EOLReset+"\n") + fmt.Sprintf(" (%d in subtree)", n.Total) + o.palette.EOLReset + "\n"
+				}
+				_, _ = io.WriteString(out, indentLines(header, indent))
+				_, _ = io.WriteString(out, indentLines(o.palette.StackLines(&b.Signature, srcLen, pkgLen, o.pf, &o.lo, b.First), indent))
+			}
+			write(n.Children, indent+"  ")
+		}
+	}
+	write(a.CreationTree(), "")
+	if a.Previous != nil {
+		fmt.Fprintf(out, "%d goroutines gone since the previous snapshot\n", len(a.Gone))
+	}
+	return nil
+}
+
+// treeBucket returns the bucket of the node, restricted to the goroutines in
+// the node.
+func treeBucket(n *stack.CreationNode) *stack.Bucket {
+	b := *n.Bucket
+	b.IDs = n.IDs
+	if n.Bucket.Existing != nil {
+		in := make(map[int]bool, len(n.IDs))
+		for _, id := range n.IDs {
+			in[id] = true
+		}
+		b.Existing = []int{}
+		for _, id := range n.Bucket.Existing {
+			if in[id] {
+				b.Existing = append(b.Existing, id)
+			}
+		}
+	}
+	return &b
+}
+
+// indentLines prefixes each line of s with indent.
+func indentLines(s, indent string) string {
+	if indent == "" {
+		return s
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = indent + l
+		}
+	}
+	return strings.Join(lines, "")
+}
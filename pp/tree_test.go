@@ -0,0 +1,55 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package pp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+)
+
+func TestProcessTree(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:10 +0x1",
+		"",
+		"goroutine 6 [IO wait]:",
+		"main.accept()",
+		"\t/a/main.go:20 +0x1",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:11 +0x1",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+		"goroutine 8 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+	}, "\n")
+	out := bytes.Buffer{}
+	o := processOpts{palette: &render.Palette{}, similarity: stack.AnyPointer, pf: render.BasePath, mID: -1, tree: true}
+	if err := process(strings.NewReader(in), &out, &o); err != nil {
+		t.Fatal(err)
+	}
+	want := "1: running (4 in subtree)\n" +
+		"    main main.go:10 main()\n" +
+		"  1: IO wait [Created by main.main @ main.go:11] (3 in subtree)\n" +
+		"      main main.go:20 accept()\n" +
+		"    2: chan receive [Created by main.accept @ main.go:21]\n" +
+		"        main main.go:30 serve()\n" +
+		"Parsed as go1.21 dialect\n"
+	compareString(t, want, out.String())
+}
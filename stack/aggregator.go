@@ -0,0 +1,73 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "sort"
+
+// Aggregator merges similar goroutines into buckets as they are added, like
+// Snapshot.Aggregate, without keeping the goroutines.
+//
+// It is meant for dumps too large to be kept in memory, e.g. with the
+// goroutines returned by GoroutineScanner. The goroutines are compared only
+// with the buckets that have the same Signature.Hash, so it also scales better
+// with the number of buckets.
+//
+// Unlike Snapshot.Aggregate, the arguments are not named across goroutines,
+// since this requires all of them.
+type Aggregator struct {
+	similar Similarity
+	// groups is the buckets by Signature.Hash. Similar signatures always have
+	// the same hash.
+	groups  map[string][]*Bucket
+	buckets []*Bucket
+	total   int
+}
+
+// NewAggregator returns an Aggregator that merges goroutines at the similar
+// level.
+func NewAggregator(similar Similarity) *Aggregator {
+	return &Aggregator{similar: similar, groups: map[string][]*Bucket{}}
+}
+
+// Add merges the goroutine into its bucket.
+//
+// g is not modified nor kept.
+func (ag *Aggregator) Add(g *Goroutine) {
+	ag.total++
+	h := g.Hash()
+	for _, b := range ag.groups[h] {
+		if b.Signature.similar(&g.Signature, ag.similar) {
+			b.IDs = append(b.IDs, g.ID)
+			b.First = b.First || g.First
+			b.OnSystemStack = b.OnSystemStack || g.OnSystemStack
+			if !b.Signature.equal(&g.Signature) {
+				// Zap out the different values.
+				b.Signature = *b.Signature.merge(&g.Signature)
+			}
+			return
+		}
+	}
+	b := &Bucket{Signature: g.Signature, IDs: []int{g.ID}, First: g.First, OnSystemStack: g.OnSystemStack}
+	ag.groups[h] = append(ag.groups[h], b)
+	ag.buckets = append(ag.buckets, b)
+}
+
+// Aggregated returns the buckets of the goroutines added so far, sorted like
+// Snapshot.Aggregate.
+//
+// s is set as the Snapshot of the result. Its goroutines are not used. The
+// Aggregator must not be used afterward.
+func (ag *Aggregator) Aggregated(s *Snapshot) *Aggregated {
+	for _, b := range ag.buckets {
+		sort.Ints(b.IDs)
+	}
+	sortBuckets(ag.buckets)
+	return &Aggregated{
+		Snapshot:        s,
+		Buckets:         ag.buckets,
+		TotalBuckets:    len(ag.buckets),
+		TotalGoroutines: ag.total,
+	}
+}
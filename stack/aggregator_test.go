@@ -0,0 +1,44 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestAggregator(t *testing.T) {
+	t.Parallel()
+	s, _, err := ScanSnapshot(bytes.NewReader(internaltest.StaticPanicwebOutput()), io.Discard, DefaultOpts())
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	for _, similar := range []Similarity{ExactLines, AnyPointer, AnyValue} {
+		want := s.Aggregate(similar)
+		ag := NewAggregator(similar)
+		for _, g := range s.Goroutines {
+			ag.Add(g)
+		}
+		got := ag.Aggregated(s)
+		if got.TotalBuckets != want.TotalBuckets || got.TotalGoroutines != want.TotalGoroutines {
+			t.Fatalf("%d: want %d buckets of %d goroutines, got %d of %d", similar, want.TotalBuckets, want.TotalGoroutines, got.TotalBuckets, got.TotalGoroutines)
+		}
+		// The arguments are not named, so only compare the goroutines in each
+		// bucket.
+		for i := range want.Buckets {
+			w, g := want.Buckets[i], got.Buckets[i]
+			if diff := cmp.Diff(w.IDs, g.IDs); diff != "" {
+				t.Fatalf("%d: bucket %d: IDs (-want +got):\n%s", similar, i, diff)
+			}
+			if w.State != g.State || w.Hash() != g.Hash() || w.First != g.First {
+				t.Fatalf("%d: bucket %d: want %s %s, got %s %s", similar, i, w.State, w.Hash(), g.State, g.Hash())
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how likely a bucket is to be a problem, as found by
+// Aggregated.Analyze.
+type Severity int
+
+// Severities, from the least to the most likely to be a problem.
+const (
+	SeverityNone Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+)
+
+// String returns the lowercase name of the severity, e.g. "high".
+func (s Severity) String() string {
+	switch s {
+	case SeverityNone:
+		return "none"
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Suspicion is a reason why a bucket looks like a goroutine leak or a
+// contention.
+type Suspicion struct {
+	Severity    Severity
+	Explanation string
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// AnalyzeOpts are the thresholds used by Aggregated.Analyze.
+type AnalyzeOpts struct {
+	// MinGoroutines is the number of blocked goroutines from which a bucket is
+	// considered large. Defaults to 100.
+	MinGoroutines int
+	// MinSleep is the wait time in minutes from which a goroutine is
+	// considered stuck. Defaults to 10.
+	MinSleep int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Analyze flags the buckets that look like a goroutine leak or a contention,
+// and sets the Suspicions member of each bucket.
+//
+// The heuristics are:
+//   - goroutines blocked forever on a nil channel, or that the runtime
+//     reported as leaked;
+//   - large buckets blocked on a channel operation, more so when they have
+//     all been waiting for a long time;
+//   - goroutines waiting on the same sync.Mutex or sync.RWMutex, as found by
+//     the pointer value of the receiver. This requires the goroutines to be
+//     in Snapshot.
+//
+// opts can be nil to use the defaults.
+func (a *Aggregated) Analyze(opts *AnalyzeOpts) {
+	minGoroutines, minSleep := 100, 10
+	if opts != nil {
+		if opts.MinGoroutines > 0 {
+			minGoroutines = opts.MinGoroutines
+		}
+		if opts.MinSleep > 0 {
+			minSleep = opts.MinSleep
+		}
+	}
+	bucketOf := map[int]*Bucket{}
+	for _, b := range a.Buckets {
+		b.Suspicions = nil
+		for _, id := range b.IDs {
+			bucketOf[id] = b
+		}
+		b.analyzeState(minGoroutines, minSleep)
+	}
+	if a.Snapshot == nil {
+		return
+	}
+
+	// Group the goroutines waiting on a lock by its address.
+	waiters := map[uint64][]*Goroutine{}
+	for _, g := range a.Goroutines {
+		if _, ok := bucketOf[g.ID]; ok && isBlockedState(g.State) {
+			if ptr := lockAddress(&g.Stack); ptr != 0 {
+				waiters[ptr] = append(waiters[ptr], g)
+			}
+		}
+	}
+	ptrs := make([]uint64, 0, len(waiters))
+	for ptr, w := range waiters {
+		if len(w) > 1 {
+			ptrs = append(ptrs, ptr)
+		}
+	}
+	sort.Slice(ptrs, func(i, j int) bool { return ptrs[i] < ptrs[j] })
+	for _, ptr := range ptrs {
+		w := waiters[ptr]
+		sev := SeverityMedium
+		seen := map[*Bucket]bool{}
+		var buckets []*Bucket
+		for _, g := range w {
+			if g.SleepMin >= minSleep {
+				sev = SeverityHigh
+			}
+			if b := bucketOf[g.ID]; !seen[b] {
+				seen[b] = true
+				buckets = append(buckets, b)
+			}
+		}
+		if len(w) >= minGoroutines {
+			sev = SeverityHigh
+		}
+		e := fmt.Sprintf("%d goroutines waiting on the same lock 0x%x", len(w), ptr)
+		for _, b := range buckets {
+			b.Suspicions = append(b.Suspicions, Suspicion{Severity: sev, Explanation: e})
+		}
+	}
+}
+
+// Severity returns the highest severity of the bucket's suspicions.
+func (b *Bucket) Severity() Severity {
+	s := SeverityNone
+	for i := range b.Suspicions {
+		if b.Suspicions[i].Severity > s {
+			s = b.Suspicions[i].Severity
+		}
+	}
+	return s
+}
+
+// Private stuff.
+
+// analyzeState flags the bucket based on its state, size and wait time.
+func (b *Bucket) analyzeState(minGoroutines, minSleep int) {
+	switch b.State {
+	case StateLeaked:
+		b.Suspicions = append(b.Suspicions, Suspicion{Severity: SeverityHigh, Explanation: "reported as leaked by the runtime"})
+	case StateChanReceiveNilChan, StateChanSendNilChan:
+		b.Suspicions = append(b.Suspicions, Suspicion{Severity: SeverityHigh, Explanation: "blocked forever on a nil channel"})
+	case StateChanReceive, StateChanSend, StateSelect:
+		// Small buckets and short waits are normal, e.g. idle workers.
+		if len(b.IDs) < minGoroutines {
+			return
+		}
+		if b.SleepMin >= minSleep {
+			b.Suspicions = append(b.Suspicions, Suspicion{Severity: SeverityHigh, Explanation: fmt.Sprintf("%d goroutines blocked on %s for at least %d minutes, likely leaked", len(b.IDs), b.State, b.SleepMin)})
+		} else {
+			b.Suspicions = append(b.Suspicions, Suspicion{Severity: SeverityLow, Explanation: fmt.Sprintf("%d goroutines blocked on %s", len(b.IDs), b.State)})
+		}
+	}
+}
+
+// lockAddress returns the address of the sync.Mutex or sync.RWMutex the stack
+// is waiting on, or 0.
+func lockAddress(s *Stack) uint64 {
+	for i := range s.Calls {
+		c := &s.Calls[i]
+		if c.Func.ImportPath != "sync" && c.Func.ImportPath != "internal/sync" {
+			continue
+		}
+		if !strings.HasPrefix(c.Func.Name, "(*Mutex).") && !strings.HasPrefix(c.Func.Name, "(*RWMutex).") {
+			continue
+		}
+		if len(c.Args.Values) != 0 && c.Args.Values[0].IsPtr {
+			return c.Args.Values[0].Value
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAggregated_Analyze(t *testing.T) {
+	t.Parallel()
+	lock := func(ptr uint64, sleep int) Signature {
+		return Signature{
+			State:    StateSyncMutexLock,
+			SleepMin: sleep,
+			SleepMax: sleep,
+			Stack: Stack{Calls: []Call{
+				newCall("sync.(*Mutex).lockSlow", Args{Values: []Arg{{Value: ptr, IsPtr: true}}}, "/goroot/src/sync/mutex.go", 171),
+				newCall("main.handler", Args{}, "/home/user/go/src/main.go", 20),
+			}},
+		}
+	}
+	s := &Snapshot{Goroutines: []*Goroutine{
+		{Signature: lock(0xc000010000, 0), ID: 1},
+		{Signature: lock(0xc000010000, 12), ID: 2},
+		{Signature: lock(0xc000020000, 0), ID: 3},
+	}}
+	recv := func(n, sleep int) *Bucket {
+		b := &Bucket{Signature: Signature{State: StateChanReceive, SleepMin: sleep, SleepMax: sleep}}
+		for i := 0; i < n; i++ {
+			b.IDs = append(b.IDs, 100+i)
+		}
+		return b
+	}
+	a := &Aggregated{
+		Snapshot: s,
+		Buckets: []*Bucket{
+			{Signature: Signature{State: StateChanSendNilChan}, IDs: []int{10}},
+			recv(3, 0),
+			recv(3, 30),
+			recv(100, 0),
+			recv(100, 30),
+			{Signature: s.Goroutines[0].Signature, IDs: []int{1, 3}},
+			{Signature: s.Goroutines[1].Signature, IDs: []int{2}},
+		},
+	}
+	a.Analyze(nil)
+	want := [][]Suspicion{
+		{{Severity: SeverityHigh, Explanation: "blocked forever on a nil channel"}},
+		nil,
+		nil,
+		{{Severity: SeverityLow, Explanation: "100 goroutines blocked on chan receive"}},
+		{{Severity: SeverityHigh, Explanation: "100 goroutines blocked on chan receive for at least 30 minutes, likely leaked"}},
+		{{Severity: SeverityHigh, Explanation: "2 goroutines waiting on the same lock 0xc000010000"}},
+		{{Severity: SeverityHigh, Explanation: "2 goroutines waiting on the same lock 0xc000010000"}},
+	}
+	var got [][]Suspicion
+	for _, b := range a.Buckets {
+		got = append(got, b.Suspicions)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Suspicion{})); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if s := a.Buckets[1].Severity(); s != SeverityNone {
+		t.Fatalf("unexpected %s", s)
+	}
+
+	// Without the long wait, the contention is only medium.
+	a.Analyze(&AnalyzeOpts{MinSleep: 60})
+	if s := a.Buckets[5].Severity(); s != SeverityMedium {
+		t.Fatalf("unexpected %s", s)
+	}
+	if s := a.Buckets[4].Severity(); s != SeverityLow {
+		t.Fatalf("unexpected %s", s)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package analyzer is the extension point to run custom analyses on a parsed
+// snapshot, e.g. "goroutine holding our DB lock".
+//
+// Analyzers register themselves with Register, normally in an init()
+// function. pp runs all the registered analyzers and prints their findings
+// along its own output.
+//
+// To load an analyzer in pp without recompiling it, build it as a Go plugin
+// whose init() calls Register, then pass it to pp:
+//
+//	go build -buildmode=plugin -o custom.so ./custom
+//	pp -analyzer ./custom.so
+//
+// Go plugins must be built with the exact same toolchain and dependency
+// versions as pp itself, and are only supported on linux, darwin and freebsd.
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Finding is one result of an Analyzer.
+type Finding struct {
+	// Analyzer is the name of the Analyzer that generated the finding. It is
+	// set by Run.
+	Analyzer string
+	// Message describes the finding.
+	Message string
+	// IDs is the goroutine IDs involved, if any.
+	IDs []int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// String returns a one line description of the finding.
+func (f *Finding) String() string {
+	s := "[" + f.Analyzer + "] " + f.Message
+	if len(f.IDs) != 0 {
+		s += fmt.Sprintf(" (goroutines %v)", f.IDs)
+	}
+	return s
+}
+
+// Analyzer analyzes a snapshot.
+type Analyzer interface {
+	// Analyze returns the findings in the snapshot. It must not modify s.
+	Analyze(s *stack.Snapshot) []Finding
+}
+
+// AnalyzerFunc is a function implementing Analyzer.
+type AnalyzerFunc func(s *stack.Snapshot) []Finding
+
+// Analyze implements Analyzer.
+func (f AnalyzerFunc) Analyze(s *stack.Snapshot) []Finding {
+	return f(s)
+}
+
+// Register registers an analyzer under a unique name.
+//
+// It panics if the name is already registered.
+func Register(name string, a Analyzer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("analyzer %q is already registered", name))
+	}
+	registry[name] = a
+}
+
+// Names returns the sorted names of the registered analyzers.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]string, 0, len(registry))
+	for k := range registry {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Run runs all the registered analyzers on s, in the order of their names.
+func Run(s *stack.Snapshot) []Finding {
+	var out []Finding
+	for _, name := range Names() {
+		mu.Lock()
+		a := registry[name]
+		mu.Unlock()
+		for _, f := range a.Analyze(s) {
+			f.Analyzer = name
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Private stuff.
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Analyzer{}
+)
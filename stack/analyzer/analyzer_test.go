@@ -0,0 +1,41 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestRun(t *testing.T) {
+	Register("b", AnalyzerFunc(func(s *stack.Snapshot) []Finding {
+		return []Finding{{Message: "second", IDs: []int{1, 2}}}
+	}))
+	Register("a", AnalyzerFunc(func(s *stack.Snapshot) []Finding {
+		return []Finding{{Message: "first"}}
+	}))
+	defer func() {
+		mu.Lock()
+		registry = map[string]Analyzer{}
+		mu.Unlock()
+	}()
+	var got []string
+	for _, f := range Run(&stack.Snapshot{}) {
+		got = append(got, f.String())
+	}
+	want := []string{"[a] first", "[b] second (goroutines [1 2])"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	Register("a", AnalyzerFunc(func(s *stack.Snapshot) []Finding { return nil }))
+}
@@ -5,7 +5,9 @@
 package stack
 
 import (
+	"fmt"
 	"sort"
+	"strconv"
 )
 
 // Similarity is the level at which two call lines arguments must match to be
@@ -31,6 +33,22 @@ type Aggregated struct {
 
 	Buckets []*Bucket
 
+	// TotalBuckets is the number of buckets created by Aggregate. Prune keeps
+	// it unchanged, so it includes the buckets collapsed into "other".
+	TotalBuckets int
+	// TotalGoroutines is the number of goroutines that were aggregated.
+	//
+	// Formatters that skip buckets can use both totals to tell how much of the
+	// snapshot is shown. A caller that removed goroutines from the snapshot
+	// before aggregating it can set it to the original count.
+	TotalGoroutines int
+
+	// Previous is the snapshot passed to Track, if any.
+	Previous *Snapshot
+	// Gone is the sorted IDs of the goroutines of Previous that are not in
+	// Snapshot anymore. It is set by Track.
+	Gone []int
+
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
@@ -41,8 +59,9 @@ type Aggregated struct {
 // reorder at your choosing.
 func (s *Snapshot) Aggregate(similar Similarity) *Aggregated {
 	type count struct {
-		ids   []int
-		first bool
+		ids    []int
+		first  bool
+		system bool
 	}
 	b := map[*Signature]*count{}
 	// O(n²). Fix eventually.
@@ -54,6 +73,7 @@ func (s *Snapshot) Aggregate(similar Similarity) *Aggregated {
 				found = true
 				c.ids = append(c.ids, routine.ID)
 				c.first = c.first || routine.First
+				c.system = c.system || routine.OnSystemStack
 				if !key.equal(&routine.Signature) {
 					// Almost but not quite equal. There's different pointers passed
 					// around but the same values. Zap out the different values.
@@ -68,14 +88,31 @@ func (s *Snapshot) Aggregate(similar Similarity) *Aggregated {
 			// Create a copy of the Signature, since it will be mutated.
 			key := &Signature{}
 			*key = routine.Signature
-			b[key] = &count{ids: []int{routine.ID}, first: routine.First}
+			b[key] = &count{ids: []int{routine.ID}, first: routine.First, system: routine.OnSystemStack}
 		}
 	}
+	byID := make(map[int]*Goroutine, len(s.Goroutines))
+	for _, g := range s.Goroutines {
+		byID[g.ID] = g
+	}
 	bs := make([]*Bucket, 0, len(b))
 	for signature, c := range b {
 		sort.Ints(c.ids)
-		bs = append(bs, &Bucket{Signature: *signature, IDs: c.ids, First: c.first})
+		bucket := &Bucket{Signature: *signature, IDs: c.ids, First: c.first, OnSystemStack: c.system}
+		bucket.nameArguments(byID)
+		bs = append(bs, bucket)
+	}
+	sortBuckets(bs)
+	return &Aggregated{
+		Snapshot:        s,
+		Buckets:         bs,
+		TotalBuckets:    len(bs),
+		TotalGoroutines: len(s.Goroutines),
 	}
+}
+
+// sortBuckets sorts the buckets in library provided order of relevancy.
+func sortBuckets(bs []*Bucket) {
 	// Do reverse sort.
 	sort.SliceStable(bs, func(i, j int) bool {
 		l := bs[i]
@@ -89,12 +126,88 @@ func (s *Snapshot) Aggregate(similar Similarity) *Aggregated {
 		if r.Signature.less(&l.Signature) {
 			return false
 		}
-		return len(r.IDs) > len(l.IDs)
+		if len(r.IDs) != len(l.IDs) {
+			return len(r.IDs) > len(l.IDs)
+		}
+		// Otherwise the order would depend on the map iteration order.
+		return l.IDs[0] < r.IDs[0]
 	})
-	return &Aggregated{
-		Snapshot: s,
-		Buckets:  bs,
+}
+
+// Prune returns a copy of the buckets with only the ones with at least
+// minCount goroutines or for which keep returns true. keep can be nil.
+//
+// The bucket with the first goroutine is always kept. The other buckets are
+// collapsed into a single bucket at the end, with a State like "other (123
+// buckets, 150 goroutines)" and an empty stack, so the long tail remains
+// visible.
+func (a *Aggregated) Prune(minCount int, keep func(*Bucket) bool) *Aggregated {
+	out := &Aggregated{
+		Snapshot:        a.Snapshot,
+		Buckets:         make([]*Bucket, 0, len(a.Buckets)),
+		TotalBuckets:    a.TotalBuckets,
+		TotalGoroutines: a.TotalGoroutines,
+		Previous:        a.Previous,
+		Gone:            a.Gone,
 	}
+	var ids, existing []int
+	pruned := 0
+	for _, b := range a.Buckets {
+		if b.First || len(b.IDs) >= minCount || (keep != nil && keep(b)) {
+			out.Buckets = append(out.Buckets, b)
+			continue
+		}
+		ids = append(ids, b.IDs...)
+		existing = append(existing, b.Existing...)
+		pruned++
+	}
+	if pruned != 0 {
+		sort.Ints(ids)
+		o := &Bucket{
+			Signature: Signature{State: fmt.Sprintf("other (%d buckets, %d goroutines)", pruned, len(ids))},
+			IDs:       ids,
+		}
+		if a.Previous != nil {
+			sort.Ints(existing)
+			o.Existing = append([]int{}, existing...)
+		}
+		out.Buckets = append(out.Buckets, o)
+	}
+	return out
+}
+
+// Track compares the goroutines with the ones in prev, a previous snapshot of
+// the same process, to tell which goroutines are stuck and which are
+// churning.
+//
+// It sets Previous, Gone and the Existing member of each bucket. Goroutines
+// are matched by ID, which the runtime never reuses. This is meaningless for
+// goroutine profiles since their IDs are synthetic.
+func (a *Aggregated) Track(prev *Snapshot) {
+	before := make(map[int]bool, len(prev.Goroutines))
+	for _, g := range prev.Goroutines {
+		before[g.ID] = true
+	}
+	now := make(map[int]bool, len(a.Goroutines))
+	for _, g := range a.Goroutines {
+		now[g.ID] = true
+	}
+	for _, b := range a.Buckets {
+		b.Existing = []int{}
+		for _, id := range b.IDs {
+			if before[id] {
+				b.Existing = append(b.Existing, id)
+			}
+		}
+	}
+	a.Previous = prev
+	a.Gone = nil
+	for _, g := range prev.Goroutines {
+		if !now[g.ID] {
+			a.Gone = append(a.Gone, g.ID)
+		}
+	}
+	sort.Ints(a.Gone)
 }
 
 // Bucket is a stack trace signature and the list of goroutines that fits this
@@ -107,7 +220,160 @@ type Bucket struct {
 	// First is true if this Bucket contains the first goroutine, e.g. the one
 	// Signature that likely generated the panic() call, if any.
 	First bool
+	// OnSystemStack is true if this Bucket contains a goroutine on a system
+	// stack. See Goroutine.OnSystemStack.
+	OnSystemStack bool
+	// Existing is the subset of IDs that were already present in the previous
+	// snapshot; the others are new. It is nil unless Aggregated.Track was
+	// called.
+	Existing []int
+	// Suspicions are the reasons why the bucket looks like a goroutine leak or
+	// a contention. It is nil unless Aggregated.Analyze was called.
+	Suspicions []Suspicion
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// nameArguments names the merged arguments of the bucket that refer to the
+// same object in each goroutine of the bucket.
+//
+// When goroutines are merged, arguments with different values are renamed to
+// "*". When two such arguments point to the same object in every goroutine,
+// they are renamed "*1", "*2", etc so the relationship is kept.
+func (b *Bucket) nameArguments(byID map[int]*Goroutine) {
+	if len(b.IDs) < 2 {
+		return
+	}
+	// Collect the merged arguments in walk order.
+	var merged []*Arg
+	for i := range b.Stack.Calls {
+		b.Stack.Calls[i].Args.walk(func(arg *Arg) {
+			merged = append(merged, arg)
+		})
+	}
+	// For each goroutine, the list of values in the same order.
+	values := make([][]uint64, 0, len(b.IDs))
+	for _, id := range b.IDs {
+		g := byID[id]
+		if g == nil {
+			return
+		}
+		var v []uint64
+		for i := range g.Stack.Calls {
+			g.Stack.Calls[i].Args.walk(func(arg *Arg) {
+				v = append(v, arg.Value)
+			})
+		}
+		if len(v) != len(merged) {
+			return
+		}
+		values = append(values, v)
+	}
+	nextID := 1
+	named := make([]bool, len(merged))
+	for i, arg := range merged {
+		if named[i] || arg.Name != "*" || !arg.IsPtr {
+			continue
+		}
+		var same []int
+		for j := i + 1; j < len(merged); j++ {
+			if named[j] || merged[j].Name != "*" || !merged[j].IsPtr {
+				continue
+			}
+			eq := true
+			for _, v := range values {
+				if v[i] != v[j] {
+					eq = false
+					break
+				}
+			}
+			if eq {
+				same = append(same, j)
+			}
+		}
+		if len(same) == 0 {
+			continue
+		}
+		name := "*" + strconv.Itoa(nextID)
+		nextID++
+		arg.Name = name
+		for _, j := range same {
+			merged[j].Name = name
+			named[j] = true
+		}
+	}
+}
+
+// CreationNode is a node in the goroutine creation tree as returned by
+// Aggregated.CreationTree.
+type CreationNode struct {
+	// Bucket is the bucket of the goroutines in this node.
+	*Bucket
+	// IDs is the subset of Bucket.IDs that are in this node. Goroutines in the
+	// same bucket but created by different parents are in separate nodes.
+	IDs []int
+	// Children are the nodes of the goroutines created by the goroutines in
+	// this node.
+	Children []*CreationNode
+	// Total is the number of goroutines in this node and all its descendants.
+	Total int
 
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
+
+// CreationTree returns the goroutines organized by which goroutine created
+// them.
+//
+// It is Snapshot.Tree with the sibling goroutines grouped by bucket, see it
+// for how the roots are determined. The goroutines not in any bucket, e.g.
+// removed by Prune, are skipped and their children take their place. Within a
+// level, nodes are sorted in the same order as Buckets.
+func (a *Aggregated) CreationTree() []*CreationNode {
+	order := make(map[*Bucket]int, len(a.Buckets))
+	bucketOf := map[int]*Bucket{}
+	for i, b := range a.Buckets {
+		order[b] = i
+		for _, id := range b.IDs {
+			bucketOf[id] = b
+		}
+	}
+	var build func(nodes []*GoroutineNode) []*CreationNode
+	build = func(nodes []*GoroutineNode) []*CreationNode {
+		m := map[*Bucket]*CreationNode{}
+		var out []*CreationNode
+		// sub are the children of the goroutines in each node.
+		sub := map[*CreationNode][]*GoroutineNode{}
+		for len(nodes) != 0 {
+			g := nodes[0]
+			nodes = nodes[1:]
+			b := bucketOf[g.ID]
+			if b == nil {
+				nodes = append(nodes, g.Children...)
+				continue
+			}
+			n := m[b]
+			if n == nil {
+				n = &CreationNode{Bucket: b}
+				m[b] = n
+				out = append(out, n)
+			}
+			n.IDs = append(n.IDs, g.ID)
+			sub[n] = append(sub[n], g.Children...)
+		}
+		for _, n := range out {
+			sort.Ints(n.IDs)
+			n.Children = build(sub[n])
+			n.Total = len(n.IDs)
+			for _, c := range n.Children {
+				n.Total += c.Total
+			}
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			return order[out[i].Bucket] < order[out[j].Bucket]
+		})
+		return out
+	}
+	return build(a.Tree())
+}
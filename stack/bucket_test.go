@@ -7,6 +7,7 @@ package stack
 import (
 	"bytes"
 	"io"
+	"sort"
 	"strings"
 	"testing"
 
@@ -363,6 +364,137 @@ func TestAggregateDeadlockPanic(t *testing.T) {
 	compareString(t, "", string(suffix))
 }
 
+func TestAggregateNameArguments(t *testing.T) {
+	t.Parallel()
+	// Each goroutine passes the same object twice, and a different one once.
+	data := []string{
+		"goroutine 6 [chan receive]:",
+		"main.func·001(0x11000000, 0x11000000, 0x31000000)",
+		"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:72 +0x49",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.func·001(0x21000000, 0x21000000, 0x41000000)",
+		"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:72 +0x49",
+		"",
+	}
+	s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(data, "\n")), io.Discard, defaultOpts())
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	want := map[string][]int{"#1": {6}, "#2": {7}, "#3": {7}}
+	if diff := cmp.Diff(want, s.NamedPointers); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	a := s.Aggregate(AnyPointer)
+	if len(a.Buckets) != 1 {
+		t.Fatalf("unexpected %d buckets", len(a.Buckets))
+	}
+	got := a.Buckets[0].Stack.Calls[0].Args.String()
+	compareString(t, "*1, *1, *", got)
+}
+
+func TestCreationTree(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:10 +0x1",
+		"",
+		"goroutine 6 [IO wait]:",
+		"main.accept()",
+		"\t/a/main.go:20 +0x1",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:11 +0x1",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+		"goroutine 8 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+		"goroutine 9 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 42",
+		"\t/a/main.go:21 +0x1",
+		"",
+	}
+	s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(data, "\n")), io.Discard, defaultOpts())
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if got := s.Goroutines[1].CreatedByID; got != 1 {
+		t.Fatalf("unexpected CreatedByID %d", got)
+	}
+	a := s.Aggregate(AnyValue)
+	roots := a.CreationTree()
+	// Goroutine 9 was created by a goroutine that is not in the snapshot.
+	if len(roots) != 2 {
+		t.Fatalf("unexpected %d roots", len(roots))
+	}
+	if diff := cmp.Diff([]int{1}, roots[0].IDs); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if roots[0].Total != 4 {
+		t.Fatalf("unexpected total %d", roots[0].Total)
+	}
+	accept := roots[0].Children
+	if len(accept) != 1 || len(accept[0].Children) != 1 {
+		t.Fatal("unexpected tree shape")
+	}
+	serve := accept[0].Children[0]
+	if diff := cmp.Diff([]int{7, 8}, serve.IDs); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	// The bucket also contains goroutine 9, which is in another subtree.
+	if diff := cmp.Diff([]int{7, 8, 9}, serve.Bucket.IDs); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{9}, roots[1].IDs); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+func TestCreationTreeCycle(t *testing.T) {
+	t.Parallel()
+	s := &Snapshot{
+		Goroutines: []*Goroutine{
+			{ID: 1, Signature: Signature{State: "running"}},
+			{ID: 2, Signature: Signature{State: "chan receive"}, CreatedByID: 3},
+			{ID: 3, Signature: Signature{State: "chan receive"}, CreatedByID: 2},
+			{ID: 4, Signature: Signature{State: "select"}, CreatedByID: 3},
+		},
+	}
+	roots := s.Aggregate(AnyValue).CreationTree()
+	// Goroutines 2 and 3 created each other, they must not be dropped.
+	var got []int
+	var walk func(n []*CreationNode)
+	walk = func(n []*CreationNode) {
+		for _, c := range n {
+			got = append(got, c.IDs...)
+			walk(c.Children)
+		}
+	}
+	walk(roots)
+	sort.Ints(got)
+	if diff := cmp.Diff([]int{1, 2, 3, 4}, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	total := 0
+	for _, n := range roots {
+		total += n.Total
+	}
+	if total != 4 {
+		t.Fatalf("unexpected total %d", total)
+	}
+}
+
 func BenchmarkAggregate(b *testing.B) {
 	b.ReportAllocs()
 	s, suffix, err := ScanSnapshot(bytes.NewReader(internaltest.StaticPanicwebOutput()), io.Discard, defaultOpts())
@@ -390,3 +522,114 @@ func compareBuckets(t *testing.T, want, got []*Bucket) {
 		t.Fatalf("Bucket mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestAggregateIgnoreTrailingRuntime(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [chan receive]:",
+		"main.f()",
+		"\t/a/main.go:5 +0x13",
+		"created by main.main",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.f()",
+		"\t/a/main.go:5 +0x13",
+		"runtime.goexit()",
+		"\t/goroot/src/runtime/asm_amd64.s:1650 +0x1",
+		"created by main.main",
+		"\t/a/main.go:9 +0x13",
+		"",
+	}, "\n")
+	for _, ignore := range []bool{false, true} {
+		s, _, err := ScanSnapshot(strings.NewReader(in), io.Discard, &Opts{IgnoreTrailingRuntime: ignore})
+		if err != io.EOF {
+			t.Fatal(err)
+		}
+		want := 2
+		if ignore {
+			want = 1
+		}
+		a := s.Aggregate(AnyPointer)
+		if len(a.Buckets) != want {
+			t.Fatalf("%t: unexpected %d buckets", ignore, len(a.Buckets))
+		}
+		if got := s.Goroutines[0].Hash() == s.Goroutines[1].Hash(); got != ignore {
+			t.Fatalf("%t: unexpected hash equality", ignore)
+		}
+		// The frame is kept for display.
+		if len(s.Goroutines[1].Stack.Calls) != 2 {
+			t.Fatalf("%t: unexpected %d calls", ignore, len(s.Goroutines[1].Stack.Calls))
+		}
+	}
+}
+
+func TestAggregated_Prune(t *testing.T) {
+	t.Parallel()
+	a := &Aggregated{
+		Buckets: []*Bucket{
+			{Signature: Signature{State: "running"}, IDs: []int{1}, First: true},
+			{Signature: Signature{State: "chan receive"}, IDs: []int{2, 3, 4}},
+			{Signature: Signature{State: "select"}, IDs: []int{9}},
+			{Signature: Signature{State: "IO wait"}, IDs: []int{5}},
+			{Signature: Signature{State: "sleep"}, IDs: []int{6, 7}},
+		},
+	}
+	got := a.Prune(3, func(b *Bucket) bool { return b.State == "IO wait" })
+	var states []string
+	for _, b := range got.Buckets {
+		states = append(states, b.State)
+	}
+	want := []string{"running", "chan receive", "IO wait", "other (2 buckets, 3 goroutines)"}
+	if diff := cmp.Diff(want, states); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{6, 7, 9}, got.Buckets[3].IDs); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if len(a.Buckets) != 5 {
+		t.Fatal("the original must not be modified")
+	}
+	if got = a.Prune(1, nil); len(got.Buckets) != 5 {
+		t.Fatalf("unexpected %d buckets", len(got.Buckets))
+	}
+}
+
+func TestAggregated_Track(t *testing.T) {
+	t.Parallel()
+	prev := &Snapshot{Goroutines: []*Goroutine{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 8}}}
+	a := &Aggregated{
+		Snapshot: &Snapshot{Goroutines: []*Goroutine{{ID: 1}, {ID: 2}, {ID: 4}, {ID: 5}, {ID: 6}}},
+		Buckets: []*Bucket{
+			{Signature: Signature{State: "running"}, IDs: []int{1}, First: true},
+			{Signature: Signature{State: "chan receive"}, IDs: []int{2, 4}},
+			{Signature: Signature{State: "select"}, IDs: []int{5}},
+			{Signature: Signature{State: "sleep"}, IDs: []int{6}},
+		},
+	}
+	if a.Buckets[0].Existing != nil {
+		t.Fatal("Existing must be nil before Track")
+	}
+	a.Track(prev)
+	if a.Previous != prev {
+		t.Fatal("expected Previous to be set")
+	}
+	if diff := cmp.Diff([]int{3, 8}, a.Gone); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	var got [][]int
+	for _, b := range a.Buckets {
+		got = append(got, b.Existing)
+	}
+	if diff := cmp.Diff([][]int{{1}, {2}, {}, {}}, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	// Prune keeps the tracking.
+	p := a.Prune(2, nil)
+	if p.Previous != prev || len(p.Gone) != 2 {
+		t.Fatal("Prune must keep the tracking")
+	}
+	if o := p.Buckets[len(p.Buckets)-1]; o.Existing == nil || len(o.Existing) != 0 {
+		t.Fatalf("unexpected %v", o.Existing)
+	}
+}
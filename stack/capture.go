@@ -0,0 +1,44 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"time"
+)
+
+// Capture returns a snapshot of the stack traces of the current process.
+//
+// When all is false, only the current goroutine is included. The stack traces
+// are collected with runtime.Stack and parsed with DefaultOpts(), so the
+// arguments are processed from the local sources when available.
+//
+// CapturedAt is set to when the goroutines were collected.
+func Capture(all bool) (*Snapshot, error) {
+	// We don't know how big the buffer needs to be to collect all the
+	// goroutines. Start with 64 KiB and double until it fits.
+	buf := make([]byte, 64<<10)
+	now := time.Now()
+	for {
+		n := runtime.Stack(buf, all)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+		now = time.Now()
+	}
+	s, _, err := ScanSnapshot(bytes.NewReader(buf), io.Discard, DefaultOpts())
+	// That's expected.
+	if err == io.EOF {
+		err = nil
+	}
+	if s != nil {
+		s.CapturedAt = now
+	}
+	return s, err
+}
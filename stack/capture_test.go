@@ -0,0 +1,62 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+	s, err := Capture(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Goroutines) != 1 {
+		t.Fatalf("expected 1 goroutine, got %d", len(s.Goroutines))
+	}
+	if !s.Goroutines[0].First {
+		t.Fatal("expected the current goroutine to be first")
+	}
+	if !hasCall(s.Goroutines[0], "TestCapture") {
+		t.Fatalf("TestCapture not found in %+v", s.Goroutines[0].Stack.Calls)
+	}
+	if s.CapturedAt.IsZero() {
+		t.Fatal("expected CapturedAt to be set")
+	}
+
+	// Another goroutine must show up.
+	c := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-c
+	}()
+	defer func() {
+		close(c)
+		<-done
+	}()
+	if s, err = Capture(true); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, g := range s.Goroutines {
+		if hasCall(g, "TestCapture.func1") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("goroutine not found")
+	}
+}
+
+func hasCall(g *Goroutine, name string) bool {
+	for _, c := range g.Stack.Calls {
+		if c.Func.Name == name {
+			return true
+		}
+	}
+	return false
+}
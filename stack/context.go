@@ -5,6 +5,8 @@
 //go:generate go install golang.org/x/tools/cmd/stringer@latest
 //go:generate stringer -type state
 //go:generate stringer -type Location
+//go:generate stringer -type Dialect
+//go:generate stringer -type Confidence
 
 package stack
 
@@ -22,7 +24,10 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unsafe"
+
+	"github.com/maruel/panicparse/v2/stack/rawparse"
 )
 
 // Opts represents options to process the snapshot.
@@ -56,6 +61,88 @@ type Opts struct {
 	// Requires GuessPaths to be true.
 	AnalyzeSources bool
 
+	// ResolveModules tells panicparse to set Call.Module for the calls in a
+	// go module, reading the "require" directives of the go.mod files found
+	// in Snapshot.LocalGomods to tell whether the module is a direct or an
+	// indirect dependency.
+	//
+	// Requires GuessPaths to be true.
+	ResolveModules bool
+
+	// ArgsLimits limits how much of the aggregate arguments are kept in
+	// Args.Processed when AnalyzeSources is true.
+	ArgsLimits ArgsLimits
+
+	// SanitizeUTF8 replaces invalid UTF-8 sequences in the function names and
+	// source paths with U+FFFD, so the JSON and HTML outputs stay valid when
+	// the stack trace comes from a corrupted log.
+	//
+	// Snapshot.Warnings lists the goroutines that were sanitized.
+	SanitizeUTF8 bool
+
+	// IgnoreTrailingRuntime ignores the runtime.goexit frames at the bottom of
+	// the stacks when comparing and hashing them. Only some Go versions print
+	// these frames, so this keeps the buckets and Signature.Hash() stable
+	// across versions. The frames are kept in Stack.Calls for display.
+	//
+	// Stack.TrailingRuntime is set to the number of frames ignored.
+	IgnoreTrailingRuntime bool
+
+	// Strict tells ScanSnapshot to return an error when a line inside the
+	// stack trace could not be parsed, instead of silently considering it the
+	// end of the trace and returning it as part of the suffix.
+	//
+	// A line is considered inside the stack trace when the lines following it,
+	// up to the next empty line, look like they are part of a goroutine dump.
+	// The lines checked are included in the suffix.
+	//
+	// This is meant for test harnesses, so that changes in the format of a new
+	// Go version fail loudly.
+	Strict bool
+
+	// Binary is the path to the executable that generated the stack trace.
+	//
+	// When set, the pc offset printed after each source line, e.g. "+0x1a5",
+	// is looked up in the DWARF line table of the executable to insert the
+	// calls that were inlined in the frame, with Call.Inlined set. This is
+	// useful for the Go versions that do not print inlined frames, and for
+	// the frames the runtime could not expand.
+	//
+	// The calls that only have a program counter are also resolved with the
+	// Go symbol table of the executable, see Snapshot.Symbolize.
+	//
+	// The executable must be the exact one that crashed and must not have
+	// been stripped. Failures are reported in Snapshot.Warnings.
+	Binary string
+
+	// MaxBytes is the maximum number of bytes ScanSnapshot and FirstGoroutine
+	// read from the input. 0 means no limit.
+	//
+	// When the limit is reached before the snapshot is complete, parsing stops
+	// and a *MaxBytesError is returned along with what was parsed so far. This
+	// is meant for services that parse untrusted uploads.
+	MaxBytes int64
+
+	// GoVersion is the Go version that produced the stack trace, e.g.
+	// "go1.22.3", if known. A language version like "go1.22" matches any of
+	// its releases.
+	//
+	// When GuessPaths is true and it differs from the version of LocalGOROOT,
+	// the standard library sources of this version are used instead, as
+	// returned by FindGOROOT. When not set, Snapshot.DialectVersion is used
+	// if it is newer than the local version.
+	//
+	// When the matching sources are not found, LocalGOROOT is used and the
+	// mismatch is reported in Snapshot.Warnings.
+	GoVersion string
+
+	// FindGOROOT returns a directory with the standard library sources of the
+	// Go version, with "/" as path separator, to use instead of LocalGOROOT.
+	// See GoVersion.
+	//
+	// The version can be a language version like "go1.22".
+	FindGOROOT func(version string) (string, error)
+
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
@@ -76,7 +163,7 @@ func DefaultOpts() *Opts {
 }
 
 func (o *Opts) isValid() bool {
-	if !o.GuessPaths && o.AnalyzeSources {
+	if !o.GuessPaths && (o.AnalyzeSources || o.ResolveModules) {
 		return false
 	}
 	if strings.Contains(o.LocalGOROOT, "\\") {
@@ -97,11 +184,58 @@ type Snapshot struct {
 	// They are in the order that they were printed.
 	Goroutines []*Goroutine
 
-	// LocalGOROOT is copied from Opts.
+	// LocalGOROOT is copied from Opts. It is replaced with the sources of
+	// Opts.GoVersion when found, see Opts.FindGOROOT.
 	LocalGOROOT string
 	// LocalGOPATHs is copied from Opts.
 	LocalGOPATHs []string
 
+	// StackOverflow is true if the stack trace was generated by a "goroutine
+	// stack exceeds" fatal error. In this case, the repeated calls of the first
+	// goroutine, the one that overflowed, are folded. See Stack.Folded.
+	StackOverflow bool
+
+	// PanicValue is the recovered panic value logged right before the stack
+	// trace, e.g. by log.Printf("panic: %v\n%s", r, debug.Stack()).
+	//
+	// It is a heuristic: the line must contain "panic: " and be immediately
+	// followed by the first goroutine header, without an empty line in between
+	// like the runtime prints. The line is then not written to the prefix.
+	PanicValue string
+
+	// LikelyTruncated is true if the snapshot likely only contains the
+	// goroutine that crashed because GOTRACEBACK was not set to "all", so the
+	// other goroutines were not printed.
+	//
+	// It is a heuristic: there is a single goroutine, it is running and it
+	// panicked, hit a fatal runtime error or overflowed its stack. Use it to
+	// prompt the user to capture again with GOTRACEBACK=all.
+	LikelyTruncated bool
+
+	// DialectVersion is the oldest Go version whose stack trace format has all
+	// the features found while parsing, e.g. "go1.21". It is "go1" when no
+	// version specific feature was found.
+	//
+	// It is meant to help triage parsing issues caused by format changes in
+	// new Go versions.
+	DialectVersion string
+
+	// CapturedAt is when the stack trace was captured, if known.
+	//
+	// The stack trace doesn't include it so ScanSnapshot doesn't set it. For
+	// example webstack sets it to when it collected the goroutines.
+	CapturedAt time.Time
+
+	// Warnings are the non fatal issues found while processing the snapshot,
+	// e.g. the invalid UTF-8 sequences replaced when Opts.SanitizeUTF8 is true.
+	Warnings []string
+
+	// NamedPointers maps each pseudo name assigned to pointer arguments, e.g.
+	// "#1", to the sorted IDs of the goroutines referencing it.
+	//
+	// It is initialized when Opts.NameArguments is true.
+	NamedPointers map[string][]int
+
 	// The following members are initialized when Opts.GuessPaths is true.
 
 	// RemoteGOROOT is the GOROOT as detected in the traceback, not the on the
@@ -116,6 +250,10 @@ type Snapshot struct {
 	// It can be empty if only stdlib code is in the traceback or if no local
 	// sources were matched up. In the general case there is only one entry in
 	// the map.
+	//
+	// Like all the maps in this package, it is marshaled to JSON with sorted
+	// keys, and calls are matched against the longest root first so the
+	// result doesn't depend on the map iteration order.
 	RemoteGOPATHs map[string]string
 
 	// LocalGomods are the root directories containing go.mod or that directly
@@ -132,8 +270,44 @@ type Snapshot struct {
 	//
 	// Unlike GOROOT and GOPATH, it only works with stack traces created in the
 	// local file system, hence "Local" prefix.
+	//
+	// As with RemoteGOPATHs, a call is matched against the longest root first,
+	// so a nested module takes precedence over its parent directory.
 	LocalGomods map[string]string
 
+	// ExitInfo is the process exit disposition printed right after the stack
+	// trace, e.g. "exit status 2", if any.
+	ExitInfo *ExitInfo
+
+	// ProfileSamples are the samples of a goroutine profile, as served by
+	// /debug/pprof/goroutine?debug=1, in the order they were printed.
+	//
+	// The profile is already aggregated by the runtime: each sample is a
+	// bucket of identical stacks. The goroutines are still expanded in
+	// Goroutines with made up IDs, which are the ones listed in each bucket.
+	//
+	// It is nil when the input is not a goroutine profile. It is only set by
+	// ScanSnapshot.
+	ProfileSamples []*Bucket
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// ExitInfo is how the process that printed the stack trace exited, as
+// printed right after the stack trace by the tool that ran it, e.g. "exit
+// status 2" by go run or "signal: killed" by go test.
+type ExitInfo struct {
+	// Line is the trailer line as printed, without its EOL.
+	Line string
+	// Code is the exit code, or -1 if the process was terminated by a signal.
+	Code int
+	// Signal is the name of the signal that terminated the process in lower
+	// case, e.g. "killed" or "segmentation fault", if any.
+	Signal string
+	// CoreDumped is true if the process dumped core.
+	CoreDumped bool
+
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
@@ -152,7 +326,9 @@ type Snapshot struct {
 //
 // Returns io.EOF if all of reader was read.
 //
-// The suffix of the stack trace is returned as []byte.
+// The suffix of the stack trace is returned as []byte. When the suffix starts
+// with a trailer like "exit status 2", it is parsed into Snapshot.ExitInfo
+// but is still included in the suffix.
 //
 // It pipes anything not detected as a panic stack trace from r into out. It
 // assumes there is junk before the actual stack trace. The junk is streamed to
@@ -167,44 +343,278 @@ func ScanSnapshot(in io.Reader, prefix io.Writer, opts *Opts) (*Snapshot, []byte
 			LocalGOROOT:  opts.LocalGOROOT,
 			LocalGOPATHs: opts.LocalGOPATHs,
 		},
-		state: looking,
+		state:       looking,
+		keepOffsets: opts.Binary != "",
 	}
-	r := reader{rd: in}
+	r := reader{rd: limitReader(in, opts.MaxBytes)}
 	var err error
 	var suffix []byte
+	lineno := 0
 	for err == nil && s.state != done {
 		var d []byte
 		if d, err = r.readLine(); len(d) != 0 {
+			lineno++
+			if isSchedLine(d) {
+				// Scheduler traces can be printed in the middle of the stack trace.
+				if _, err1 := prefix.Write(d); err1 != nil && (err == nil || err == io.EOF) {
+					err = err1
+				}
+				continue
+			}
 			l, err1 := s.scan(d)
 			if err1 != nil && (err == nil || err == io.EOF) {
 				err = err1
 			}
 			if !l {
 				if s.state != looking {
+					s.ExitInfo = parseExitInfo(d)
 					suffix = append([]byte{}, d...)
+					if opts.Strict && (err == nil || err == io.EOF) {
+						suffix, err = s.checkStrict(&r, suffix, lineno, err)
+					}
 					suffix = append(suffix, r.buffered()...)
 					break
 				}
-				if _, err1 = prefix.Write(d); err1 != nil && (err == nil || err == io.EOF) {
+				if _, err1 = prefix.Write(s.unhold(d)); err1 != nil && (err == nil || err == io.EOF) {
 					err = err1
 					break
 				}
 			}
 		}
 	}
+	if s.state == done && suffix == nil {
+		// The last line was part of the stack trace, e.g. the footer of a race
+		// report. Return what was read after it.
+		suffix = append(suffix, r.buffered()...)
+	}
+	if len(s.held) != 0 && s.Goroutines == nil {
+		// The input ended in the middle of a race report header.
+		if _, err1 := prefix.Write(s.held); err1 != nil && (err == nil || err == io.EOF) {
+			err = err1
+		}
+	}
 	if s.Goroutines != nil {
-		if opts.NameArguments {
-			nameArguments(s.Goroutines)
+		if s.profileCount != 0 {
+			// The goroutine profile was truncated.
+			s.expandProfileSample()
+		}
+		s.symbolize(opts.Binary)
+		s.expandInlined(opts.Binary)
+		s.postProcess(opts)
+		s.ProfileSamples = s.profileSamples()
+		return s.Snapshot, suffix, err
+	}
+	return nil, suffix, err
+}
+
+// FirstGoroutine scans the Reader for a stack trace and returns only the first
+// goroutine found, normally the one that panicked.
+//
+// Parsing stops right after this goroutine, so the rest of a potentially very
+// large dump is neither read nor parsed. Everything before the stack trace is
+// discarded.
+//
+// Returns a nil *Goroutine and a nil error if no stack trace was detected.
+func FirstGoroutine(in io.Reader, opts *Opts) (*Goroutine, error) {
+	if opts == nil || !opts.isValid() {
+		return nil, errors.New("invalid Opts")
+	}
+	s := scanningState{
+		Snapshot: &Snapshot{
+			LocalGOROOT:  opts.LocalGOROOT,
+			LocalGOPATHs: opts.LocalGOPATHs,
+		},
+		state:       looking,
+		keepOffsets: opts.Binary != "",
+	}
+	r := reader{rd: limitReader(in, opts.MaxBytes)}
+	var err error
+	for err == nil && s.state != done && s.state != betweenRoutine {
+		var d []byte
+		if d, err = r.readLine(); len(d) != 0 && !isSchedLine(d) {
+			l, err1 := s.scan(d)
+			if err1 != nil && (err == nil || err == io.EOF) {
+				err = err1
+			}
+			if !l && s.state != looking {
+				break
+			}
+			// Race detector traces list multiple goroutines without an empty line
+			// in between; stop as soon as the second one starts.
+			if len(s.Goroutines) > 1 {
+				s.Goroutines = s.Goroutines[:1]
+				break
+			}
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if s.Goroutines == nil {
+		return nil, err
+	}
+	s.symbolize(opts.Binary)
+	s.expandInlined(opts.Binary)
+	s.postProcess(opts)
+	return s.Goroutines[0], err
+}
+
+// postProcess runs the optional processing steps requested in opts.
+func (s *Snapshot) postProcess(opts *Opts) {
+	s.DialectVersion = s.dialectVersion()
+	if opts.SanitizeUTF8 {
+		s.sanitizeUTF8()
+	}
+	if s.StackOverflow {
+		s.Goroutines[0].Stack.fold()
+	}
+	s.LikelyTruncated = s.likelyTruncated()
+	for _, g := range s.Goroutines {
+		g.OnSystemStack = g.ID == 0 || g.Stack.isSystemStack()
+		if opts.IgnoreTrailingRuntime {
+			g.Stack.TrailingRuntime = g.Stack.trailingRuntime()
+		}
+	}
+	if opts.NameArguments {
+		s.NamedPointers = nameArguments(s.UserGoroutines())
+	}
+	if opts.GuessPaths {
+		s.matchGOROOT(opts)
+		_ = s.guessPaths()
+	}
+	if opts.ResolveModules {
+		s.resolveModules()
+	}
+	if opts.AnalyzeSources {
+		_ = s.augment(&opts.ArgsLimits)
+	}
+}
+
+// fatalFuncs are the calls the runtime uses to crash the process.
+var fatalFuncs = map[string]bool{
+	"runtime.throw":      true,
+	"runtime.fatal":      true,
+	"runtime.fatalthrow": true,
+	"runtime.fatalpanic": true,
+}
+
+// likelyTruncated returns true if only the crashing goroutine was printed.
+func (s *Snapshot) likelyTruncated() bool {
+	if len(s.Goroutines) != 1 || s.IsRace() {
+		return false
+	}
+	if s.StackOverflow {
+		return true
+	}
+	g := s.Goroutines[0]
+	if g.State != StateRunning {
+		return false
+	}
+	if g.Stack.PanicIndex() != -1 {
+		return true
+	}
+	for i := range g.Stack.Calls {
+		if fatalFuncs[g.Stack.Calls[i].Func.Complete] {
+			return true
+		}
+	}
+	return false
+}
+
+// UserGoroutines returns the goroutines that are not on a system stack.
+//
+// The goroutines on a system stack are still in Goroutines.
+func (s *Snapshot) UserGoroutines() []*Goroutine {
+	out := make([]*Goroutine, 0, len(s.Goroutines))
+	for _, g := range s.Goroutines {
+		if !g.OnSystemStack {
+			out = append(out, g)
 		}
-		if opts.GuessPaths {
-			_ = s.guessPaths()
+	}
+	return out
+}
+
+// sanitizeUTF8 replaces the invalid UTF-8 sequences in the calls and adds a
+// warning for each goroutine affected.
+func (s *Snapshot) sanitizeUTF8() {
+	for _, g := range s.Goroutines {
+		n := 0
+		for _, st := range []*Stack{&g.Stack, &g.CreatedBy} {
+			for i := range st.Calls {
+				if st.Calls[i].sanitizeUTF8() {
+					n++
+				}
+			}
 		}
-		if opts.AnalyzeSources {
-			_ = s.augment()
+		if n != 0 {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("goroutine %d: replaced invalid UTF-8 in %d calls", g.ID, n))
 		}
-		return s.Snapshot, suffix, err
 	}
-	return nil, suffix, err
+}
+
+// dialectVersion returns the oldest Go version that generates all the
+// features found in the goroutines.
+func (s *Snapshot) dialectVersion() string {
+	minor := 0
+	atLeast := func(m int) {
+		if m > minor {
+			minor = m
+		}
+	}
+	for _, g := range s.Goroutines {
+		if len(g.RuntimeInfo) != 0 {
+			// "goroutine 1 gp=0x... m=0 mp=0x... [running]:"
+			atLeast(23)
+		}
+		if g.CreatedByID != 0 || g.Stack.ElidedFrames != 0 {
+			// "created by main.main in goroutine 1" and "...N frames elided...".
+			atLeast(21)
+		}
+		for i := range g.Stack.Calls {
+			g.Stack.Calls[i].Args.walk(func(a *Arg) {
+				if a.IsInaccurate {
+					// "0x1?"
+					atLeast(18)
+				} else if a.IsOffsetTooLarge {
+					// "_"
+					atLeast(17)
+				}
+			})
+			for _, a := range g.Stack.Calls[i].Args.Values {
+				if a.IsAggregate {
+					// "{0x1, 0x2}"
+					atLeast(17)
+				}
+			}
+		}
+	}
+	if minor == 0 {
+		return "go1"
+	}
+	return "go1." + strconv.Itoa(minor)
+}
+
+// StackOverflowSummary returns a one line description of the stack overflow,
+// e.g. "stack overflow in main.recurse (depth > 1M)".
+//
+// Returns an empty string if StackOverflow is false.
+func (s *Snapshot) StackOverflowSummary() string {
+	if !s.StackOverflow {
+		return ""
+	}
+	st := &s.Goroutines[0].Stack
+	if len(st.Calls) == 0 {
+		return "stack overflow"
+	}
+	d := st.Depth()
+	depth := strconv.Itoa(d)
+	switch {
+	case d >= 1000000:
+		depth = strconv.Itoa(d/1000000) + "M"
+	case d >= 1000:
+		depth = strconv.Itoa(d/1000) + "K"
+	}
+	return "stack overflow in " + st.Calls[0].Func.Complete + " (depth > " + depth + ")"
 }
 
 // IsRace returns true if a race detector stack trace was found.
@@ -213,15 +623,20 @@ func ScanSnapshot(in io.Reader, prefix io.Writer, opts *Opts) (*Snapshot, []byte
 //
 // When a race condition was detected, it is preferable to not call Aggregate().
 func (s *Snapshot) IsRace() bool {
-	return s.Goroutines[0].RaceAddr != 0
+	return len(s.Goroutines) != 0 && s.Goroutines[0].RaceAddr != 0
 }
 
 func (s *Snapshot) guessPaths() bool {
-	b := s.findRoots() == 0
+	// The same cache is used to find the roots and to match the calls against
+	// them, so both agree on the canonical paths.
+	pc := pathCache{}
+	b := s.findRoots(pc) == 0
+	gomods := newRoots(s.LocalGomods)
+	gopaths := newRoots(s.RemoteGOPATHs)
 	for _, r := range s.Goroutines {
 		// Note that this is important to call it even if
 		// s.RemoteGOROOT == s.LocalGOROOT.
-		b = r.updateLocations(s.RemoteGOROOT, s.LocalGOROOT, s.LocalGomods, s.RemoteGOPATHs) && b
+		b = r.updateLocations(s.RemoteGOROOT, s.LocalGOROOT, gomods, gopaths, pc) && b
 	}
 	return b
 }
@@ -231,14 +646,16 @@ func (s *Snapshot) guessPaths() bool {
 // It modifies goroutines in place. It requires calling guessPaths() to work
 // properly.
 //
+// l limits how much of the aggregate arguments are kept, it can be nil.
+//
 // Returns the last error that occurred while processing files.
-func (s *Snapshot) augment() error {
+func (s *Snapshot) augment(l *ArgsLimits) error {
 	c := cacheAST{
-		files:  map[string][]byte{},
 		parsed: map[string]*parsedFile{},
+		limits: l,
 	}
 	var err error
-	for _, g := range s.Goroutines {
+	for _, g := range s.UserGoroutines() {
 		if err1 := c.augmentGoroutine(g); err1 != nil {
 			err = err1
 		}
@@ -252,76 +669,89 @@ const pathSeparator = string(filepath.Separator)
 
 var (
 	lockedToThread = []byte("locked to thread")
-	framesElided   = []byte("...additional frames elided...")
+	stackOverflow  = []byte("runtime: goroutine stack exceeds ")
 	// gotRaceHeader1, done
 	raceHeaderFooter = []byte("==================")
 	// gotRaceHeader2
-	raceHeader             = []byte("WARNING: DATA RACE")
+	raceHeader = []byte("WARNING: DATA RACE")
+	// gotRaceGoroutineFile
+	raceStackUnavailable   = []byte("[failed to restore the stack]")
 	crlf                   = []byte("\r\n")
 	lf                     = []byte("\n")
 	commaSpace             = []byte(", ")
-	writeCap               = []byte("Write")
-	writeLow               = []byte("write")
 	threeDots              = []byte("...")
 	underscore             = []byte("_")
 	inaccurateQuestionMark = []byte("?")
 )
 
+// indent matches the indentation of the source file lines.
+//
+// The runtime prints a tab but log shippers and copy-paste frequently replace
+// it with spaces, so any run of tabs and spaces is accepted. The function
+// lines are never indented so this is not ambiguous.
+const indent = "[\t ]+"
+
+// shellSignals are the messages printed by bash for the common signals.
+const shellSignals = "(Killed|Terminated|Aborted|Segmentation fault|Quit|Bus error|Illegal instruction|Hangup|Floating point exception)"
+
 // These are effectively constants.
 var (
 	// gotRoutineHeader
-	reRoutineHeader = regexp.MustCompile("^([ \t]*)goroutine (\\d+) \\[([^\\]]+)\\]\\:$")
-	reMinutes       = regexp.MustCompile(`^(\d+) minutes$`)
+	reMinutes = regexp.MustCompile(`^(\d+) minutes$`)
 
 	// gotUnavail
-	reUnavail = regexp.MustCompile("^(?:\t| +)goroutine running on other thread; stack unavailable")
-
-	// gotFileFunc, gotRaceOperationFile, gotRaceGoroutineFile
-	// See gentraceback() in src/runtime/traceback.go for more information.
-	// - Sometimes the source file comes up as "<autogenerated>". It is the
-	//   compiler than generated these, not the runtime.
-	// - The tab may be replaced with spaces when a user copy-paste it, handle
-	//   this transparently.
-	// - "runtime.gopanic" is explicitly replaced with "panic" by gentraceback().
-	// - The +0x123 byte offset is printed when frame.pc > _func.entry. _func is
-	//   generated by the linker.
-	// - The +0x123 byte offset is not included with generated code, e.g. unnamed
-	//   functions "func·006()" which is generally go func() { ... }()
-	//   statements. Since the _func is generated at runtime, it's probably why
-	//   _func.entry is not set.
-	// - C calls may have fp=0x123 sp=0x123 appended. I think it normally happens
-	//   when a signal is not correctly handled. It is printed with m.throwing>0.
-	//   These are discarded.
-	// - For cgo, the source file may be "??".
-	reFile = regexp.MustCompile("^(?:\t| +)(\\?\\?|\\<autogenerated\\>|.+\\.(?:c|go|s))\\:(\\d+)(?:| \\+0x[0-9a-f]+)(?:| fp=0x[0-9a-f]+ sp=0x[0-9a-f]+(?:| pc=0x[0-9a-f]+))$")
-
-	// gotCreated
-	// Sadly, it doesn't note the goroutine number so we could cascade them per
-	// parenthood.
-	reCreated = regexp.MustCompile("^created by (.+)$")
-
-	// gotFunc, gotRaceOperationFunc, gotRaceGoroutineFunc
-	reFunc = regexp.MustCompile(`^(.+)\((.*)\)$`)
+	reUnavail = regexp.MustCompile("^" + indent + "goroutine running on other thread; stack unavailable")
 
 	// Race:
 	// See https://github.com/llvm/llvm-project/blob/HEAD/compiler-rt/lib/tsan/rtl/tsan_report.cpp
 	// for the code generating these messages. Please note only the block in
 	//   #else  // #if !SANITIZER_GO
 	// is used.
-	// TODO(maruel): "    [failed to restore the stack]\n\n"
-	// TODO(maruel): "Global var %s of size %zu at %p declared at %s:%zu\n"
 
 	// gotRaceOperationHeader
-	reRaceOperationHeader = regexp.MustCompile(`^(Read|Write) at (0x[0-9a-f]+) by goroutine (\d+):$`)
-
-	// gotRaceOperationHeader
-	reRacePreviousOperationHeader = regexp.MustCompile(`^Previous (read|write) at (0x[0-9a-f]+) by goroutine (\d+):$`)
+	// "Read at 0x00c0000e4030 by goroutine 7:" or "Previous write at
+	// 0x00c0000e4030 by main goroutine:". Atomic operations are prefixed and
+	// the mutexes held may be appended.
+	reRaceOperationHeader = regexp.MustCompile(`^(Previous )?((?:[Aa]tomic )?(?:[Rr]ead|[Ww]rite)) at (0x[0-9a-f]+) by (?:goroutine (\d+)|main goroutine)(?: \(mutexes: [^)]*\))?:$`)
 
 	// gotRaceGoroutineHeader
-	reRaceGoroutine = regexp.MustCompile(`^Goroutine (\d+) \((running|finished)\) created at:$`)
-
-	// TODO(maruel): Use it.
-	//reRacePreviousOperationMainHeader = regexp.MustCompile("^Previous (read|write) at (0x[0-9a-f]+) by main goroutine:$")
+	reRaceGoroutine = regexp.MustCompile(`^Goroutine (\d+) \(([a-z]+)\) created at:$`)
+
+	// Lines that are expected in a race report but carry no information for
+	// us. They are ignored anywhere in the report.
+	reRaceIgnored = regexp.MustCompile(`^(?:SUMMARY: .*|Global var .*|\[failed to restore the stack\])$`)
+
+	// Goroutine profile, /debug/pprof/goroutine?debug=1:
+	// See printCountProfile() in src/runtime/pprof/pprof.go.
+
+	// gotProfileHeader
+	reProfileHeader = regexp.MustCompile(`^goroutine profile: total \d+$`)
+
+	// gotProfileSample
+	reProfileSample = regexp.MustCompile(`^(\d+) @(?: 0x[0-9a-f]+)+$`)
+
+	// gotProfileSample
+	reProfileLabels = regexp.MustCompile(`^# labels: \{(.*)\}$`)
+	reProfileLabel  = regexp.MustCompile(`("(?:[^"\\]|\\.)*"):("(?:[^"\\]|\\.)*")`)
+
+	// gotProfileCall
+	// Frames without symbol only have the pc, see Snapshot.Symbolize. The tabs may be replaced with
+	// spaces, see indent.
+	reProfileCall = regexp.MustCompile(`^#` + indent + `0x([0-9a-f]+)(?:` + indent + `(\S+)\+0x[0-9a-f]+` + indent + `(.+):(\d+))?$`)
+
+	// Trailers, printed after the stack trace when the process exits.
+
+	// "exit status 2" is printed by go run and go test, from
+	// os/exec.ExitError.
+	reExitStatus = regexp.MustCompile(`^exit status (\d+)$`)
+	// "signal: killed" is printed by go run and go test when the process is
+	// terminated by a signal.
+	reExitSignal = regexp.MustCompile(`^signal: (.+?)( \(core dumped\))?$`)
+	// "Killed" is printed by shells like bash when the process is terminated by
+	// a signal. In a script, it is prefixed by the script name and the job
+	// details, e.g. "./run.sh: line 3:  1234 Killed   ./app".
+	reExitShell    = regexp.MustCompile(`^` + shellSignals + `( \(core dumped\))?$`)
+	reExitShellJob = regexp.MustCompile(`^.+: line \d+: +\d+ ` + shellSignals + `( \(core dumped\))?(?: +.*)?$`)
 )
 
 // state is the state of the scan to detect and process a stack trace.
@@ -343,31 +773,31 @@ const (
 	// from: gotFileCreated, gotFileFunc
 	// to: gotRoutineHeader, done
 	betweenRoutine
-	// Regexp: reRoutineHeader
+	// Token: rawparse.GoroutineHeader
 	// Signature: "goroutine 1 [running]:"
 	// Goroutine header was found.
 	// from: looking
 	// to: gotUnavail, gotFunc
 	gotRoutineHeader
-	// Regexp: reFunc
+	// Token: rawparse.FuncLine
 	// Signature: "main.main()"
 	// Function call line was found.
 	// from: gotRoutineHeader
 	// to: gotFileFunc
 	gotFunc
-	// Regexp: reCreated
+	// Token: rawparse.CreatedBy
 	// Signature: "created by main.glob..func4"
 	// Goroutine creation line was found.
 	// from: gotFileFunc
 	// to: gotFileCreated
 	gotCreated
-	// Regexp: reFile
+	// Token: rawparse.FileLine
 	// Signature: "\t/foo/bar/baz.go:116 +0x35"
 	// File header was found.
 	// from: gotFunc
 	// to: gotFunc, gotCreated, betweenRoutine, done
 	gotFileFunc
-	// Regexp: reFile
+	// Token: rawparse.FileLine
 	// Signature: "\t/foo/bar/baz.go:116 +0x35"
 	// File header was found.
 	// from: gotCreated
@@ -384,27 +814,28 @@ const (
 
 	// Constant: raceHeaderFooter
 	// Signature: "=================="
+	// The line is held until the next one confirms it is a race report.
 	// from: looking
-	// to: done, gotRaceHeader2
+	// to: looking, gotRaceHeader2
 	gotRaceHeader1
-	// Constant: raceHeader
+	// Token: rawparse.RaceMarker
 	// Signature: "WARNING: DATA RACE"
 	// from: gotRaceHeader1
-	// to: done, gotRaceOperationHeader
+	// to: looking, gotRaceOperationHeader
 	gotRaceHeader2
-	// Regexp: reRaceOperationHeader, reRacePreviousOperationHeader
+	// Regexp: reRaceOperationHeader
 	// Signature: "Read at 0x00c0000e4030 by goroutine 7:"
 	// A race operation was found.
-	// from: gotRaceHeader2
-	// to: done, gotRaceOperationFunc
+	// from: gotRaceHeader2, betweenRaceOperations
+	// to: done, gotRaceOperationFunc, betweenRaceOperations
 	gotRaceOperationHeader
-	// Regexp: reFunc
+	// Token: rawparse.FuncLine
 	// Signature: "  main.panicRace.func1()"
 	// Function that caused the race.
 	// from: gotRaceOperationHeader
 	// to: done, gotRaceOperationFile
 	gotRaceOperationFunc
-	// Regexp: reFile
+	// Token: rawparse.FileLine
 	// Signature: "\t/foo/bar/baz.go:116 +0x35"
 	// File header that caused the race.
 	// from: gotRaceOperationFunc
@@ -412,7 +843,7 @@ const (
 	gotRaceOperationFile
 	// Signature: ""
 	// Empty line between race operations or just after.
-	// from: gotRaceOperationFile
+	// from: gotRaceOperationHeader, gotRaceOperationFile
 	// to: done, gotRaceOperationHeader, gotRaceGoroutineHeader
 	betweenRaceOperations
 
@@ -420,25 +851,55 @@ const (
 	// Signature: "Goroutine 7 (running) created at:"
 	// Goroutine header.
 	// from: betweenRaceOperations, betweenRaceGoroutines
-	// to: done, gotRaceOperationHeader
+	// to: done, gotRaceGoroutineFunc, betweenRaceGoroutines
 	gotRaceGoroutineHeader
-	// Regexp: reFunc
+	// Token: rawparse.FuncLine
 	// Signature: "  main.panicRace.func1()"
 	// Function that caused the race.
 	// from: gotRaceGoroutineHeader
 	// to: done, gotRaceGoroutineFile
 	gotRaceGoroutineFunc
-	// Regexp: reFile
+	// Token: rawparse.FileLine
 	// Signature: "\t/foo/bar/baz.go:116 +0x35"
 	// File header that caused the race.
-	// from: gotRaceGoroutineFunc
-	// to: done, betweenRaceGoroutines
+	// Or "  [failed to restore the stack]" right after gotRaceGoroutineHeader.
+	// from: gotRaceGoroutineHeader, gotRaceGoroutineFunc
+	// to: done, gotRaceGoroutineFunc, betweenRaceGoroutines
 	gotRaceGoroutineFile
 	// Signature: ""
 	// Empty line between race stack traces.
-	// from: gotRaceGoroutineFile
+	// from: gotRaceGoroutineHeader, gotRaceGoroutineFile
 	// to: done, gotRaceGoroutineHeader
 	betweenRaceGoroutines
+
+	// In all the race states after gotRaceHeader2, the lines matching
+	// reRaceIgnored and a few unexpected lines, e.g. interleaved output of the
+	// program, are skipped. See raceUnknown.
+
+	// Goroutine profile (debug=1):
+
+	// Regexp: reProfileHeader
+	// Signature: "goroutine profile: total 4"
+	// from: looking
+	// to: done, gotProfileSample
+	gotProfileHeader
+	// Regexp: reProfileSample
+	// Signature: "2 @ 0x43a6d6 0x4068cb 0x46c8c1"
+	// A sample of one or more goroutines with the same stack was found.
+	// from: gotProfileHeader, betweenProfileSamples
+	// to: done, gotProfileCall
+	gotProfileSample
+	// Regexp: reProfileCall
+	// Signature: "#\t0x4b1b5a\tmain.worker+0x3a\t/home/user/main.go:20"
+	// Optionally preceded by the labels: "# labels: {"team":"payments"}"
+	// from: gotProfileSample, gotProfileCall
+	// to: done, gotProfileCall, betweenProfileSamples
+	gotProfileCall
+	// Signature: ""
+	// Empty line between samples.
+	// from: gotProfileCall
+	// to: done, gotProfileSample
+	betweenProfileSamples
 )
 
 // scanningState is the state of the scan to detect and process a stack trace
@@ -448,6 +909,25 @@ type scanningState struct {
 	state          state
 	prefix         []byte
 	goroutineIndex int
+	// profileCount is the number of goroutines of the current goroutine
+	// profile sample.
+	profileCount int
+	// profileStarts is the index in Goroutines of the first goroutine of each
+	// goroutine profile sample.
+	profileStarts []int
+	// keepOffsets tells to record the pc offset of each call in offsets.
+	keepOffsets bool
+	offsets     map[*Goroutine][]uint64
+	// held are the lines of a race report header consumed while it is not
+	// yet known if it is really a race report. They must be output by the
+	// caller if the report is aborted, see unhold.
+	held []byte
+	// panicValue is the value of the logged panic held in held, until the
+	// next line confirms it is followed by a stack trace.
+	panicValue string
+	// raceUnknownLines is the number of unexpected lines ignored in the race
+	// report.
+	raceUnknownLines int
 }
 
 // scan scans one line, updates goroutines and move to the next state.
@@ -501,36 +981,37 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 	case looking:
 		// We could look for '^panic:' but this is more risky, there can be a lot
 		// of junk between this and the stack dump.
+		if bytes.HasPrefix(trimmed, stackOverflow) {
+			s.StackOverflow = true
+		}
+		if s.panicValue != "" {
+			v := s.panicValue
+			s.panicValue = ""
+			if _, ok := rawparse.Parse(rawparse.GoroutineHeader, trimmed); !ok {
+				// Not a logged panic after all, the caller outputs the held line.
+				return false, nil
+			}
+			s.held = nil
+			s.PanicValue = v
+		} else if t, ok := rawparse.Parse(rawparse.PanicHeader, trimmed); ok {
+			// Hold the line in case the next one is not a goroutine header.
+			s.held = append([]byte{}, line...)
+			s.panicValue = string(t.Value)
+			return true, nil
+		}
 		fallthrough
 
 	case betweenRoutine:
 		// Look for a goroutine header.
-		if match := reRoutineHeader.FindSubmatch(trimmed); match != nil {
-			if id, ok := atou(match[2]); ok {
-				// See runtime/traceback.go.
-				// "<state>, \d+ minutes, locked to thread"
-				items := bytes.Split(match[3], commaSpace)
-				sleep := 0
-				locked := false
-				for i := 1; i < len(items); i++ {
-					if bytes.Equal(items[i], lockedToThread) {
-						locked = true
-						continue
-					}
-					// Look for duration, if any.
-					if match2 := reMinutes.FindSubmatch(items[i]); match2 != nil {
-						sleep, _ = atou(match2[1])
-					}
+		if t, ok := rawparse.Parse(rawparse.GoroutineHeader, trimmed); ok {
+			if t.ID >= 0 {
+				g := &Goroutine{ID: t.ID, First: len(s.Goroutines) == 0}
+				parseState(&g.Signature, t.State)
+				for _, e := range t.Extra {
+					g.Extra = append(g.Extra, string(e))
 				}
-				g := &Goroutine{
-					Signature: Signature{
-						State:    string(items[0]),
-						SleepMin: sleep,
-						SleepMax: sleep,
-						Locked:   locked,
-					},
-					ID:    id,
-					First: len(s.Goroutines) == 0,
+				if len(t.RuntimeInfo) != 0 {
+					parseRuntimeInfo(g, t.RuntimeInfo)
 				}
 				// Increase performance by always allocating 4 goroutines minimally.
 				if s.Goroutines == nil {
@@ -538,14 +1019,19 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 				}
 				s.Goroutines = append(s.Goroutines, g)
 				s.state = gotRoutineHeader
-				s.prefix = append([]byte{}, match[1]...)
+				s.prefix = append([]byte{}, t.Indent...)
 				return true, nil
 			}
 		}
+		// Switch to goroutine profile mode.
+		if s.state == looking && reProfileHeader.Match(trimmed) {
+			s.state = gotProfileHeader
+			return true, nil
+		}
 		// Switch to race detection mode.
 		if bytes.Equal(trimmed, raceHeaderFooter) {
-			// TODO(maruel): We should buffer it in case the next line is not a
-			// WARNING so we can output it back.
+			// Hold the line in case the next one is not a race report header.
+			s.held = append([]byte{}, line...)
 			s.state = gotRaceHeader1
 			return true, nil
 		}
@@ -581,6 +1067,12 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 		} else if !found {
 			return false, fmt.Errorf("expected a file after a function, got: %q", bytes.TrimSpace(trimmed))
 		}
+		if s.keepOffsets {
+			if s.offsets == nil {
+				s.offsets = map[*Goroutine][]uint64{}
+			}
+			s.offsets[cur] = append(s.offsets[cur], parsePCOffset(trimmed))
+		}
 		s.state = gotFileFunc
 		return true, nil
 
@@ -594,20 +1086,18 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 		return true, nil
 
 	case gotFileFunc:
-		if match := reCreated.FindSubmatch(trimmed); match != nil {
-			cur.CreatedBy.Calls = make([]Call, 1)
-			if err := cur.CreatedBy.Calls[0].Func.Init(string(match[1])); err != nil {
-				cur.CreatedBy.Calls = nil
+		if t, ok := rawparse.Parse(rawparse.CreatedBy, trimmed); ok {
+			if err := parseCreated(cur, &t); err != nil {
 				return false, err
 			}
-			// This initializes ImportPath.
-			cur.CreatedBy.Calls[0].init("", 0)
 			s.state = gotCreated
 			return true, nil
 		}
-		if bytes.Equal(trimmed, framesElided) {
-			cur.Stack.Elided = true
+		if t, ok := rawparse.Parse(rawparse.FramesElided, trimmed); ok {
+			// With a count, the bottom of the stack follows.
 			// TODO(maruel): New state.
+			cur.Stack.Elided = true
+			cur.Stack.ElidedFrames += t.Frames
 			return true, nil
 		}
 		c := Call{}
@@ -640,10 +1130,8 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 			s.state = betweenRoutine
 			return true, nil
 		}
-		if match := reCreated.FindSubmatch(trimmed); match != nil {
-			cur.CreatedBy.Calls = make([]Call, 1)
-			if err := cur.CreatedBy.Calls[0].Func.Init(string(match[1])); err != nil {
-				cur.CreatedBy.Calls = nil
+		if t, ok := rawparse.Parse(rawparse.CreatedBy, trimmed); ok {
+			if err := parseCreated(cur, &t); err != nil {
 				return false, err
 			}
 			s.state = gotCreated
@@ -654,40 +1142,46 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 		// Race detector.
 
 	case gotRaceHeader1:
-		if bytes.Equal(trimmed, raceHeader) {
-			// TODO(maruel): We should buffer it in case the next line is not a
-			// WARNING so we can output it back.
+		if t, ok := rawparse.Parse(rawparse.RaceMarker, trimmed); ok && len(t.Value) != 0 {
+			s.held = append(s.held, line...)
 			s.state = gotRaceHeader2
 			return true, nil
 		}
-		// TODO(maruel): While this shouldn't error out, it should still force the
-		// output of raceHeaderFooter.
+		// Not a race report after all, the caller outputs the held lines.
 		s.state = looking
 		s.prefix = nil
 		return false, nil
 
 	case gotRaceHeader2:
-		if match := reRaceOperationHeader.FindSubmatch(trimmed); match != nil {
-			w := bytes.Equal(match[1], writeCap)
-			addr, err := strconv.ParseUint(unsafeString(match[2]), 0, 64)
-			if err != nil {
-				return false, fmt.Errorf("failed to parse address on line: %q", bytes.TrimSpace(trimmed))
-			}
-			id, ok := atou(match[3])
-			if !ok {
-				return false, fmt.Errorf("failed to parse goroutine id on line: %q", bytes.TrimSpace(trimmed))
-			}
-			if s.Goroutines != nil {
-				panic("internal failure; expected s.Goroutines to be nil")
-			}
-			s.Goroutines = append(make([]*Goroutine, 0, 4), &Goroutine{ID: id, First: true, RaceWrite: w, RaceAddr: addr})
-			s.goroutineIndex = len(s.Goroutines) - 1
-			s.state = gotRaceOperationHeader
-			return true, nil
+		g, err := parseRaceOperation(trimmed)
+		if err != nil {
+			return false, err
+		}
+		if g == nil {
+			// Not a race report after all, the caller outputs the held lines.
+			s.state = looking
+			s.prefix = nil
+			return false, nil
+		}
+		if s.Goroutines != nil {
+			panic("internal failure; expected s.Goroutines to be nil")
 		}
-		return false, fmt.Errorf("expected race condition, got: %q", bytes.TrimSpace(trimmed))
+		g.First = true
+		s.Goroutines = append(make([]*Goroutine, 0, 4), g)
+		s.goroutineIndex = len(s.Goroutines) - 1
+		s.held = nil
+		s.state = gotRaceOperationHeader
+		return true, nil
 
-	case gotRaceOperationHeader:
+	case gotRaceOperationHeader, gotRaceOperationFile:
+		if len(trimmed) == 0 {
+			s.state = betweenRaceOperations
+			return true, nil
+		}
+		if bytes.Equal(trimmed, raceHeaderFooter) {
+			s.state = done
+			return true, nil
+		}
 		c := Call{}
 		if found, err := parseFunc(&c, trimLeftSpace(trimmed)); found {
 			// Increase performance by always allocating 4 calls minimally.
@@ -698,50 +1192,39 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 			s.state = gotRaceOperationFunc
 			return err == nil, err
 		}
-		return false, fmt.Errorf("expected a function after a race operation, got: %q", trimmed)
+		return s.raceUnknown(trimmed)
 
 	case gotRaceOperationFunc:
 		if found, err := parseFile(&cur.Stack.Calls[len(cur.Stack.Calls)-1], trimmed); err != nil {
 			return false, err
-		} else if !found {
-			return false, fmt.Errorf("expected a file after a race function, got: %q", trimmed)
+		} else if found {
+			s.state = gotRaceOperationFile
+			return true, nil
 		}
-		s.state = gotRaceOperationFile
-		return true, nil
+		return s.raceUnknown(trimmed)
 
-	case gotRaceOperationFile:
+	case betweenRaceOperations, betweenRaceGoroutines:
 		if len(trimmed) == 0 {
-			s.state = betweenRaceOperations
 			return true, nil
 		}
-		c := Call{}
-		if found, err := parseFunc(&c, trimLeftSpace(trimmed)); found {
-			cur.Stack.Calls = append(cur.Stack.Calls, c)
-			s.state = gotRaceOperationFunc
-			return err == nil, err
+		if bytes.Equal(trimmed, raceHeaderFooter) {
+			// Happens when all the goroutines involved are the main goroutine.
+			s.state = done
+			return true, nil
 		}
-		return false, fmt.Errorf("expected an empty line after a race file, got: %q", trimmed)
-
-	case betweenRaceOperations:
-		// Look for other previous race data operations.
-		if match := reRacePreviousOperationHeader.FindSubmatch(trimmed); match != nil {
-			w := bytes.Equal(match[1], writeLow)
-			addr, err := strconv.ParseUint(unsafeString(match[2]), 0, 64)
+		if s.state == betweenRaceOperations {
+			// Look for other previous race data operations.
+			g, err := parseRaceOperation(trimmed)
 			if err != nil {
-				return false, fmt.Errorf("failed to parse address on line: %q", bytes.TrimSpace(trimmed))
+				return false, err
 			}
-			id, ok := atou(match[3])
-			if !ok {
-				return false, fmt.Errorf("failed to parse goroutine id on line: %q", bytes.TrimSpace(trimmed))
+			if g != nil {
+				s.Goroutines = append(s.Goroutines, g)
+				s.goroutineIndex = len(s.Goroutines) - 1
+				s.state = gotRaceOperationHeader
+				return true, nil
 			}
-			s.Goroutines = append(s.Goroutines, &Goroutine{ID: id, RaceWrite: w, RaceAddr: addr})
-			s.goroutineIndex = len(s.Goroutines) - 1
-			s.state = gotRaceOperationHeader
-			return true, nil
 		}
-		fallthrough
-
-	case betweenRaceGoroutines:
 		if match := reRaceGoroutine.FindSubmatch(trimmed); match != nil {
 			id, ok := atou(match[1])
 			if !ok {
@@ -762,7 +1245,7 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 			s.state = gotRaceGoroutineHeader
 			return true, nil
 		}
-		return false, fmt.Errorf("expected an operator or goroutine, got: %q", trimmed)
+		return s.raceUnknown(trimmed)
 
 		// Race stack traces
 
@@ -770,14 +1253,14 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 		c := s.Goroutines[s.goroutineIndex].CreatedBy.Calls
 		if found, err := parseFile(&c[len(c)-1], trimmed); err != nil {
 			return false, err
-		} else if !found {
-			return false, fmt.Errorf("expected a file after a race function, got: %q", trimmed)
+		} else if found {
+			// TODO(maruel): Set s.Goroutines[].CreatedBy.
+			s.state = gotRaceGoroutineFile
+			return true, nil
 		}
-		// TODO(maruel): Set s.Goroutines[].CreatedBy.
-		s.state = gotRaceGoroutineFile
-		return true, nil
+		return s.raceUnknown(trimmed)
 
-	case gotRaceGoroutineFile:
+	case gotRaceGoroutineHeader, gotRaceGoroutineFile:
 		if len(trimmed) == 0 {
 			s.state = betweenRaceGoroutines
 			return true, nil
@@ -786,35 +1269,337 @@ func (s *scanningState) scan(line []byte) (bool, error) {
 			s.state = done
 			return true, nil
 		}
-		fallthrough
-
-	case gotRaceGoroutineHeader:
+		if s.state == gotRaceGoroutineHeader && bytes.Equal(trimLeftSpace(trimmed), raceStackUnavailable) {
+			s.Goroutines[s.goroutineIndex].CreatedBy.Unavailable = true
+			s.state = gotRaceGoroutineFile
+			return true, nil
+		}
 		c := Call{}
 		if found, err := parseFunc(&c, trimLeftSpace(trimmed)); found {
 			s.Goroutines[s.goroutineIndex].CreatedBy.Calls = append(s.Goroutines[s.goroutineIndex].CreatedBy.Calls, c)
 			s.state = gotRaceGoroutineFunc
 			return err == nil, err
 		}
-		return false, fmt.Errorf("expected a function after a race operation or a race file, got: %q", trimmed)
+		return s.raceUnknown(trimmed)
+
+		// Goroutine profile
+
+	case gotProfileHeader, betweenProfileSamples:
+		if match := reProfileSample.FindSubmatch(trimmed); match != nil {
+			if n, ok := atou(match[1]); ok && n > 0 {
+				// The format doesn't include the goroutine IDs, number them.
+				g := &Goroutine{ID: len(s.Goroutines) + 1}
+				s.Goroutines = append(s.Goroutines, g)
+				s.profileCount = n
+				s.state = gotProfileSample
+				return true, nil
+			}
+		}
+		s.state = done
+		return false, nil
+
+	case gotProfileSample:
+		if match := reProfileLabels.FindSubmatch(trimmed); match != nil {
+			cur.Labels = parseLabels(match[1])
+			return true, nil
+		}
+		fallthrough
+
+	case gotProfileCall:
+		if match := reProfileCall.FindSubmatch(trimmed); match != nil {
+			if len(match[2]) != 0 {
+				c := Call{}
+				if err := c.Func.Init(string(match[2])); err != nil {
+					return false, err
+				}
+				line, ok := atou(match[4])
+				if !ok {
+					return false, fmt.Errorf("failed to parse int on line: %q", trimmed)
+				}
+				c.init(string(match[3]), line)
+				cur.Stack.Calls = append(cur.Stack.Calls, c)
+			} else if pc, err := strconv.ParseUint(string(match[1]), 16, 64); err == nil && pc != 0 {
+				// Keep the pc so the call can be symbolized later.
+				cur.Stack.Calls = append(cur.Stack.Calls, Call{PC: pc})
+			}
+			s.state = gotProfileCall
+			return true, nil
+		}
+		s.expandProfileSample()
+		if len(trimmed) == 0 && s.state == gotProfileCall {
+			s.state = betweenProfileSamples
+			return true, nil
+		}
+		s.state = done
+		return false, nil
 
 	default:
 		return false, errors.New("internal error")
 	}
 }
 
+// expandProfileSample duplicates the last goroutine so there is one per
+// goroutine of the current goroutine profile sample.
+func (s *scanningState) expandProfileSample() {
+	g := s.Goroutines[len(s.Goroutines)-1]
+	s.profileStarts = append(s.profileStarts, len(s.Goroutines)-1)
+	for i := 1; i < s.profileCount; i++ {
+		d := &Goroutine{Signature: g.Signature, ID: len(s.Goroutines) + 1}
+		d.Stack.Calls = append([]Call(nil), g.Stack.Calls...)
+		s.Goroutines = append(s.Goroutines, d)
+	}
+	s.profileCount = 0
+}
+
+// profileSamples returns one bucket per goroutine profile sample.
+//
+// It must be called after postProcess so the buckets have the processed
+// calls.
+func (s *scanningState) profileSamples() []*Bucket {
+	if len(s.profileStarts) == 0 {
+		return nil
+	}
+	out := make([]*Bucket, len(s.profileStarts))
+	for i, start := range s.profileStarts {
+		end := len(s.Goroutines)
+		if i+1 < len(s.profileStarts) {
+			end = s.profileStarts[i+1]
+		}
+		g := s.Goroutines[start]
+		b := &Bucket{Signature: g.Signature, IDs: make([]int, 0, end-start), First: g.First, OnSystemStack: g.OnSystemStack}
+		b.Stack.Calls = append([]Call(nil), g.Stack.Calls...)
+		for _, d := range s.Goroutines[start:end] {
+			b.IDs = append(b.IDs, d.ID)
+		}
+		out[i] = b
+	}
+	return out
+}
+
+// parseLabels parses the labels of a goroutine profile sample, e.g.
+// `"team":"payments", "tier":"1"`.
+func parseLabels(line []byte) map[string]string {
+	out := map[string]string{}
+	for _, m := range reProfileLabel.FindAllSubmatch(line, -1) {
+		k, err1 := strconv.Unquote(string(m[1]))
+		v, err2 := strconv.Unquote(string(m[2]))
+		if err1 == nil && err2 == nil {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// checkStrict verifies that the line that ended the stack trace, which is in
+// suffix, is not followed by lines that look like part of a goroutine dump.
+//
+// The lines are read up to the next empty line and appended to suffix.
+// lineno is the line number of the line that ended the stack trace.
+func (s *scanningState) checkStrict(r *reader, suffix []byte, lineno int, err error) ([]byte, error) {
+	bad := suffix
+	for i := 1; err == nil; i++ {
+		var d []byte
+		d, err = r.readLine()
+		suffix = append(suffix, d...)
+		d = bytes.TrimRight(d, "\r\n")
+		if len(d) == 0 {
+			break
+		}
+		if s.isDumpLine(d) {
+			id := 0
+			if len(s.Goroutines) != 0 {
+				id = s.Goroutines[len(s.Goroutines)-1].ID
+			}
+			return suffix, fmt.Errorf("strict: line %d could not be parsed after goroutine %d and the dump continues on line %d: %q", lineno, id, lineno+i, bytes.TrimRight(bad, "\r\n"))
+		}
+	}
+	return suffix, err
+}
+
+// unhold returns the lines held while scanning the header of a race report
+// followed by line, when the report was aborted. Otherwise returns line.
+func (s *scanningState) unhold(line []byte) []byte {
+	if len(s.held) == 0 || s.state != looking {
+		return line
+	}
+	out := append(s.held, line...)
+	s.held = nil
+	return out
+}
+
+// maxRaceUnknown is the number of unexpected lines tolerated in a race report
+// before giving up on it.
+const maxRaceUnknown = 16
+
+// raceUnknown handles a line that is not expected in the current state of a
+// race report.
+//
+// The race detector prints the report while the program keeps running, so the
+// output of the other goroutines may be interleaved with it. These lines are
+// ignored and reported in Warnings, up to maxRaceUnknown.
+func (s *scanningState) raceUnknown(line []byte) (bool, error) {
+	if reRaceIgnored.Match(trimLeftSpace(line)) {
+		return true, nil
+	}
+	if s.raceUnknownLines == maxRaceUnknown {
+		s.state = done
+		return false, nil
+	}
+	s.raceUnknownLines++
+	s.Warnings = append(s.Warnings, fmt.Sprintf("race report: ignored unexpected line %q", line))
+	return true, nil
+}
+
+// parseRaceOperation returns the goroutine of a race operation header, or nil
+// if line is not one.
+func parseRaceOperation(line []byte) (*Goroutine, error) {
+	match := reRaceOperationHeader.FindSubmatch(line)
+	if match == nil {
+		return nil, nil
+	}
+	addr, err := strconv.ParseUint(unsafeString(match[3]), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address on line: %q", bytes.TrimSpace(line))
+	}
+	// The runtime prints "main goroutine" for goroutine 1.
+	id := 1
+	if len(match[4]) != 0 {
+		ok := false
+		if id, ok = atou(match[4]); !ok {
+			return nil, fmt.Errorf("failed to parse goroutine id on line: %q", bytes.TrimSpace(line))
+		}
+	}
+	op := bytes.ToLower(match[2])
+	return &Goroutine{ID: id, RaceWrite: bytes.HasSuffix(op, []byte("write")), RaceAddr: addr}, nil
+}
+
+// parseExitInfo parses a trailer line printed after the stack trace. Returns
+// nil if line is not a known trailer.
+func parseExitInfo(line []byte) *ExitInfo {
+	line = bytes.TrimRight(line, "\r\n")
+	if m := reExitStatus.FindSubmatch(line); m != nil {
+		code, err := strconv.Atoi(string(m[1]))
+		if err != nil {
+			return nil
+		}
+		return &ExitInfo{Line: string(line), Code: code}
+	}
+	m := reExitSignal.FindSubmatch(line)
+	if m == nil {
+		m = reExitShell.FindSubmatch(line)
+	}
+	if m == nil {
+		m = reExitShellJob.FindSubmatch(line)
+	}
+	if m == nil {
+		return nil
+	}
+	return &ExitInfo{
+		Line:       string(line),
+		Code:       -1,
+		Signal:     strings.ToLower(string(m[1])),
+		CoreDumped: len(m[2]) != 0,
+	}
+}
+
+// isDumpLine returns true if line, without its EOL, looks like it is part of
+// a goroutine dump.
+func (s *scanningState) isDumpLine(line []byte) bool {
+	line = bytes.TrimPrefix(line, s.prefix)
+	for _, k := range []rawparse.Kind{rawparse.GoroutineHeader, rawparse.CreatedBy, rawparse.FramesElided} {
+		if _, ok := rawparse.Parse(k, line); ok {
+			return true
+		}
+	}
+	found, _ := parseFile(&Call{}, line)
+	return found
+}
+
+// parseState parses the comma separated attributes between the brackets of a
+// goroutine header into sig.
+//
+// See runtime/traceback.go. It is normally "<state>, \d+ minutes, locked to
+// thread" but the attributes are recognized in any order. The first attribute
+// that is not known is the state, the other ones are stored in Extra, e.g.
+// "synctest bubble 1".
+func parseState(sig *Signature, b []byte) {
+	stateFound := false
+	for _, item := range bytes.Split(b, commaSpace) {
+		if bytes.Equal(item, lockedToThread) {
+			sig.Locked = true
+			continue
+		}
+		if match := reMinutes.FindSubmatch(item); match != nil {
+			sig.SleepMin, _ = atou(match[1])
+			sig.SleepMax = sig.SleepMin
+			continue
+		}
+		if !stateFound {
+			sig.State = string(item)
+			stateFound = true
+			continue
+		}
+		sig.Extra = append(sig.Extra, string(item))
+	}
+}
+
+// parseRuntimeInfo parses the " key=value" pairs of a goroutine header.
+//
+// Values of "nil" are skipped.
+func parseRuntimeInfo(g *Goroutine, kv []byte) {
+	g.RuntimeInfo = map[string]uint64{}
+	for _, f := range bytes.Fields(kv) {
+		i := bytes.IndexByte(f, '=')
+		v, err := strconv.ParseUint(unsafeString(f[i+1:]), 0, 64)
+		if err != nil {
+			// nil.
+			continue
+		}
+		g.RuntimeInfo[string(f[:i])] = v
+	}
+	g.GP = g.RuntimeInfo["gp"]
+	if mp, ok := g.RuntimeInfo["mp"]; ok {
+		g.M = int(g.RuntimeInfo["m"])
+		g.MP = mp
+	}
+}
+
+// parseCreated initializes the CreatedBy member of g with the
+// rawparse.CreatedBy token.
+func parseCreated(g *Goroutine, t *rawparse.Token) error {
+	g.CreatedBy.Calls = make([]Call, 1)
+	if err := g.CreatedBy.Calls[0].Func.Init(string(t.Func)); err != nil {
+		g.CreatedBy.Calls = nil
+		return err
+	}
+	// This initializes ImportPath.
+	g.CreatedBy.Calls[0].init("", 0)
+	if len(t.Args) != 0 {
+		args, err := parseArgs(t.Args)
+		if err != nil {
+			return err
+		}
+		g.CreatedBy.Calls[0].Args = args
+	}
+	if t.ID > 0 {
+		g.CreatedByID = t.ID
+	}
+	return nil
+}
+
 // parseFunc only return an error if it also returns true.
 //
-// Uses reFunc.
+// Uses rawparse.FuncLine.
 func parseFunc(c *Call, line []byte) (bool, error) {
-	if match := reFunc.FindSubmatch(line); match != nil {
-		if err := c.Func.Init(string(match[1])); err != nil {
+	if t, ok := rawparse.Parse(rawparse.FuncLine, line); ok {
+		if err := c.Func.Init(string(t.Func)); err != nil {
 			return true, err
 		}
 		// It is also done in c.init() but do it here in case of a corrupted trace
 		// for the file section.
 		c.ImportPath = c.Func.ImportPath
 
-		args, err := parseArgs(match[2])
+		args, err := parseArgs(t.Args)
 		if err != nil {
 			return true, fmt.Errorf("%s on line: %q", err, bytes.TrimSpace(line))
 		}
@@ -885,24 +1670,92 @@ func parseArgs(line []byte) (Args, error) {
 
 // parseFile only return an error if also processing a Call.
 //
-// Uses reFile.
+// Uses rawparse.FileLine.
 func parseFile(c *Call, line []byte) (bool, error) {
-	if match := reFile.FindSubmatch(line); match != nil {
-		num, ok := atou(match[2])
-		if !ok {
+	if t, ok := rawparse.Parse(rawparse.FileLine, line); ok {
+		if t.SrcLine < 0 {
 			return true, fmt.Errorf("failed to parse int on line: %q", bytes.TrimSpace(line))
 		}
-		c.init(string(match[1]), num)
+		c.init(string(t.Path), t.SrcLine)
 		return true, nil
 	}
 	return false, nil
 }
 
+// pathCache caches the canonical form of paths.
+//
+// The canonical form has the symlinks evaluated and uses "/" as path
+// separator. It is used to match paths that refer to the same file but are
+// spelled differently, like /var vs /private/var on macOS or a symlinked
+// GOROOT. A nil pathCache is valid and evaluates the symlinks every time.
+type pathCache map[string]string
+
+// resolve returns the canonical form of p, or p itself if it cannot be
+// evaluated, e.g. because it doesn't exist locally.
+func (pc pathCache) resolve(p string) string {
+	if r, ok := pc[p]; ok {
+		return r
+	}
+	r := p
+	l := p
+	if runtime.GOOS == "windows" {
+		l = strings.Replace(p, "/", pathSeparator, -1)
+	}
+	if e, err := filepath.EvalSymlinks(l); err == nil {
+		if runtime.GOOS == "windows" {
+			e = strings.Replace(e, pathSeparator, "/", -1)
+		}
+		r = e
+	}
+	if pc != nil {
+		pc[p] = r
+	}
+	return r
+}
+
+// rel returns the path of p relative to the directory dir, if p is inside
+// dir.
+//
+// The paths are first compared as-is, then in their canonical form.
+func (pc pathCache) rel(p, dir string) (string, bool) {
+	if pathHasPrefix(p, dir+"/") {
+		return p[len(dir)+1:], true
+	}
+	rp := pc.resolve(p)
+	rd := pc.resolve(dir)
+	if (rp != p || rd != dir) && pathHasPrefix(rp, rd+"/") {
+		return rp[len(rd)+1:], true
+	}
+	return "", false
+}
+
+// pathHasPrefix returns true if p starts with prefix.
+//
+// The comparison is case insensitive on Windows.
+func pathHasPrefix(p, prefix string) bool {
+	if len(p) < len(prefix) {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(p[:len(prefix)], prefix)
+	}
+	return p[:len(prefix)] == prefix
+}
+
+// pathKey returns the key to use for p in a map.
+//
+// Paths are case insensitive on Windows.
+func pathKey(p string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToLower(p)
+	}
+	return p
+}
+
 // hasPrefix returns true if any of s is the prefix of p.
-func hasPrefix(p string, s map[string]string) bool {
-	lp := len(p)
+func (pc pathCache) hasPrefix(p string, s map[string]string) bool {
 	for prefix := range s {
-		if l := len(prefix); lp > l+1 && p[:l] == prefix && p[l] == '/' {
+		if _, ok := pc.rel(p, prefix); ok {
 			return true
 		}
 	}
@@ -911,16 +1764,12 @@ func hasPrefix(p string, s map[string]string) bool {
 
 // hasSrcPrefix returns true if any of s is the prefix of p with /src/ or
 // /pkg/mod/.
-func hasSrcPrefix(p string, s map[string]string) bool {
-	lp := len(p)
-	const src = "/src/"
-	const pkgmod = "/pkg/mod/"
+func (pc pathCache) hasSrcPrefix(p string, s map[string]string) bool {
 	for prefix := range s {
-		l := len(prefix)
-		if lp > l+len(src) && p[:l] == prefix && p[l:l+len(src)] == src {
+		if _, ok := pc.rel(p, prefix+"/src"); ok {
 			return true
 		}
-		if lp > l+len(pkgmod) && p[:l] == prefix && p[l:l+len(pkgmod)] == pkgmod {
+		if _, ok := pc.rel(p, prefix+"/pkg/mod"); ok {
 			return true
 		}
 	}
@@ -1001,10 +1850,11 @@ func (g *gomodCache) isGoModule(parts []string) (string, string) {
 	for i := len(parts); i > 0; i-- {
 		prefix := pathJoin(parts[:i]...)
 		// Was already looked up.
-		if _, ok := (*g)[prefix]; ok {
+		k := pathKey(prefix)
+		if _, ok := (*g)[k]; ok {
 			break
 		}
-		(*g)[prefix] = struct{}{}
+		(*g)[k] = struct{}{}
 		p := pathJoin(prefix, "go.mod")
 		if runtime.GOOS == "windows" {
 			p = strings.Replace(p, "/", pathSeparator, -1)
@@ -1026,7 +1876,7 @@ func (g *gomodCache) isGoModule(parts []string) (string, string) {
 // This causes disk I/O as it checks for file presence.
 //
 // Returns the number of missing files.
-func (s *Snapshot) findRoots() int {
+func (s *Snapshot) findRoots(pc pathCache) int {
 	// TODO(maruel): Reduce memory allocations in this function.
 	s.RemoteGOPATHs = map[string]string{}
 	s.LocalGomods = map[string]string{}
@@ -1038,15 +1888,17 @@ func (s *Snapshot) findRoots() int {
 		//log.Printf("  Analyzing %s", f)
 
 		// First checks skip file I/O.
-		if s.RemoteGOROOT != "" && strings.HasPrefix(f, s.RemoteGOROOT+"/src/") {
-			// stdlib.
-			continue
+		if s.RemoteGOROOT != "" {
+			if _, ok := pc.rel(f, s.RemoteGOROOT+"/src"); ok {
+				// stdlib.
+				continue
+			}
 		}
-		if hasSrcPrefix(f, s.RemoteGOPATHs) {
+		if pc.hasSrcPrefix(f, s.RemoteGOPATHs) {
 			// $GOPATH/src or go.mod dependency in $GOPATH/pkg/mod.
 			continue
 		}
-		if hasPrefix(f, s.LocalGomods) {
+		if pc.hasPrefix(f, s.LocalGomods) {
 			continue
 		}
 
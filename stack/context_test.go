@@ -1000,8 +1000,9 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 							},
 						},
 					},
-					ID:    0,
-					First: true,
+					ID:            0,
+					First:         true,
+					OnSystemStack: true,
 				},
 			},
 		},
@@ -1179,6 +1180,57 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 			},
 		},
 
+		// Log shippers may replace the tab with spaces.
+		{
+			name: "SpacesInsteadOfTab",
+			in: []string{
+				"panic: bleh",
+				"",
+				"goroutine 1 [running]:",
+				"main.func1()",
+				"    /gopath/src/main.go:12 +0x20",
+				"main.main()",
+				"  \t/gopath/src/main.go:20 +0x40",
+				"",
+				"goroutine 2 [running]:",
+				"        goroutine running on other thread; stack unavailable",
+				"created by main.main",
+				"        /gopath/src/main.go:19 +0x30",
+				"",
+			},
+			prefix: "panic: bleh\n\n",
+			err:    io.EOF,
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State: "running",
+						Stack: Stack{
+							Calls: []Call{
+								newCall("main.func1", Args{}, "/gopath/src/main.go", 12),
+								newCall("main.main", Args{}, "/gopath/src/main.go", 20),
+							},
+						},
+					},
+					ID:    1,
+					First: true,
+				},
+				{
+					Signature: Signature{
+						State: "running",
+						CreatedBy: Stack{
+							Calls: []Call{
+								newCall("main.main", Args{}, "/gopath/src/main.go", 19),
+							},
+						},
+						Stack: Stack{
+							Calls: []Call{{RemoteSrcPath: "<unavailable>"}},
+						},
+					},
+					ID: 2,
+				},
+			},
+		},
+
 		{
 			name:   "Race",
 			in:     []string{string(internaltest.StaticPanicRaceOutput())},
@@ -1264,6 +1316,239 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 			},
 		},
 
+		{
+			name: "GoroutineThread",
+			in: []string{
+				"panic: bleh",
+				"",
+				"goroutine 1 gp=0xc000002380 m=3 mp=0xc000080008 [running, locked to thread]:",
+				"main.main()",
+				"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:428 +0x27",
+				"",
+				"goroutine 2 gp=0xc000002e00 m=nil [force gc (idle)]:",
+				"runtime.gopark()",
+				"\t/goroot/src/runtime/proc.go:435 +0xce",
+				"",
+			},
+			prefix: "panic: bleh\n\n",
+			err:    io.EOF,
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State:  "running",
+						Locked: true,
+						Stack: Stack{
+							Calls: []Call{
+								newCall(
+									"main.main",
+									Args{},
+									"/gopath/src/github.com/maruel/panicparse/stack/stack.go",
+									428),
+							},
+						},
+					},
+					ID:    1,
+					First: true,
+					GP:    0xc000002380,
+					M:     3,
+					MP:    0xc000080008,
+					RuntimeInfo: map[string]uint64{
+						"gp": 0xc000002380,
+						"m":  3,
+						"mp": 0xc000080008,
+					},
+				},
+				{
+					Signature: Signature{
+						State: "force gc (idle)",
+						Stack: Stack{
+							Calls: []Call{
+								newCall("runtime.gopark", Args{}, "/goroot/src/runtime/proc.go", 435),
+							},
+						},
+					},
+					ID:          2,
+					GP:          0xc000002e00,
+					RuntimeInfo: map[string]uint64{"gp": 0xc000002e00},
+				},
+			},
+		},
+		{
+			// GOTRACEBACK=crash on SIGQUIT, as printed by go1.22 and later. The
+			// register dump and the other threads' traces are left in the suffix.
+			name: "GoroutineThreadSIGQUIT",
+			in: []string{
+				"SIGQUIT: quit",
+				"PC=0x40c84e m=0 sigcode=0",
+				"",
+				"goroutine 0 gp=0xc000006a80 m=1 mp=0xc000050008 [idle]:",
+				"runtime.futex()",
+				"\t/goroot/src/runtime/sys_linux_amd64.s:557 +0x21 fp=0xc000059e90 sp=0xc000059e88 pc=0x47d2a1",
+				"",
+				"rax    0xca",
+				"rbx    0x0",
+				"",
+				"-----",
+				"",
+			},
+			prefix: "SIGQUIT: quit\nPC=0x40c84e m=0 sigcode=0\n\n",
+			suffix: "rax    0xca\nrbx    0x0\n\n-----\n",
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State: "idle",
+						Stack: Stack{
+							Calls: []Call{
+								newCall("runtime.futex", Args{}, "/goroot/src/runtime/sys_linux_amd64.s", 557),
+							},
+						},
+					},
+					ID:            0,
+					First:         true,
+					OnSystemStack: true,
+					GP:            0xc000006a80,
+					M:             1,
+					MP:            0xc000050008,
+					RuntimeInfo: map[string]uint64{
+						"gp": 0xc000006a80,
+						"m":  1,
+						"mp": 0xc000050008,
+					},
+				},
+			},
+		},
+
+		{
+			name: "HeaderExtra",
+			in: []string{
+				"panic: bleh",
+				"",
+				"goroutine 1 [GC worker (idle), 2 minutes, synctest bubble 1] [dedicated]:",
+				"main.main()",
+				"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:428 +0x27",
+				"",
+			},
+			prefix: "panic: bleh\n\n",
+			err:    io.EOF,
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State:    "GC worker (idle)",
+						SleepMin: 2,
+						SleepMax: 2,
+						Extra:    []string{"synctest bubble 1", "dedicated"},
+						Stack: Stack{
+							Calls: []Call{
+								newCall(
+									"main.main",
+									Args{},
+									"/gopath/src/github.com/maruel/panicparse/stack/stack.go",
+									428),
+							},
+						},
+					},
+					ID:    1,
+					First: true,
+				},
+			},
+		},
+
+		{
+			name: "CreatedByArgs",
+			in: []string{
+				"panic: bleh",
+				"",
+				"goroutine 7 [chan receive]:",
+				"main.worker()",
+				"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:428 +0x27",
+				"created by main.(*Pool).start(0xc000010000, 0x2) in goroutine 1",
+				"\t/gopath/src/github.com/maruel/panicparse/stack/stack.go:20 +0x2d",
+				"",
+			},
+			prefix: "panic: bleh\n\n",
+			err:    io.EOF,
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State: "chan receive",
+						CreatedBy: Stack{
+							Calls: []Call{
+								newCall(
+									"main.(*Pool).start",
+									Args{Values: []Arg{{Value: 0xc000010000, IsPtr: true}, {Value: 2}}},
+									"/gopath/src/github.com/maruel/panicparse/stack/stack.go",
+									20),
+							},
+						},
+						Stack: Stack{
+							Calls: []Call{
+								newCall(
+									"main.worker",
+									Args{},
+									"/gopath/src/github.com/maruel/panicparse/stack/stack.go",
+									428),
+							},
+						},
+					},
+					ID:          7,
+					First:       true,
+					CreatedByID: 1,
+				},
+			},
+		},
+
+		{
+			name: "RaceCreatedByUnavailable",
+			in: []string{
+				string(raceHeaderFooter),
+				string(raceHeader),
+				"Read at 0x00c0000e4030 by goroutine 8:",
+				"  main.panicDoRaceRead()",
+				"      /go/src/main.go:137 +0x3a",
+				"",
+				"Previous write at 0x00c0000e4030 by goroutine 7:",
+				"  main.panicDoRaceWrite()",
+				"      /go/src/main.go:132 +0x3a",
+				"",
+				"Goroutine 8 (running) created at:",
+				"  [failed to restore the stack]",
+				"",
+				"Goroutine 7 (finished) created at:",
+				"  main.panicRace()",
+				"      /go/src/main.go:151 +0x8a",
+				string(raceHeaderFooter),
+				"",
+			},
+			want: []*Goroutine{
+				{
+					Signature: Signature{
+						State:     "running",
+						CreatedBy: Stack{Unavailable: true},
+						Stack: Stack{
+							Calls: []Call{newCall("main.panicDoRaceRead", Args{}, "/go/src/main.go", 137)},
+						},
+					},
+					ID:       8,
+					First:    true,
+					RaceAddr: 0xc0000e4030,
+				},
+				{
+					Signature: Signature{
+						State: "finished",
+						CreatedBy: Stack{
+							Calls: []Call{newCall("main.panicRace", Args{}, "/go/src/main.go", 151)},
+						},
+						Stack: Stack{
+							Calls: []Call{newCall("main.panicDoRaceWrite", Args{}, "/go/src/main.go", 132)},
+						},
+					},
+					ID:        7,
+					RaceWrite: true,
+					RaceAddr:  0xc0000e4030,
+				},
+			},
+		},
+
 		{
 			name: "RaceHdr1Err",
 			in: []string{
@@ -1279,8 +1564,7 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 				string(raceHeaderFooter),
 				"",
 			},
-			// TODO(maruel): This is incorrect.
-			prefix: "",
+			prefix: string(raceHeaderFooter) + "\n",
 			err:    io.EOF,
 		},
 
@@ -1290,8 +1574,7 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 				string(raceHeaderFooter),
 				string(raceHeader),
 			},
-			// TODO(maruel): This is incorrect.
-			prefix: "",
+			prefix: string(raceHeaderFooter) + "\n" + string(raceHeader),
 			err:    io.EOF,
 		},
 
@@ -1302,8 +1585,18 @@ func TestScanSnapshotSynthetic(t *testing.T) {
 				string(raceHeader),
 				"",
 			},
-			// TODO(maruel): This is incorrect.
-			prefix: "",
+			prefix: string(raceHeaderFooter) + "\n" + string(raceHeader) + "\n",
+			err:    io.EOF,
+		},
+
+		{
+			name: "RaceHdrNotRace",
+			in: []string{
+				string(raceHeaderFooter),
+				"not a race",
+				"",
+			},
+			prefix: string(raceHeaderFooter) + "\nnot a race\n",
 			err:    io.EOF,
 		},
 	}
@@ -1417,65 +1710,817 @@ func TestScanSnapshotSyntheticTwoSnapshots(t *testing.T) {
 	compareString(t, "Yo\n", string(suffix))
 }
 
-func TestSplitPath(t *testing.T) {
+func TestScanSnapshotStackOverflow(t *testing.T) {
 	t.Parallel()
-	if p := splitPath(""); p != nil {
-		t.Fatalf("expected nil, got: %v", p)
-	}
-}
-
-func TestGetGOPATHs(t *testing.T) {
-	// This test cannot run in parallel.
-	old := os.Getenv("GOPATH")
-	defer os.Setenv("GOPATH", old)
-	os.Setenv("GOPATH", "")
-	if p := getGOPATHs(); len(p) != 1 {
-		// It's the home directory + /go.
-		t.Fatalf("expected only one path: %v", p)
+	lines := []string{
+		"runtime: goroutine stack exceeds 1000000000-byte limit",
+		"runtime: sp=0xc020160398 stack=[0xc020160000, 0xc040160000]",
+		"fatal error: stack overflow",
+		"",
+		"runtime stack:",
+		"runtime.throw({0x4a0fd6?, 0x5b1b20?})",
+		"\t/goroot/src/runtime/panic.go:1023 +0x5c fp=0x7ffd8d6f2d68 sp=0x7ffd8d6f2d38 pc=0x437f1c",
+		"",
+		"goroutine 1 gp=0xc000002380 m=0 mp=0x5b1b20 [running]:",
+	}
+	frames := func(n int) {
+		for i := 0; i < n; i++ {
+			lines = append(lines,
+				"main.recurse(0x0?)",
+				"\t/home/user/src/foo/main.go:3 +0x17 fp=0xc0201603b8 sp=0xc0201603a0 pc=0x47db17",
+				"main.recurse(...)",
+				"\t/home/user/src/foo/main.go:3")
+		}
 	}
-
-	root, err := os.MkdirTemp("", "stack")
-	if err != nil {
+	frames(25)
+	lines = append(lines, "...2000000 frames elided...")
+	frames(25)
+	lines = append(lines,
+		"main.main()",
+		"\t/home/user/src/foo/main.go:5 +0x13 fp=0xc040160f50 sp=0xc040160f40 pc=0x47db53",
+		"",
+		"goroutine 2 gp=0xc000002e00 m=nil [force gc (idle)]:",
+		"runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)",
+		"\t/goroot/src/runtime/proc.go:402 +0xce fp=0xc00004efa8 sp=0xc00004ef88 pc=0x43aaae",
+		"",
+	)
+	s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(lines, "\n")), io.Discard, &Opts{})
+	if err != io.EOF {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err = os.RemoveAll(root); err != nil {
-			t.Error(err)
-		}
-	}()
-	os.Setenv("GOPATH", filepath.Join(root, "a")+string(filepath.ListSeparator)+filepath.Join(root, "b")+string(filepath.Separator))
-	if p := getGOPATHs(); len(p) != 2 {
-		t.Fatalf("expected two paths: %v", p)
+	if !s.StackOverflow {
+		t.Fatal("expected StackOverflow")
 	}
-}
-
-// TestGomoduleComplex is an integration test that creates a non-trivial tree
-// of go modules using the "replace" statement.
-func TestGomoduleComplex(t *testing.T) {
-	// This test cannot run in parallel.
-	if internaltest.GetGoMinorVersion() < 11 {
-		t.Skip("requires go module support")
+	if len(s.Goroutines) != 2 {
+		t.Fatalf("unexpected %d goroutines", len(s.Goroutines))
 	}
-	old := os.Getenv("GOPATH")
-	defer os.Setenv("GOPATH", old)
-	root, err := os.MkdirTemp("", "stack")
-	if err != nil {
-		t.Fatal(err)
+	st := &s.Goroutines[0].Stack
+	if !st.Elided || st.ElidedFrames != 2000000 || st.Folded != 99 || len(st.Calls) != 2 {
+		t.Fatalf("unexpected %t %d %d %d", st.Elided, st.ElidedFrames, st.Folded, len(st.Calls))
 	}
-	defer func() {
-		if err = os.RemoveAll(root); err != nil {
-			t.Error(err)
-		}
-	}()
+	compareString(t, "main.recurse", st.Calls[0].Func.Complete)
+	compareString(t, "main.main", st.Calls[1].Func.Complete)
+	compareString(t, "stack overflow in main.recurse (depth > 2M)", s.StackOverflowSummary())
+	// Only the goroutine that overflowed is folded.
+	if s.Goroutines[1].Stack.Folded != 0 {
+		t.Fatal("unexpected folding")
+	}
+}
 
-	os.Setenv("GOPATH", filepath.Join(root, "go"))
-	os.Setenv("GO111MODULE", "on")
-	tree := map[string]string{
-		"pkg1/go.mod": "module example.com/pkg1\n" +
-			"require (\n" +
-			"\texample.com/pkg2 v0.0.1\n" +
-			"\texample.com/pkg3 v0.0.1\n" +
-			")\n" +
+func TestScanSnapshotStrict(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name   string
+		in     []string
+		suffix string
+		err    string
+	}{
+		{
+			name: "Clean",
+			in: []string{
+				"goroutine 1 [running]:",
+				"main.main()",
+				"\t/home/user/src/foo/main.go:5 +0x13",
+				"exit status 2",
+				"",
+				"goroutine 2 [running]:",
+			},
+			suffix: "exit status 2\n\ngoroutine 2 [running]:",
+			err:    "",
+		},
+		{
+			name: "Unknown",
+			in: []string{
+				"goroutine 1 [running]:",
+				"main.main()",
+				"\t/home/user/src/foo/main.go:5 +0x13",
+				"[new annotation]",
+				"main.foo()",
+				"\t/home/user/src/foo/main.go:9 +0x13",
+				"",
+				"junk",
+			},
+			suffix: "[new annotation]\nmain.foo()\n\t/home/user/src/foo/main.go:9 +0x13\n\njunk",
+			err:    "strict: line 4 could not be parsed after goroutine 1 and the dump continues on line 6: \"[new annotation]\"",
+		},
+	}
+	for i, line := range data {
+		line := line
+		t.Run(fmt.Sprintf("%d-%s", i, line.name), func(t *testing.T) {
+			t.Parallel()
+			in := bytes.NewBufferString(strings.Join(line.in, "\n"))
+			s, suffix, err := ScanSnapshot(in, io.Discard, &Opts{Strict: true})
+			if s == nil {
+				t.Fatal("expected snapshot")
+			}
+			if line.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || err.Error() != line.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			compareString(t, line.suffix, string(suffix))
+		})
+	}
+}
+
+func TestScanSnapshotRaceFormats(t *testing.T) {
+	t.Parallel()
+	type race struct {
+		ID        int
+		RaceWrite bool
+		Calls     int
+		Creator   int
+	}
+	data := []struct {
+		name     string
+		in       []string
+		want     []race
+		warnings int
+		suffix   string
+	}{
+		{
+			name: "go1.12",
+			in: []string{
+				"==================",
+				"WARNING: DATA RACE",
+				"Read at 0x00c0000e4030 by goroutine 7:",
+				"  main.panicDoRaceRead()",
+				"      /go/src/main.go:137 +0x3a",
+				"  main.panicRace.func2()",
+				"      /go/src/main.go:154 +0x38",
+				"",
+				"Previous write at 0x00c0000e4030 by goroutine 6:",
+				"  main.panicDoRaceWrite()",
+				"      /go/src/main.go:132 +0x4a",
+				"",
+				"Goroutine 7 (running) created at:",
+				"  main.panicRace()",
+				"      /go/src/main.go:153 +0x12e",
+				"",
+				"Goroutine 6 (finished) created at:",
+				"  main.panicRace()",
+				"      /go/src/main.go:150 +0xe8",
+				"==================",
+				"Found 1 data race(s)",
+				"exit status 66",
+			},
+			want:   []race{{7, false, 2, 1}, {6, true, 1, 1}},
+			suffix: "Found 1 data race(s)\nexit status 66",
+		},
+		{
+			name: "MainGoroutine",
+			in: []string{
+				"==================",
+				"WARNING: DATA RACE",
+				"Write at 0x00c00001a0f8 by goroutine 7:",
+				"  main.main.func1()",
+				"      /a/main.go:9 +0x3c",
+				"",
+				"Previous read at 0x00c00001a0f8 by main goroutine:",
+				"  main.main()",
+				"      /a/main.go:11 +0xd5",
+				"",
+				"Goroutine 7 (running) created at:",
+				"  main.main()",
+				"      /a/main.go:8 +0xc4",
+				"==================",
+			},
+			want: []race{{7, true, 1, 1}, {1, false, 1, 0}},
+		},
+		{
+			name: "TSanBanner",
+			in: []string{
+				"==================",
+				"WARNING: ThreadSanitizer: data race (pid=1234)",
+				"Atomic write at 0x00c00001a0f8 by goroutine 8 (mutexes: write M1):",
+				"  [failed to restore the stack]",
+				"",
+				"Previous read at 0x00c00001a0f8 by goroutine 9:",
+				"  main.foo()",
+				"      /a/main.go:11 +0xd5",
+				"",
+				"SUMMARY: ThreadSanitizer: data race /a/main.go:11 in main.foo",
+				"==================",
+			},
+			want: []race{{8, true, 0, 0}, {9, false, 1, 0}},
+		},
+		{
+			name: "Interleaved",
+			in: []string{
+				"==================",
+				"WARNING: DATA RACE",
+				"Write at 0x00c00001a0f8 by goroutine 7:",
+				"  main.main.func1()",
+				"2024/01/02 15:04:05 server listening",
+				"      /a/main.go:9 +0x3c",
+				"",
+				"Previous read at 0x00c00001a0f8 by main goroutine:",
+				"  main.main()",
+				"      /a/main.go:11 +0xd5",
+				"request done",
+				"==================",
+			},
+			want:     []race{{7, true, 1, 0}, {1, false, 1, 0}},
+			warnings: 2,
+		},
+	}
+	for i, line := range data {
+		line := line
+		t.Run(fmt.Sprintf("%d-%s", i, line.name), func(t *testing.T) {
+			t.Parallel()
+			prefix := bytes.Buffer{}
+			in := bytes.NewBufferString(strings.Join(line.in, "\n"))
+			s, suffix, err := ScanSnapshot(in, &prefix, &Opts{})
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			if s == nil || !s.IsRace() {
+				t.Fatalf("expected a race, got %v", s)
+			}
+			var got []race
+			for _, g := range s.Goroutines {
+				got = append(got, race{g.ID, g.RaceWrite, len(g.Stack.Calls), len(g.CreatedBy.Calls)})
+			}
+			if diff := cmp.Diff(line.want, got); diff != "" {
+				t.Fatalf("-want, +got:\n%s", diff)
+			}
+			if len(s.Warnings) != line.warnings {
+				t.Fatalf("unexpected warnings: %q", s.Warnings)
+			}
+			compareString(t, "", prefix.String())
+			compareString(t, line.suffix, string(suffix))
+		})
+	}
+}
+
+func TestScanSnapshotMaxBytes(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/home/user/src/foo/main.go:5 +0x13",
+		"",
+		"goroutine 2 [running]:",
+		"main.foo()",
+		"\t/home/user/src/foo/main.go:9 +0x13",
+		"",
+	}, "\n")
+	// The limit is in the middle of the second goroutine.
+	limit := int64(strings.Index(in, "main.foo"))
+	s, _, err := ScanSnapshot(strings.NewReader(in), io.Discard, &Opts{MaxBytes: limit})
+	var m *MaxBytesError
+	if !errors.As(err, &m) || m.Limit != limit {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s == nil || len(s.Goroutines) != 2 || len(s.Goroutines[0].Stack.Calls) != 1 {
+		t.Fatalf("expected the partial snapshot, got %#v", s)
+	}
+	if _, err = FirstGoroutine(strings.NewReader(in), &Opts{MaxBytes: 10}); !errors.As(err, &m) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The limit is not reached when the snapshot completes before.
+	in += "junk\n" + strings.Repeat("x", 100)
+	limit = int64(strings.Index(in, "junk") + 10)
+	if _, suffix, err := ScanSnapshot(strings.NewReader(in), io.Discard, &Opts{MaxBytes: limit}); err != nil {
+		t.Fatal(err)
+	} else if string(suffix) != "junk\nxxxxx" {
+		t.Fatalf("unexpected suffix %q", suffix)
+	}
+
+	// An input of exactly the limit is fine.
+	in = "goroutine 1 [running]:\nmain.main()\n\t/home/user/src/foo/main.go:5 +0x13\n"
+	if _, _, err := ScanSnapshot(strings.NewReader(in), io.Discard, &Opts{MaxBytes: int64(len(in))}); err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestScanSnapshotDialectVersion(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		want string
+		in   []string
+	}{
+		{"go1", []string{"goroutine 1 [running]:", "main.main()", "\t/a/main.go:5 +0x13"}},
+		{"go1.17", []string{"goroutine 1 [running]:", "main.main({0x1, 0x2})", "\t/a/main.go:5 +0x13"}},
+		{"go1.18", []string{"goroutine 1 [running]:", "main.main(0x1?)", "\t/a/main.go:5 +0x13"}},
+		{"go1.21", []string{"goroutine 2 [running]:", "main.f()", "\t/a/main.go:5 +0x13", "created by main.main in goroutine 1", "\t/a/main.go:9 +0x13"}},
+		{"go1.23", []string{"goroutine 1 gp=0xc000002380 m=0 mp=0x5b1b20 [running]:", "main.main()", "\t/a/main.go:5 +0x13"}},
+	}
+	for i, line := range data {
+		s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(line.in, "\n")), io.Discard, &Opts{})
+		if err != io.EOF {
+			t.Fatal(err)
+		}
+		if s.DialectVersion != line.want {
+			t.Fatalf("#%d: want %q, got %q", i, line.want, s.DialectVersion)
+		}
+	}
+}
+
+func TestScanSnapshotSanitizeUTF8(t *testing.T) {
+	t.Parallel()
+	in := "goroutine 1 [running]:\nmain.f\xff()\n\t/a/m\xfein.go:5 +0x13\nmain.main()\n\t/a/main.go:9 +0x13\n"
+	s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{})
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if s.Warnings != nil || s.Goroutines[0].Stack.Calls[0].Func.Complete != "main.f\xff" {
+		t.Fatalf("unexpected %v", s.Warnings)
+	}
+	s, _, err = ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{SanitizeUTF8: true})
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	c := &s.Goroutines[0].Stack.Calls[0]
+	compareString(t, "main.f\uFFFD", c.Func.Complete)
+	compareString(t, "f\uFFFD", c.Func.Name)
+	compareString(t, "/a/m\uFFFDin.go", c.RemoteSrcPath)
+	compareString(t, "m\uFFFDin.go", c.SrcName)
+	if diff := cmp.Diff([]string{"goroutine 1: replaced invalid UTF-8 in 1 calls"}, s.Warnings); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+func TestScanSnapshotSystemStack(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.f(0xc000010000)",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 2 [signal handler]:",
+		"runtime.sighandler(0xb, 0xc000010000)",
+		"\t/goroot/src/runtime/signal_unix.go:613 +0x13",
+		"runtime.sigtrampgo(0xb, 0xc000010000)",
+		"\t/goroot/src/runtime/signal_unix.go:490 +0x13",
+		"",
+		"goroutine 3 [chan receive]:",
+		"main.g(0xc000010000)",
+		"\t/a/main.go:9 +0x13",
+		"",
+	}, "\n")
+	s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{NameArguments: true})
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if s.Goroutines[0].OnSystemStack || !s.Goroutines[1].OnSystemStack || s.Goroutines[2].OnSystemStack {
+		t.Fatal("unexpected OnSystemStack")
+	}
+	if got := s.UserGoroutines(); len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Fatalf("unexpected %v", got)
+	}
+	// The system stack doesn't participate in the argument naming.
+	if diff := cmp.Diff(map[string][]int{"#1": {1, 3}}, s.NamedPointers); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if name := s.Goroutines[1].Stack.Calls[0].Args.Values[1].Name; name != "" {
+		t.Fatalf("unexpected %q", name)
+	}
+}
+
+func TestScanSnapshotLikelyTruncated(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		in   []string
+		want bool
+	}{
+		{
+			"Panic",
+			[]string{
+				"panic: boom",
+				"",
+				"goroutine 1 [running]:",
+				"panic({0x4b1b5a, 0x15})",
+				"\t/goroot/src/runtime/panic.go:770 +0x132",
+				"main.f(...)",
+				"\t/a/main.go:5",
+				"main.main()",
+				"\t/a/main.go:9 +0x13",
+			},
+			true,
+		},
+		{
+			"Throw",
+			[]string{
+				"fatal error: concurrent map writes",
+				"",
+				"goroutine 1 [running]:",
+				"runtime.throw({0x4b1b5a, 0x15})",
+				"\t/goroot/src/runtime/panic.go:1023 +0x5c",
+				"main.main()",
+				"\t/a/main.go:9 +0x13",
+			},
+			true,
+		},
+		{
+			// e.g. debug.Stack().
+			"NoPanic",
+			[]string{
+				"goroutine 1 [running]:",
+				"runtime/debug.Stack()",
+				"\t/goroot/src/runtime/debug/stack.go:24 +0x5e",
+				"main.main()",
+				"\t/a/main.go:9 +0x13",
+			},
+			false,
+		},
+		{
+			"All",
+			[]string{
+				"panic: boom",
+				"",
+				"goroutine 1 [running]:",
+				"main.main()",
+				"\t/a/main.go:9 +0x13",
+				"",
+				"goroutine 2 [chan receive]:",
+				"main.g()",
+				"\t/a/main.go:12 +0x13",
+			},
+			false,
+		},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			in := strings.Join(line.in, "\n") + "\n"
+			s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{})
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			if s.LikelyTruncated != line.want {
+				t.Fatalf("want %t, got %t", line.want, s.LikelyTruncated)
+			}
+		})
+	}
+}
+
+func TestScanSnapshotExitInfo(t *testing.T) {
+	t.Parallel()
+	dump := []string{
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:9 +0x13",
+	}
+	data := []struct {
+		name   string
+		in     []string
+		suffix string
+		want   *ExitInfo
+	}{
+		{"None", []string{""}, "", nil},
+		{"Junk", []string{"", "junk"}, "junk\n", nil},
+		{"ExitStatus", []string{"", "exit status 2", "FAIL\tfoo\t0.010s"}, "exit status 2\nFAIL\tfoo\t0.010s\n", &ExitInfo{Line: "exit status 2", Code: 2}},
+		{"Signal", []string{"signal: killed"}, "signal: killed\n", &ExitInfo{Line: "signal: killed", Code: -1, Signal: "killed"}},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			in := strings.Join(append(append([]string{}, dump...), line.in...), "\n") + "\n"
+			s, suffix, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{})
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			// The trailer is still passed through.
+			compareString(t, line.suffix, string(suffix))
+			if diff := cmp.Diff(line.want, s.ExitInfo); diff != "" {
+				t.Fatalf("-want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScanSnapshotPanicValue(t *testing.T) {
+	t.Parallel()
+	stack := "goroutine 7 [running]:\nmain.main()\n\t/a/main.go:9 +0x13\n\n"
+	data := []struct {
+		name   string
+		in     string
+		prefix string
+		want   string
+	}{
+		{"Logged", "2026/01/02 15:04:05 panic: boom\n" + stack, "", "boom"},
+		{"LoggedAfterJunk", "junk\npanic: runtime error: index out of range\n" + stack, "junk\n", "runtime error: index out of range"},
+		{"Runtime", "panic: boom\n\n" + stack, "panic: boom\n\n", ""},
+		{"Twice", "panic: a\npanic: b\n" + stack, "panic: a\npanic: b\n", ""},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			prefix := bytes.Buffer{}
+			s, _, err := ScanSnapshot(bytes.NewBufferString(line.in), &prefix, &Opts{})
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			compareString(t, line.prefix, prefix.String())
+			if len(s.Goroutines) != 1 || s.Goroutines[0].ID != 7 {
+				t.Fatalf("unexpected goroutines %v", s.Goroutines)
+			}
+			compareString(t, line.want, s.PanicValue)
+		})
+	}
+
+	// Not followed by a stack trace.
+	prefix := bytes.Buffer{}
+	s, _, err := ScanSnapshot(bytes.NewBufferString("panic: boom\njunk\n"), &prefix, &Opts{})
+	if s != nil || err != io.EOF {
+		t.Fatal(s, err)
+	}
+	compareString(t, "panic: boom\njunk\n", prefix.String())
+	prefix.Reset()
+	if s, _, err = ScanSnapshot(bytes.NewBufferString("panic: boom\n"), &prefix, &Opts{}); s != nil || err != io.EOF {
+		t.Fatal(s, err)
+	}
+	compareString(t, "panic: boom\n", prefix.String())
+}
+
+func TestScanSnapshotSchedTrace(t *testing.T) {
+	t.Parallel()
+	sched := "SCHED 1004ms: gomaxprocs=4 idleprocs=3 threads=5 spinningthreads=0 needspinning=0 idlethreads=2 runqueue=1 [ 0 2 0 0 ] schedticks=[ 5 6 7 8 ]\n"
+	in := "" +
+		"panic: boom\n" +
+		sched +
+		"goroutine 1 [running]:\n" +
+		"main.main()\n" +
+		"\t/a/main.go:9 +0x13\n" +
+		sched +
+		"\n" +
+		"goroutine 6 [chan receive]:\n" +
+		"main.worker()\n" +
+		"\t/a/main.go:20 +0x40\n" +
+		"exit status 2\n"
+	prefix := bytes.Buffer{}
+	s, suffix, err := ScanSnapshot(bytes.NewBufferString(in), &prefix, &Opts{})
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	compareString(t, sched+sched, prefix.String())
+	compareString(t, "exit status 2\n", string(suffix))
+	if len(s.Goroutines) != 2 || s.PanicValue != "boom" {
+		t.Fatalf("unexpected %v", s)
+	}
+}
+
+func TestScanSnapshotProfileLabels(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine profile: total 4",
+		"2 @ 0x43a6d6 0x4068cb 0x4b1b5b 0x46c8c1",
+		"# labels: {\"team\":\"payments\", \"tier\":\"a \\\"b\\\"\"}",
+		"#\t0x4b1b5a\tmain.worker+0x3a\t/a/main.go:20",
+		"#\t0x46c8c0\truntime.goexit+0x0\t/goroot/src/runtime/asm_amd64.s:1700",
+		"",
+		"1 @ 0x43a6d6 0x4b1b5b 0x46c8c1",
+		"# labels: {\"team\":\"search\"}",
+		"#\t0x4b1b5a\tmain.worker+0x3a\t/a/main.go:20",
+		"#\t0x46c8c0\truntime.goexit+0x0\t/goroot/src/runtime/asm_amd64.s:1700",
+		"",
+		"1 @ 0x4b1c00 0x46c8c1",
+		// Log shippers may replace the tabs with spaces.
+		"#    0x4b1bff    main.main+0x1f    /a/main.go:12",
+		"#   0x46c8c0",
+		"",
+		"junk",
+	}, "\n")
+	prefix := bytes.Buffer{}
+	s, suffix, err := ScanSnapshot(bytes.NewBufferString(in), &prefix, &Opts{})
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	compareString(t, "", prefix.String())
+	compareString(t, "junk", string(suffix))
+	var got []string
+	for _, g := range s.Goroutines {
+		l := fmt.Sprintf("%d %v", g.ID, g.Labels)
+		for _, c := range g.Stack.Calls {
+			l += fmt.Sprintf(" %s:%d", c.Func.Complete, c.Line)
+		}
+		got = append(got, l)
+	}
+	want := []string{
+		"1 map[team:payments tier:a \"b\"] main.worker:20 runtime.goexit:1700",
+		"2 map[team:payments tier:a \"b\"] main.worker:20 runtime.goexit:1700",
+		"3 map[team:search] main.worker:20 runtime.goexit:1700",
+		// The frame without symbol is kept with only its pc.
+		"4 map[] main.main:12 :0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if pc := s.Goroutines[3].Stack.Calls[1].PC; pc != 0x46c8c0 {
+		t.Fatalf("unexpected pc %#x", pc)
+	}
+	// The labels split the buckets.
+	a := s.Aggregate(AnyValue)
+	if len(a.Buckets) != 3 {
+		t.Fatalf("unexpected %d buckets", len(a.Buckets))
+	}
+	// The samples are kept as printed.
+	got = nil
+	for _, b := range s.ProfileSamples {
+		got = append(got, fmt.Sprintf("%v %t %v %d", b.IDs, b.First, b.Labels, len(b.Stack.Calls)))
+	}
+	want = []string{
+		"[1 2] false map[team:payments tier:a \"b\"] 2",
+		"[3] false map[team:search] 2",
+		"[4] false map[] 2",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+func TestFirstGoroutine(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"junk",
+		"panic: reflect.Set: value of type",
+		"",
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:10 +0x1",
+		"",
+		"goroutine 6 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:20 +0x1",
+		"",
+	}
+	g, err := FirstGoroutine(bytes.NewBufferString(strings.Join(data, "\n")), defaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Goroutine{
+		{
+			Signature: Signature{
+				State: "running",
+				Stack: Stack{
+					Calls: []Call{newCall("main.main", Args{}, "/a/main.go", 10)},
+				},
+			},
+			ID:    1,
+			First: true,
+		},
+	}
+	compareGoroutines(t, want, []*Goroutine{g})
+
+	g, err = FirstGoroutine(bytes.NewBufferString("no trace\n"), defaultOpts())
+	if g != nil || err != nil {
+		t.Fatalf("unexpected %v, %v", g, err)
+	}
+	if _, err = FirstGoroutine(bytes.NewBufferString(""), &Opts{AnalyzeSources: true}); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseExitInfo(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want *ExitInfo
+	}{
+		{"exit status 2\n", &ExitInfo{Line: "exit status 2", Code: 2}},
+		{"exit status 1\r\n", &ExitInfo{Line: "exit status 1", Code: 1}},
+		{"signal: killed\n", &ExitInfo{Line: "signal: killed", Code: -1, Signal: "killed"}},
+		{"signal: segmentation fault (core dumped)\n", &ExitInfo{Line: "signal: segmentation fault (core dumped)", Code: -1, Signal: "segmentation fault", CoreDumped: true}},
+		{"Killed\n", &ExitInfo{Line: "Killed", Code: -1, Signal: "killed"}},
+		{"Aborted (core dumped)\n", &ExitInfo{Line: "Aborted (core dumped)", Code: -1, Signal: "aborted", CoreDumped: true}},
+		{"./run.sh: line 3:  1234 Killed                  ./app\n", &ExitInfo{Line: "./run.sh: line 3:  1234 Killed                  ./app", Code: -1, Signal: "killed"}},
+		{"exit status\n", nil},
+		{"Killed the process\n", nil},
+		{"FAIL\tpkg\t0.1s\n", nil},
+		{"junk\n", nil},
+	}
+	for i, line := range data {
+		if diff := cmp.Diff(line.want, parseExitInfo([]byte(line.in))); diff != "" {
+			t.Fatalf("#%d: -want, +got:\n%s", i, diff)
+		}
+	}
+}
+
+func TestParseState(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want Signature
+	}{
+		{"running", Signature{State: "running"}},
+		{"select, 5 minutes", Signature{State: "select", SleepMin: 5, SleepMax: 5}},
+		{"select, locked to thread", Signature{State: "select", Locked: true}},
+		{"chan receive, synctest bubble 1", Signature{State: "chan receive", Extra: []string{"synctest bubble 1"}}},
+	}
+	for i, line := range data {
+		got := Signature{}
+		parseState(&got, []byte(line.in))
+		if diff := cmp.Diff(line.want, got); diff != "" {
+			t.Fatalf("#%d: -want, +got:\n%s", i, diff)
+		}
+	}
+
+	// All the permutations of the attributes must be parsed the same, except
+	// that the state is the first attribute that is not known.
+	items := []string{"select", "locked to thread", "5 minutes", "synctest bubble 1"}
+	var permute func(n int)
+	permute = func(n int) {
+		if n == 1 {
+			want := Signature{SleepMin: 5, SleepMax: 5, Locked: true}
+			for _, item := range items {
+				if item == "select" || item == "synctest bubble 1" {
+					if want.State == "" {
+						want.State = item
+					} else {
+						want.Extra = []string{item}
+					}
+				}
+			}
+			in := strings.Join(items, ", ")
+			got := Signature{}
+			parseState(&got, []byte(in))
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("%q: -want, +got:\n%s", in, diff)
+			}
+			return
+		}
+		// Heap's algorithm.
+		for i := 0; i < n; i++ {
+			permute(n - 1)
+			if n%2 == 0 {
+				items[i], items[n-1] = items[n-1], items[i]
+			} else {
+				items[0], items[n-1] = items[n-1], items[0]
+			}
+		}
+	}
+	permute(len(items))
+}
+
+func TestSplitPath(t *testing.T) {
+	t.Parallel()
+	if p := splitPath(""); p != nil {
+		t.Fatalf("expected nil, got: %v", p)
+	}
+}
+
+func TestGetGOPATHs(t *testing.T) {
+	// This test cannot run in parallel.
+	old := os.Getenv("GOPATH")
+	defer os.Setenv("GOPATH", old)
+	os.Setenv("GOPATH", "")
+	if p := getGOPATHs(); len(p) != 1 {
+		// It's the home directory + /go.
+		t.Fatalf("expected only one path: %v", p)
+	}
+
+	root, err := os.MkdirTemp("", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = os.RemoveAll(root); err != nil {
+			t.Error(err)
+		}
+	}()
+	os.Setenv("GOPATH", filepath.Join(root, "a")+string(filepath.ListSeparator)+filepath.Join(root, "b")+string(filepath.Separator))
+	if p := getGOPATHs(); len(p) != 2 {
+		t.Fatalf("expected two paths: %v", p)
+	}
+}
+
+// TestGomoduleComplex is an integration test that creates a non-trivial tree
+// of go modules using the "replace" statement.
+func TestGomoduleComplex(t *testing.T) {
+	// This test cannot run in parallel.
+	if internaltest.GetGoMinorVersion() < 11 {
+		t.Skip("requires go module support")
+	}
+	old := os.Getenv("GOPATH")
+	defer os.Setenv("GOPATH", old)
+	root, err := os.MkdirTemp("", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = os.RemoveAll(root); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	os.Setenv("GOPATH", filepath.Join(root, "go"))
+	os.Setenv("GO111MODULE", "on")
+	tree := map[string]string{
+		"pkg1/go.mod": "module example.com/pkg1\n" +
+			"require (\n" +
+			"\texample.com/pkg2 v0.0.1\n" +
+			"\texample.com/pkg3 v0.0.1\n" +
+			")\n" +
 			"replace example.com/pkg2 => ../pkg2\n" +
 			// This is kind of a hack to force testing with a package inside GOPATH,
 			// since this won't normally work by default.
@@ -1678,6 +2723,9 @@ func TestGoRun(t *testing.T) {
 	if s == nil {
 		t.Fatal("expected snapshot")
 	}
+	if s.ExitInfo == nil || s.ExitInfo.Code != 2 {
+		t.Fatalf("unexpected exit info %#v", s.ExitInfo)
+	}
 	if runtime.GOOS == "windows" {
 		// On Windows, we must make the path to be POSIX style.
 		p = strings.Replace(p, pathSeparator, "/", -1)
@@ -1733,6 +2781,7 @@ func TestPanic(t *testing.T) {
 	want := map[string]int{
 		"chan_receive":              2,
 		"chan_send":                 2,
+		"coroutine":                 2,
 		"goroutine_1":               2,
 		"goroutine_dedupe_pointers": 101,
 		"goroutine_100":             101,
@@ -1792,6 +2841,74 @@ func TestPanic(t *testing.T) {
 	}
 }
 
+func TestPanicTraceback(t *testing.T) {
+	t.Parallel()
+	outs := internaltest.PanicTracebackOutputs("goroutine_1")
+	for _, l := range []string{"none", "single", "all", "system", "crash"} {
+		l := l
+		t.Run(l, func(t *testing.T) {
+			t.Parallel()
+			data := outs[l]
+			if len(data) == 0 {
+				t.Fatal("no output")
+			}
+			prefix := bytes.Buffer{}
+			s, _, err := ScanSnapshot(bytes.NewReader(data), &prefix, defaultOpts())
+			if err != nil && err != io.EOF {
+				t.Fatal(err)
+			}
+			compareString(t, "GOTRACEBACK="+l+"\npanic: 42\n", strings.TrimRight(prefix.String(), "\n")+"\n")
+			switch l {
+			case "none":
+				// The goroutines are omitted entirely.
+				if s != nil {
+					t.Fatalf("unexpected goroutines: %v", s.Goroutines)
+				}
+				return
+			case "single", "all":
+				// Only the user goroutines, without the runtime headers.
+				want := 1
+				if l == "all" {
+					want = 2
+				}
+				if s == nil || len(s.Goroutines) != want {
+					t.Fatalf("want %d goroutines, got %v", want, s)
+				}
+				for _, g := range s.Goroutines {
+					if g.GP != 0 || g.MP != 0 {
+						t.Fatalf("unexpected runtime header: %#v", g)
+					}
+				}
+			case "system", "crash":
+				// The runtime goroutines are included, with gp in the header.
+				if s == nil || len(s.Goroutines) <= 2 {
+					t.Fatalf("want runtime goroutines, got %v", s)
+				}
+				for _, g := range s.Goroutines {
+					if g.GP == 0 {
+						t.Fatalf("missing gp in header: %#v", g)
+					}
+				}
+				if s.Goroutines[0].MP == 0 {
+					t.Fatalf("missing mp in header: %#v", s.Goroutines[0])
+				}
+			}
+			if !s.Goroutines[0].First {
+				t.Fatal("the panicking goroutine must be first")
+			}
+			found := false
+			for _, c := range s.Goroutines[0].Stack.Calls {
+				if c.Func.Complete == "main.panicint" {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("main.panicint not found: %#v", s.Goroutines[0].Stack.Calls)
+			}
+		})
+	}
+}
+
 func testPanicArgsElided(t *testing.T, s *Snapshot, b *bytes.Buffer, ppDir string) {
 	if s.RemoteGOROOT != "" {
 		t.Fatalf("RemoteGOROOT is %q", s.RemoteGOROOT)
@@ -0,0 +1,436 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package coredump extracts the goroutines from a core file.
+//
+// It walks the runtime's list of goroutines in the memory of the crashed
+// process and prints their stack traces in the format of the runtime, so they
+// are parsed with stack.ScanSnapshot like any other trace. This permits
+// analyzing a crash where only a core was captured, for example with
+// GOTRACEBACK=crash and a system that collects the core files.
+//
+// Only ELF core files of linux/amd64 processes are supported. The executable
+// must be the exact one that crashed, must not be position independent and
+// must have its DWARF information since it is used to find the layout of the
+// runtime structures.
+//
+// The stacks are unwound with the frame pointers, so the goroutines that were
+// running at the time of the crash are reported without a stack, like the
+// runtime does for a goroutine running on another thread. The arguments are
+// not recovered.
+package coredump
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Load returns the goroutines found in the core file, as a Snapshot.
+//
+// exe is the executable that generated the core file. opts is passed to
+// stack.ScanSnapshot; Opts.Binary defaults to exe so the inlined calls are
+// expanded.
+func Load(core, exe string, opts *stack.Opts) (*stack.Snapshot, error) {
+	if opts == nil {
+		return nil, errors.New("invalid Opts")
+	}
+	b := bytes.Buffer{}
+	if err := Write(&b, core, exe); err != nil {
+		return nil, err
+	}
+	if opts.Binary == "" {
+		o := *opts
+		o.Binary = exe
+		opts = &o
+	}
+	s, _, err := stack.ScanSnapshot(&b, io.Discard, opts)
+	if err == io.EOF {
+		err = nil
+	}
+	if s == nil && err == nil {
+		err = errors.New("no goroutine found")
+	}
+	return s, err
+}
+
+// Write writes the stack traces of the goroutines found in the core file
+// to w, in the format of the runtime.
+//
+// exe is the executable that generated the core file.
+func Write(w io.Writer, core, exe string) error {
+	p, err := open(core, exe)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	return p.write(w)
+}
+
+// Private stuff.
+
+// maxFrames is the maximum number of frames printed per goroutine, like the
+// runtime does.
+const maxFrames = 100
+
+// The values of runtime.g.atomicstatus. See src/runtime/runtime2.go.
+const (
+	gIdle     = 0
+	gRunnable = 1
+	gRunning  = 2
+	gSyscall  = 3
+	gWaiting  = 4
+	gDead     = 6
+	gScan     = 0x1000
+)
+
+// segment is a range of the memory of the process.
+type segment struct {
+	addr, size uint64
+	r          io.ReaderAt
+}
+
+// process is the state of the crashed process.
+type process struct {
+	core, exe *elf.File
+	segments  []segment
+	syms      *gosym.Table
+	// g and gobuf are the offsets of the fields of runtime.g and
+	// runtime.gobuf.
+	g, gobuf map[string]int64
+	// allgs and waitReasons are the addresses of runtime.allgs and
+	// runtime.waitReasonStrings, the latter being 0 if not found.
+	allgs, waitReasons uint64
+}
+
+func open(core, exe string) (*process, error) {
+	p := &process{}
+	var err error
+	if p.core, err = elf.Open(core); err != nil {
+		return nil, err
+	}
+	if p.exe, err = elf.Open(exe); err != nil {
+		p.close()
+		return nil, err
+	}
+	if err = p.init(); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *process) init() error {
+	if p.core.Type != elf.ET_CORE {
+		return errors.New("not a core file")
+	}
+	if p.exe.Type != elf.ET_EXEC {
+		return errors.New("only non position independent executables are supported")
+	}
+	if p.core.Machine != elf.EM_X86_64 || p.exe.Machine != elf.EM_X86_64 {
+		return errors.New("only amd64 is supported")
+	}
+	// The core contains the writable memory; the rest comes from the
+	// executable.
+	for _, f := range []*elf.File{p.core, p.exe} {
+		for _, prog := range f.Progs {
+			if prog.Type == elf.PT_LOAD && prog.Filesz != 0 {
+				p.segments = append(p.segments, segment{addr: prog.Vaddr, size: prog.Filesz, r: prog})
+			}
+		}
+	}
+	text, pclntab := p.exe.Section(".text"), p.exe.Section(".gopclntab")
+	if text == nil || pclntab == nil {
+		return errors.New("no Go symbol table in the executable")
+	}
+	data, err := pclntab.Data()
+	if err != nil {
+		return err
+	}
+	if p.syms, err = gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr)); err != nil {
+		return err
+	}
+	syms, err := p.exe.Symbols()
+	if err != nil {
+		return err
+	}
+	for _, s := range syms {
+		switch s.Name {
+		case "runtime.allgs":
+			p.allgs = s.Value
+		case "runtime.waitReasonStrings":
+			p.waitReasons = s.Value
+		}
+	}
+	if p.allgs == 0 {
+		return errors.New("runtime.allgs not found in the executable")
+	}
+	d, err := p.exe.DWARF()
+	if err != nil {
+		return err
+	}
+	if p.g, err = structFields(d, "runtime.g"); err != nil {
+		return err
+	}
+	if p.gobuf, err = structFields(d, "runtime.gobuf"); err != nil {
+		return err
+	}
+	for _, f := range []string{"atomicstatus", "goid", "sched", "gopc", "waitreason"} {
+		if _, ok := p.g[f]; !ok {
+			return fmt.Errorf("runtime.g.%s not found", f)
+		}
+	}
+	for _, f := range []string{"sp", "pc", "bp"} {
+		if _, ok := p.gobuf[f]; !ok {
+			return fmt.Errorf("runtime.gobuf.%s not found", f)
+		}
+	}
+	return nil
+}
+
+func (p *process) close() {
+	if p.core != nil {
+		_ = p.core.Close()
+	}
+	if p.exe != nil {
+		_ = p.exe.Close()
+	}
+}
+
+// read reads len(b) bytes of the memory of the process at addr.
+func (p *process) read(addr uint64, b []byte) error {
+	for _, s := range p.segments {
+		if addr >= s.addr && addr+uint64(len(b)) <= s.addr+s.size {
+			_, err := s.r.ReadAt(b, int64(addr-s.addr))
+			return err
+		}
+	}
+	return fmt.Errorf("address %#x not found", addr)
+}
+
+func (p *process) readUint64(addr uint64) (uint64, error) {
+	var b [8]byte
+	if err := p.read(addr, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b[:]), nil
+}
+
+func (p *process) readUint32(addr uint64) (uint32, error) {
+	var b [4]byte
+	if err := p.read(addr, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]), nil
+}
+
+func (p *process) write(w io.Writer) error {
+	// allgs is a slice: pointer, length, capacity.
+	ptr, err := p.readUint64(p.allgs)
+	if err != nil {
+		return err
+	}
+	n, err := p.readUint64(p.allgs + 8)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		g, err := p.readUint64(ptr + 8*i)
+		if err != nil {
+			return err
+		}
+		if err = p.writeGoroutine(w, g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGoroutine writes the stack trace of the goroutine at address g.
+func (p *process) writeGoroutine(w io.Writer, g uint64) error {
+	status, err := p.readUint32(g + uint64(p.g["atomicstatus"]))
+	if err != nil {
+		return err
+	}
+	status &^= gScan
+	if status == gIdle || status == gDead {
+		return nil
+	}
+	id, err := p.readUint64(g + uint64(p.g["goid"]))
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "goroutine %d [%s]:\n", id, p.state(g, status))
+	if status == gRunning {
+		b.WriteString("\tgoroutine running on other thread; stack unavailable\n")
+	} else {
+		sched := g + uint64(p.g["sched"])
+		pcOff, bpOff := uint64(p.gobuf["pc"]), uint64(p.gobuf["bp"])
+		if off, ok := p.g["syscallpc"]; ok && status == gSyscall {
+			// The registers were saved when entering the system call.
+			sched, pcOff = g, uint64(off)
+			if off, ok = p.g["syscallbp"]; ok {
+				bpOff = uint64(off)
+			}
+		}
+		pc, err := p.readUint64(sched + pcOff)
+		if err != nil {
+			return err
+		}
+		bp, err := p.readUint64(sched + bpOff)
+		if err != nil {
+			return err
+		}
+		p.writeFrames(&b, pc, bp)
+	}
+	// Like the runtime, do not print the creator of the main goroutine.
+	if pc, err := p.readUint64(g + uint64(p.g["gopc"])); err == nil && id != 1 {
+		if fn := p.syms.PCToFunc(pc); fn != nil {
+			b.WriteString("created by " + fn.Name)
+			if off, ok := p.g["parentGoid"]; ok {
+				if parent, err := p.readUint64(g + uint64(off)); err == nil && parent != 0 {
+					fmt.Fprintf(&b, " in goroutine %d", parent)
+				}
+			}
+			b.WriteString("\n")
+			p.writeLocation(&b, fn, pc)
+		}
+	}
+	b.WriteString("\n")
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// writeFrames unwinds the stack with the frame pointers, starting at pc.
+//
+// Each frame pointer points to the caller's frame pointer, followed by the
+// return address.
+func (p *process) writeFrames(b *bytes.Buffer, pc, bp uint64) {
+	for i := 0; pc != 0; i++ {
+		if i == maxFrames {
+			b.WriteString("...additional frames elided...\n")
+			return
+		}
+		fn := p.syms.PCToFunc(pc)
+		if fn == nil {
+			return
+		}
+		b.WriteString(fn.Name + "(...)\n")
+		p.writeLocation(b, fn, pc)
+		if fn.Name == "runtime.goexit" || bp == 0 {
+			return
+		}
+		var err error
+		if pc, err = p.readUint64(bp + 8); err != nil {
+			return
+		}
+		if bp, err = p.readUint64(bp); err != nil {
+			return
+		}
+	}
+}
+
+// writeLocation writes the source line of the return address pc in fn.
+func (p *process) writeLocation(b *bytes.Buffer, fn *gosym.Func, pc uint64) {
+	tracepc := pc
+	if pc > fn.Entry {
+		// Look at the call instruction.
+		tracepc--
+	}
+	file, line, _ := p.syms.PCToLine(tracepc)
+	fmt.Fprintf(b, "\t%s:%d +%#x\n", file, line, pc-fn.Entry)
+}
+
+// state returns the state of the goroutine as printed by the runtime.
+func (p *process) state(g uint64, status uint32) string {
+	switch status {
+	case gRunnable:
+		return "runnable"
+	case gRunning:
+		return "running"
+	case gSyscall:
+		return "syscall"
+	case gWaiting:
+		if s := p.waitReason(g); s != "" {
+			return s
+		}
+		return "waiting"
+	default:
+		return fmt.Sprintf("status %d", status)
+	}
+}
+
+// waitReason returns the reason the goroutine is waiting, if found.
+func (p *process) waitReason(g uint64) string {
+	if p.waitReasons == 0 {
+		return ""
+	}
+	var r [1]byte
+	if err := p.read(g+uint64(p.g["waitreason"]), r[:]); err != nil || r[0] == 0 {
+		return ""
+	}
+	// waitReasonStrings is an array of strings: pointer, length.
+	ptr, err := p.readUint64(p.waitReasons + 16*uint64(r[0]))
+	if err != nil {
+		return ""
+	}
+	n, err := p.readUint64(p.waitReasons + 16*uint64(r[0]) + 8)
+	if err != nil || n == 0 || n > 256 {
+		return ""
+	}
+	s := make([]byte, n)
+	if err = p.read(ptr, s); err != nil {
+		return ""
+	}
+	return string(s)
+}
+
+// structFields returns the offset of each field of the struct name.
+func structFields(d *dwarf.Data, name string) (map[string]int64, error) {
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, fmt.Errorf("%s not found", name)
+		}
+		if e.Tag != dwarf.TagStructType || !e.Children {
+			if e.Children && e.Tag != dwarf.TagCompileUnit {
+				r.SkipChildren()
+			}
+			continue
+		}
+		if n, _ := e.Val(dwarf.AttrName).(string); n != name {
+			r.SkipChildren()
+			continue
+		}
+		out := map[string]int64{}
+		for {
+			c, err := r.Next()
+			if err != nil {
+				return nil, err
+			}
+			if c == nil || c.Tag == 0 {
+				return out, nil
+			}
+			n, _ := c.Val(dwarf.AttrName).(string)
+			if off, ok := c.Val(dwarf.AttrDataMemberLoc).(int64); ok && c.Tag == dwarf.TagMember {
+				out[n] = off
+			}
+			if c.Children {
+				r.SkipChildren()
+			}
+		}
+	}
+}
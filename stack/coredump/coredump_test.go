@@ -0,0 +1,110 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package coredump
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("only linux/amd64 is supported")
+	}
+	root := t.TempDir()
+	const content = "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"runtime/debug\"\n" +
+		"\t\"syscall\"\n" +
+		"\t\"time\"\n" +
+		")\n" +
+		"\n" +
+		"//go:noinline\n" +
+		"func block(c chan int) { <-c }\n" +
+		"\n" +
+		"func main() {\n" +
+		"\t// Enable the core file.\n" +
+		"\tl := syscall.Rlimit{}\n" +
+		"\t_ = syscall.Getrlimit(syscall.RLIMIT_CORE, &l)\n" +
+		"\tl.Cur = l.Max\n" +
+		"\t_ = syscall.Setrlimit(syscall.RLIMIT_CORE, &l)\n" +
+		"\tdebug.SetTraceback(\"crash\")\n" +
+		"\tgo block(make(chan int))\n" +
+		"\ttime.Sleep(10 * time.Millisecond)\n" +
+		"\tpanic(\"boom\")\n" +
+		"}\n"
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module crash\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(root, "crash")
+	c := exec.Command("go", "build", "-o", exe)
+	c.Dir = root
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	c = exec.Command(exe)
+	c.Dir = root
+	if err := c.Run(); err == nil {
+		t.Fatal("expected failure")
+	}
+	// The core file is written in the current directory by default but the
+	// system may be configured otherwise.
+	core := filepath.Join(root, "core")
+	if matches, _ := filepath.Glob(core + "*"); len(matches) == 1 {
+		core = matches[0]
+	} else {
+		t.Skip("no core file was written, see /proc/sys/kernel/core_pattern")
+	}
+
+	s, err := Load(core, exe, stack.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var g *stack.Goroutine
+	var block *stack.Call
+	for _, e := range s.Goroutines {
+		if e.ID == 1 && e.State != "running" {
+			t.Fatalf("unexpected main goroutine state %q", e.State)
+		}
+		for i := range e.Stack.Calls {
+			if e.Stack.Calls[i].Func.Complete == "main.block" {
+				g, block = e, &e.Stack.Calls[i]
+			}
+		}
+	}
+	if g == nil {
+		t.Fatal("goroutine running main.block not found")
+	}
+	if g.State != "chan receive" {
+		t.Fatalf("unexpected state %q", g.State)
+	}
+	if block.SrcName != "main.go" || block.Line != 10 {
+		t.Fatalf("unexpected %s:%d", block.SrcName, block.Line)
+	}
+	if g.CreatedBy.Calls[0].Func.Complete != "main.main" || g.CreatedBy.Calls[0].Line != 19 || g.CreatedByID != 1 {
+		t.Fatalf("unexpected creator %+v", g.CreatedBy)
+	}
+}
+
+func TestLoadErr(t *testing.T) {
+	t.Parallel()
+	if _, err := Load("coredump.go", "coredump.go", stack.DefaultOpts()); err == nil || !strings.Contains(err.Error(), "bad magic") {
+		t.Fatal(err)
+	}
+	if _, err := Load("coredump.go", "coredump.go", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"html/template"
 )
 
-const indexHTML = "<!DOCTYPE html>\n{{- /* Join a list */ -}}\n{{- define \"Join\" -}}\n{{- if . -}}\n{{- $l := len . -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := . -}}\n{{- $e -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if $isNotLast}}, {{end -}}\n{{- end -}}\n{{- end -}}\n{{- end -}}\n{{- /* Accepts a Args */ -}}\n{{- define \"RenderArgs\" -}}\n<span class=\"args\"><span>\n{{- $elided := .Elided -}}\n{{- if .Processed -}}\n{{- $l := len .Processed -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := .Processed -}}\n{{- $e -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if or $elided $isNotLast}}, {{end -}}\n{{- end -}}\n{{- else -}}\n{{- $l := len .Values -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := .Values -}}\n{{- $e.String -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if or $elided $isNotLast}}, {{end -}}\n{{- end -}}\n{{- end -}}\n{{- if $elided}}…{{end -}}\n</span></span>\n{{- end -}}\n{{- /* Accepts a Call */ -}}\n{{- define \"RenderCreatedBy\" -}}\n<span class=\"call hastooltip\"><span class=\"tooltip\">\n{{- if and .LocalSrcPath (ne .RemoteSrcPath .LocalSrcPath) -}}\nRemoteSrcPath: {{.RemoteSrcPath}}\n<br>LocalSrcPath: {{.LocalSrcPath}}\n{{- else -}}\nSrcPath: {{.RemoteSrcPath}}\n{{- end -}}\n<br>Func: {{.Func.Complete}}\n<br>Location: {{.Location}}\n</span><a href=\"{{srcURL .}}\">{{.SrcName}}:{{.Line}}</a> <span class=\"{{funcClass .}}\">\n<a href=\"{{pkgURL .}}\">{{.Func.DirName}}.{{.Func.Name}}</a></span>()\n</span>\n{{- end -}}\n{{- /* Accepts a Stack */ -}}\n{{- define \"RenderCalls\" -}}\n<table class=\"stack\">\n{{- range $i, $e := .Calls -}}\n<tr>\n<td>{{$i}}</td>\n<td>\n<a href=\"{{pkgURL $e}}\">{{$e.Func.DirName}}</a>\n</td>\n<td class=\"hastooltip\">\n<span class=\"tooltip\">\n{{- if and $e.LocalSrcPath (ne $e.RemoteSrcPath $e.LocalSrcPath) -}}\nRemoteSrcPath: {{$e.RemoteSrcPath}}\n<br>LocalSrcPath: {{$e.LocalSrcPath}}\n{{- else -}}\nSrcPath: {{$e.RemoteSrcPath}}\n{{- end -}}\n<br>Func: {{$e.Func.Complete}}\n<br>Location: {{$e.Location}}\n</span>\n<a href=\"{{srcURL $e}}\">{{$e.SrcName}}:{{$e.Line}}</a>\n</td>\n<td>\n<span class=\"{{funcClass $e}}\"><a href=\"{{pkgURL $e}}\">{{$e.Func.Name}}</a></span>({{template \"RenderArgs\" $e.Args}})\n</td>\n</tr>\n{{- end -}}\n{{- if .Elided}}<tr><td>(…)</td><tr>{{end -}}\n</table>\n{{- end -}}\n<meta charset=\"UTF-8\">\n<meta name=\"author\" content=\"Marc-Antoine Ruel\" >\n<meta name=\"generator\" content=\"https://github.com/maruel/panicparse\" >\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n<title>PanicParse</title>\n<link rel=\"shortcut icon\" type=\"image/gif\" href=\"data:image/gif;base64,{{.Favicon}}\"/>\n<style>\n{{- /* Minimal CSS reset */ -}}\n* {\nfont-family: inherit;\nfont-size: 1em;\nmargin: 0;\npadding: 0;\n}\nhtml {\nbox-sizing: border-box;\nfont-size: 62.5%;\n}\n*, *:before, *:after {\nbox-sizing: inherit;\n}\nh1, h2 {\nmargin-bottom: 0.2em;\nmargin-top: 0.8em;\n}\nh1 {\nfont-size: 1.4em;\n}\nh2 {\nfont-size: 1.2em;\n}\nbody {\nfont-size: 1.6em;\nmargin: 2px;\n}\nli {\nmargin-left: 2.5em;\n}\na {\ncolor: inherit;\ntext-decoration: inherit;\n}\nol, ul {\nmargin-bottom: 0.5em;\nmargin-top: 0.5em;\n}\np {\nmargin-bottom: 2em;\n}\ntable {\nmargin: 0.6em;\n}\ntable tr:nth-child(odd) {\nbackground-color: #F0F0F0;\n}\ntable tr:hover {\nbackground-color: #DDD !important;\n}\ntable td {\nfont-family: monospace;\npadding: 0.2em 0.4em 0.2em;\n}\n.call {\nfont-family: monospace;\n}\n@media screen and (max-width: 500px) {\nh1 {\nfont-size: 1.3em;\n}\n}\n@media screen and (max-width: 500px) and (orientation: portrait) {\n.args span {\ndisplay: none;\n}\n.args::after {\ncontent: '…';\n}\n}\n.created {\nwhite-space: nowrap;\n}\n.race {\nfont-weight: 700;\ncolor: #600;\n}\n#content {\nwidth: 100%;\n}\n.hastooltip:hover .tooltip {\nbackground: #fffAF0;\nborder: 1px solid #DCA;\nborder-radius: 6px;\nbox-shadow: 5px 5px 8px #CCC;\ncolor: #111;\ndisplay: inline;\nposition: absolute;\n}\n.tooltip {\ndisplay: none;\nline-height: 16px;\nmargin-left: 1rem;\nmargin-top: 2.5rem;\npadding: 1rem;\nz-index: 10;\n}\n.bottom-padding {\nmargin-top: 5em;\n}\n{{- /* Highlights based on stack.Location value. */ -}}\n.FuncMain {\ncolor: #880;\n}\n.FuncLocationUnknown {\ncolor: #888;\n}\n.FuncGoMod {\ncolor: #800;\n}\n.FuncGOPATH {\ncolor: #109090;\n}\n.FuncGoPkg {\ncolor: #008;\n}\n.FuncStdlib {\ncolor: #080;\n}\n.Exported {\nfont-weight: 700;\n}\n</style>\n<div id=\"content\">\n{{- if .Aggregated -}}\n{{- range $i, $e := .Aggregated.Buckets -}}\n{{$l := len $e.IDs}}\n<h1>Signature #{{$i}}: {{$l}} routine{{if ne 1 $l}}s{{end}}: <span class=\"state\">{{$e.State}}</span>\n{{- if $e.SleepMax -}}\n{{- if ne $e.SleepMin $e.SleepMax}} <span class=\"sleep\">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>\n{{- else}} <span class=\"sleep\">[{{$e.SleepMax}} mins]</span>\n{{- end -}}\n{{- end -}}\n</h1>\n{{if $e.Locked}} <span class=\"locked\">[locked]</span>\n{{- end -}}\n{{- if $e.CreatedBy.Calls}} <span class=\"created\">Created by: {{template \"RenderCreatedBy\" index $e.CreatedBy.Calls 0}}</span>\n{{- end -}}\n{{template \"RenderCalls\" $e.Signature.Stack}}\n{{- end -}}\n{{- else -}}\n{{- range $i, $e := .Snapshot.Goroutines -}}\n<h1>Routine {{$e.ID}}: <span class=\"state\">{{$e.State}}</span>\n{{- if $e.SleepMax -}}\n{{- if ne $e.SleepMin $e.SleepMax}} <span class=\"sleep\">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>\n{{- else}} <span class=\"sleep\">[{{$e.SleepMax}} mins]</span>\n{{- end -}}\n{{- end -}}\n</h1>\n{{if $e.Locked}} <span class=\"locked\">[locked]</span>\n{{- end -}}\n{{if $e.RaceAddr}} <span class=\"race\">Race {{if $e.RaceWrite}}write{{else}}read{{end}} @ {{printf \"0x%08X\" $e.RaceAddr}}</span><br>\n{{- end -}}\n{{- if $e.CreatedBy.Calls}} <span class=\"created\">Created by: {{template \"RenderCreatedBy\" index $e.CreatedBy.Calls 0}}</span>\n{{- end -}}\n{{template \"RenderCalls\" $e.Signature.Stack}}\n{{- end -}}\n{{- end -}}\n</div>\n<h2>Metadata</h2>\n<ul>\n<li>Created on {{.Now.String}}</li>\n<li>{{.Version}}</li>\n{{- if and .Snapshot.LocalGOROOT (ne .Snapshot.RemoteGOROOT .Snapshot.LocalGOROOT) -}}\n<li>GOROOT (remote): {{.Snapshot.RemoteGOROOT}}</li>\n<li>GOROOT (local): {{.Snapshot.LocalGOROOT}}</li>\n{{- else -}}\n<li>GOROOT: {{.Snapshot.RemoteGOROOT}}</li>\n{{- end -}}\n<li>GOPATH: {{template \"Join\" .Snapshot.LocalGOPATHs}}</li>\n{{- if .Snapshot.LocalGomods -}}\n<li>go modules (local):\n<ul>\n{{- range $path, $import := .Snapshot.LocalGomods -}}\n<li>{{$path}}: {{$import}}</li>\n{{- end -}}\n</ul>\n</li>\n{{- end -}}\n<li>GOMAXPROCS: {{.GOMAXPROCS}}</li>\n</ul>\n<h2>Legend</h2>\n<table class=\"legend\">\n<thead>\n<th>Type</th>\n<th>Exported</th>\n<th>Private</th>\n</thead>\n<tr class=\"call hastooltip\">\n<td>\nPackage main\n<span class=\"tooltip\">Sources that are in the main package.</span>\n</td>\n<td class=\"FuncMain\">main.Foo()</td>\n<td class=\"FuncMain\">main.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nGo module\n<span class=\"tooltip\">Sources located inside a directory containing a\n<strong>go.mod</strong> file but outside $GOPATH.</span>\n</td>\n<td class=\"FuncGoMod Exported\">pkg.Foo()</td>\n<td class=\"FuncGoMod\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\n$GOPATH/src/...\n<span class=\"tooltip\">Sources located inside the traditional $GOPATH/src\ndirectory.</span>\n</td>\n<td class=\"FuncGOPATH Exported\">pkg.Foo()</td>\n<td class=\"FuncGOPATH\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\n$GOPATH/pkg/mod/...\n<span class=\"tooltip\">Sources located inside the go module dependency\ncache under $GOPATH/pkg/mod. These files are unmodified third parties.</span>\n</td>\n<td class=\"FuncGoPkg Exported\">pkg.Foo()</td>\n<td class=\"FuncGoPkg\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nStandard library\n<span class=\"tooltip\">Sources from the Go standard library under\n$GOROOT/src/.</span>\n</td>\n<td class=\"FuncStdlib Exported\">pkg.Foo()</td>\n<td class=\"FuncStdlib\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nUnknown source location\n<span class=\"tooltip\">Sources which location was not successfully\ndetermined.</span>\n</td>\n<td class=\"FuncLocationUnknown Exported\">pkg.Foo()</td>\n<td class=\"FuncLocationUnknown\">pkg.foo()</td>\n</tr>\n</table>\n{{- .Footer -}}\n{{- /* Add unnecessary bottom spacing so the last tooltip from the legend is visible. */ -}}\n<div class=\"bottom-padding\"></div>\n"
+const indexHTML = "<!DOCTYPE html>\n{{- /* Join a list */ -}}\n{{- define \"Join\" -}}\n{{- if . -}}\n{{- $l := len . -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := . -}}\n{{- $e -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if $isNotLast}}, {{end -}}\n{{- end -}}\n{{- end -}}\n{{- end -}}\n{{- /* Accepts a Args */ -}}\n{{- define \"RenderArgs\" -}}\n<span class=\"args\"><span>\n{{- $elided := .Elided -}}\n{{- if .Processed -}}\n{{- $l := len .Processed -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := .Processed -}}\n{{- $e -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if or $elided $isNotLast}}, {{end -}}\n{{- end -}}\n{{- else -}}\n{{- $l := len .Values -}}\n{{- $last := minus $l 1 -}}\n{{- range $i, $e := .Values -}}\n{{- $e.String -}}\n{{- $isNotLast := ne $i $last -}}\n{{- if or $elided $isNotLast}}, {{end -}}\n{{- end -}}\n{{- end -}}\n{{- if $elided}}…{{end -}}\n</span></span>\n{{- end -}}\n{{- /* Accepts a Call */ -}}\n{{- define \"RenderCreatedBy\" -}}\n<span class=\"call hastooltip\"><span class=\"tooltip\">\n{{- if and .LocalSrcPath (ne .RemoteSrcPath .LocalSrcPath) -}}\nRemoteSrcPath: {{.RemoteSrcPath}}\n<br>LocalSrcPath: {{.LocalSrcPath}}\n{{- else -}}\nSrcPath: {{.RemoteSrcPath}}\n{{- end -}}\n<br>Func: {{.Func.Complete}}\n<br>Location: {{.Location}}\n{{- with .Module}}\n<br>Module: {{.Path}}{{if .Version}}@{{.Version}}{{end}} ({{.Dependency}})\n{{- end}}\n</span><a href=\"{{srcURL .}}\">{{.SrcName}}:{{.Line}}</a> <span class=\"{{funcClass .}}\">\n<a href=\"{{pkgURL .}}\">{{.Func.DirName}}.{{.Func.Name}}</a></span>()\n</span>\n{{- end -}}\n{{- /* The controls to filter, sort and collapse the buckets */ -}}\n{{- define \"Toolbar\" -}}\n<form class=\"toolbar\" onsubmit=\"return false\">\n<label title=\"Regexp to filter out headers or packages that match, like -f\">Hide: <input type=\"search\" id=\"filter\" placeholder=\"IO wait|syscall\"></label>\n<label title=\"Regexp to filter by only headers or packages that match, like -m\">Show only: <input type=\"search\" id=\"match\" placeholder=\"semacquire\"></label>\n<label>Sort by: <select id=\"sort\">\n<option value=\"index\">default</option>\n<option value=\"count\">count</option>\n<option value=\"sleep\">sleep time</option>\n</select></label>\n<button type=\"button\" id=\"collapse\">Collapse all</button>\n<button type=\"button\" id=\"expand\">Expand all</button>\n<span id=\"shown\"></span>\n</form>\n{{- end -}}\n{{- /* Accepts a []*CreationNode */ -}}\n{{- define \"RenderTree\" -}}\n<ul class=\"tree\">\n{{- range $i, $e := . -}}\n{{$l := len $e.IDs}}\n<li><details open><summary>{{$l}} routine{{if ne 1 $l}}s{{end}}\n{{- if ne $l $e.Total}} ({{$e.Total}} in subtree){{end}}: <span class=\"state\">{{$e.State}}</span>\n{{- if $e.Signature.Stack.Calls -}}\n{{- with index $e.Signature.Stack.Calls 0}} <span class=\"{{funcClass .}}\">{{.Func.DirName}}.{{.Func.Name}}</span>(){{end -}}\n{{- end -}}\n</summary>\n{{template \"RenderCalls\" $e.Signature.Stack}}\n{{- if $e.Children}}{{template \"RenderTree\" $e.Children}}{{end -}}\n</details></li>\n{{- end -}}\n</ul>\n{{- end -}}\n{{- /* Accepts a Stack */ -}}\n{{- define \"RenderCalls\" -}}\n<table class=\"stack\">\n{{- range $i, $e := .Calls -}}\n<tr>\n<td>{{$i}}</td>\n<td>\n<a href=\"{{pkgURL $e}}\">{{$e.Func.DirName}}</a>\n{{- with $e.Module}}{{if not .Main}} <span class=\"module\" title=\"{{.Path}}\">{{.Version}}{{if not .Direct}}, indirect{{end}}</span>{{end}}{{end}}\n</td>\n<td class=\"hastooltip\">\n<span class=\"tooltip\">\n{{- if and $e.LocalSrcPath (ne $e.RemoteSrcPath $e.LocalSrcPath) -}}\nRemoteSrcPath: {{$e.RemoteSrcPath}}\n<br>LocalSrcPath: {{$e.LocalSrcPath}}\n{{- else -}}\nSrcPath: {{$e.RemoteSrcPath}}\n{{- end -}}\n<br>Func: {{$e.Func.Complete}}\n<br>Location: {{$e.Location}}\n{{- with $e.Module}}\n<br>Module: {{.Path}}{{if .Version}}@{{.Version}}{{end}} ({{.Dependency}})\n{{- end}}\n</span>\n<a href=\"{{srcURL $e}}\">{{$e.SrcName}}:{{$e.Line}}</a>\n</td>\n<td>\n<span class=\"{{funcClass $e}}\"><a href=\"{{pkgURL $e}}\">{{$e.Func.Name}}</a></span>({{template \"RenderArgs\" $e.Args}})\n{{- with annotate $e}} {{.}}{{end}}\n</td>\n</tr>\n{{- end -}}\n{{- if .Elided}}<tr><td>(…)</td><tr>{{end -}}\n</table>\n{{- end -}}\n<meta charset=\"UTF-8\">\n<meta name=\"author\" content=\"Marc-Antoine Ruel\" >\n<meta name=\"generator\" content=\"https://github.com/maruel/panicparse\" >\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n<title>PanicParse</title>\n<link rel=\"shortcut icon\" type=\"image/gif\" href=\"data:image/gif;base64,{{.Favicon}}\"/>\n<style>\n{{- /* Minimal CSS reset */ -}}\n* {\nfont-family: inherit;\nfont-size: 1em;\nmargin: 0;\npadding: 0;\n}\nhtml {\nbox-sizing: border-box;\nfont-size: 62.5%;\n}\n*, *:before, *:after {\nbox-sizing: inherit;\n}\nh1, h2 {\nmargin-bottom: 0.2em;\nmargin-top: 0.8em;\n}\nh1 {\nfont-size: 1.4em;\n}\nh2 {\nfont-size: 1.2em;\n}\nbody {\nfont-size: 1.6em;\nmargin: 2px;\n}\nli {\nmargin-left: 2.5em;\n}\na {\ncolor: inherit;\ntext-decoration: inherit;\n}\nol, ul {\nmargin-bottom: 0.5em;\nmargin-top: 0.5em;\n}\np {\nmargin-bottom: 2em;\n}\ntable {\nmargin: 0.6em;\n}\ntable tr:nth-child(odd) {\nbackground-color: #F0F0F0;\n}\ntable tr:hover {\nbackground-color: #DDD !important;\n}\ntable td {\nfont-family: monospace;\npadding: 0.2em 0.4em 0.2em;\n}\n.call {\nfont-family: monospace;\n}\n@media screen and (max-width: 500px) {\nh1 {\nfont-size: 1.3em;\n}\n}\n@media screen and (max-width: 500px) and (orientation: portrait) {\n.args span {\ndisplay: none;\n}\n.args::after {\ncontent: '…';\n}\n}\n.created {\nwhite-space: nowrap;\n}\ndetails.created, details.calls {\nmargin-left: 0.6em;\n}\ndetails.created > summary, details.calls > summary {\ncolor: #666;\ncursor: pointer;\nfont-size: smaller;\n}\n.toolbar {\nbackground-color: #F8F8F8;\nborder-bottom: 1px solid #DDD;\npadding: 0.4em;\nposition: sticky;\ntop: 0;\nz-index: 20;\n}\n.toolbar label, .toolbar button {\nmargin-right: 1em;\n}\n.toolbar input.invalid {\nbackground-color: #FDD;\n}\n.bucket:target, .bucket:has(a:target) {\nbackground-color: #FFFDE0;\n}\n.annotation {\ncolor: #666;\nmargin-left: 1em;\n}\n.module {\ncolor: #666;\nfont-size: smaller;\n}\n.tree {\nlist-style: none;\npadding-left: 1.5em;\n}\n.tree summary {\ncursor: pointer;\nfont-weight: 700;\n}\n.race {\nfont-weight: 700;\ncolor: #600;\n}\n#content {\nwidth: 100%;\n}\n.hastooltip:hover .tooltip {\nbackground: #fffAF0;\nborder: 1px solid #DCA;\nborder-radius: 6px;\nbox-shadow: 5px 5px 8px #CCC;\ncolor: #111;\ndisplay: inline;\nposition: absolute;\n}\n.tooltip {\ndisplay: none;\nline-height: 16px;\nmargin-left: 1rem;\nmargin-top: 2.5rem;\npadding: 1rem;\nz-index: 10;\n}\n.suspicion {\nfont-weight: 700;\n}\n.suspicion.low {\ncolor: #960;\n}\n.suspicion.medium {\ncolor: #C60;\n}\n.suspicion.high {\ncolor: #C00;\n}\n.captured, .tracked {\ncolor: #888;\n}\n.bottom-padding {\nmargin-top: 5em;\n}\n.copy {\ncursor: pointer;\nfont-size: 0.8em;\nmargin-left: 1em;\npadding: 0 0.4em;\n}\n{{- /* Highlights based on stack.Location value. */ -}}\n.FuncMain {\ncolor: #880;\n}\n.FuncLocationUnknown {\ncolor: #888;\n}\n.FuncGoMod {\ncolor: #800;\n}\n.FuncGOPATH {\ncolor: #109090;\n}\n.FuncGoPkg {\ncolor: #008;\n}\n.FuncStdlib {\ncolor: #080;\n}\n.Exported {\nfont-weight: 700;\n}\n</style>\n<div id=\"content\">\n{{- if not .Snapshot.CapturedAt.IsZero -}}\n<p class=\"captured\">Captured <span class=\"ago\" data-ts=\"{{.Snapshot.CapturedAt.Unix}}\" title=\"{{.Snapshot.CapturedAt.String}}\">{{ago .Snapshot.CapturedAt}}</span></p>\n{{- end -}}\n{{- if .Tree -}}\n{{template \"RenderTree\" .Tree}}\n{{- else if .Aggregated -}}\n{{- if .Aggregated.Previous -}}\n{{- $g := len .Aggregated.Gone}}\n<p class=\"tracked\">{{$g}} routine{{if ne 1 $g}}s{{end}} gone since the previous snapshot.</p>\n{{- end -}}\n{{template \"Toolbar\"}}\n{{- range $i, $e := .Aggregated.Buckets -}}\n{{$l := len $e.IDs}}\n<div class=\"bucket\" id=\"b{{$i}}\" data-index=\"{{$i}}\" data-count=\"{{$l}}\" data-sleep=\"{{$e.SleepMax}}\" data-pkgs=\"{{packages $e.Signature.Stack}}\">\n{{- range $e.IDs}}<a id=\"g{{.}}\"></a>{{end}}\n<h1><a href=\"#b{{$i}}\">Signature #{{$i}}</a>: {{$l}} routine{{if ne 1 $l}}s{{end}}: <span class=\"state\">{{$e.State}}</span>\n{{- if $e.SleepMax -}}\n{{- if ne $e.SleepMin $e.SleepMax}} <span class=\"sleep\">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>\n{{- else}} <span class=\"sleep\">[{{$e.SleepMax}} mins]</span>\n{{- end -}}\n{{- end -}}\n</h1>\n{{if $e.Locked}} <span class=\"locked\">[locked]</span>\n{{- end -}}\n{{- range $e.Extra}} <span class=\"extra\">[{{.}}]</span>\n{{- end -}}\n{{- if $e.OnSystemStack}} <span class=\"extra\">[system stack: runtime or signal handler, not user code]</span>\n{{- end -}}\n{{- if $.Aggregated.Previous -}}\n{{- $n := len $e.Existing}} <span class=\"tracked\">[{{$n}} existed, {{minus $l $n}} new]</span>\n{{- end -}}\n{{- range $e.Suspicions}} <span class=\"suspicion {{.Severity}}\">[{{.Severity}}: {{.Explanation}}]</span>\n{{- end -}}\n<button class=\"copy\" data-markdown=\"{{markdown $e}}\" title=\"Copy as Markdown, e.g. for a GitHub issue\">Copy as Markdown</button>\n{{- if $e.CreatedBy.Calls}}\n<details class=\"created\" open><summary>Created by</summary>{{template \"RenderCreatedBy\" index $e.CreatedBy.Calls 0}}</details>\n{{- else if $e.CreatedBy.Unavailable}}\n<details class=\"created\" open><summary>Created by</summary>unknown, failed to restore the stack</details>\n{{- end}}\n<details class=\"calls\" open><summary>Stack</summary>{{template \"RenderCalls\" $e.Signature.Stack}}</details>\n</div>\n{{- end -}}\n{{- else -}}\n{{template \"Toolbar\"}}\n{{- range $i, $e := .Snapshot.Goroutines -}}\n<div class=\"bucket\" id=\"g{{$e.ID}}\" data-index=\"{{$i}}\" data-count=\"1\" data-sleep=\"{{$e.SleepMax}}\" data-pkgs=\"{{packages $e.Signature.Stack}}\">\n<h1><a href=\"#g{{$e.ID}}\">Routine {{$e.ID}}</a>: <span class=\"state\">{{$e.State}}</span>\n{{- if $e.SleepMax -}}\n{{- if ne $e.SleepMin $e.SleepMax}} <span class=\"sleep\">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>\n{{- else}} <span class=\"sleep\">[{{$e.SleepMax}} mins]</span>\n{{- end -}}\n{{- end -}}\n</h1>\n{{if $e.Locked}} <span class=\"locked\">[locked]</span>\n{{- end -}}\n{{- range $e.Extra}} <span class=\"extra\">[{{.}}]</span>\n{{- end -}}\n{{- if $e.OnSystemStack}} <span class=\"extra\">[system stack: runtime or signal handler, not user code]</span>\n{{- end -}}\n{{if $e.RaceAddr}} <span class=\"race\">Race {{if $e.RaceWrite}}write{{else}}read{{end}} @ {{printf \"0x%08X\" $e.RaceAddr}}</span><br>\n{{- end -}}\n{{- if $e.CreatedBy.Calls}}\n<details class=\"created\" open><summary>Created by</summary>{{template \"RenderCreatedBy\" index $e.CreatedBy.Calls 0}}</details>\n{{- else if $e.CreatedBy.Unavailable}}\n<details class=\"created\" open><summary>Created by</summary>unknown, failed to restore the stack</details>\n{{- end}}\n<details class=\"calls\" open><summary>Stack</summary>{{template \"RenderCalls\" $e.Signature.Stack}}</details>\n</div>\n{{- end -}}\n{{- end -}}\n</div>\n<h2>Metadata</h2>\n<ul>\n<li>Created on {{.Now.String}}</li>\n<li>{{.Version}}</li>\n{{- if and .Snapshot.LocalGOROOT (ne .Snapshot.RemoteGOROOT .Snapshot.LocalGOROOT) -}}\n<li>GOROOT (remote): {{.Snapshot.RemoteGOROOT}}</li>\n<li>GOROOT (local): {{.Snapshot.LocalGOROOT}}</li>\n{{- else -}}\n<li>GOROOT: {{.Snapshot.RemoteGOROOT}}</li>\n{{- end -}}\n<li>GOPATH: {{template \"Join\" .Snapshot.LocalGOPATHs}}</li>\n{{- if .Snapshot.LocalGomods -}}\n<li>go modules (local):\n<ul>\n{{- range $path, $import := .Snapshot.LocalGomods -}}\n<li>{{$path}}: {{$import}}</li>\n{{- end -}}\n</ul>\n</li>\n{{- end -}}\n<li>GOMAXPROCS: {{.GOMAXPROCS}}</li>\n{{- if .Snapshot.DialectVersion -}}\n<li>Parsed as: {{.Snapshot.DialectVersion}} dialect</li>\n{{- end -}}\n</ul>\n<h2>Legend</h2>\n<table class=\"legend\">\n<thead>\n<th>Type</th>\n<th>Exported</th>\n<th>Private</th>\n</thead>\n<tr class=\"call hastooltip\">\n<td>\nPackage main\n<span class=\"tooltip\">Sources that are in the main package.</span>\n</td>\n<td class=\"FuncMain\">main.Foo()</td>\n<td class=\"FuncMain\">main.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nGo module\n<span class=\"tooltip\">Sources located inside a directory containing a\n<strong>go.mod</strong> file but outside $GOPATH.</span>\n</td>\n<td class=\"FuncGoMod Exported\">pkg.Foo()</td>\n<td class=\"FuncGoMod\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\n$GOPATH/src/...\n<span class=\"tooltip\">Sources located inside the traditional $GOPATH/src\ndirectory.</span>\n</td>\n<td class=\"FuncGOPATH Exported\">pkg.Foo()</td>\n<td class=\"FuncGOPATH\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\n$GOPATH/pkg/mod/...\n<span class=\"tooltip\">Sources located inside the go module dependency\ncache under $GOPATH/pkg/mod. These files are unmodified third parties.</span>\n</td>\n<td class=\"FuncGoPkg Exported\">pkg.Foo()</td>\n<td class=\"FuncGoPkg\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nStandard library\n<span class=\"tooltip\">Sources from the Go standard library under\n$GOROOT/src/.</span>\n</td>\n<td class=\"FuncStdlib Exported\">pkg.Foo()</td>\n<td class=\"FuncStdlib\">pkg.foo()</td>\n</tr>\n<tr class=\"call hastooltip\">\n<td>\nUnknown source location\n<span class=\"tooltip\">Sources which location was not successfully\ndetermined.</span>\n</td>\n<td class=\"FuncLocationUnknown Exported\">pkg.Foo()</td>\n<td class=\"FuncLocationUnknown\">pkg.foo()</td>\n</tr>\n</table>\n{{- .Footer -}}\n<script>\n{{- /* Keeps the time since the capture up to date, in the same format as ago. */ -}}\ndocument.querySelectorAll(\"span.ago\").forEach(function(e) {\nvar ts = parseInt(e.dataset.ts, 10);\nvar update = function() {\nvar d = Math.max(0, Math.floor(Date.now() / 1000) - ts);\nvar s = \"\";\nif (d >= 3600) {\ns = Math.floor(d / 3600) + \"h\" + Math.floor((d % 3600) / 60) + \"m\";\n} else if (d >= 60) {\ns = Math.floor(d / 60) + \"m\";\n}\ne.textContent = s + (d % 60) + \"s ago\";\n};\nupdate();\nsetInterval(update, 1000);\n});\n{{- /* Filters, sorts and collapses the buckets, like -f and -m. */ -}}\n(function() {\nvar content = document.getElementById(\"content\");\nvar buckets = Array.prototype.slice.call(content.querySelectorAll(\"div.bucket\"));\nif (!buckets.length) {\nreturn;\n}\nvar filter = document.getElementById(\"filter\");\nvar match = document.getElementById(\"match\");\nvar sort = document.getElementById(\"sort\");\nvar shown = document.getElementById(\"shown\");\nvar compile = function(input) {\ninput.classList.remove(\"invalid\");\nif (!input.value) {\nreturn null;\n}\ntry {\nreturn new RegExp(input.value);\n} catch (err) {\ninput.classList.add(\"invalid\");\nreturn null;\n}\n};\nvar apply = function() {\nvar f = compile(filter);\nvar m = compile(match);\nvar n = 0;\nbuckets.forEach(function(b) {\nvar text = b.querySelector(\"h1\").textContent + \"\\n\" + b.dataset.pkgs;\nvar hide = (f && f.test(text)) || (m && !m.test(text));\nb.hidden = hide;\nif (!hide) {\nn++;\n}\n});\nshown.textContent = n + \"/\" + buckets.length + \" shown\";\nvar key = sort.value;\nbuckets.slice().sort(function(a, b) {\nif (key !== \"index\") {\nvar d = parseInt(b.dataset[key], 10) - parseInt(a.dataset[key], 10);\nif (d) {\nreturn d;\n}\n}\nreturn parseInt(a.dataset.index, 10) - parseInt(b.dataset.index, 10);\n}).forEach(function(b) {\ncontent.appendChild(b);\n});\n};\nvar setOpen = function(open) {\ncontent.querySelectorAll(\"div.bucket details\").forEach(function(d) {\nd.open = open;\n});\n};\nfilter.addEventListener(\"input\", apply);\nmatch.addEventListener(\"input\", apply);\nsort.addEventListener(\"change\", apply);\ndocument.getElementById(\"collapse\").addEventListener(\"click\", function() { setOpen(false); });\ndocument.getElementById(\"expand\").addEventListener(\"click\", function() { setOpen(true); });\n{{- /* Reveals the goroutine linked to, even when filtered out. */ -}}\nvar reveal = function() {\nvar t = location.hash && document.getElementById(location.hash.slice(1));\nvar b = t && t.closest(\"div.bucket\");\nif (b) {\nb.hidden = false;\nb.querySelectorAll(\"details\").forEach(function(d) { d.open = true; });\nt.scrollIntoView();\n}\n};\nwindow.addEventListener(\"hashchange\", reveal);\napply();\nreveal();\n})();\n{{- /* Copies the bucket as Markdown in the clipboard. */ -}}\ndocument.querySelectorAll(\"button.copy\").forEach(function(b) {\nb.addEventListener(\"click\", function() {\nnavigator.clipboard.writeText(b.dataset.markdown).then(function() {\nb.textContent = \"Copied\";\nsetTimeout(function() { b.textContent = \"Copy as Markdown\"; }, 1500);\n});\n});\n});\n</script>\n{{- /* Add unnecessary bottom spacing so the last tooltip from the legend is visible. */ -}}\n<div class=\"bottom-padding\"></div>\n"
 
 // favicon is the bomb emoji U+1F4A3 in Noto Emoji as a 128x128 base64 encoded
 // PNG.
@@ -0,0 +1,208 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Deadlock is a probable deadlock found by Snapshot.FindDeadlocks: a cycle of
+// blocked goroutines, each waiting on an object referenced by the next one.
+type Deadlock struct {
+	// IDs is the goroutines in the cycle. Each one waits on an object
+	// referenced by the next one, and the last one on an object referenced by
+	// the first one.
+	IDs []int
+	// Objects is the address of the object, like a sync.Mutex or a channel,
+	// that each goroutine in IDs waits on.
+	Objects []uint64
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// String returns a one line description of the cycle.
+func (d *Deadlock) String() string {
+	parts := make([]string, len(d.IDs))
+	for i, id := range d.IDs {
+		parts[i] = fmt.Sprintf("goroutine %d waits on 0x%x referenced by goroutine %d", id, d.Objects[i], d.IDs[(i+1)%len(d.IDs)])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FindDeadlocks returns the probable deadlocks in the snapshot.
+//
+// It builds a wait-for graph between the goroutines: a goroutine blocked in
+// package runtime or sync on an object, like a sync.Mutex or a channel, waits
+// for the other goroutines referencing the same pointer in their arguments,
+// which are likely to hold the lock or be the other end of the channel. One
+// cycle is returned per group of goroutines waiting on each other.
+//
+// Like ToDot, this is a heuristic based on pointer values, as the stack trace
+// doesn't contain type information. It requires the arguments to be printed,
+// so it finds nothing in a goroutine profile or a race detector report.
+func (s *Snapshot) FindDeadlocks() []Deadlock {
+	if s.IsRace() {
+		return nil
+	}
+	edges := s.waitFor()
+	var out []Deadlock
+	for _, scc := range stronglyConnected(edges) {
+		if len(scc) < 2 {
+			continue
+		}
+		in := make(map[int]bool, len(scc))
+		for _, id := range scc {
+			in[id] = true
+		}
+		// Follow the edges inside the component from its smallest goroutine ID
+		// until a goroutine is visited twice. Every goroutine of the component
+		// has an edge to another one of the component, so this always loops.
+		pos := map[int]int{}
+		var ids []int
+		var objs []uint64
+		id := scc[0]
+		for {
+			if i, ok := pos[id]; ok {
+				out = append(out, Deadlock{IDs: ids[i:], Objects: objs[i:]})
+				break
+			}
+			pos[id] = len(ids)
+			ids = append(ids, id)
+			for _, e := range edges[id] {
+				if in[e.to] {
+					objs = append(objs, e.obj)
+					id = e.to
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Private stuff.
+
+type waitEdge struct {
+	to  int
+	obj uint64
+}
+
+// waitFor returns the wait-for edges between goroutines, keyed by the ID of
+// the waiting goroutine and sorted.
+//
+// See ToDot for the same heuristic between buckets.
+func (s *Snapshot) waitFor() map[int][]waitEdge {
+	waits := map[uint64][]int{}
+	refs := map[uint64][]int{}
+	add := func(m map[uint64][]int, ptr uint64, id int) {
+		if l := m[ptr]; len(l) == 0 || l[len(l)-1] != id {
+			m[ptr] = append(l, id)
+		}
+	}
+	for _, g := range s.Goroutines {
+		blocked := isBlockedState(g.State)
+		for i := range g.Stack.Calls {
+			c := &g.Stack.Calls[i]
+			m := refs
+			if isSyncPkg(c.Func.ImportPath) {
+				if !blocked {
+					continue
+				}
+				m = waits
+			}
+			c.Args.walk(func(arg *Arg) {
+				if arg.IsPtr {
+					add(m, arg.Value, g.ID)
+				}
+			})
+		}
+	}
+	out := map[int][]waitEdge{}
+	for ptr, waiters := range waits {
+		waiting := make(map[int]bool, len(waiters))
+		for _, w := range waiters {
+			waiting[w] = true
+		}
+		// A goroutine waiting on the same object is not holding it.
+		var holders []int
+		for _, r := range refs[ptr] {
+			if !waiting[r] {
+				holders = append(holders, r)
+			}
+		}
+		for _, w := range waiters {
+			for _, r := range holders {
+				out[w] = append(out[w], waitEdge{to: r, obj: ptr})
+			}
+		}
+	}
+	for _, e := range out {
+		sort.Slice(e, func(i, j int) bool {
+			if e[i].to != e[j].to {
+				return e[i].to < e[j].to
+			}
+			return e[i].obj < e[j].obj
+		})
+	}
+	return out
+}
+
+// stronglyConnected returns the strongly connected components of the graph
+// with Tarjan's algorithm. Each component is sorted, and the components are
+// sorted by their first node.
+func stronglyConnected(edges map[int][]waitEdge) [][]int {
+	nodes := make([]int, 0, len(edges))
+	for n := range edges {
+		nodes = append(nodes, n)
+	}
+	sort.Ints(nodes)
+	index := map[int]int{}
+	low := map[int]int{}
+	onStack := map[int]bool{}
+	var st []int
+	var out [][]int
+	var visit func(n int)
+	visit = func(n int) {
+		index[n] = len(index)
+		low[n] = index[n]
+		st = append(st, n)
+		onStack[n] = true
+		for _, e := range edges[n] {
+			if _, ok := index[e.to]; !ok {
+				visit(e.to)
+				if low[e.to] < low[n] {
+					low[n] = low[e.to]
+				}
+			} else if onStack[e.to] && index[e.to] < low[n] {
+				low[n] = index[e.to]
+			}
+		}
+		if low[n] != index[n] {
+			return
+		}
+		var scc []int
+		for {
+			m := st[len(st)-1]
+			st = st[:len(st)-1]
+			onStack[m] = false
+			scc = append(scc, m)
+			if m == n {
+				break
+			}
+		}
+		sort.Ints(scc)
+		out = append(out, scc)
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i][0] < out[j][0] })
+	return out
+}
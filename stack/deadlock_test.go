@@ -0,0 +1,69 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot_FindDeadlocks(t *testing.T) {
+	t.Parallel()
+	// Goroutines 6 and 7 lock two mutexes in opposite order. Goroutine 8 waits
+	// on the same mutex as goroutine 6, which must not be reported as a cycle.
+	// Goroutine 9 waits on a channel nobody else references.
+	in := "" +
+		"goroutine 1 [running]:\n" +
+		"main.main()\n" +
+		"\t/home/user/go/src/main.go:50 +0x20\n" +
+		"\n" +
+		"goroutine 6 [sync.Mutex.Lock]:\n" +
+		"sync.runtime_SemacquireMutex(0xc000010004, 0x0, 0x1)\n" +
+		"\t/goroot/src/runtime/sema.go:77 +0x25\n" +
+		"sync.(*Mutex).lockSlow(0xc000010000)\n" +
+		"\t/goroot/src/sync/mutex.go:171 +0x165\n" +
+		"main.transfer(0xc000010008, 0xc000010000)\n" +
+		"\t/home/user/go/src/main.go:20 +0x40\n" +
+		"\n" +
+		"goroutine 7 [sync.Mutex.Lock]:\n" +
+		"sync.runtime_SemacquireMutex(0xc00001000c, 0x0, 0x1)\n" +
+		"\t/goroot/src/runtime/sema.go:77 +0x25\n" +
+		"sync.(*Mutex).lockSlow(0xc000010008)\n" +
+		"\t/goroot/src/sync/mutex.go:171 +0x165\n" +
+		"main.transfer(0xc000010000, 0xc000010008)\n" +
+		"\t/home/user/go/src/main.go:20 +0x40\n" +
+		"\n" +
+		"goroutine 8 [sync.Mutex.Lock]:\n" +
+		"sync.runtime_SemacquireMutex(0xc000010004, 0x0, 0x1)\n" +
+		"\t/goroot/src/runtime/sema.go:77 +0x25\n" +
+		"sync.(*Mutex).lockSlow(0xc000010000)\n" +
+		"\t/goroot/src/sync/mutex.go:171 +0x165\n" +
+		"main.other(0xc000010000)\n" +
+		"\t/home/user/go/src/main.go:30 +0x40\n" +
+		"\n" +
+		"goroutine 9 [chan receive]:\n" +
+		"runtime.chanrecv1(0xc000020000, 0x0)\n" +
+		"\t/goroot/src/runtime/chan.go:442 +0x18\n" +
+		"main.worker(0xc000020000)\n" +
+		"\t/home/user/go/src/main.go:40 +0x40\n" +
+		"\n"
+	s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{})
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	got := s.FindDeadlocks()
+	want := []Deadlock{{IDs: []int{6, 7}, Objects: []uint64{0xc000010000, 0xc000010008}}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Deadlock{})); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	compareString(t, "goroutine 6 waits on 0xc000010000 referenced by goroutine 7, goroutine 7 waits on 0xc000010008 referenced by goroutine 6", got[0].String())
+
+	if d := (&Snapshot{Goroutines: s.Goroutines[3:]}).FindDeadlocks(); d != nil {
+		t.Fatalf("unexpected %v", d)
+	}
+}
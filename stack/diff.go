@@ -0,0 +1,98 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+// SnapshotDiff is the difference between two snapshots of the same process,
+// as returned by Diff.
+//
+// Each goroutine is in exactly one of the lists. They are in the order they
+// were printed.
+type SnapshotDiff struct {
+	// Persisted are the goroutines of the new snapshot that were in the old
+	// one with a similar signature. These are the candidates for a leak when
+	// the snapshots are far apart.
+	Persisted []*Goroutine
+	// Moved are the goroutines of the new snapshot that were in the old one
+	// with a different signature, e.g. a worker that processed another item.
+	Moved []*Goroutine
+	// Appeared are the goroutines of the new snapshot that were not in the
+	// old one.
+	Appeared []*Goroutine
+	// Disappeared are the goroutines of the old snapshot that are not in the
+	// new one.
+	Disappeared []*Goroutine
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Diff compares the goroutines of prev and cur, two snapshots of the same
+// process taken at different times, to find the goroutines that persist,
+// appear or disappear.
+//
+// Goroutines are matched by ID, which the runtime never reuses, then their
+// signatures are compared according to similar. This is meaningless for
+// goroutine profiles since their IDs are synthetic.
+//
+// See Aggregated.Track to annotate the buckets of a single snapshot instead.
+func Diff(prev, cur *Snapshot, similar Similarity) *SnapshotDiff {
+	before := make(map[int]*Goroutine, len(prev.Goroutines))
+	for _, g := range prev.Goroutines {
+		before[g.ID] = g
+	}
+	d := &SnapshotDiff{}
+	for _, g := range cur.Goroutines {
+		p := before[g.ID]
+		switch {
+		case p == nil:
+			d.Appeared = append(d.Appeared, g)
+		case g.Signature.SimilarTo(&p.Signature, similar):
+			d.Persisted = append(d.Persisted, g)
+		default:
+			d.Moved = append(d.Moved, g)
+		}
+		delete(before, g.ID)
+	}
+	for _, g := range prev.Goroutines {
+		if before[g.ID] != nil {
+			d.Disappeared = append(d.Disappeared, g)
+		}
+	}
+	return d
+}
+
+// Merge returns a snapshot with the goroutines of both prev and cur, two
+// snapshots of the same process taken at different times, so they can be
+// aggregated together, e.g. to find the buckets of short lived goroutines
+// that only show up in some of the snapshots. Call it repeatedly to merge
+// more snapshots.
+//
+// Goroutines are matched by ID like Diff. A goroutine in both snapshots is
+// kept once, as found in cur. The goroutines of cur are first, in the order
+// they were printed, followed by the ones that disappeared, which are copied
+// with First cleared.
+//
+// The other fields are copied from cur, except NamedPointers and
+// ProfileSamples which are reset since they only describe cur. Neither
+// snapshot is modified.
+func Merge(prev, cur *Snapshot) *Snapshot {
+	m := *cur
+	m.NamedPointers = nil
+	m.ProfileSamples = nil
+	m.Goroutines = make([]*Goroutine, 0, len(cur.Goroutines)+len(prev.Goroutines))
+	seen := make(map[int]bool, len(cur.Goroutines))
+	for _, g := range cur.Goroutines {
+		m.Goroutines = append(m.Goroutines, g)
+		seen[g.ID] = true
+	}
+	for _, g := range prev.Goroutines {
+		if !seen[g.ID] {
+			c := *g
+			c.First = false
+			m.Goroutines = append(m.Goroutines, &c)
+		}
+	}
+	return &m
+}
@@ -0,0 +1,95 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	worker := Signature{State: "chan receive", Stack: Stack{Calls: []Call{newCall("main.worker", Args{Values: []Arg{{Value: 0xc000010000, IsPtr: true}}}, "/home/user/go/src/main.go", 20)}}}
+	other := Signature{State: "chan receive", Stack: Stack{Calls: []Call{newCall("main.worker", Args{Values: []Arg{{Value: 0xc000020000, IsPtr: true}}}, "/home/user/go/src/main.go", 20)}}}
+	sel := Signature{State: "select", Stack: Stack{Calls: []Call{newCall("main.other", Args{}, "/home/user/go/src/main.go", 30)}}}
+	prev := &Snapshot{Goroutines: []*Goroutine{
+		{Signature: worker, ID: 1},
+		{Signature: worker, ID: 2},
+		{Signature: worker, ID: 3},
+		{Signature: sel, ID: 4},
+	}}
+	cur := &Snapshot{Goroutines: []*Goroutine{
+		{Signature: other, ID: 5},
+		{Signature: sel, ID: 2},
+		{Signature: other, ID: 1},
+		{Signature: worker, ID: 3},
+	}}
+	ids := func(g []*Goroutine) []int {
+		var out []int
+		for _, r := range g {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	data := []struct {
+		similar Similarity
+		want    [4][]int
+	}{
+		{AnyPointer, [4][]int{{1, 3}, {2}, {5}, {4}}},
+		{ExactLines, [4][]int{{3}, {2, 1}, {5}, {4}}},
+	}
+	for i, line := range data {
+		d := Diff(prev, cur, line.similar)
+		got := [4][]int{ids(d.Persisted), ids(d.Moved), ids(d.Appeared), ids(d.Disappeared)}
+		if diff := cmp.Diff(line.want, got); diff != "" {
+			t.Errorf("#%d: -want, +got:\n%s", i, diff)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	worker := Signature{State: "chan receive", Stack: Stack{Calls: []Call{newCall("main.worker", Args{}, "/home/user/go/src/main.go", 20)}}}
+	sel := Signature{State: "select", Stack: Stack{Calls: []Call{newCall("main.other", Args{}, "/home/user/go/src/main.go", 30)}}}
+	prev := &Snapshot{Goroutines: []*Goroutine{
+		{Signature: worker, ID: 1, First: true},
+		{Signature: worker, ID: 2},
+		{Signature: sel, ID: 4},
+	}}
+	cur := &Snapshot{
+		Goroutines: []*Goroutine{
+			{Signature: sel, ID: 2, First: true},
+			{Signature: worker, ID: 5},
+		},
+		DialectVersion: "go1.21",
+		NamedPointers:  map[string][]int{"#1": {2}},
+	}
+	m := Merge(prev, cur)
+	var got []int
+	for _, g := range m.Goroutines {
+		got = append(got, g.ID)
+	}
+	if diff := cmp.Diff([]int{2, 5, 1, 4}, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	// Goroutine 2 is kept as found in cur.
+	if m.Goroutines[1].State != "chan receive" || m.Goroutines[0].State != "select" {
+		t.Fatal("unexpected signature")
+	}
+	if !m.Goroutines[0].First || m.Goroutines[2].First {
+		t.Fatal("only the first goroutine of cur must be first")
+	}
+	if !prev.Goroutines[0].First || len(cur.Goroutines) != 2 {
+		t.Fatal("the snapshots were modified")
+	}
+	if m.DialectVersion != "go1.21" || m.NamedPointers != nil {
+		t.Fatalf("unexpected %q %v", m.DialectVersion, m.NamedPointers)
+	}
+	// The merged goroutines can be aggregated together.
+	if a := m.Aggregate(AnyPointer); len(a.Buckets) != 2 {
+		t.Fatalf("unexpected %d buckets", len(a.Buckets))
+	}
+}
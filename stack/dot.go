@@ -0,0 +1,175 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ToDot writes the buckets as a GraphViz dot graph.
+//
+// Each bucket is a node. Two kinds of edges are generated:
+//   - "created": from the bucket of the creator goroutine to the bucket of the
+//     goroutines it created. This requires go1.21+ traces.
+//   - "waits": from a bucket blocked in package runtime or sync on an object,
+//     like a channel or a mutex, to the other buckets referencing the same
+//     pointer in their arguments.
+//
+// Wait-for edges are a heuristic based on pointer values, as the stack trace
+// doesn't contain type information. A cycle of wait-for edges hints at a
+// deadlock.
+func (a *Aggregated) ToDot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph goroutines {\n")
+	fmt.Fprintf(bw, "  node [shape=box fontname=monospace];\n")
+	for i, b := range a.Buckets {
+		label := strconv.Itoa(len(b.IDs)) + " routine"
+		if len(b.IDs) != 1 {
+			label += "s"
+		}
+		label += "\n" + b.State
+		if len(b.Stack.Calls) != 0 {
+			c := &b.Stack.Calls[0]
+			label += "\n" + c.Func.DirName + "." + c.Func.Name
+		}
+		attrs := ""
+		if b.First {
+			attrs = " style=bold"
+		}
+		fmt.Fprintf(bw, "  b%d [label=%s%s];\n", i, dotQuote(label), attrs)
+	}
+	for _, e := range a.dotEdges() {
+		fmt.Fprintf(bw, "  b%d -> b%d [label=%s", e.from, e.to, dotQuote(e.label))
+		if e.kind == "created" {
+			fmt.Fprintf(bw, " style=dashed")
+		} else {
+			fmt.Fprintf(bw, " color=red")
+		}
+		fmt.Fprintf(bw, "];\n")
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// Private stuff.
+
+type dotEdge struct {
+	from, to int
+	kind     string
+	label    string
+}
+
+// dotEdges returns the deduplicated edges between buckets, sorted.
+func (a *Aggregated) dotEdges() []dotEdge {
+	bucketOf := map[int]int{}
+	for i, b := range a.Buckets {
+		for _, id := range b.IDs {
+			bucketOf[id] = i
+		}
+	}
+	type key struct {
+		from, to int
+		kind     string
+	}
+	edges := map[key][]string{}
+	add := func(from, to int, kind, label string) {
+		k := key{from, to, kind}
+		for _, l := range edges[k] {
+			if l == label {
+				return
+			}
+		}
+		edges[k] = append(edges[k], label)
+	}
+
+	// Objects being waited on and objects referenced, per bucket.
+	waits := map[uint64]map[int]bool{}
+	refs := map[uint64]map[int]bool{}
+	created := map[[2]int]int{}
+	for _, g := range a.Goroutines {
+		b, ok := bucketOf[g.ID]
+		if !ok {
+			continue
+		}
+		if p, ok := bucketOf[g.CreatedByID]; ok && g.CreatedByID != 0 {
+			created[[2]int{p, b}]++
+		}
+		blocked := isBlockedState(g.State)
+		for i := range g.Stack.Calls {
+			c := &g.Stack.Calls[i]
+			m := refs
+			if isSyncPkg(c.Func.ImportPath) {
+				if !blocked {
+					continue
+				}
+				m = waits
+			}
+			c.Args.walk(func(arg *Arg) {
+				if !arg.IsPtr {
+					return
+				}
+				if m[arg.Value] == nil {
+					m[arg.Value] = map[int]bool{}
+				}
+				m[arg.Value][b] = true
+			})
+		}
+	}
+	for k, n := range created {
+		add(k[0], k[1], "created", "created "+strconv.Itoa(n))
+	}
+	for ptr, waiters := range waits {
+		for w := range waiters {
+			for r := range refs[ptr] {
+				if r != w {
+					add(w, r, "waits", fmt.Sprintf("waits 0x%x", ptr))
+				}
+			}
+		}
+	}
+
+	out := make([]dotEdge, 0, len(edges))
+	for k, labels := range edges {
+		sort.Strings(labels)
+		out = append(out, dotEdge{from: k.from, to: k.to, kind: k.kind, label: strings.Join(labels, "\n")})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].from != out[j].from {
+			return out[i].from < out[j].from
+		}
+		if out[i].to != out[j].to {
+			return out[i].to < out[j].to
+		}
+		return out[i].kind < out[j].kind
+	})
+	return out
+}
+
+// isBlockedState returns true if the goroutine state means it is waiting on
+// something.
+func isBlockedState(s string) bool {
+	switch s {
+	case StateRunning, StateRunnable, StateSyscall, StateIdle, StateDead, "finished":
+		return false
+	}
+	return true
+}
+
+// isSyncPkg returns true if the package implements a synchronization
+// primitive that can block a goroutine.
+func isSyncPkg(p string) bool {
+	return p == "runtime" || p == "sync" || p == "internal/sync"
+}
+
+// dotQuote quotes a string as a dot ID.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
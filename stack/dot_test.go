@@ -0,0 +1,50 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAggregated_ToDot(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [chan receive]:",
+		"runtime.chanrecv1(0xc000010000, 0x0)",
+		"\t/goroot/src/runtime/chan.go:442 +0x18",
+		"main.main()",
+		"\t/a/main.go:10 +0x1",
+		"",
+		"goroutine 6 [sync.Mutex.Lock]:",
+		"sync.(*Mutex).Lock(0xc000020000)",
+		"\t/goroot/src/sync/mutex.go:90 +0x18",
+		"main.worker(0xc000010000, 0xc000020000)",
+		"\t/a/main.go:20 +0x1",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:9 +0x1",
+		"",
+	}
+	opts := defaultOpts()
+	opts.NameArguments = false
+	s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(data, "\n")), io.Discard, opts)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	if err := s.Aggregate(AnyPointer).ToDot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "digraph goroutines {\n" +
+		"  node [shape=box fontname=monospace];\n" +
+		"  b0 [label=\"1 routine\\nchan receive\\nruntime.chanrecv1\" style=bold];\n" +
+		"  b1 [label=\"1 routine\\nsync.Mutex.Lock\\nsync.(*Mutex).Lock\"];\n" +
+		"  b0 -> b1 [label=\"created 1\" style=dashed];\n" +
+		"  b0 -> b1 [label=\"waits 0xc000010000\" color=red];\n" +
+		"}\n"
+	compareString(t, want, buf.String())
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PanicError is the error returned by WrapPanic.
+//
+// Use errors.As() to retrieve it from an error chain and access the parsed
+// Snapshot.
+type PanicError struct {
+	// Recovered is the value returned by recover().
+	Recovered interface{}
+	// Snapshot is the parsed stack trace. It is nil if the stack trace couldn't
+	// be parsed.
+	Snapshot *Snapshot
+	// Raw is the stack trace as passed to WrapPanic.
+	Raw []byte
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// WrapPanic returns an error wrapping a recovered panic and the stack trace
+// of the goroutine that panicked, as returned by runtime/debug.Stack().
+//
+// The stack trace is parsed with DefaultOpts(). Formatting the error with
+// "%+v" prints the stack trace in the same deduplicated format as pp.
+//
+// Example:
+//
+//	defer func() {
+//	  if v := recover(); v != nil {
+//	    err = stack.WrapPanic(v, debug.Stack())
+//	  }
+//	}()
+func WrapPanic(recovered interface{}, stack []byte) error {
+	p := &PanicError{Recovered: recovered, Raw: stack}
+	if s, _, err := ScanSnapshot(bytes.NewReader(stack), io.Discard, DefaultOpts()); s != nil && (err == nil || err == io.EOF) {
+		p.Snapshot = s
+	}
+	return p
+}
+
+// Error implements error.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Recovered)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Recovered.(error)
+	return err
+}
+
+// Format implements fmt.Formatter.
+//
+// "%+v" prints the error followed by the stack trace. Other verbs print the
+// same as Error().
+func (p *PanicError) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			_, _ = io.WriteString(f, p.Error()+"\n\n")
+			if p.Snapshot == nil {
+				_, _ = f.Write(p.Raw)
+				return
+			}
+			_, _ = io.WriteString(f, p.Snapshot.Aggregate(AnyPointer).text())
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(f, p.Error())
+	case 'q':
+		_, _ = io.WriteString(f, strconv.Quote(p.Error()))
+	default:
+		_, _ = fmt.Fprintf(f, "%%!%c(%s)", verb, p.Error())
+	}
+}
+
+// MaxBytesError is returned by ScanSnapshot and FirstGoroutine when the
+// input is larger than Opts.MaxBytes.
+type MaxBytesError struct {
+	// Limit is Opts.MaxBytes.
+	Limit int64
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Error implements error.
+func (m *MaxBytesError) Error() string {
+	return fmt.Sprintf("read more than %d bytes without completing the snapshot", m.Limit)
+}
+
+// text renders the buckets as plain text without colors, in the same layout
+// as pp.
+func (a *Aggregated) text() string {
+	srcLen, pkgLen := measureText(a.Buckets)
+	var out strings.Builder
+	for _, b := range a.Buckets {
+		out.WriteString(b.textHeader() + "\n")
+		out.WriteString(b.textCalls(srcLen, pkgLen))
+	}
+	return out.String()
+}
+
+// measureText returns the column widths to align the calls of the buckets.
+func measureText(buckets []*Bucket) (srcLen, pkgLen int) {
+	for _, b := range buckets {
+		for i := range b.Stack.Calls {
+			c := &b.Stack.Calls[i]
+			if l := len(c.SrcName) + 1 + len(strconv.Itoa(c.Line)); l > srcLen {
+				srcLen = l
+			}
+			if l := len(c.Func.DirName); l > pkgLen {
+				pkgLen = l
+			}
+		}
+	}
+	return srcLen, pkgLen
+}
+
+// textHeader returns the goroutine count, state and creator of the bucket on
+// one line.
+func (b *Bucket) textHeader() string {
+	out := fmt.Sprintf("%d: %s", len(b.IDs), b.State)
+	if len(b.CreatedBy.Calls) != 0 {
+		c := &b.CreatedBy.Calls[0]
+		out += fmt.Sprintf(" [Created by %s @ %s:%d]", c.Func.Complete, c.SrcName, c.Line)
+	}
+	return out
+}
+
+// textCalls returns one line per call of the bucket.
+func (b *Bucket) textCalls(srcLen, pkgLen int) string {
+	var out strings.Builder
+	for i := range b.Stack.Calls {
+		c := &b.Stack.Calls[i]
+		fmt.Fprintf(&out, "    %-*s %-*s %s(%s)\n", pkgLen, c.Func.DirName, srcLen, c.SrcName+":"+strconv.Itoa(c.Line), c.Func.Name, &c.Args)
+	}
+	if b.Stack.Elided {
+		out.WriteString("    (...)\n")
+	}
+	return out.String()
+}
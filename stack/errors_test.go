@@ -0,0 +1,60 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestWrapPanic(t *testing.T) {
+	t.Parallel()
+	err := recoverPanic(func() { panic("boom") })
+	compareString(t, "panic: boom", err.Error())
+	compareString(t, "panic: boom", fmt.Sprintf("%v", err))
+	compareString(t, `"panic: boom"`, fmt.Sprintf("%q", err))
+	var p *PanicError
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &p) {
+		t.Fatal("expected a PanicError")
+	}
+	if p.Snapshot == nil || len(p.Snapshot.Goroutines) != 1 {
+		t.Fatalf("unexpected %#v", p.Snapshot)
+	}
+	got := fmt.Sprintf("%+v", err)
+	if !strings.HasPrefix(got, "panic: boom\n\n1: running") || !strings.Contains(got, " recoverPanic(") {
+		t.Fatalf("unexpected %q", got)
+	}
+}
+
+func TestWrapPanic_Unwrap(t *testing.T) {
+	t.Parallel()
+	err := recoverPanic(func() { panic(io.EOF) })
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("unexpected %v", err)
+	}
+	if errors.Unwrap(recoverPanic(func() { panic(42) })) != nil {
+		t.Fatal("expected nil")
+	}
+}
+
+func TestWrapPanic_Invalid(t *testing.T) {
+	t.Parallel()
+	err := WrapPanic("boom", []byte("junk\n"))
+	compareString(t, "panic: boom\n\njunk\n", fmt.Sprintf("%+v", err))
+}
+
+func recoverPanic(f func()) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = WrapPanic(v, debug.Stack())
+		}
+	}()
+	f()
+	return nil
+}
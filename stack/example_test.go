@@ -293,3 +293,26 @@ func wrapPanic(h http.Handler) http.Handler {
 		h.ServeHTTP(w, r)
 	})
 }
+
+// Converts a recovered panic into an error. Printing it with "%+v" prints the
+// deduplicated stack trace.
+func ExampleWrapPanic() {
+	f := func() (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = stack.WrapPanic(v, debug.Stack())
+			}
+		}()
+		panic("It happens")
+	}
+
+	err := f()
+	fmt.Printf("%v\n", err)
+	var p *stack.PanicError
+	if errors.As(err, &p) {
+		fmt.Printf("%d goroutine\n", len(p.Snapshot.Goroutines))
+	}
+	// Output:
+	// panic: It happens
+	// 1 goroutine
+}
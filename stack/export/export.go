@@ -0,0 +1,112 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package export converts a parsed snapshot into machine readable crash
+// reports, so they can be forwarded to other tools without custom glue.
+//
+// WriteSARIF writes a SARIF 2.1.0 log, as consumed by code scanning UIs.
+// WriteOTLP writes an OpenTelemetry log record with the exception semantic
+// conventions, as accepted by the OTLP/HTTP JSON endpoint of observability
+// backends.
+//
+// Both report the goroutine that panicked, or the goroutines involved in a
+// data race, including their stack frames.
+package export
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Opts are the options to export a snapshot.
+type Opts struct {
+	// SrcRoot is the directory the source paths are relative to in the SARIF
+	// log, normally the root of the repository. Code scanning UIs only link
+	// the paths relative to it. Other paths are absolute file:// URIs. The
+	// local path is used when known.
+	SrcRoot string
+	// Resource is the attributes of the entity that crashed in the OTLP log
+	// record, e.g. "service.name".
+	Resource map[string]string
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// crashed returns the goroutines to report: the ones involved in a data race
+// or the one that panicked.
+//
+// When no goroutine is marked as the first, as in a goroutine profile, the
+// first one is used.
+func crashed(s *stack.Snapshot) []*stack.Goroutine {
+	if len(s.Goroutines) == 0 {
+		return nil
+	}
+	if s.IsRace() {
+		return s.Goroutines
+	}
+	for _, g := range s.Goroutines {
+		if g.First {
+			return []*stack.Goroutine{g}
+		}
+	}
+	return s.Goroutines[:1]
+}
+
+// message returns a one line description of the crash.
+func message(s *stack.Snapshot, g *stack.Goroutine) string {
+	switch {
+	case s.IsRace():
+		return "data race"
+	case s.PanicValue != "":
+		return s.PanicValue
+	default:
+		return "goroutine " + strconv.Itoa(g.ID) + " [" + g.State + "]"
+	}
+}
+
+// culprit returns the call that panicked as determined by
+// stack.Stack.CulpritIndex, or else the top of the stack. It returns nil if
+// there is no call.
+func culprit(st *stack.Stack) *stack.Call {
+	if i := st.CulpritIndex(); i != -1 {
+		return &st.Calls[i]
+	}
+	if len(st.Calls) != 0 {
+		return &st.Calls[0]
+	}
+	return nil
+}
+
+// srcPath returns the path of the source file, preferring the local one.
+func srcPath(c *stack.Call) string {
+	if c.LocalSrcPath != "" {
+		return c.LocalSrcPath
+	}
+	return c.RemoteSrcPath
+}
+
+// goTrace formats the goroutine like the Go runtime does.
+func goTrace(g *stack.Goroutine) string {
+	var b strings.Builder
+	b.WriteString("goroutine " + strconv.Itoa(g.ID) + " [" + g.State + "]:\n")
+	for i := range g.Stack.Calls {
+		c := &g.Stack.Calls[i]
+		b.WriteString(c.Func.Complete + "(" + c.Args.String() + ")\n\t" + c.RemoteSrcPath + ":" + strconv.Itoa(c.Line) + "\n")
+	}
+	if g.Stack.Elided {
+		b.WriteString("...additional frames elided...\n")
+	}
+	if len(g.CreatedBy.Calls) != 0 {
+		c := &g.CreatedBy.Calls[0]
+		b.WriteString("created by " + c.Func.Complete)
+		if g.CreatedByID != 0 {
+			b.WriteString(" in goroutine " + strconv.Itoa(g.CreatedByID))
+		}
+		b.WriteString("\n\t" + c.RemoteSrcPath + ":" + strconv.Itoa(c.Line) + "\n")
+	}
+	return b.String()
+}
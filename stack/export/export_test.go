@@ -0,0 +1,203 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// panicTrace is a panic logged by a recover handler, so the panic value is
+// known.
+const panicTrace = "" +
+	"panic: oh no\n" +
+	"goroutine 1 [running]:\n" +
+	"panic({0x4a5b20, 0x4e0d48})\n" +
+	"\t/goroot/src/runtime/panic.go:914 +0x21f\n" +
+	"main.crash(...)\n" +
+	"\t/home/user/src/main.go:12\n" +
+	"main.main()\n" +
+	"\t/home/user/src/main.go:20 +0x20\n" +
+	"\n" +
+	"goroutine 6 [chan receive]:\n" +
+	"main.worker()\n" +
+	"\t/home/user/src/main.go:30 +0x40\n" +
+	"created by main.main in goroutine 1\n" +
+	"\t/home/user/src/main.go:18 +0x40\n" +
+	"\n"
+
+func TestWriteSARIF(t *testing.T) {
+	t.Parallel()
+	s := parse(t, panicTrace)
+	b := bytes.Buffer{}
+	if err := WriteSARIF(&b, s, &Opts{SrcRoot: "/home/user/src/"}); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	loc := func(uri string, line int, fn string) map[string]interface{} {
+		return map[string]interface{}{
+			"physicalLocation": map[string]interface{}{
+				"artifactLocation": map[string]interface{}{"uri": uri},
+				"region":           map[string]interface{}{"startLine": float64(line)},
+			},
+			"logicalLocations": []interface{}{map[string]interface{}{"fullyQualifiedName": fn, "kind": "function"}},
+		}
+	}
+	want := map[string]interface{}{
+		"$schema": sarifSchema,
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":           "panicparse",
+				"informationUri": "https://github.com/maruel/panicparse",
+				"rules": []interface{}{
+					map[string]interface{}{"id": "panic", "shortDescription": map[string]interface{}{"text": "Go panic"}},
+					map[string]interface{}{"id": "data-race", "shortDescription": map[string]interface{}{"text": "Go data race"}},
+				},
+			}},
+			"results": []interface{}{map[string]interface{}{
+				"ruleId":    "panic",
+				"level":     "error",
+				"message":   map[string]interface{}{"text": "oh no"},
+				"locations": []interface{}{loc("main.go", 12, "main.crash")},
+				"stacks": []interface{}{map[string]interface{}{
+					"message": map[string]interface{}{"text": "goroutine 1 [running]"},
+					"frames": []interface{}{
+						map[string]interface{}{"location": loc("file:///goroot/src/runtime/panic.go", 914, "panic")},
+						map[string]interface{}{"location": loc("main.go", 12, "main.crash")},
+						map[string]interface{}{"location": loc("main.go", 20, "main.main")},
+					},
+				}},
+				"partialFingerprints": map[string]interface{}{"panicparse/v1": s.Goroutines[0].Hash()},
+			}},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("WriteSARIF() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteSARIF_Race(t *testing.T) {
+	t.Parallel()
+	s := parse(t, string(internaltest.StaticPanicRaceOutput()))
+	if !s.IsRace() {
+		t.Fatal("expected a data race")
+	}
+	var got sarifLog
+	b := bytes.Buffer{}
+	if err := WriteSARIF(&b, s, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	r := got.Runs[0].Results[0]
+	if r.RuleID != "data-race" || r.Message.Text != "data race" || len(r.Stacks) != len(s.Goroutines) {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if uri := r.Locations[0].PhysicalLocation.ArtifactLocation.URI; !strings.HasPrefix(uri, "file:///") {
+		t.Fatal(uri)
+	}
+}
+
+func TestWriteSARIF_Empty(t *testing.T) {
+	t.Parallel()
+	b := bytes.Buffer{}
+	if err := WriteSARIF(&b, &stack.Snapshot{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"results": []`) {
+		t.Fatal(b.String())
+	}
+}
+
+func TestWriteOTLP(t *testing.T) {
+	t.Parallel()
+	s := parse(t, panicTrace)
+	s.CapturedAt = time.Unix(1700000000, 5)
+	b := bytes.Buffer{}
+	if err := WriteOTLP(&b, s, &Opts{Resource: map[string]string{"service.name": "api", "host.name": "h1"}}); err != nil {
+		t.Fatal(err)
+	}
+	str := func(k, v string) otlpKeyValue { return otlpString(k, v) }
+	want := &otlpLogs{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{str("host.name", "h1"), str("service.name", "api")}},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope: otlpScopeInfo{Name: otlpScope},
+			LogRecords: []otlpLogRecord{{
+				TimeUnixNano:   "1700000000000000005",
+				SeverityNumber: 21,
+				SeverityText:   "FATAL",
+				Body:           otlpString("", "oh no").Value,
+				Attributes: []otlpKeyValue{
+					str("exception.type", "panic"),
+					str("exception.message", "oh no"),
+					str("exception.stacktrace", "goroutine 1 [running]:\n"+
+						"panic({0x4a5b20, 0x4e0d48})\n\t/goroot/src/runtime/panic.go:914\n"+
+						"main.crash(...)\n\t/home/user/src/main.go:12\n"+
+						"main.main()\n\t/home/user/src/main.go:20\n"),
+					str("panicparse.signature_hash", s.Goroutines[0].Hash()),
+					otlpInt("panicparse.goroutines", 2),
+					str("code.function.name", "main.crash"),
+					str("code.file.path", "/home/user/src/main.go"),
+					otlpInt("code.line.number", 12),
+				},
+			}},
+		}},
+	}}}
+	var got otlpLogs
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, &got); diff != "" {
+		t.Fatalf("WriteOTLP() mismatch (-want +got):\n%s", diff)
+	}
+
+	b.Reset()
+	if err := WriteOTLP(&b, &stack.Snapshot{}, nil); err != nil || b.Len() != 0 {
+		t.Fatal(err, b.String())
+	}
+}
+
+func parse(t *testing.T, in string) *stack.Snapshot {
+	s, _, err := stack.ScanSnapshot(strings.NewReader(in), io.Discard, &stack.Opts{})
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCulprit(t *testing.T) {
+	t.Parallel()
+	st := stack.Stack{
+		Calls: []stack.Call{
+			{Func: stack.Func{Complete: "panic"}, Location: stack.Stdlib},
+			{Func: stack.Func{Complete: "sync.fatal"}, Location: stack.Stdlib},
+			{Func: stack.Func{Complete: "main.main"}, Location: stack.GoMod},
+		},
+	}
+	// The same frame as the one highlighted on the console.
+	if c := culprit(&st); c == nil || c.Func.Complete != "main.main" {
+		t.Fatalf("unexpected %#v", c)
+	}
+	st.Calls = st.Calls[:2]
+	if c := culprit(&st); c == nil || c.Func.Complete != "panic" {
+		t.Fatalf("unexpected %#v", c)
+	}
+	if c := culprit(&stack.Stack{}); c != nil {
+		t.Fatalf("unexpected %#v", c)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// WriteOTLP writes the crash in s as an OpenTelemetry log record in the
+// OTLP/JSON encoding, ready to be POSTed to a collector's /v1/logs endpoint.
+//
+// The record has a FATAL severity and the exception.type,
+// exception.message and exception.stacktrace attributes. The stack trace is
+// in the Go runtime format, with every goroutine reported. The call that
+// panicked is set in the code.* attributes. The timestamp is
+// Snapshot.CapturedAt, if known.
+//
+// Nothing is written when s has no goroutine.
+func WriteOTLP(w io.Writer, s *stack.Snapshot, opts *Opts) error {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	gs := crashed(s)
+	if len(gs) == 0 {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(newOTLPLogs(s, gs, opts))
+}
+
+// Private stuff.
+
+// otlpScope is the instrumentation scope of the log records.
+const otlpScope = "github.com/maruel/panicparse/v2/stack/export"
+
+// otlpSeverityFatal is SEVERITY_NUMBER_FATAL.
+const otlpSeverityFatal = 21
+
+type otlpLogs struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScopeInfo   `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScopeInfo struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano,omitempty"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is a string or an int64. int64 values are encoded as strings
+// in OTLP/JSON.
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func otlpString(k, v string) otlpKeyValue {
+	return otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpInt(k string, v int) otlpKeyValue {
+	s := strconv.Itoa(v)
+	return otlpKeyValue{Key: k, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func newOTLPLogs(s *stack.Snapshot, gs []*stack.Goroutine, opts *Opts) *otlpLogs {
+	res := otlpResource{Attributes: []otlpKeyValue{}}
+	keys := make([]string, 0, len(opts.Resource))
+	for k := range opts.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		res.Attributes = append(res.Attributes, otlpString(k, opts.Resource[k]))
+	}
+
+	msg := message(s, gs[0])
+	typ := "panic"
+	if s.IsRace() {
+		typ = "data race"
+	}
+	traces := make([]string, len(gs))
+	for i, g := range gs {
+		traces[i] = goTrace(g)
+	}
+	r := otlpLogRecord{
+		SeverityNumber: otlpSeverityFatal,
+		SeverityText:   "FATAL",
+		Body:           otlpAnyValue{StringValue: &msg},
+		Attributes: []otlpKeyValue{
+			otlpString("exception.type", typ),
+			otlpString("exception.message", msg),
+			otlpString("exception.stacktrace", strings.Join(traces, "\n")),
+			otlpString("panicparse.signature_hash", gs[0].Hash()),
+			otlpInt("panicparse.goroutines", len(s.Goroutines)),
+		},
+	}
+	if !s.CapturedAt.IsZero() {
+		r.TimeUnixNano = strconv.FormatInt(s.CapturedAt.UnixNano(), 10)
+	}
+	if c := culprit(&gs[0].Stack); c != nil {
+		r.Attributes = append(r.Attributes,
+			otlpString("code.function.name", c.Func.Complete),
+			otlpString("code.file.path", srcPath(c)),
+			otlpInt("code.line.number", c.Line))
+	}
+	return &otlpLogs{ResourceLogs: []otlpResourceLogs{{
+		Resource: res,
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScopeInfo{Name: otlpScope},
+			LogRecords: []otlpLogRecord{r},
+		}},
+	}}}
+}
@@ -0,0 +1,189 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// WriteSARIF writes the crash in s as a SARIF 2.1.0 log.
+//
+// The log has one result: a "panic" or a "data-race". Its location is the
+// call that panicked, or the first call outside the standard library, and it
+// has one stack per goroutine reported. The signature hash of the first
+// goroutine is set as the "panicparse/v1" partial fingerprint, so the same
+// crash is deduplicated across runs.
+//
+// The log has no result when s has no goroutine.
+func WriteSARIF(w io.Writer, s *stack.Snapshot, opts *Opts) error {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(newSARIFLog(s, opts))
+}
+
+// Private stuff.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+var sarifRules = []sarifRule{
+	{ID: "panic", ShortDescription: sarifMessage{Text: "Go panic"}},
+	{ID: "data-race", ShortDescription: sarifMessage{Text: "Go data race"}},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	Stacks              []sarifStack      `json:"stacks,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type sarifStack struct {
+	Message sarifMessage `json:"message"`
+	Frames  []sarifFrame `json:"frames"`
+}
+
+type sarifFrame struct {
+	Location sarifLocation `json:"location"`
+	Module   string        `json:"module,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func newSARIFLog(s *stack.Snapshot, opts *Opts) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "panicparse",
+			InformationURI: "https://github.com/maruel/panicparse",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+	if gs := crashed(s); len(gs) != 0 {
+		r := sarifResult{
+			RuleID:              "panic",
+			Level:               "error",
+			Message:             sarifMessage{Text: message(s, gs[0])},
+			PartialFingerprints: map[string]string{"panicparse/v1": gs[0].Hash()},
+		}
+		if s.IsRace() {
+			r.RuleID = "data-race"
+		}
+		if c := culprit(&gs[0].Stack); c != nil {
+			r.Locations = []sarifLocation{newSARIFLocation(c, opts)}
+		}
+		for _, g := range gs {
+			st := sarifStack{Message: sarifMessage{Text: "goroutine " + strconv.Itoa(g.ID) + " [" + g.State + "]"}, Frames: []sarifFrame{}}
+			for i := range g.Stack.Calls {
+				c := &g.Stack.Calls[i]
+				f := sarifFrame{Location: newSARIFLocation(c, opts)}
+				if c.Module != nil {
+					f.Module = c.Module.Path
+					if c.Module.Version != "" {
+						f.Module += "@" + c.Module.Version
+					}
+				}
+				st.Frames = append(st.Frames, f)
+			}
+			r.Stacks = append(r.Stacks, st)
+		}
+		run.Results = append(run.Results, r)
+	}
+	return &sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}}
+}
+
+func newSARIFLocation(c *stack.Call, opts *Opts) sarifLocation {
+	l := sarifLocation{}
+	// The path is unknown for a call without symbol.
+	if p := srcPath(c); p != "" {
+		l.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(p, opts.SrcRoot)}}
+		if c.Line > 0 {
+			l.PhysicalLocation.Region = &sarifRegion{StartLine: c.Line}
+		}
+	}
+	if c.Func.Complete != "" {
+		l.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: c.Func.Complete, Kind: "function"}}
+	}
+	return l
+}
+
+// sarifURI returns p relative to root when it is inside, otherwise as an
+// absolute file:// URI.
+func sarifURI(p, root string) string {
+	if root != "" {
+		root = strings.TrimSuffix(root, "/") + "/"
+		if strings.HasPrefix(p, root) {
+			return p[len(root):]
+		}
+	}
+	if !strings.HasPrefix(p, "/") {
+		// A Windows path, like C:/foo.
+		p = "/" + p
+	}
+	return "file://" + p
+}
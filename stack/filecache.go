@@ -0,0 +1,98 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"container/list"
+	"os"
+	"sync"
+)
+
+// Private stuff.
+
+// maxCachedSource is the maximum total size of the sources of the files kept
+// in fileCache.
+const maxCachedSource = 32 << 20
+
+// fileCache is the process wide cache of parsed source files.
+//
+// It is shared across snapshots so repeated analyses, e.g. in webstack or
+// when processing a stream of stack traces, don't parse the same files again.
+var fileCache = newLRUFiles(maxCachedSource)
+
+// fileKey identifies a version of a source file.
+type fileKey struct {
+	path  string
+	mtime int64
+	size  int64
+}
+
+// statFile returns the key for the current version of the file.
+//
+// Files that cannot be stat'ed, like the ones read from a module zip, are
+// keyed by their path only. These are immutable.
+func statFile(path string) fileKey {
+	k := fileKey{path: path, size: -1}
+	if fi, err := os.Stat(path); err == nil {
+		k.mtime = fi.ModTime().UnixNano()
+		k.size = fi.Size()
+	}
+	return k
+}
+
+// lruFiles is a least recently used cache of parsed files, bounded by the
+// total size of their sources.
+type lruFiles struct {
+	mu    sync.Mutex
+	max   int
+	size  int
+	order *list.List
+	items map[fileKey]*list.Element
+}
+
+type lruEntry struct {
+	key  fileKey
+	p    *parsedFile
+	size int
+}
+
+func newLRUFiles(max int) *lruFiles {
+	return &lruFiles{max: max, order: list.New(), items: map[fileKey]*list.Element{}}
+}
+
+// get returns the parsed file if present.
+func (l *lruFiles) get(k fileKey) *parsedFile {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := l.items[k]
+	if e == nil {
+		return nil
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruEntry).p
+}
+
+// add adds a parsed file of size bytes, evicting the least recently used ones
+// as needed. A file larger than the cache is not added.
+func (l *lruFiles) add(k fileKey, p *parsedFile, size int) {
+	if size > l.max {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e := l.items[k]; e != nil {
+		l.order.MoveToFront(e)
+		return
+	}
+	l.items[k] = l.order.PushFront(&lruEntry{key: k, p: p, size: size})
+	l.size += size
+	for l.size > l.max {
+		e := l.order.Back()
+		v := e.Value.(*lruEntry)
+		l.order.Remove(e)
+		delete(l.items, v.key)
+		l.size -= v.size
+	}
+}
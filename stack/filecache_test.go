@@ -0,0 +1,74 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLRUFiles(t *testing.T) {
+	t.Parallel()
+	l := newLRUFiles(10)
+	a, b, c := &parsedFile{}, &parsedFile{}, &parsedFile{}
+	l.add(fileKey{path: "a"}, a, 4)
+	l.add(fileKey{path: "b"}, b, 4)
+	if l.get(fileKey{path: "a"}) != a {
+		t.Fatal("expected a")
+	}
+	// b is the least recently used.
+	l.add(fileKey{path: "c"}, c, 4)
+	if l.get(fileKey{path: "b"}) != nil {
+		t.Fatal("expected b to be evicted")
+	}
+	if l.get(fileKey{path: "a"}) != a || l.get(fileKey{path: "c"}) != c {
+		t.Fatal("expected a and c")
+	}
+	// Too large.
+	l.add(fileKey{path: "d"}, &parsedFile{}, 11)
+	if l.get(fileKey{path: "d"}) != nil || l.size != 8 {
+		t.Fatalf("unexpected size %d", l.size)
+	}
+}
+
+func TestCacheASTShared(t *testing.T) {
+	t.Parallel()
+	root, err := os.MkdirTemp("", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err2 := os.RemoveAll(root); err2 != nil {
+			t.Error(err2)
+		}
+	}()
+	p := filepath.Join(root, "main.go")
+	if err = os.WriteFile(p, []byte("package main\n\nfunc f(i int) {\n\tpanic(i)\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	load := func() *parsedFile {
+		c := cacheAST{parsed: map[string]*parsedFile{}}
+		if err := c.loadFile(p); err != nil {
+			t.Fatal(err)
+		}
+		return c.parsed[p]
+	}
+	first := load()
+	if load() != first {
+		t.Fatal("expected the parsed file to be shared")
+	}
+	d, err := first.getFuncAST("f", 4)
+	if err != nil || d == nil || d != first.funcs[funcLine{"f", 4}] {
+		t.Fatalf("unexpected %v, %v", d, err)
+	}
+	// A modified file is parsed again.
+	if err = os.WriteFile(p, []byte("package main\n\nfunc f(i, j int) {\n\tpanic(i)\n}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if load() == first {
+		t.Fatal("expected the file to be parsed again")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// GoVersionOf returns the version of the Go toolchain installed in goroot,
+// e.g. "go1.22.3", as found in its VERSION file.
+//
+// It returns runtime.Version() for the GOROOT of the current process when
+// the file is missing, as in a development toolchain. It returns an empty
+// string when the version cannot be determined.
+func GoVersionOf(goroot string) string {
+	if goroot == "" {
+		return ""
+	}
+	if b, err := os.ReadFile(goroot + "/VERSION"); err == nil {
+		// The first line is the version, the next ones are metadata.
+		if i := bytes.IndexByte(b, '\n'); i != -1 {
+			b = b[:i]
+		}
+		return string(bytes.TrimSpace(b))
+	}
+	if p := strings.Replace(runtime.GOROOT(), pathSeparator, "/", -1); p == goroot {
+		return runtime.Version()
+	}
+	return ""
+}
+
+// Private stuff.
+
+// matchGOROOT replaces s.LocalGOROOT with the sources of the Go version that
+// produced the stack trace, when it is known to differ.
+//
+// The version is opts.GoVersion if set. Otherwise it is DialectVersion, only
+// when it is newer than the local version. The issues are reported in
+// s.Warnings, so the calls in the standard library are not silently analyzed
+// with mismatched sources.
+func (s *Snapshot) matchGOROOT(opts *Opts) {
+	local := GoVersionOf(s.LocalGOROOT)
+	if local == "" {
+		return
+	}
+	want := opts.GoVersion
+	if want == "" {
+		if goMinor(s.DialectVersion) <= goMinor(local) {
+			return
+		}
+		want = s.DialectVersion
+	} else if sameGoVersion(want, local) {
+		return
+	}
+	if opts.FindGOROOT == nil {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("the stack trace is from %s but the local GOROOT is %s; the standard library sources may not match", want, local))
+		return
+	}
+	goroot, err := opts.FindGOROOT(want)
+	if err != nil {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("the stack trace is from %s but the local GOROOT is %s and the matching sources were not found: %v", want, local, err))
+		return
+	}
+	s.LocalGOROOT = goroot
+}
+
+// goMinor returns the minor version of a Go version like "go1.21.5", or 0 if
+// it can't be parsed, e.g. for "go1" or a development version.
+func goMinor(v string) int {
+	if !strings.HasPrefix(v, "go1.") {
+		return 0
+	}
+	v = v[len("go1."):]
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	m, _ := strconv.Atoi(v[:end])
+	return m
+}
+
+// sameGoVersion returns true if local is want. want can be a language version
+// like "go1.21", then any release of it matches.
+func sameGoVersion(want, local string) bool {
+	if want == local {
+		return true
+	}
+	if strings.Count(want, ".") == 1 && goMinor(want) != 0 {
+		return goMinor(want) == goMinor(local) && strings.HasPrefix(local, want)
+	}
+	return false
+}
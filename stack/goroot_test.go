@@ -0,0 +1,132 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGoVersionOf(t *testing.T) {
+	t.Parallel()
+	root := newGOROOT(t, "go1.22.3\ntime 2024-05-01T19:59:41Z\n")
+	if v := GoVersionOf(root); v != "go1.22.3" {
+		t.Fatal(v)
+	}
+	if v := GoVersionOf(filepath.ToSlash(t.TempDir())); v != "" {
+		t.Fatal(v)
+	}
+	if v := GoVersionOf(""); v != "" {
+		t.Fatal(v)
+	}
+}
+
+func TestSnapshot_matchGOROOT(t *testing.T) {
+	t.Parallel()
+	local := newGOROOT(t, "go1.20.1")
+	other := newGOROOT(t, "go1.21.5")
+	data := []struct {
+		name     string
+		dialect  string
+		version  string
+		find     bool
+		err      error
+		want     string
+		called   string
+		warnings []string
+	}{
+		{name: "same", dialect: "go1.17", want: local},
+		{name: "sameLanguage", dialect: "go1.21", version: "go1.20", find: true, want: local},
+		{name: "sameRelease", version: "go1.20.1", find: true, want: local},
+		{name: "release", dialect: "go1.17", version: "go1.20.2", find: true, want: other, called: "go1.20.2"},
+		{name: "dialect", dialect: "go1.21", find: true, want: other, called: "go1.21"},
+		{
+			name: "noFinder", dialect: "go1.21", want: local,
+			warnings: []string{"the stack trace is from go1.21 but the local GOROOT is go1.20.1; the standard library sources may not match"},
+		},
+		{
+			name: "notFound", version: "go1.22.0", find: true, err: errors.New("nope"), want: local, called: "go1.22.0",
+			warnings: []string{"the stack trace is from go1.22.0 but the local GOROOT is go1.20.1 and the matching sources were not found: nope"},
+		},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.name, func(t *testing.T) {
+			t.Parallel()
+			s := &Snapshot{LocalGOROOT: local, DialectVersion: line.dialect}
+			opts := &Opts{GoVersion: line.version}
+			called := ""
+			if line.find {
+				opts.FindGOROOT = func(v string) (string, error) {
+					called = v
+					return other, line.err
+				}
+			}
+			s.matchGOROOT(opts)
+			if s.LocalGOROOT != line.want {
+				t.Errorf("LocalGOROOT = %q, want %q", s.LocalGOROOT, line.want)
+			}
+			if called != line.called {
+				t.Errorf("FindGOROOT(%q), want %q", called, line.called)
+			}
+			if diff := cmp.Diff(line.warnings, s.Warnings); diff != "" {
+				t.Errorf("Warnings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScanSnapshot_GoVersion(t *testing.T) {
+	t.Parallel()
+	// The local GOROOT doesn't have the file, the one of the version that
+	// produced the stack trace does.
+	local := newGOROOT(t, "go1.20.1")
+	other := newGOROOT(t, "go1.22.3")
+	src := other + "/src/sync/mutex.go"
+	if err := os.MkdirAll(filepath.Dir(src), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("package sync\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	in := "goroutine 1 [running]:\n" +
+		"sync.(*Mutex).Lock(...)\n" +
+		"\t/remote/goroot/src/sync/mutex.go:1\n" +
+		"\n"
+	opts := &Opts{
+		LocalGOROOT: local,
+		GuessPaths:  true,
+		GoVersion:   "go1.22.3",
+		FindGOROOT:  func(string) (string, error) { return other, nil },
+	}
+	s, _, err := ScanSnapshot(strings.NewReader(in), io.Discard, opts)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if s.LocalGOROOT != other || s.RemoteGOROOT != "/remote/goroot" {
+		t.Fatalf("LocalGOROOT = %q, RemoteGOROOT = %q", s.LocalGOROOT, s.RemoteGOROOT)
+	}
+	if c := &s.Goroutines[0].Stack.Calls[0]; c.LocalSrcPath != src || c.Location != Stdlib {
+		t.Fatalf("LocalSrcPath = %q, Location = %s", c.LocalSrcPath, c.Location)
+	}
+	if len(s.Warnings) != 0 {
+		t.Fatal(s.Warnings)
+	}
+}
+
+// newGOROOT returns a directory with a VERSION file.
+func newGOROOT(t *testing.T, version string) string {
+	d := t.TempDir()
+	if err := os.WriteFile(filepath.Join(d, "VERSION"), []byte(version), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.ToSlash(d)
+}
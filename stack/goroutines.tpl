@@ -49,11 +49,48 @@
     {{- end -}}
     <br>Func: {{.Func.Complete}}
     <br>Location: {{.Location}}
+    {{- with .Module}}
+    <br>Module: {{.Path}}{{if .Version}}@{{.Version}}{{end}} ({{.Dependency}})
+    {{- end}}
     </span><a href="{{srcURL .}}">{{.SrcName}}:{{.Line}}</a> <span class="{{funcClass .}}">
     <a href="{{pkgURL .}}">{{.Func.DirName}}.{{.Func.Name}}</a></span>()
   </span>
 {{- end -}}
 
+{{- /* The controls to filter, sort and collapse the buckets */ -}}
+{{- define "Toolbar" -}}
+  <form class="toolbar" onsubmit="return false">
+    <label title="Regexp to filter out headers or packages that match, like -f">Hide: <input type="search" id="filter" placeholder="IO wait|syscall"></label>
+    <label title="Regexp to filter by only headers or packages that match, like -m">Show only: <input type="search" id="match" placeholder="semacquire"></label>
+    <label>Sort by: <select id="sort">
+      <option value="index">default</option>
+      <option value="count">count</option>
+      <option value="sleep">sleep time</option>
+    </select></label>
+    <button type="button" id="collapse">Collapse all</button>
+    <button type="button" id="expand">Expand all</button>
+    <span id="shown"></span>
+  </form>
+{{- end -}}
+
+{{- /* Accepts a []*CreationNode */ -}}
+{{- define "RenderTree" -}}
+  <ul class="tree">
+    {{- range $i, $e := . -}}
+    {{$l := len $e.IDs}}
+    <li><details open><summary>{{$l}} routine{{if ne 1 $l}}s{{end}}
+      {{- if ne $l $e.Total}} ({{$e.Total}} in subtree){{end}}: <span class="state">{{$e.State}}</span>
+      {{- if $e.Signature.Stack.Calls -}}
+        {{- with index $e.Signature.Stack.Calls 0}} <span class="{{funcClass .}}">{{.Func.DirName}}.{{.Func.Name}}</span>(){{end -}}
+      {{- end -}}
+      </summary>
+      {{template "RenderCalls" $e.Signature.Stack}}
+      {{- if $e.Children}}{{template "RenderTree" $e.Children}}{{end -}}
+    </details></li>
+    {{- end -}}
+  </ul>
+{{- end -}}
+
 {{- /* Accepts a Stack */ -}}
 {{- define "RenderCalls" -}}
   <table class="stack">
@@ -62,6 +99,7 @@
         <td>{{$i}}</td>
         <td>
           <a href="{{pkgURL $e}}">{{$e.Func.DirName}}</a>
+          {{- with $e.Module}}{{if not .Main}} <span class="module" title="{{.Path}}">{{.Version}}{{if not .Direct}}, indirect{{end}}</span>{{end}}{{end}}
         </td>
         <td class="hastooltip">
           <span class="tooltip">
@@ -73,11 +111,15 @@
             {{- end -}}
             <br>Func: {{$e.Func.Complete}}
             <br>Location: {{$e.Location}}
+            {{- with $e.Module}}
+            <br>Module: {{.Path}}{{if .Version}}@{{.Version}}{{end}} ({{.Dependency}})
+            {{- end}}
           </span>
           <a href="{{srcURL $e}}">{{$e.SrcName}}:{{$e.Line}}</a>
         </td>
         <td>
           <span class="{{funcClass $e}}"><a href="{{pkgURL $e}}">{{$e.Func.Name}}</a></span>({{template "RenderArgs" $e.Args}})
+          {{- with annotate $e}} {{.}}{{end}}
         </td>
       </tr>
     {{- end -}}
@@ -166,6 +208,47 @@
   .created {
     white-space: nowrap;
   }
+  details.created, details.calls {
+    margin-left: 0.6em;
+  }
+  details.created > summary, details.calls > summary {
+    color: #666;
+    cursor: pointer;
+    font-size: smaller;
+  }
+  .toolbar {
+    background-color: #F8F8F8;
+    border-bottom: 1px solid #DDD;
+    padding: 0.4em;
+    position: sticky;
+    top: 0;
+    z-index: 20;
+  }
+  .toolbar label, .toolbar button {
+    margin-right: 1em;
+  }
+  .toolbar input.invalid {
+    background-color: #FDD;
+  }
+  .bucket:target, .bucket:has(a:target) {
+    background-color: #FFFDE0;
+  }
+  .annotation {
+    color: #666;
+    margin-left: 1em;
+  }
+  .module {
+    color: #666;
+    font-size: smaller;
+  }
+  .tree {
+    list-style: none;
+    padding-left: 1.5em;
+  }
+  .tree summary {
+    cursor: pointer;
+    font-weight: 700;
+  }
   .race {
     font-weight: 700;
     color: #600;
@@ -190,9 +273,30 @@
     padding: 1rem;
     z-index: 10;
   }
+  .suspicion {
+    font-weight: 700;
+  }
+  .suspicion.low {
+    color: #960;
+  }
+  .suspicion.medium {
+    color: #C60;
+  }
+  .suspicion.high {
+    color: #C00;
+  }
+  .captured, .tracked {
+    color: #888;
+  }
   .bottom-padding {
     margin-top: 5em;
   }
+  .copy {
+    cursor: pointer;
+    font-size: 0.8em;
+    margin-left: 1em;
+    padding: 0 0.4em;
+  }
 
   {{- /* Highlights based on stack.Location value. */ -}}
   .FuncMain {
@@ -218,10 +322,22 @@
   }
 </style>
 <div id="content">
-  {{- if .Aggregated -}}
+  {{- if not .Snapshot.CapturedAt.IsZero -}}
+    <p class="captured">Captured <span class="ago" data-ts="{{.Snapshot.CapturedAt.Unix}}" title="{{.Snapshot.CapturedAt.String}}">{{ago .Snapshot.CapturedAt}}</span></p>
+  {{- end -}}
+  {{- if .Tree -}}
+    {{template "RenderTree" .Tree}}
+  {{- else if .Aggregated -}}
+    {{- if .Aggregated.Previous -}}
+      {{- $g := len .Aggregated.Gone}}
+      <p class="tracked">{{$g}} routine{{if ne 1 $g}}s{{end}} gone since the previous snapshot.</p>
+    {{- end -}}
+    {{template "Toolbar"}}
     {{- range $i, $e := .Aggregated.Buckets -}}
       {{$l := len $e.IDs}}
-      <h1>Signature #{{$i}}: {{$l}} routine{{if ne 1 $l}}s{{end}}: <span class="state">{{$e.State}}</span>
+      <div class="bucket" id="b{{$i}}" data-index="{{$i}}" data-count="{{$l}}" data-sleep="{{$e.SleepMax}}" data-pkgs="{{packages $e.Signature.Stack}}">
+      {{- range $e.IDs}}<a id="g{{.}}"></a>{{end}}
+      <h1><a href="#b{{$i}}">Signature #{{$i}}</a>: {{$l}} routine{{if ne 1 $l}}s{{end}}: <span class="state">{{$e.State}}</span>
       {{- if $e.SleepMax -}}
         {{- if ne $e.SleepMin $e.SleepMax}} <span class="sleep">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>
         {{- else}} <span class="sleep">[{{$e.SleepMax}} mins]</span>
@@ -230,13 +346,29 @@
       </h1>
       {{if $e.Locked}} <span class="locked">[locked]</span>
       {{- end -}}
-      {{- if $e.CreatedBy.Calls}} <span class="created">Created by: {{template "RenderCreatedBy" index $e.CreatedBy.Calls 0}}</span>
+      {{- range $e.Extra}} <span class="extra">[{{.}}]</span>
       {{- end -}}
-      {{template "RenderCalls" $e.Signature.Stack}}
+      {{- if $e.OnSystemStack}} <span class="extra">[system stack: runtime or signal handler, not user code]</span>
+      {{- end -}}
+      {{- if $.Aggregated.Previous -}}
+        {{- $n := len $e.Existing}} <span class="tracked">[{{$n}} existed, {{minus $l $n}} new]</span>
+      {{- end -}}
+      {{- range $e.Suspicions}} <span class="suspicion {{.Severity}}">[{{.Severity}}: {{.Explanation}}]</span>
+      {{- end -}}
+      <button class="copy" data-markdown="{{markdown $e}}" title="Copy as Markdown, e.g. for a GitHub issue">Copy as Markdown</button>
+      {{- if $e.CreatedBy.Calls}}
+      <details class="created" open><summary>Created by</summary>{{template "RenderCreatedBy" index $e.CreatedBy.Calls 0}}</details>
+      {{- else if $e.CreatedBy.Unavailable}}
+      <details class="created" open><summary>Created by</summary>unknown, failed to restore the stack</details>
+      {{- end}}
+      <details class="calls" open><summary>Stack</summary>{{template "RenderCalls" $e.Signature.Stack}}</details>
+      </div>
     {{- end -}}
   {{- else -}}
+    {{template "Toolbar"}}
     {{- range $i, $e := .Snapshot.Goroutines -}}
-      <h1>Routine {{$e.ID}}: <span class="state">{{$e.State}}</span>
+      <div class="bucket" id="g{{$e.ID}}" data-index="{{$i}}" data-count="1" data-sleep="{{$e.SleepMax}}" data-pkgs="{{packages $e.Signature.Stack}}">
+      <h1><a href="#g{{$e.ID}}">Routine {{$e.ID}}</a>: <span class="state">{{$e.State}}</span>
       {{- if $e.SleepMax -}}
         {{- if ne $e.SleepMin $e.SleepMax}} <span class="sleep">[{{$e.SleepMin}}~{{$e.SleepMax}} mins]</span>
         {{- else}} <span class="sleep">[{{$e.SleepMax}} mins]</span>
@@ -245,11 +377,19 @@
       </h1>
       {{if $e.Locked}} <span class="locked">[locked]</span>
       {{- end -}}
-      {{if $e.RaceAddr}} <span class="race">Race {{if $e.RaceWrite}}write{{else}}read{{end}} @ {{printf "0x%08X" $e.RaceAddr}}</span><br>
+      {{- range $e.Extra}} <span class="extra">[{{.}}]</span>
       {{- end -}}
-      {{- if $e.CreatedBy.Calls}} <span class="created">Created by: {{template "RenderCreatedBy" index $e.CreatedBy.Calls 0}}</span>
+      {{- if $e.OnSystemStack}} <span class="extra">[system stack: runtime or signal handler, not user code]</span>
       {{- end -}}
-      {{template "RenderCalls" $e.Signature.Stack}}
+      {{if $e.RaceAddr}} <span class="race">Race {{if $e.RaceWrite}}write{{else}}read{{end}} @ {{printf "0x%08X" $e.RaceAddr}}</span><br>
+      {{- end -}}
+      {{- if $e.CreatedBy.Calls}}
+      <details class="created" open><summary>Created by</summary>{{template "RenderCreatedBy" index $e.CreatedBy.Calls 0}}</details>
+      {{- else if $e.CreatedBy.Unavailable}}
+      <details class="created" open><summary>Created by</summary>unknown, failed to restore the stack</details>
+      {{- end}}
+      <details class="calls" open><summary>Stack</summary>{{template "RenderCalls" $e.Signature.Stack}}</details>
+      </div>
     {{- end -}}
   {{- end -}}
 </div>
@@ -274,6 +414,9 @@
     </li>
   {{- end -}}
   <li>GOMAXPROCS: {{.GOMAXPROCS}}</li>
+  {{- if .Snapshot.DialectVersion -}}
+    <li>Parsed as: {{.Snapshot.DialectVersion}} dialect</li>
+  {{- end -}}
 </ul>
 <h2>Legend</h2>
 <table class="legend">
@@ -337,5 +480,105 @@
   </tr>
 </table>
 {{- .Footer -}}
+<script>
+  {{- /* Keeps the time since the capture up to date, in the same format as ago. */ -}}
+  document.querySelectorAll("span.ago").forEach(function(e) {
+    var ts = parseInt(e.dataset.ts, 10);
+    var update = function() {
+      var d = Math.max(0, Math.floor(Date.now() / 1000) - ts);
+      var s = "";
+      if (d >= 3600) {
+        s = Math.floor(d / 3600) + "h" + Math.floor((d % 3600) / 60) + "m";
+      } else if (d >= 60) {
+        s = Math.floor(d / 60) + "m";
+      }
+      e.textContent = s + (d % 60) + "s ago";
+    };
+    update();
+    setInterval(update, 1000);
+  });
+  {{- /* Filters, sorts and collapses the buckets, like -f and -m. */ -}}
+  (function() {
+    var content = document.getElementById("content");
+    var buckets = Array.prototype.slice.call(content.querySelectorAll("div.bucket"));
+    if (!buckets.length) {
+      return;
+    }
+    var filter = document.getElementById("filter");
+    var match = document.getElementById("match");
+    var sort = document.getElementById("sort");
+    var shown = document.getElementById("shown");
+    var compile = function(input) {
+      input.classList.remove("invalid");
+      if (!input.value) {
+        return null;
+      }
+      try {
+        return new RegExp(input.value);
+      } catch (err) {
+        input.classList.add("invalid");
+        return null;
+      }
+    };
+    var apply = function() {
+      var f = compile(filter);
+      var m = compile(match);
+      var n = 0;
+      buckets.forEach(function(b) {
+        var text = b.querySelector("h1").textContent + "\n" + b.dataset.pkgs;
+        var hide = (f && f.test(text)) || (m && !m.test(text));
+        b.hidden = hide;
+        if (!hide) {
+          n++;
+        }
+      });
+      shown.textContent = n + "/" + buckets.length + " shown";
+      var key = sort.value;
+      buckets.slice().sort(function(a, b) {
+        if (key !== "index") {
+          var d = parseInt(b.dataset[key], 10) - parseInt(a.dataset[key], 10);
+          if (d) {
+            return d;
+          }
+        }
+        return parseInt(a.dataset.index, 10) - parseInt(b.dataset.index, 10);
+      }).forEach(function(b) {
+        content.appendChild(b);
+      });
+    };
+    var setOpen = function(open) {
+      content.querySelectorAll("div.bucket details").forEach(function(d) {
+        d.open = open;
+      });
+    };
+    filter.addEventListener("input", apply);
+    match.addEventListener("input", apply);
+    sort.addEventListener("change", apply);
+    document.getElementById("collapse").addEventListener("click", function() { setOpen(false); });
+    document.getElementById("expand").addEventListener("click", function() { setOpen(true); });
+    {{- /* Reveals the goroutine linked to, even when filtered out. */ -}}
+    var reveal = function() {
+      var t = location.hash && document.getElementById(location.hash.slice(1));
+      var b = t && t.closest("div.bucket");
+      if (b) {
+        b.hidden = false;
+        b.querySelectorAll("details").forEach(function(d) { d.open = true; });
+        t.scrollIntoView();
+      }
+    };
+    window.addEventListener("hashchange", reveal);
+    apply();
+    reveal();
+  })();
+  {{- /* Copies the bucket as Markdown in the clipboard. */ -}}
+  document.querySelectorAll("button.copy").forEach(function(b) {
+    b.addEventListener("click", function() {
+      navigator.clipboard.writeText(b.dataset.markdown).then(function() {
+        b.textContent = "Copied";
+        setTimeout(function() { b.textContent = "Copy as Markdown"; }, 1500);
+      });
+    });
+  });
+</script>
 {{- /* Add unnecessary bottom spacing so the last tooltip from the legend is visible. */ -}}
 <div class="bottom-padding"></div>
@@ -0,0 +1,20 @@
+// To build against the local checkout of github.com/maruel/panicparse/v2,
+// create an untracked go.work at the root of the repository with:
+//
+//	go work init . ./stack/grpcrecovery
+module github.com/maruel/panicparse/v2/stack/grpcrecovery
+
+go 1.25.0
+
+require (
+	github.com/maruel/panicparse/v2 v2.0.0-20261017001526-c902e910bdef
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260904194346-d0f1323225a4
+	google.golang.org/grpc v1.84.0
+)
+
+require (
+	golang.org/x/net v0.57.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	google.golang.org/protobuf v1.36.12 // indirect
+)
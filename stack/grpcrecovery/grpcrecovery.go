@@ -0,0 +1,167 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package grpcrecovery provides gRPC server interceptors that recover panics
+// and report them as parsed stack traces.
+//
+// It is the gRPC equivalent of the HTTP middleware shown in the stack package
+// example. It lives in its own go module so that the stack package doesn't
+// depend on gRPC.
+package grpcrecovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"runtime/debug"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures the interceptors.
+//
+// The zero value is valid: panics are logged with log.Printf and the client
+// receives a codes.Internal status without the stack trace.
+type Options struct {
+	// Logf receives the panic value and its rendered stack trace. Defaults to
+	// log.Printf.
+	Logf func(format string, v ...interface{})
+	// Count, if set, is called with the signature hash of the panicking
+	// goroutine, as returned by stack.Signature.Hash. It is meant to increment
+	// a metrics counter, so the same crash is counted together across
+	// processes.
+	Count func(hash string)
+	// Details adds the panic value and the rendered stack trace to the
+	// returned status as an errdetails.DebugInfo.
+	//
+	// This discloses the source code layout to the client, so only enable it
+	// for trusted clients.
+	Details bool
+	// Opts is used to parse the stack trace. Defaults to stack.DefaultOpts.
+	Opts *stack.Opts
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that recovers
+// the panics of the handler and returns them as a codes.Internal error.
+//
+// opts can be nil.
+func UnaryServerInterceptor(opts *Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				resp, err = nil, Report(opts, info.FullMethod, v, debug.Stack())
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor that
+// recovers the panics of the handler and returns them as a codes.Internal
+// error.
+//
+// opts can be nil.
+func StreamServerInterceptor(opts *Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = Report(opts, info.FullMethod, v, debug.Stack())
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// Report processes a recovered panic value v and raw, the stack trace of the
+// panicking goroutine as returned by debug.Stack, and returns the status error
+// to return to the client.
+//
+// It is used by the interceptors and can be called directly by custom
+// recovery handlers. method is the full gRPC method name, used in the log.
+//
+// o can be nil.
+func Report(o *Options, method string, v interface{}, raw []byte) error {
+	if o == nil {
+		o = &Options{}
+	}
+	logf := o.Logf
+	if logf == nil {
+		logf = log.Printf
+	}
+	msg := fmt.Sprint(v)
+	g := o.parse(raw)
+	if g == nil {
+		// Processing failed. Log the raw stack.
+		logf("%s: panic: %s\n%s", method, msg, raw)
+		return status.Error(codes.Internal, "panic: "+msg)
+	}
+	if o.Count != nil {
+		o.Count(g.Signature.Hash())
+	}
+	lines := formatCalls(g)
+	logf("%s: panic: %s\n%s", method, msg, strings.Join(lines, "\n"))
+	st := status.New(codes.Internal, "panic: "+msg)
+	if o.Details {
+		if d, err := st.WithDetails(&errdetails.DebugInfo{StackEntries: lines, Detail: msg}); err == nil {
+			st = d
+		}
+	}
+	return st.Err()
+}
+
+// Private stuff.
+
+// parse returns the panicking goroutine with only the calls up to the one
+// that panicked, or nil if raw couldn't be parsed.
+func (o *Options) parse(raw []byte) *stack.Goroutine {
+	opts := o.Opts
+	if opts == nil {
+		opts = stack.DefaultOpts()
+	}
+	s, _, err := stack.ScanSnapshot(bytes.NewReader(append(raw, '\n', '\n')), io.Discard, opts)
+	if s == nil || len(s.Goroutines) != 1 || (err != nil && err != io.EOF) {
+		return nil
+	}
+	g := s.Goroutines[0]
+	// Remove the calls to debug.Stack, the deferred function and the panic
+	// machinery, so the signature hash only depends on the code that panicked.
+	if i := g.Stack.PanicIndex(); i != -1 {
+		g.Stack.Calls = g.Stack.Calls[i+1:]
+	}
+	return g
+}
+
+// formatCalls returns one line per call, aligned like pp does.
+func formatCalls(g *stack.Goroutine) []string {
+	srcLen, pkgLen := 0, 0
+	for i := range g.Stack.Calls {
+		c := &g.Stack.Calls[i]
+		if l := len(render.BasePath.FormatCall(c)); l > srcLen {
+			srcLen = l
+		}
+		if l := len(c.Func.DirName); l > pkgLen {
+			pkgLen = l
+		}
+	}
+	out := make([]string, 0, len(g.Stack.Calls)+1)
+	for i := range g.Stack.Calls {
+		c := &g.Stack.Calls[i]
+		out = append(out, fmt.Sprintf("    %-*s %-*s %s(%s)", pkgLen, c.Func.DirName, srcLen, render.BasePath.FormatCall(c), c.Func.Name, &c.Args))
+	}
+	if g.Stack.Elided {
+		out = append(out, "    (...)")
+	}
+	return out
+}
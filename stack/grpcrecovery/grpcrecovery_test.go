@@ -0,0 +1,121 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package grpcrecovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Parallel()
+	var logs []string
+	var hashes []string
+	o := &Options{
+		Logf: func(format string, v ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, v...))
+		},
+		Count: func(hash string) {
+			hashes = append(hashes, hash)
+		},
+		Details: true,
+	}
+	f := UnaryServerInterceptor(o)
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc.Service/Method"}
+	for i := 0; i < 2; i++ {
+		resp, err := f(context.Background(), nil, info, panickingHandler)
+		if resp != nil {
+			t.Fatalf("unexpected response %v", resp)
+		}
+		s, ok := status.FromError(err)
+		if !ok || s.Code() != codes.Internal || s.Message() != "panic: boom" {
+			t.Fatalf("unexpected error %v", err)
+		}
+		d := s.Details()
+		if len(d) != 1 {
+			t.Fatalf("unexpected details %v", d)
+		}
+		info, ok := d[0].(*errdetails.DebugInfo)
+		if !ok || info.Detail != "boom" || len(info.StackEntries) == 0 {
+			t.Fatalf("unexpected details %v", d)
+		}
+		// The first call is the one that panicked.
+		if !strings.Contains(info.StackEntries[0], "panickingHandler") {
+			t.Fatalf("unexpected stack:\n%s", strings.Join(info.StackEntries, "\n"))
+		}
+	}
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "/svc.Service/Method: panic: boom\n") {
+		t.Fatalf("unexpected logs %q", logs)
+	}
+	// The same panic has the same signature.
+	if len(hashes) != 2 || hashes[0] == "" || hashes[0] != hashes[1] {
+		t.Fatalf("unexpected hashes %q", hashes)
+	}
+
+	// Without panic, the handler result is returned.
+	resp, err := f(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	if resp != "ok" || err != nil {
+		t.Fatal(resp, err)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	t.Parallel()
+	var logs []string
+	o := &Options{
+		Logf: func(format string, v ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, v...))
+		},
+	}
+	f := StreamServerInterceptor(o)
+	info := &grpc.StreamServerInfo{FullMethod: "/svc.Service/Stream"}
+	err := f(nil, nil, info, func(srv interface{}, ss grpc.ServerStream) error {
+		var m map[string]int
+		m["a"] = 1
+		return nil
+	})
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.Internal || !strings.HasPrefix(s.Message(), "panic: assignment to entry in nil map") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// The stack trace is not sent to the client by default.
+	if d := s.Details(); len(d) != 0 {
+		t.Fatalf("unexpected details %v", d)
+	}
+	if len(logs) != 1 || !strings.Contains(logs[0], "TestStreamServerInterceptor") {
+		t.Fatalf("unexpected logs %q", logs)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	var logs []string
+	o := &Options{
+		Logf: func(format string, v ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, v...))
+		},
+	}
+	// The raw stack is logged as-is when it can't be parsed.
+	err := Report(o, "/svc.Service/Method", "boom", []byte("junk"))
+	if s, ok := status.FromError(err); !ok || s.Code() != codes.Internal {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []string{"/svc.Service/Method: panic: boom\njunk"}; len(logs) != 1 || logs[0] != want[0] {
+		t.Fatalf("unexpected logs %q", logs)
+	}
+}
+
+func panickingHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	panic("boom")
+}
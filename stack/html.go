@@ -18,35 +18,79 @@ import (
 	"time"
 )
 
+// HTMLOpts are the options to render HTML.
+type HTMLOpts struct {
+	// Footer is custom HTML added at the bottom of the page.
+	Footer template.HTML
+	// Tree organizes the buckets as a tree of which goroutines created which.
+	// See CreationTree for details. It is ignored for a Snapshot.
+	Tree bool
+	// AnnotateCall returns the annotation to append to each call of the
+	// stacks, e.g. its owner
+	// or a link to an internal code search. When it is an http or https URL,
+	// it is rendered as a link. When nil or when it returns an empty string,
+	// the call is not annotated.
+	AnnotateCall func(c *Call) string
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
 // ToHTML formats the aggregated buckets as HTML to the writer.
 //
 // Use footer to add custom HTML at the bottom of the page.
 func (a *Aggregated) ToHTML(w io.Writer, footer template.HTML) error {
+	return a.WriteHTML(w, &HTMLOpts{Footer: footer})
+}
+
+// ToHTMLTree formats the aggregated buckets as HTML to the writer, organized
+// as a tree of which goroutines created which.
+//
+// See CreationTree for details. Use footer to add custom HTML at the bottom of
+// the page.
+func (a *Aggregated) ToHTMLTree(w io.Writer, footer template.HTML) error {
+	return a.WriteHTML(w, &HTMLOpts{Footer: footer, Tree: true})
+}
+
+// WriteHTML formats the aggregated buckets as HTML to the writer.
+func (a *Aggregated) WriteHTML(w io.Writer, opts *HTMLOpts) error {
 	data := map[string]interface{}{
 		"Aggregated": a,
-		"Footer":     footer,
+		"Footer":     opts.Footer,
 		"Snapshot":   a.Snapshot,
 	}
-	return toHTML(w, data)
+	if opts.Tree {
+		data["Tree"] = a.CreationTree()
+	}
+	return toHTML(w, data, opts.AnnotateCall)
 }
 
 // ToHTML formats the snapshot as HTML to the writer.
 //
 // Use footer to add custom HTML at the bottom of the page.
 func (s *Snapshot) ToHTML(w io.Writer, footer template.HTML) error {
+	return s.WriteHTML(w, &HTMLOpts{Footer: footer})
+}
+
+// WriteHTML formats the snapshot as HTML to the writer.
+func (s *Snapshot) WriteHTML(w io.Writer, opts *HTMLOpts) error {
 	data := map[string]interface{}{
-		"Footer":   footer,
+		"Footer":   opts.Footer,
 		"Snapshot": s,
 	}
-	return toHTML(w, data)
+	return toHTML(w, data, opts.AnnotateCall)
 }
 
 // Private stuff.
 
-func toHTML(w io.Writer, data map[string]interface{}) error {
+func toHTML(w io.Writer, data map[string]interface{}, annotateCall func(c *Call) string) error {
 	m := template.FuncMap{
+		"ago":       ago,
+		"annotate":  func(c *Call) template.HTML { return annotate(c, annotateCall) },
 		"funcClass": funcClass,
+		"markdown":  markdown,
 		"minus":     minus,
+		"packages":  packages,
 		"pkgURL":    pkgURL,
 		"srcURL":    srcURL,
 		"symbol":    symbol,
@@ -62,8 +106,52 @@ func toHTML(w io.Writer, data map[string]interface{}) error {
 	return t.Execute(w, data)
 }
 
+// ago returns how long ago t was, e.g. "1m35s ago".
+//
+// The format must match the one of the javascript code updating it.
+func ago(t time.Time) string {
+	d := time.Since(t).Truncate(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	return d.String() + " ago"
+}
+
 var reMethodSymbol = regexp.MustCompile(`^\(\*?([^)]+)\)(\..+)$`)
 
+// annotate returns the annotation of the call as HTML, linked when it is an
+// URL.
+func annotate(c *Call, f func(c *Call) string) template.HTML {
+	if f == nil {
+		return ""
+	}
+	a := f(c)
+	if a == "" {
+		return ""
+	}
+	e := template.HTMLEscapeString(a)
+	if u, err := url.Parse(a); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		/* #nosec G203 */
+		return template.HTML(`<a class="annotation" href="` + e + `">` + e + `</a>`)
+	}
+	/* #nosec G203 */
+	return template.HTML(`<span class="annotation">` + e + `</span>`)
+}
+
+// packages returns the space separated unique import paths of the calls, used
+// to filter the buckets in the browser.
+func packages(s *Stack) string {
+	var out []string
+	seen := map[string]bool{}
+	for i := range s.Calls {
+		if p := s.Calls[i].Func.ImportPath; p != "" && !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
 func funcClass(c *Call) template.HTML {
 	if c.Func.IsPkgMain {
 		return "FuncMain Exported"
@@ -76,6 +164,13 @@ func funcClass(c *Call) template.HTML {
 	return template.HTML("Func") + template.HTML(template.HTMLEscapeString(s))
 }
 
+// markdown returns the bucket as Markdown, to be pasted in issue trackers or
+// chat.
+func markdown(b *Bucket) string {
+	srcLen, pkgLen := measureText([]*Bucket{b})
+	return "**" + b.textHeader() + "**\n\n```\n" + b.textCalls(srcLen, pkgLen) + "```\n"
+}
+
 func minus(i, j int) int {
 	return i - j
 }
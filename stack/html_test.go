@@ -15,6 +15,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/maruel/panicparse/v2/internal/internaltest"
 )
@@ -27,12 +28,27 @@ func TestAggregated_ToHTML_2Buckets(t *testing.T) {
 	}
 	// We expect this to be fairly static across Go versions. We want to know if
 	// it changes significantly, thus assert the approximate size. This is being
-	// tested on travis.
-	if l := buf.Len(); l < 4000 || l > 10000 {
+	// tested on travis. The toolbar and its script to filter, sort and collapse
+	// the buckets add about 3.5KB, see TestAggregated_ToHTML_Interactive.
+	if l := buf.Len(); l < 4000 || l > 16000 {
 		t.Fatalf("unexpected length %d", l)
 	}
 }
 
+func TestAggregated_ToHTML_Captured(t *testing.T) {
+	t.Parallel()
+	buf := bytes.Buffer{}
+	a := getBuckets()
+	a.Snapshot = &Snapshot{CapturedAt: time.Now().Add(-95 * time.Second)}
+	if err := a.ToHTML(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("data-ts=\"%d\"", a.Snapshot.CapturedAt.Unix())
+	if s := buf.String(); !strings.Contains(s, want) || !strings.Contains(s, ">1m35s ago</span>") {
+		t.Fatalf("missing capture time:\n%s", s)
+	}
+}
+
 func TestAggregated_ToHTML_1Bucket(t *testing.T) {
 	t.Parallel()
 	// Exercise a condition when there's only one bucket.
@@ -44,8 +60,9 @@ func TestAggregated_ToHTML_1Bucket(t *testing.T) {
 	}
 	// We expect this to be fairly static across Go versions. We want to know if
 	// it changes significantly, thus assert the approximate size. This is being
-	// tested on travis.
-	if l := buf.Len(); l < 4000 || l > 10000 {
+	// tested on travis. The toolbar and its script to filter, sort and collapse
+	// the buckets add about 3.5KB, see TestAggregated_ToHTML_Interactive.
+	if l := buf.Len(); l < 4000 || l > 16000 {
 		t.Fatalf("unexpected length %d", l)
 	}
 	if strings.Contains(buf.String(), "foo-bar") {
@@ -66,6 +83,77 @@ func TestAggregated_ToHTML_1Bucket_Footer(t *testing.T) {
 	}
 }
 
+func TestAggregated_WriteHTML_AnnotateCall(t *testing.T) {
+	t.Parallel()
+	buf := bytes.Buffer{}
+	a := getBuckets()
+	opts := HTMLOpts{AnnotateCall: func(c *Call) string {
+		if c.Func.Name == "DoStuff" {
+			return "https://cs.example.com/?q=<DoStuff>"
+		}
+		return "owner: <team>"
+	}}
+	if err := a.WriteHTML(&buf, &opts); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	if !strings.Contains(s, `<a class="annotation" href="https://cs.example.com/?q=&lt;DoStuff&gt;">`) {
+		t.Fatal("expected link")
+	}
+	if !strings.Contains(s, `<span class="annotation">owner: &lt;team&gt;</span>`) {
+		t.Fatal("expected annotation")
+	}
+}
+
+func TestAggregated_ToHTML_Interactive(t *testing.T) {
+	t.Parallel()
+	buf := bytes.Buffer{}
+	if err := getBuckets().ToHTML(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, want := range []string{
+		`<form class="toolbar"`,
+		`<div class="bucket" id="b0" data-index="0" data-count="2" data-sleep="0" data-pkgs="main">`,
+		`<a id="g1"></a><a id="g2"></a>`,
+		`<div class="bucket" id="b1" data-index="1" data-count="1" data-sleep="0" data-pkgs="">`,
+		`<a id="g3"></a>`,
+		`<details class="calls" open><summary>Stack</summary>`,
+		`<input type="search" id="filter"`,
+		`<input type="search" id="match"`,
+		`<option value="count">count</option>`,
+		`<option value="sleep">sleep time</option>`,
+		`<button type="button" id="collapse">`,
+		`<button type="button" id="expand">`,
+		`<span id="shown"></span>`,
+		`filter.addEventListener("input", apply);`,
+		`match.addEventListener("input", apply);`,
+		`sort.addEventListener("change", apply);`,
+		`window.addEventListener("hashchange", reveal);`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestAggregated_ToHTMLTree(t *testing.T) {
+	t.Parallel()
+	buf := bytes.Buffer{}
+	a := getBuckets()
+	for _, b := range a.Buckets {
+		for _, id := range b.IDs {
+			a.Goroutines = append(a.Goroutines, &Goroutine{Signature: b.Signature, ID: id})
+		}
+	}
+	if err := a.ToHTMLTree(&buf, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<ul class="tree">`) {
+		t.Fatal("expected tree")
+	}
+}
+
 func TestGenerate(t *testing.T) {
 	t.Parallel()
 	// Confirms that nobody forgot to regenate data.go.
@@ -205,6 +293,29 @@ func TestGetSrcBranchURL(t *testing.T) {
 	}
 }
 
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+	b := &Bucket{
+		Signature: Signature{
+			State: "chan receive",
+			Stack: Stack{
+				Calls: []Call{
+					newCall("main.func·001", Args{Values: []Arg{{Value: 0x11000000}, {Value: 2}}}, "/gopath/src/github.com/maruel/panicparse/stack/stack.go", 72),
+					newCall("sync.(*WaitGroup).Wait", Args{}, "/goroot/src/sync/waitgroup.go", 130),
+				},
+			},
+			CreatedBy: Stack{Calls: []Call{newCall("main.mainImpl", Args{}, "/gopath/src/foo/main.go", 74)}},
+		},
+		IDs: []int{1, 2},
+	}
+	want := "**2: chan receive [Created by main.mainImpl @ main.go:74]**\n\n" +
+		"```\n" +
+		"    main stack.go:72      func·001(0x11000000, 2)\n" +
+		"    sync waitgroup.go:130 (*WaitGroup).Wait()\n" +
+		"```\n"
+	compareString(t, want, markdown(b))
+}
+
 func TestSymbol(t *testing.T) {
 	t.Parallel()
 	data := []struct {
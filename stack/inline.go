@@ -0,0 +1,267 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// This file contains the code to expand inlined calls with the DWARF debug
+// information of the executable.
+
+package stack
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Private stuff.
+
+var rePCOffset = regexp.MustCompile(` \+0x([0-9a-f]+)(?: |$)`)
+
+// parsePCOffset returns the offset of the pc relative to the function entry
+// as printed after the line number, e.g. "+0x1a5". Returns 0 if absent, e.g.
+// for the inlined calls printed by the runtime.
+func parsePCOffset(line []byte) uint64 {
+	if m := rePCOffset.FindSubmatch(line); m != nil {
+		v, _ := strconv.ParseUint(string(m[1]), 16, 64)
+		return v
+	}
+	return 0
+}
+
+// binaryInfo is the DWARF debug information of an executable.
+type binaryInfo struct {
+	d *dwarf.Data
+	// funcs maps the function name to its concrete DW_TAG_subprogram entry.
+	funcs map[string]binaryFunc
+}
+
+type binaryFunc struct {
+	entry  uint64
+	offset dwarf.Offset
+	cu     *dwarf.Entry
+}
+
+// inlinedFrame is one call expanded from the inlining information.
+type inlinedFrame struct {
+	name string
+	file string
+	line int
+}
+
+// openBinary loads the DWARF debug information of an ELF, Mach-O or PE
+// executable.
+func openBinary(path string) (*binaryInfo, error) {
+	d, err := loadDWARF(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &binaryInfo{d: d, funcs: map[string]binaryFunc{}}
+	r := d.Reader()
+	var cu *dwarf.Entry
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			cu = e
+		case dwarf.TagSubprogram:
+			name, _ := e.Val(dwarf.AttrName).(string)
+			if low, ok := e.Val(dwarf.AttrLowpc).(uint64); ok && name != "" {
+				b.funcs[name] = binaryFunc{entry: low, offset: e.Offset, cu: cu}
+			}
+			r.SkipChildren()
+		}
+	}
+	return b, nil
+}
+
+func loadDWARF(path string) (*dwarf.Data, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	return nil, fmt.Errorf("%s: unsupported executable format", path)
+}
+
+// expand returns the calls inlined in the function name at pc, innermost
+// first, followed by the function itself with the line of the call to the
+// outermost inlined function.
+//
+// Returns nil if nothing was inlined at pc.
+func (b *binaryInfo) expand(name string, pc uint64) ([]inlinedFrame, error) {
+	f, ok := b.funcs[name]
+	if !ok {
+		return nil, nil
+	}
+	r := b.d.Reader()
+	r.Seek(f.offset)
+	e, err := r.Next()
+	if err != nil || e == nil || !e.Children {
+		return nil, err
+	}
+	var chain []*dwarf.Entry
+	if chain, err = b.findInlined(r, pc, chain); err != nil || len(chain) == 0 {
+		return nil, err
+	}
+	lr, err := b.d.LineReader(f.cu)
+	if err != nil || lr == nil {
+		return nil, err
+	}
+	files := lr.Files()
+	le := dwarf.LineEntry{}
+	if err = lr.SeekPC(pc, &le); err != nil {
+		return nil, err
+	}
+	out := make([]inlinedFrame, 0, len(chain)+1)
+	file, line := le.File.Name, le.Line
+	for i := len(chain) - 1; i >= 0; i-- {
+		out = append(out, inlinedFrame{name: b.originName(chain[i]), file: file, line: line})
+		file, line = "", 0
+		if idx, ok := chain[i].Val(dwarf.AttrCallFile).(int64); ok && idx >= 0 && int(idx) < len(files) && files[idx] != nil {
+			file = files[idx].Name
+		}
+		if l, ok := chain[i].Val(dwarf.AttrCallLine).(int64); ok {
+			line = int(l)
+		}
+	}
+	return append(out, inlinedFrame{name: name, file: file, line: line}), nil
+}
+
+// findInlined walks the children of the current entry of r and appends the
+// nested DW_TAG_inlined_subroutine entries containing pc, outermost first.
+func (b *binaryInfo) findInlined(r *dwarf.Reader, pc uint64, chain []*dwarf.Entry) ([]*dwarf.Entry, error) {
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == 0 {
+			return chain, err
+		}
+		if (e.Tag == dwarf.TagInlinedSubroutine || e.Tag == dwarf.TagLexDwarfBlock) && b.contains(e, pc) {
+			if e.Tag == dwarf.TagInlinedSubroutine {
+				chain = append(chain, e)
+			}
+			if e.Children {
+				return b.findInlined(r, pc, chain)
+			}
+			return chain, nil
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+}
+
+func (b *binaryInfo) contains(e *dwarf.Entry, pc uint64) bool {
+	ranges, err := b.d.Ranges(e)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if r[0] <= pc && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// originName returns the name of the function inlined by e.
+func (b *binaryInfo) originName(e *dwarf.Entry) string {
+	o, ok := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+	if !ok {
+		return ""
+	}
+	r := b.d.Reader()
+	r.Seek(o)
+	if a, err := r.Next(); err == nil && a != nil {
+		name, _ := a.Val(dwarf.AttrName).(string)
+		return name
+	}
+	return ""
+}
+
+// expandInlined inserts the calls inlined in each frame of the goroutine,
+// using offsets, the pc offset of each call as printed in the stack trace.
+//
+// Frames that the runtime already expanded are left as-is.
+func (b *binaryInfo) expandInlined(g *Goroutine, offsets []uint64) error {
+	if len(offsets) != len(g.Stack.Calls) {
+		return nil
+	}
+	var out []Call
+	for i := range g.Stack.Calls {
+		c := g.Stack.Calls[i]
+		// A frame printed by the runtime without offset is an inlined call, so
+		// the next frame was already expanded.
+		if offsets[i] == 0 || (i > 0 && offsets[i-1] == 0) {
+			out = append(out, c)
+			continue
+		}
+		f, ok := b.funcs[c.Func.Complete]
+		if !ok {
+			out = append(out, c)
+			continue
+		}
+		pc := f.entry + offsets[i]
+		if i > 0 && g.Stack.Calls[i-1].Func.Complete != "runtime.sigpanic" {
+			// This is the return address, look at the call instruction instead.
+			pc--
+		}
+		frames, err := b.expand(c.Func.Complete, pc)
+		if err != nil {
+			return err
+		}
+		if len(frames) == 0 {
+			out = append(out, c)
+			continue
+		}
+		for _, fr := range frames[:len(frames)-1] {
+			n := Call{Inlined: true}
+			if err := n.Func.Init(fr.name); err != nil {
+				return err
+			}
+			n.Args.Elided = true
+			n.init(fr.file, fr.line)
+			out = append(out, n)
+		}
+		if last := frames[len(frames)-1]; last.file != "" {
+			c.init(last.file, last.line)
+		}
+		out = append(out, c)
+	}
+	g.Stack.Calls = out
+	return nil
+}
+
+// expandInlined expands the inlined calls of all the goroutines with the
+// DWARF information of the executable at path, if set.
+func (s *scanningState) expandInlined(path string) {
+	if path == "" || s.offsets == nil {
+		return
+	}
+	b, err := openBinary(path)
+	if err != nil {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("failed to load %s: %v", path, err))
+		return
+	}
+	for _, g := range s.Goroutines {
+		if err := b.expandInlined(g, s.offsets[g]); err != nil {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("goroutine %d: failed to expand inlined calls: %v", g.ID, err))
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParsePCOffset(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want uint64
+	}{
+		{"\t/gopath/main.go:12 +0x1a5", 0x1a5},
+		{"\t/gopath/main.go:12 +0x1a5 fp=0xc000 sp=0xc000 pc=0x4", 0x1a5},
+		{"\t/gopath/main.go:12", 0},
+	}
+	for i, line := range data {
+		if got := parsePCOffset([]byte(line.in)); got != line.want {
+			t.Errorf("#%d: want %#x, got %#x", i, line.want, got)
+		}
+	}
+}
+
+func TestScanSnapshotBinaryInlined(t *testing.T) {
+	t.Parallel()
+	root, err := os.MkdirTemp("", "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = os.RemoveAll(root); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	const content = "package main\n" +
+		"\n" +
+		"var sink *int\n" +
+		"\n" +
+		"func inner() int { return *sink }\n" +
+		"\n" +
+		"func outer() int { return inner() + 1 }\n" +
+		"\n" +
+		"//go:noinline\n" +
+		"func run() int { return outer() }\n" +
+		"\n" +
+		"func main() { println(run()) }\n"
+	if err = os.WriteFile(filepath.Join(root, "main.go"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(root, "go.mod"), []byte("module inlined\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(root, "inlined")
+	c := exec.Command("go", "build", "-o", exe)
+	c.Dir = root
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	out, err := exec.Command(exe).CombinedOutput()
+	if err == nil {
+		t.Fatal("expected failure")
+	}
+
+	// The runtime prints the inlined calls without pc offset. Remove them to
+	// simulate an older Go version.
+	var stripped []byte
+	lines := bytes.SplitAfter(out, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		if i+1 < len(lines) && bytes.HasPrefix(lines[i+1], []byte("\t")) && !bytes.Contains(lines[i+1], []byte(" +0x")) {
+			i++
+			continue
+		}
+		stripped = append(stripped, lines[i]...)
+	}
+	if bytes.Equal(stripped, out) {
+		t.Fatalf("expected inlined calls in:\n%s", out)
+	}
+
+	opts := &Opts{}
+	s, _, err := ScanSnapshot(bytes.NewReader(out), &bytes.Buffer{}, opts)
+	if err != nil && s == nil {
+		t.Fatal(err)
+	}
+	want := callLocations(s.Goroutines[0].Stack.Calls)
+
+	opts.Binary = exe
+	s, _, err = ScanSnapshot(bytes.NewReader(stripped), &bytes.Buffer{}, opts)
+	if err != nil && s == nil {
+		t.Fatal(err)
+	}
+	if len(s.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", s.Warnings)
+	}
+	if diff := cmp.Diff(want, callLocations(s.Goroutines[0].Stack.Calls)); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	var inlined []string
+	for _, c := range s.Goroutines[0].Stack.Calls {
+		if c.Inlined {
+			inlined = append(inlined, c.Func.Complete)
+		}
+	}
+	if diff := cmp.Diff([]string{"main.inner", "main.outer"}, inlined); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+// callLocations returns "func file:line" for each call.
+func callLocations(calls []Call) []string {
+	out := make([]string, 0, len(calls))
+	for _, c := range calls {
+		out = append(out, c.Func.Complete+" "+c.SrcName+":"+strconv.Itoa(c.Line))
+	}
+	return out
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Module is the go module a call belongs to, relative to the local main
+// modules.
+//
+// It is only set when Opts.ResolveModules is true.
+type Module struct {
+	// Path is the module path, e.g. "github.com/maruel/panicparse/v2".
+	Path string
+	// Version is the version of the module, e.g. "v2.3.1". It is empty for a
+	// main module.
+	Version string
+	// Main is true when the module is one of Snapshot.LocalGomods and no other
+	// local module requires it.
+	Main bool
+	// Direct is true when a main module requires it without an "// indirect"
+	// comment in its go.mod.
+	Direct bool
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Dependency returns a human readable description of the relationship of the
+// module with the main module.
+func (m *Module) Dependency() string {
+	switch {
+	case m.Main:
+		return "main module"
+	case m.Direct:
+		return "direct dependency"
+	default:
+		return "indirect dependency"
+	}
+}
+
+// Private stuff.
+
+// requirement is a "require" directive in a go.mod file.
+type requirement struct {
+	version string
+	direct  bool
+}
+
+// resolveModules sets Call.Module for the calls in a go module.
+//
+// The requirements are read from the go.mod files of the roots in
+// LocalGomods, so it must be called after guessPaths.
+func (s *Snapshot) resolveModules() {
+	reqs := map[string]requirement{}
+	for root := range s.LocalGomods {
+		/* #nosec G304 */
+		b, err := os.ReadFile(pathJoin(root, "go.mod"))
+		if err != nil {
+			continue
+		}
+		parseRequires(string(b), reqs)
+	}
+	roots := sortedRoots(s.LocalGomods)
+	modules := map[string]*Module{}
+	get := func(p, version string) *Module {
+		k := p + "@" + version
+		if m := modules[k]; m != nil {
+			return m
+		}
+		m := &Module{Path: p, Version: version}
+		if r, ok := reqs[p]; ok {
+			m.Direct = r.direct
+			if m.Version == "" {
+				// A local module that is required by another one, presumably via a
+				// "replace" directive.
+				m.Version = r.version
+			}
+		} else if version == "" {
+			m.Main = true
+		}
+		modules[k] = m
+		return m
+	}
+	resolve := func(c *Call) {
+		switch c.Location {
+		case GoPkg:
+			if p, version := splitModuleCachePath(c.RelSrcPath); p != "" {
+				c.Module = get(p, version)
+			}
+		case GoMod:
+			for _, root := range roots {
+				if p := s.LocalGomods[root]; p != "main" && strings.HasPrefix(c.LocalSrcPath, root+"/") {
+					c.Module = get(p, "")
+					break
+				}
+			}
+		}
+	}
+	for _, g := range s.Goroutines {
+		for i := range g.Stack.Calls {
+			resolve(&g.Stack.Calls[i])
+		}
+		for i := range g.CreatedBy.Calls {
+			resolve(&g.CreatedBy.Calls[i])
+		}
+	}
+}
+
+// parseRequires adds the "require" directives of the go.mod file content to
+// reqs.
+//
+// A module required directly by any of the go.mod files is considered direct.
+func parseRequires(content string, reqs map[string]requirement) {
+	inBlock := false
+	for _, l := range strings.Split(content, "\n") {
+		l = strings.TrimSpace(l)
+		comment := ""
+		if i := strings.Index(l, "//"); i != -1 {
+			comment = strings.TrimSpace(l[i+2:])
+			l = strings.TrimSpace(l[:i])
+		}
+		if inBlock {
+			if l == ")" {
+				inBlock = false
+				continue
+			}
+		} else {
+			if !strings.HasPrefix(l, "require") {
+				continue
+			}
+			l = strings.TrimSpace(l[len("require"):])
+			if l == "(" {
+				inBlock = true
+				continue
+			}
+		}
+		f := strings.Fields(l)
+		if len(f) != 2 {
+			continue
+		}
+		p := strings.Trim(f[0], "\"`")
+		direct := !strings.HasPrefix(comment, "indirect")
+		if r, ok := reqs[p]; ok && r.direct {
+			direct = true
+		}
+		reqs[p] = requirement{version: f[1], direct: direct}
+	}
+}
+
+// splitModuleCachePath returns the module path and version of a path relative
+// to the module cache, e.g. "github.com/!foo/bar@v1.0.0/baz/baz.go".
+//
+// The upper case letters are escaped in the module cache, see
+// golang.org/x/mod/module.EscapePath.
+func splitModuleCachePath(rel string) (string, string) {
+	i := strings.IndexByte(rel, '@')
+	if i == -1 {
+		return "", ""
+	}
+	version := rel[i+1:]
+	if j := strings.IndexByte(version, '/'); j != -1 {
+		version = version[:j]
+	}
+	var p strings.Builder
+	bang := false
+	for _, r := range rel[:i] {
+		if r == '!' {
+			bang = true
+			continue
+		}
+		if bang {
+			r = unicode.ToUpper(r)
+			bang = false
+		}
+		p.WriteRune(r)
+	}
+	return p.String(), version
+}
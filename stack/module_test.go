@@ -0,0 +1,103 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRequires(t *testing.T) {
+	t.Parallel()
+	reqs := map[string]requirement{}
+	parseRequires("module example.com/a\n\ngo 1.17\n\nrequire example.com/b v1.0.0\n\nrequire (\n\texample.com/c v1.1.0 // indirect\n\t\"example.com/d\" v0.0.0-20200101000000-abcdef123456\n)\n\nreplace example.com/e => ../e\n", reqs)
+	// A direct requirement in another go.mod wins.
+	parseRequires("module example.com/f\n\nrequire example.com/b v1.0.0 // indirect\n", reqs)
+	want := map[string]requirement{
+		"example.com/b": {version: "v1.0.0", direct: true},
+		"example.com/c": {version: "v1.1.0"},
+		"example.com/d": {version: "v0.0.0-20200101000000-abcdef123456", direct: true},
+	}
+	if diff := cmp.Diff(want, reqs, cmp.AllowUnexported(requirement{})); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+func TestSplitModuleCachePath(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in, path, version string
+	}{
+		{"github.com/!burnt!sushi/toml@v1.2.0/decode.go", "github.com/BurntSushi/toml", "v1.2.0"},
+		{"example.com/a@v1.0.0/sub/a.go", "example.com/a", "v1.0.0"},
+		{"example.com/a/a.go", "", ""},
+	}
+	for i, line := range data {
+		if p, v := splitModuleCachePath(line.in); p != line.path || v != line.version {
+			t.Fatalf("#%d: got %q, %q", i, p, v)
+		}
+	}
+}
+
+func TestResolveModules(t *testing.T) {
+	t.Parallel()
+	root := filepath.ToSlash(t.TempDir())
+	gomod := "module example.com/main\n\nrequire (\n\texample.com/direct v1.0.0\n\texample.com/indirect v1.1.0 // indirect\n)\n"
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte(gomod), 0600); err != nil {
+		t.Fatal(err)
+	}
+	newCall := func(l Location, local, rel string) Call {
+		return Call{Location: l, LocalSrcPath: local, RelSrcPath: rel}
+	}
+	s := &Snapshot{
+		Goroutines: []*Goroutine{
+			{
+				Signature: Signature{
+					Stack: Stack{Calls: []Call{
+						newCall(Stdlib, "/goroot/src/runtime/panic.go", "runtime/panic.go"),
+						newCall(GoPkg, "/gopath/pkg/mod/example.com/direct@v1.0.0/a.go", "example.com/direct@v1.0.0/a.go"),
+						newCall(GoPkg, "/gopath/pkg/mod/example.com/indirect@v1.1.0/b/b.go", "example.com/indirect@v1.1.0/b/b.go"),
+						newCall(GoPkg, "/gopath/pkg/mod/example.com/other@v2.0.0/c.go", "example.com/other@v2.0.0/c.go"),
+						newCall(GoMod, root+"/main.go", "main.go"),
+					}},
+				},
+			},
+		},
+		LocalGomods: map[string]string{root: "example.com/main"},
+	}
+	s.resolveModules()
+	var got []*Module
+	for _, c := range s.Goroutines[0].Stack.Calls {
+		got = append(got, c.Module)
+	}
+	want := []*Module{
+		nil,
+		{Path: "example.com/direct", Version: "v1.0.0", Direct: true},
+		{Path: "example.com/indirect", Version: "v1.1.0"},
+		{Path: "example.com/other", Version: "v2.0.0"},
+		{Path: "example.com/main", Main: true},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Module{})); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	for i, d := range []string{"direct dependency", "indirect dependency", "indirect dependency", "main module"} {
+		if got := want[i+1].Dependency(); got != d {
+			t.Fatalf("#%d: got %q", i, got)
+		}
+	}
+
+	b := bytes.Buffer{}
+	if err := s.Aggregate(AnyValue).ToHTML(&b, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Module: example.com/indirect@v1.1.0 (indirect dependency)") {
+		t.Fatal("module not rendered")
+	}
+}
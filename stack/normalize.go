@@ -0,0 +1,139 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"sort"
+	"strings"
+)
+
+// Placeholders used by Normalize.
+const (
+	PlaceholderGOROOT   = "$GOROOT"
+	PlaceholderGOPATH   = "$GOPATH"
+	PlaceholderModCache = "$MODCACHE"
+)
+
+// NormalizeOpts are the options for Normalize.
+//
+// The zero value is valid.
+type NormalizeOpts struct {
+	// Roots maps additional path prefixes to the placeholder to use instead,
+	// e.g. the directory of the project to "$SRC".
+	//
+	// They take precedence over GOROOT and GOPATH when longer.
+	Roots map[string]string
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Normalize rewrites the environment dependent absolute paths of the snapshot
+// to placeholders, so the snapshot is reproducible across machines, e.g. to
+// be committed as a golden file.
+//
+// The GOROOT prefix is replaced with "$GOROOT", the go module cache
+// "<GOPATH>/pkg/mod" with "$MODCACHE" and the rest of GOPATH with "$GOPATH".
+// Both the remote paths, as found in the stack trace, and the local ones are
+// rewritten. The roots are the ones of the snapshot, so Opts.GuessPaths must
+// have been set for GOROOT and GOPATH to be known.
+//
+// All the path fields are rewritten: the source paths of the calls, including
+// the creators, and the roots of the snapshot. The relative paths are left
+// as is.
+func Normalize(s *Snapshot, opts NormalizeOpts) {
+	r := newNormalizer(s, &opts)
+	for _, g := range s.Goroutines {
+		r.stack(&g.Stack)
+		r.stack(&g.CreatedBy)
+	}
+	s.RemoteGOROOT = r.path(s.RemoteGOROOT)
+	s.LocalGOROOT = r.path(s.LocalGOROOT)
+	for i := range s.LocalGOPATHs {
+		s.LocalGOPATHs[i] = r.path(s.LocalGOPATHs[i])
+	}
+	if s.RemoteGOPATHs != nil {
+		m := make(map[string]string, len(s.RemoteGOPATHs))
+		for k, v := range s.RemoteGOPATHs {
+			m[r.path(k)] = r.path(v)
+		}
+		s.RemoteGOPATHs = m
+	}
+	if s.LocalGomods != nil {
+		m := make(map[string]string, len(s.LocalGomods))
+		for k, v := range s.LocalGomods {
+			m[r.path(k)] = v
+		}
+		s.LocalGomods = m
+	}
+}
+
+// Private stuff.
+
+// normalizer replaces path prefixes with placeholders.
+type normalizer struct {
+	// prefixes are sorted longest first, so the most specific one is used.
+	prefixes []string
+	repl     map[string]string
+}
+
+func newNormalizer(s *Snapshot, opts *NormalizeOpts) *normalizer {
+	r := &normalizer{repl: map[string]string{}}
+	add := func(prefix, placeholder string) {
+		prefix = strings.TrimRight(prefix, `/\`)
+		if prefix == "" {
+			return
+		}
+		if _, ok := r.repl[prefix]; !ok {
+			r.prefixes = append(r.prefixes, prefix)
+		}
+		r.repl[prefix] = placeholder
+	}
+	addGOPATH := func(p string) {
+		add(p, PlaceholderGOPATH)
+		add(p+"/pkg/mod", PlaceholderModCache)
+	}
+	add(s.RemoteGOROOT, PlaceholderGOROOT)
+	add(s.LocalGOROOT, PlaceholderGOROOT)
+	for k, v := range s.RemoteGOPATHs {
+		addGOPATH(k)
+		addGOPATH(v)
+	}
+	for _, p := range s.LocalGOPATHs {
+		addGOPATH(p)
+	}
+	// The explicit roots override the ones above when identical.
+	for k, v := range opts.Roots {
+		add(k, v)
+	}
+	sort.Slice(r.prefixes, func(i, j int) bool {
+		if len(r.prefixes[i]) != len(r.prefixes[j]) {
+			return len(r.prefixes[i]) > len(r.prefixes[j])
+		}
+		return r.prefixes[i] < r.prefixes[j]
+	})
+	return r
+}
+
+// path returns p with its longest known prefix replaced.
+func (r *normalizer) path(p string) string {
+	for _, prefix := range r.prefixes {
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if rest := p[len(prefix):]; rest == "" || rest[0] == '/' || rest[0] == '\\' {
+			return r.repl[prefix] + rest
+		}
+	}
+	return p
+}
+
+func (r *normalizer) stack(s *Stack) {
+	for i := range s.Calls {
+		c := &s.Calls[i]
+		c.RemoteSrcPath = r.path(c.RemoteSrcPath)
+		c.LocalSrcPath = r.path(c.LocalSrcPath)
+	}
+}
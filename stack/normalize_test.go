@@ -0,0 +1,72 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+	newCallPaths := func(remote, local string) Call {
+		return Call{RemoteSrcPath: remote, LocalSrcPath: local, RelSrcPath: "rel/foo.go"}
+	}
+	s := &Snapshot{
+		Goroutines: []*Goroutine{
+			{
+				Signature: Signature{
+					Stack: Stack{Calls: []Call{
+						newCallPaths("/remote/goroot/src/runtime/panic.go", "/local/goroot/src/runtime/panic.go"),
+						newCallPaths("/remote/gopath/pkg/mod/example.com/a@v1.0.0/a.go", "/local/gopath/pkg/mod/example.com/a@v1.0.0/a.go"),
+						newCallPaths("/remote/gopath/src/example.com/b/b.go", "/local/gopath/src/example.com/b/b.go"),
+						newCallPaths("/home/user/src/proj/main.go", "/home/user/src/proj/main.go"),
+						// Not a path boundary.
+						newCallPaths("/remote/gorootfoo/a.go", ""),
+					}},
+					CreatedBy: Stack{Calls: []Call{
+						newCallPaths("/remote/goroot/src/net/http/server.go", ""),
+					}},
+				},
+			},
+		},
+		LocalGOROOT:   "/local/goroot",
+		LocalGOPATHs:  []string{"/local/gopath"},
+		RemoteGOROOT:  "/remote/goroot",
+		RemoteGOPATHs: map[string]string{"/remote/gopath": "/local/gopath"},
+		LocalGomods:   map[string]string{"/home/user/src/proj": "example.com/proj"},
+	}
+	Normalize(s, NormalizeOpts{Roots: map[string]string{"/home/user/src/proj": "$SRC"}})
+	want := &Snapshot{
+		Goroutines: []*Goroutine{
+			{
+				Signature: Signature{
+					Stack: Stack{Calls: []Call{
+						newCallPaths("$GOROOT/src/runtime/panic.go", "$GOROOT/src/runtime/panic.go"),
+						newCallPaths("$MODCACHE/example.com/a@v1.0.0/a.go", "$MODCACHE/example.com/a@v1.0.0/a.go"),
+						newCallPaths("$GOPATH/src/example.com/b/b.go", "$GOPATH/src/example.com/b/b.go"),
+						newCallPaths("$SRC/main.go", "$SRC/main.go"),
+						newCallPaths("/remote/gorootfoo/a.go", ""),
+					}},
+					CreatedBy: Stack{Calls: []Call{
+						newCallPaths("$GOROOT/src/net/http/server.go", ""),
+					}},
+				},
+			},
+		},
+		LocalGOROOT:   "$GOROOT",
+		LocalGOPATHs:  []string{"$GOPATH"},
+		RemoteGOROOT:  "$GOROOT",
+		RemoteGOPATHs: map[string]string{"$GOPATH": "$GOPATH"},
+		LocalGomods:   map[string]string{"$SRC": "example.com/proj"},
+	}
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+
+	// The zero value is valid.
+	Normalize(&Snapshot{}, NormalizeOpts{})
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "sort"
+
+// PackageInfo is a package found in the calls of a snapshot, as returned by
+// Snapshot.Packages.
+type PackageInfo struct {
+	// ImportPath is the import path of the package, as in Call.ImportPath.
+	ImportPath string
+	// Location is where the package sources are, if determined.
+	Location Location
+	// Frames is the number of calls in the package, across all the goroutines.
+	Frames int
+	// Goroutines is the number of goroutines with at least one call in the
+	// package.
+	Goroutines int
+	// Callees is the import paths of the packages called by this package,
+	// sorted.
+	//
+	// This is the import graph as far as it can be seen in the snapshot: a
+	// package usually imports the packages it calls, except for callbacks,
+	// like an http.Handler called by package net/http.
+	Callees []string
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Packages returns the packages found in the current stack of the goroutines,
+// sorted by the number of goroutines in them, most first, then by import path.
+//
+// It is a quick inventory of the subsystems running, or blocked, in the
+// process. The creation site of the goroutines is not considered, nor the
+// calls without a symbol.
+func (s *Snapshot) Packages() []PackageInfo {
+	m := map[string]int{}
+	var out []PackageInfo
+	var callees []map[string]bool
+	index := func(importPath string) int {
+		i, ok := m[importPath]
+		if !ok {
+			i = len(out)
+			m[importPath] = i
+			out = append(out, PackageInfo{ImportPath: importPath})
+			callees = append(callees, map[string]bool{})
+		}
+		return i
+	}
+	for _, g := range s.Goroutines {
+		seen := map[int]bool{}
+		calls := g.Stack.Calls
+		for i := range calls {
+			c := &calls[i]
+			if c.ImportPath == "" {
+				continue
+			}
+			j := index(c.ImportPath)
+			p := &out[j]
+			if p.Location == LocationUnknown {
+				p.Location = c.Location
+			}
+			p.Frames++
+			if !seen[j] {
+				seen[j] = true
+				p.Goroutines++
+			}
+			// The caller is the next call.
+			if i+1 < len(calls) {
+				if caller := calls[i+1].ImportPath; caller != "" && caller != c.ImportPath {
+					callees[index(caller)][c.ImportPath] = true
+				}
+			}
+		}
+	}
+	for i := range out {
+		for p := range callees[i] {
+			out[i].Callees = append(out[i].Callees, p)
+		}
+		sort.Strings(out[i].Callees)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Goroutines != out[j].Goroutines {
+			return out[i].Goroutines > out[j].Goroutines
+		}
+		return out[i].ImportPath < out[j].ImportPath
+	})
+	return out
+}
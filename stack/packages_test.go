@@ -0,0 +1,57 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot_Packages(t *testing.T) {
+	t.Parallel()
+	in := "" +
+		"goroutine 1 [running]:\n" +
+		"main.main()\n" +
+		"\t/home/user/go/src/main.go:50 +0x20\n" +
+		"\n" +
+		"goroutine 6 [chan receive]:\n" +
+		"runtime.gopark(0x0, 0x0, 0x0, 0x0, 0x0)\n" +
+		"\t/goroot/src/runtime/proc.go:398 +0xce\n" +
+		"runtime.chanrecv1(0xc000020000, 0x0)\n" +
+		"\t/goroot/src/runtime/chan.go:442 +0x18\n" +
+		"example.com/foo.(*Pool).worker(0xc000020000)\n" +
+		"\t/home/user/go/src/example.com/foo/pool.go:40 +0x40\n" +
+		"example.com/foo.(*Pool).Run(0xc000020000)\n" +
+		"\t/home/user/go/src/example.com/foo/pool.go:20 +0x40\n" +
+		"main.main.func1()\n" +
+		"\t/home/user/go/src/main.go:30 +0x40\n" +
+		"\n" +
+		"goroutine 7 [IO wait]:\n" +
+		"internal/poll.runtime_pollWait(0x7f0000000000, 0x72)\n" +
+		"\t/goroot/src/runtime/netpoll.go:343 +0x85\n" +
+		"example.com/foo.(*Pool).worker(0xc000020000)\n" +
+		"\t/home/user/go/src/example.com/foo/pool.go:41 +0x40\n" +
+		"\n"
+	s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, &Opts{})
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	s.Goroutines[1].Stack.Calls[0].Location = Stdlib
+	want := []PackageInfo{
+		{ImportPath: "example.com/foo", Frames: 3, Goroutines: 2, Callees: []string{"internal/poll", "runtime"}},
+		{ImportPath: "main", Frames: 2, Goroutines: 2, Callees: []string{"example.com/foo"}},
+		{ImportPath: "internal/poll", Frames: 1, Goroutines: 1},
+		{ImportPath: "runtime", Location: Stdlib, Frames: 2, Goroutines: 1},
+	}
+	if diff := cmp.Diff(want, s.Packages(), cmp.AllowUnexported(PackageInfo{})); diff != "" {
+		t.Fatalf("Packages() mismatch (-want +got):\n%s", diff)
+	}
+	if p := (&Snapshot{}).Packages(); len(p) != 0 {
+		t.Fatal(p)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package rawparse tokenizes the lines of a goroutine dump.
+//
+// It is the lowest level of the parser: each line is classified on its own,
+// without knowledge of the lines around it. The stack package composes these
+// tokens into a Snapshot. Use this package to build other semantics on top of
+// the dump, or to repair a malformed one, e.g. to rejoin lines that were
+// split by a log shipper.
+//
+// Since the classification is context free, some tokens are ambiguous. For
+// example any line ending with a parenthesis is a FuncLine. It is up to the
+// caller to decide based on the sequence of tokens.
+package rawparse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Kind is the kind of a line.
+type Kind int
+
+// Kinds of lines.
+const (
+	// Other is a line that is not part of a goroutine dump.
+	Other Kind = iota
+	// Blank is an empty line, separating goroutines.
+	Blank
+	// PanicHeader is "panic: <value>", optionally prefixed, e.g. by a log
+	// timestamp.
+	PanicHeader
+	// GoroutineHeader is "goroutine 1 [running]:".
+	GoroutineHeader
+	// FuncLine is a call, e.g. "main.main()".
+	FuncLine
+	// FileLine is the source location of a call, e.g.
+	// "\t/home/user/main.go:12 +0x20".
+	FileLine
+	// CreatedBy is "created by main.main in goroutine 1".
+	CreatedBy
+	// FramesElided is "...additional frames elided..." or "...5 frames
+	// elided...".
+	FramesElided
+	// RaceMarker is either the "==================" separator around a race
+	// detector report or its "WARNING: DATA RACE" banner.
+	RaceMarker
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Other:
+		return "Other"
+	case Blank:
+		return "Blank"
+	case PanicHeader:
+		return "PanicHeader"
+	case GoroutineHeader:
+		return "GoroutineHeader"
+	case FuncLine:
+		return "FuncLine"
+	case FileLine:
+		return "FileLine"
+	case CreatedBy:
+		return "CreatedBy"
+	case FramesElided:
+		return "FramesElided"
+	case RaceMarker:
+		return "RaceMarker"
+	default:
+		return "Kind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Token is a tokenized line.
+//
+// The byte slices point into the line passed to Parse or Tokenize.
+type Token struct {
+	Kind Kind
+	// Line is the line, without the end of line characters.
+	Line []byte
+
+	// Indent is the whitespace before a GoroutineHeader. The lines of the
+	// goroutine are expected to have the same indentation.
+	Indent []byte
+	// ID is the goroutine ID of a GoroutineHeader, or the ID of the creator
+	// goroutine of a CreatedBy when printed. It is -1 if it doesn't fit in an
+	// int.
+	ID int
+	// RuntimeInfo is the " gp=0x... m=N mp=0x..." part of a GoroutineHeader,
+	// printed with GOTRACEBACK=system or higher.
+	RuntimeInfo []byte
+	// State is the state of a GoroutineHeader, e.g. "chan receive, 2
+	// minutes".
+	State []byte
+	// Extra is the additional bracketed segments of a GoroutineHeader, without
+	// the brackets.
+	Extra [][]byte
+
+	// Func is the function name of a FuncLine or a CreatedBy.
+	Func []byte
+	// Args is the raw arguments of a FuncLine or a CreatedBy, without the
+	// parentheses.
+	Args []byte
+
+	// Path is the source file of a FileLine.
+	Path []byte
+	// SrcLine is the line number of a FileLine. It is -1 if it doesn't fit in
+	// an int.
+	SrcLine int
+
+	// Value is the panic value of a PanicHeader, or the banner of a
+	// RaceMarker, e.g. "DATA RACE". It is empty for the race separator.
+	Value []byte
+	// Frames is the number of frames elided of a FramesElided, or 0 when not
+	// printed.
+	Frames int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Tokenize classifies line, which must not contain the end of line
+// characters.
+//
+// The kinds are tried from the most to the least specific: Blank,
+// GoroutineHeader, RaceMarker, CreatedBy, FramesElided, FileLine,
+// PanicHeader then FuncLine. It returns an Other token if none matched.
+func Tokenize(line []byte) Token {
+	for _, k := range order {
+		if t, ok := Parse(k, line); ok {
+			return t
+		}
+	}
+	return Token{Kind: Other, Line: line}
+}
+
+// Parse parses line as the kind k. Returns false if it doesn't match.
+//
+// line must not contain the end of line characters.
+func Parse(k Kind, line []byte) (Token, bool) {
+	t := Token{Kind: k, Line: line}
+	switch k {
+	case Other:
+		return t, true
+	case Blank:
+		return t, len(line) == 0
+
+	case PanicHeader:
+		m := rePanicValue.FindSubmatch(line)
+		if m == nil {
+			return Token{}, false
+		}
+		t.Value = m[1]
+
+	case GoroutineHeader:
+		m := reRoutineHeader.FindSubmatch(line)
+		if m == nil {
+			return Token{}, false
+		}
+		t.Indent = m[1]
+		t.ID = atoi(m[2])
+		t.RuntimeInfo = m[3]
+		t.State = m[4]
+		if len(m[5]) != 0 {
+			// " [a] [b]"
+			t.Extra = bytes.Split(m[5][2:len(m[5])-1], []byte("] ["))
+		}
+
+	case FuncLine:
+		m := reFunc.FindSubmatch(line)
+		if m == nil {
+			return Token{}, false
+		}
+		t.Func = m[1]
+		t.Args = m[2]
+
+	case FileLine:
+		m := reFile.FindSubmatch(line)
+		if m == nil {
+			return Token{}, false
+		}
+		t.Path = m[1]
+		t.SrcLine = atoi(m[2])
+
+	case CreatedBy:
+		m := reCreated.FindSubmatch(line)
+		if m == nil {
+			return Token{}, false
+		}
+		t.Func = m[1]
+		t.Args = m[2]
+		if len(m[3]) != 0 {
+			t.ID = atoi(m[3])
+		}
+
+	case FramesElided:
+		if !bytes.Equal(line, framesElided) {
+			m := reFramesElided.FindSubmatch(line)
+			if m == nil {
+				return Token{}, false
+			}
+			t.Frames = atoi(m[1])
+		}
+
+	case RaceMarker:
+		if !bytes.Equal(line, raceSeparator) {
+			m := reRaceHeader.FindSubmatch(line)
+			if m == nil {
+				return Token{}, false
+			}
+			t.Value = m[1]
+		}
+
+	default:
+		return Token{}, false
+	}
+	return t, true
+}
+
+// Tokenizer reads lines from a reader and tokenizes them.
+type Tokenizer struct {
+	r *bufio.Reader
+}
+
+// NewTokenizer returns a Tokenizer reading from r.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return &Tokenizer{r: bufio.NewReader(r)}
+}
+
+// Next returns the token for the next line.
+//
+// It returns io.EOF once the input is exhausted. The last line is returned
+// even if it is not terminated by an end of line.
+func (t *Tokenizer) Next() (Token, error) {
+	l, err := t.r.ReadBytes('\n')
+	if len(l) == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return Token{}, err
+	}
+	l = bytes.TrimSuffix(bytes.TrimSuffix(l, []byte("\n")), []byte("\r"))
+	return Tokenize(l), nil
+}
+
+// Private stuff.
+
+// indent matches the indentation of the source file lines.
+//
+// The runtime prints a tab but log shippers and copy-paste frequently replace
+// it with spaces, so any run of tabs and spaces is accepted. The function
+// lines are never indented so this is not ambiguous.
+const indent = "[\t ]+"
+
+var (
+	framesElided  = []byte("...additional frames elided...")
+	raceSeparator = []byte("==================")
+)
+
+// These are effectively constants.
+var (
+	// A recovered panic value logged along the stack trace, optionally after
+	// the log prefix.
+	rePanicValue = regexp.MustCompile(`^(?:.*?\s)?panic: (.+)$`)
+
+	// With GOTRACEBACK=system or higher, the runtime also prints the g and m
+	// pointers and the m id: "gp=0x... m=N mp=0x..." or "gp=0x... m=nil".
+	// Any such key=value pair is accepted. Additional bracketed segments after
+	// the state are tolerated.
+	reRoutineHeader = regexp.MustCompile("^([ \t]*)goroutine (\\d+)((?: [a-z]+=(?:nil|0x[0-9a-f]+|\\d+))*) \\[([^\\]]+)\\]((?: \\[[^\\]]*\\])*)\\:$")
+
+	// Starting with go1.21, the middle of very deep stacks is elided.
+	reFramesElided = regexp.MustCompile(`^\.\.\.(\d+) frames elided\.\.\.$`)
+
+	// See gentraceback() in src/runtime/traceback.go for more information.
+	// - Sometimes the source file comes up as "<autogenerated>". It is the
+	//   compiler than generated these, not the runtime.
+	// - The tab may be replaced with spaces, see indent.
+	// - "runtime.gopanic" is explicitly replaced with "panic" by gentraceback().
+	// - The +0x123 byte offset is printed when frame.pc > _func.entry. _func is
+	//   generated by the linker.
+	// - The +0x123 byte offset is not included with generated code, e.g. unnamed
+	//   functions "func·006()" which is generally go func() { ... }()
+	//   statements. Since the _func is generated at runtime, it's probably why
+	//   _func.entry is not set.
+	// - C calls may have fp=0x123 sp=0x123 appended. I think it normally happens
+	//   when a signal is not correctly handled. It is printed with m.throwing>0.
+	//   These are discarded.
+	// - For cgo, the source file may be "??".
+	reFile = regexp.MustCompile("^" + indent + "(\\?\\?|\\<autogenerated\\>|.+\\.(?:c|go|s))\\:(\\d+)(?:| \\+0x[0-9a-f]+)(?:| fp=0x[0-9a-f]+ sp=0x[0-9a-f]+(?:| pc=0x[0-9a-f]+))$")
+
+	// Starting with go1.21, the creator goroutine ID is appended. Older
+	// versions do not note it. Some traces include the arguments.
+	reCreated = regexp.MustCompile("^created by (.+?)(?:\\(([^()]*)\\))?(?: in goroutine (\\d+))?$")
+
+	reFunc = regexp.MustCompile(`^(.+)\((.*)\)$`)
+
+	// See https://github.com/llvm/llvm-project/blob/HEAD/compiler-rt/lib/tsan/rtl/tsan_report.cpp
+	// for the code generating these messages. Please note only the block in
+	//   #else  // #if !SANITIZER_GO
+	// is used.
+	//
+	// ThreadSanitizer builds shared with C/C++ print the generic banner, with
+	// the pid in some versions.
+	reRaceHeader = regexp.MustCompile(`^WARNING: (DATA RACE|ThreadSanitizer: data race)(?: \(pid=\d+\))?$`)
+)
+
+// order is the order in which Tokenize tries the kinds.
+var order = []Kind{Blank, GoroutineHeader, RaceMarker, CreatedBy, FramesElided, FileLine, PanicHeader, FuncLine}
+
+// atoi parses a decimal number made of digits only. Returns -1 if it doesn't
+// fit in an int.
+func atoi(s []byte) int {
+	if l := len(s); strconv.IntSize == 32 && (0 < l && l < 10) || strconv.IntSize == 64 && (0 < l && l < 19) {
+		n := 0
+		for _, ch := range s {
+			if ch -= '0'; ch > 9 {
+				return -1
+			}
+			n = n*10 + int(ch)
+		}
+		return n
+	}
+	return -1
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package rawparse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"blank", "", "Blank"},
+		{"other", "exit status 2", "Other"},
+		{"panic", "panic: oh no", "PanicHeader value=\"oh no\""},
+		{"panicLog", "2026/01/02 15:04:05 panic: oh no", "PanicHeader value=\"oh no\""},
+		{"header", "goroutine 1 [running]:", "GoroutineHeader id=1 state=\"running\""},
+		{
+			"headerFull",
+			"  goroutine 7 gp=0xc000007c40 m=nil [chan receive, 2 minutes] [a] [b]:",
+			"GoroutineHeader indent=\"  \" id=7 runtime=\" gp=0xc000007c40 m=nil\" state=\"chan receive, 2 minutes\" extra=[\"a\" \"b\"]",
+		},
+		{"headerOverflow", "goroutine 99999999999999999999 [running]:", "GoroutineHeader id=-1 state=\"running\""},
+		{"func", "main.main()", "FuncLine func=\"main.main\""},
+		{"funcArgs", "main.f(0x1, {0x2, 0x3})", "FuncLine func=\"main.f\" args=\"0x1, {0x2, 0x3}\""},
+		{"file", "\t/home/user/main.go:12 +0x20", "FileLine path=\"/home/user/main.go\" line=12"},
+		{"fileSpaces", "    /home/user/main.go:12", "FileLine path=\"/home/user/main.go\" line=12"},
+		{"created", "created by main.main in goroutine 1", "CreatedBy id=1 func=\"main.main\""},
+		{"createdOld", "created by main.main", "CreatedBy func=\"main.main\""},
+		{"createdArgs", "created by main.f(0x1)", "CreatedBy func=\"main.f\" args=\"0x1\""},
+		{"elided", "...additional frames elided...", "FramesElided"},
+		{"elidedCount", "...5 frames elided...", "FramesElided frames=5"},
+		{"raceSeparator", "==================", "RaceMarker"},
+		{"raceBanner", "WARNING: DATA RACE", "RaceMarker value=\"DATA RACE\""},
+		{"raceTSan", "WARNING: ThreadSanitizer: data race (pid=12)", "RaceMarker value=\"ThreadSanitizer: data race\""},
+	}
+	for i, line := range data {
+		line := line
+		t.Run(fmt.Sprintf("%d-%s", i, line.name), func(t *testing.T) {
+			t.Parallel()
+			tok := Tokenize([]byte(line.line))
+			if string(tok.Line) != line.line {
+				t.Fatalf("Line = %q", tok.Line)
+			}
+			if got := describe(&tok); got != line.want {
+				t.Fatalf("want %s\ngot  %s", line.want, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	if _, ok := Parse(FuncLine, []byte("goroutine 1 [running]:")); ok {
+		t.Fatal("a goroutine header is not a call")
+	}
+	// Other always matches.
+	if tok, ok := Parse(Other, []byte("main.main()")); !ok || tok.Kind != Other {
+		t.Fatal("Other must always match")
+	}
+	if _, ok := Parse(Kind(100), []byte("")); ok {
+		t.Fatal("unknown kind")
+	}
+	if s := Kind(100).String(); s != "Kind(100)" {
+		t.Fatal(s)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	t.Parallel()
+	r := strings.NewReader("panic: oh no\r\n\ngoroutine 1 [running]:\nmain.main()\n\t/home/user/main.go:12 +0x20")
+	tz := NewTokenizer(r)
+	want := []Kind{PanicHeader, Blank, GoroutineHeader, FuncLine, FileLine}
+	for i, k := range want {
+		tok, err := tz.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tok.Kind != k {
+			t.Fatalf("#%d: want %s, got %s", i, k, tok.Kind)
+		}
+	}
+	if _, err := tz.Next(); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+// describe returns the non-empty fields of t.
+func describe(t *Token) string {
+	var b strings.Builder
+	b.WriteString(t.Kind.String())
+	if len(t.Indent) != 0 {
+		fmt.Fprintf(&b, " indent=%q", t.Indent)
+	}
+	if t.Kind == GoroutineHeader || t.ID != 0 {
+		fmt.Fprintf(&b, " id=%d", t.ID)
+	}
+	if len(t.RuntimeInfo) != 0 {
+		fmt.Fprintf(&b, " runtime=%q", t.RuntimeInfo)
+	}
+	if len(t.State) != 0 {
+		fmt.Fprintf(&b, " state=%q", t.State)
+	}
+	if len(t.Extra) != 0 {
+		fmt.Fprintf(&b, " extra=%q", t.Extra)
+	}
+	if len(t.Func) != 0 {
+		fmt.Fprintf(&b, " func=%q", t.Func)
+	}
+	if len(t.Args) != 0 {
+		fmt.Fprintf(&b, " args=%q", t.Args)
+	}
+	if len(t.Path) != 0 {
+		fmt.Fprintf(&b, " path=%q line=%d", t.Path, t.SrcLine)
+	}
+	if len(t.Value) != 0 {
+		fmt.Fprintf(&b, " value=%q", t.Value)
+	}
+	if t.Frames != 0 {
+		fmt.Fprintf(&b, " frames=%d", t.Frames)
+	}
+	return b.String()
+}
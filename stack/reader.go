@@ -101,3 +101,35 @@ func (r *reader) readLine() ([]byte, error) {
 		d = append(d, f...)
 	}
 }
+
+// limitReader returns a reader that fails with a *MaxBytesError after n
+// bytes. n of 0 means no limit.
+func limitReader(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+	return &limitedReader{rd: r, n: n, limit: n}
+}
+
+type limitedReader struct {
+	rd    io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Distinguish an input of exactly the limit from a larger one.
+		var b [1]byte
+		if n, err := l.rd.Read(b[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, &MaxBytesError{Limit: l.limit}
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.rd.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
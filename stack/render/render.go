@@ -0,0 +1,81 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package render implements helpers to render stack traces as text.
+//
+// It is what pp uses, so a custom renderer using these helpers aligns
+// identically. Formatter writes the same console output as pp.
+package render
+
+import (
+	"strconv"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// PathFormat determines how much of the source path to show.
+type PathFormat int
+
+const (
+	// FullPath prints the full path to the source file, preferring the local
+	// path when known.
+	FullPath PathFormat = iota
+	// RelPath prints the path relative to GOROOT, GOPATH or the go module
+	// root, when known. Otherwise it is the same as FullPath.
+	RelPath
+	// BasePath prints only the base name of the source file.
+	BasePath
+)
+
+// FormatCall returns the source file and line of a call.
+func (pf PathFormat) FormatCall(c *stack.Call) string {
+	switch pf {
+	case RelPath:
+		if c.RelSrcPath != "" {
+			return c.RelSrcPath + ":" + strconv.Itoa(c.Line)
+		}
+		fallthrough
+	case FullPath:
+		if c.LocalSrcPath != "" {
+			return c.LocalSrcPath + ":" + strconv.Itoa(c.Line)
+		}
+		return c.RemoteSrcPath + ":" + strconv.Itoa(c.Line)
+	default:
+		return c.SrcName + ":" + strconv.Itoa(c.Line)
+	}
+}
+
+// Measure returns the maximum length of the source lines and package names
+// of all the calls in the buckets.
+//
+// They are the column widths to use to align the calls.
+func Measure(a *stack.Aggregated, pf PathFormat) (srcLen, pkgLen int) {
+	for _, e := range a.Buckets {
+		srcLen, pkgLen = measure(&e.Signature, pf, srcLen, pkgLen)
+	}
+	return srcLen, pkgLen
+}
+
+// MeasureGoroutines is the equivalent of Measure for goroutines that were not
+// aggregated, for example with a race detector trace.
+func MeasureGoroutines(s *stack.Snapshot, pf PathFormat) (srcLen, pkgLen int) {
+	for _, e := range s.Goroutines {
+		srcLen, pkgLen = measure(&e.Signature, pf, srcLen, pkgLen)
+	}
+	return srcLen, pkgLen
+}
+
+// Private stuff.
+
+func measure(s *stack.Signature, pf PathFormat, srcLen, pkgLen int) (int, int) {
+	for i := range s.Stack.Calls {
+		if l := len(pf.FormatCall(&s.Stack.Calls[i])); l > srcLen {
+			srcLen = l
+		}
+		if l := len(s.Stack.Calls[i].Func.DirName); l > pkgLen {
+			pkgLen = l
+		}
+	}
+	return srcLen, pkgLen
+}
@@ -0,0 +1,70 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package render
+
+import (
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestFormatCall(t *testing.T) {
+	t.Parallel()
+	c := stack.Call{
+		RemoteSrcPath: "/remote/go/src/foo/baz.go",
+		LocalSrcPath:  "/home/user/go/src/foo/baz.go",
+		RelSrcPath:    "foo/baz.go",
+		SrcName:       "baz.go",
+		Line:          123,
+	}
+	compareString(t, "/home/user/go/src/foo/baz.go:123", FullPath.FormatCall(&c))
+	compareString(t, "foo/baz.go:123", RelPath.FormatCall(&c))
+	compareString(t, "baz.go:123", BasePath.FormatCall(&c))
+	c.LocalSrcPath = ""
+	c.RelSrcPath = ""
+	compareString(t, "/remote/go/src/foo/baz.go:123", FullPath.FormatCall(&c))
+	compareString(t, "/remote/go/src/foo/baz.go:123", RelPath.FormatCall(&c))
+}
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+	f := stack.Func{}
+	if err := f.Init("main.func·001"); err != nil {
+		t.Fatal(err)
+	}
+	sig := stack.Signature{
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				{Func: f, RemoteSrcPath: "/home/user/go/src/foo/baz.go", SrcName: "baz.go", Line: 123},
+			},
+		},
+	}
+	a := stack.Aggregated{Buckets: []*stack.Bucket{{Signature: sig, IDs: []int{1}, First: true}}}
+	srcLen, pkgLen := Measure(&a, FullPath)
+	compareInt(t, len("/home/user/go/src/foo/baz.go:123"), srcLen)
+	compareInt(t, len("main"), pkgLen)
+	srcLen, pkgLen = Measure(&a, BasePath)
+	compareInt(t, len("baz.go:123"), srcLen)
+	compareInt(t, len("main"), pkgLen)
+
+	s := stack.Snapshot{Goroutines: []*stack.Goroutine{{Signature: sig, ID: 1, First: true}}}
+	srcLen, pkgLen = MeasureGoroutines(&s, BasePath)
+	compareInt(t, len("baz.go:123"), srcLen)
+	compareInt(t, len("main"), pkgLen)
+}
+
+func compareString(t *testing.T, want, got string) {
+	if want != got {
+		t.Helper()
+		t.Fatalf("%q != %q", want, got)
+	}
+}
+
+func compareInt(t *testing.T, want, got int) {
+	if want != got {
+		t.Helper()
+		t.Fatalf("%d != %d", want, got)
+	}
+}
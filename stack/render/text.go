@@ -0,0 +1,456 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package render
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Formatter writes stack traces as text, like pp does on the console.
+type Formatter struct {
+	// Palette is the colors to use. When nil, colors are disabled.
+	Palette *Palette
+	// PathFormat determines how much of the source path is printed. pp uses
+	// BasePath by default.
+	PathFormat PathFormat
+	// LineOpts are the options to format the calls.
+	LineOpts LineOpts
+	// NoAlign disables the alignment of the package and source columns.
+	NoAlign bool
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// WriteAggregated writes each bucket of a, as a header followed by the calls.
+func (f *Formatter) WriteAggregated(w io.Writer, a *stack.Aggregated) error {
+	p := f.palette()
+	srcLen, pkgLen := 0, 0
+	if !f.NoAlign {
+		srcLen, pkgLen = Measure(a, f.PathFormat)
+	}
+	multi := len(a.Buckets) > 1
+	for _, b := range a.Buckets {
+		s := p.BucketHeader(b, f.PathFormat, multi, nil) + p.StackLines(&b.Signature, srcLen, pkgLen, f.PathFormat, &f.LineOpts, b.First)
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGoroutines writes each goroutine of s without aggregating them, like
+// pp does for a data race.
+func (f *Formatter) WriteGoroutines(w io.Writer, s *stack.Snapshot) error {
+	p := f.palette()
+	srcLen, pkgLen := 0, 0
+	if !f.NoAlign {
+		srcLen, pkgLen = MeasureGoroutines(s, f.PathFormat)
+	}
+	multi := len(s.Goroutines) > 1
+	for _, g := range s.Goroutines {
+		l := p.GoroutineHeader(g, f.PathFormat, multi, false, false) + p.StackLines(&g.Signature, srcLen, pkgLen, f.PathFormat, &f.LineOpts, g.First)
+		if _, err := io.WriteString(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Formatter) palette() *Palette {
+	if f.Palette == nil {
+		return &Palette{}
+	}
+	return f.Palette
+}
+
+// Palette defines the color used.
+//
+// The values are written as-is before each element, normally ANSI escape
+// codes, e.g. as generated with github.com/mgutz/ansi. An empty object
+// Palette{} can be used to disable coloring.
+type Palette struct {
+	EOLReset string
+
+	// Routine header.
+	RoutineFirst string // The first routine printed.
+	Routine      string // Following routines.
+	CreatedBy    string
+	Race         string
+	Deadlock     string // Probable deadlock cycles.
+
+	// Call line.
+	Package                     string
+	SrcFile                     string
+	FuncMain                    string
+	FuncLocationUnknown         string
+	FuncLocationUnknownExported string
+	FuncGoMod                   string
+	FuncGoModExported           string
+	FuncGOPATH                  string
+	FuncGOPATHExported          string
+	FuncGoPkg                   string
+	FuncGoPkgExported           string
+	FuncStdLib                  string
+	FuncStdLibExported          string
+	Arguments                   string
+
+	// Panic boundary in the first goroutine. When empty, the color above is
+	// used.
+	FuncPanicking  string // The call that called panic().
+	FuncAbovePanic string // The calls above panic().
+}
+
+// createdByString returns the description of the creator of a goroutine.
+func createdByString(s *stack.Signature, pf PathFormat) string {
+	if s.CreatedBy.Unavailable {
+		return "unknown, failed to restore the stack"
+	}
+	if len(s.CreatedBy.Calls) == 0 {
+		return ""
+	}
+	return s.CreatedBy.Calls[0].Func.DirName + "." + s.CreatedBy.Calls[0].Func.Name + " @ " + pf.FormatCall(&s.CreatedBy.Calls[0])
+}
+
+// FunctionColor returns the color to be used for the function name based on
+// the type of package the function is in.
+func (p *Palette) FunctionColor(c *stack.Call) string {
+	return p.FuncColor(c.Location, c.Func.IsPkgMain, c.Func.IsExported)
+}
+
+// FuncColor returns the color to be used for a function name in a package at
+// location l.
+func (p *Palette) FuncColor(l stack.Location, main, exported bool) string {
+	if main {
+		return p.FuncMain
+	}
+	switch l {
+	default:
+		fallthrough
+	case stack.LocationUnknown:
+		if exported {
+			return p.FuncLocationUnknownExported
+		}
+		return p.FuncLocationUnknown
+	case stack.GoMod:
+		if exported {
+			return p.FuncGoModExported
+		}
+		return p.FuncGoMod
+	case stack.GOPATH:
+		if exported {
+			return p.FuncGOPATHExported
+		}
+		return p.FuncGOPATH
+	case stack.GoPkg:
+		if exported {
+			return p.FuncGoPkgExported
+		}
+		return p.FuncGoPkg
+	case stack.Stdlib:
+		if exported {
+			return p.FuncStdLibExported
+		}
+		return p.FuncStdLib
+	}
+}
+
+// RoutineColor returns the color for the header of the goroutines bucket.
+func (p *Palette) RoutineColor(first, multipleBuckets bool) string {
+	if first && multipleBuckets {
+		return p.RoutineFirst
+	}
+	return p.Routine
+}
+
+// BucketHeader prints the header of a goroutine signature.
+//
+// ms is the list of OS thread ids the goroutines are running on, if any.
+func (p *Palette) BucketHeader(b *stack.Bucket, pf PathFormat, multipleBuckets bool, ms []int) string {
+	extra := ""
+	if s := b.SleepString(); s != "" {
+		extra += " [" + s + "]"
+	}
+	if b.Locked {
+		extra += " [locked]"
+	}
+	for _, e := range b.Extra {
+		extra += " [" + e + "]"
+	}
+	if len(b.Labels) != 0 {
+		extra += " [" + labelsString(b.Labels) + "]"
+	}
+	if b.OnSystemStack {
+		extra += " [system stack]"
+	}
+	if b.Existing != nil {
+		extra += fmt.Sprintf(" [%d existed, %d new]", len(b.Existing), len(b.IDs)-len(b.Existing))
+	}
+	for _, s := range b.Suspicions {
+		extra += " [" + s.Severity.String() + ": " + s.Explanation + "]"
+	}
+	if len(ms) != 0 {
+		s := make([]string, len(ms))
+		for i, m := range ms {
+			s[i] = strconv.Itoa(m)
+		}
+		extra += " [m=" + strings.Join(s, ",") + "]"
+	}
+	if c := createdByString(&b.Signature, pf); c != "" {
+		extra += p.CreatedBy + " [Created by " + c + "]"
+	}
+	return fmt.Sprintf(
+		"%s%d: %s%s%s\n",
+		p.RoutineColor(b.First, multipleBuckets), len(b.IDs),
+		b.State, extra,
+		p.EOLReset)
+}
+
+// labelsString returns the labels sorted by key, e.g. "team=payments tier=1".
+func labelsString(labels map[string]string) string {
+	out := make([]string, 0, len(labels))
+	for k, v := range labels {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return strings.Join(out, " ")
+}
+
+// GoroutineHeader prints the header of a goroutine.
+//
+// If showM is true, the OS thread id is printed when known. If verbose is true,
+// the raw runtime values printed in the original header are printed.
+func (p *Palette) GoroutineHeader(g *stack.Goroutine, pf PathFormat, multipleGoroutines, showM, verbose bool) string {
+	extra := ""
+	if s := g.SleepString(); s != "" {
+		extra += " [" + s + "]"
+	}
+	if g.Locked {
+		extra += " [locked]"
+	}
+	for _, e := range g.Extra {
+		extra += " [" + e + "]"
+	}
+	if len(g.Labels) != 0 {
+		extra += " [" + labelsString(g.Labels) + "]"
+	}
+	if g.OnSystemStack {
+		extra += " [system stack]"
+	}
+	if showM && g.MP != 0 {
+		extra += " [m=" + strconv.Itoa(g.M) + "]"
+	}
+	if verbose && len(g.RuntimeInfo) != 0 {
+		extra += " [" + runtimeInfoString(g.RuntimeInfo) + "]"
+	}
+	if c := createdByString(&g.Signature, pf); c != "" {
+		extra += p.CreatedBy + " [Created by " + c + "]"
+	}
+	if g.RaceAddr != 0 {
+		r := "read"
+		if g.RaceWrite {
+			r = "write"
+		}
+		extra += fmt.Sprintf("%s%s Race %s @ 0x%08x", p.EOLReset, p.Race, r, g.RaceAddr)
+	}
+	return fmt.Sprintf(
+		"%s%d: %s%s%s\n",
+		p.RoutineColor(g.First, multipleGoroutines), g.ID,
+		g.State, extra,
+		p.EOLReset)
+}
+
+// RuntimeInfoLine prints the raw runtime values of one goroutine of a bucket.
+//
+// Returns an empty string if there is none.
+func (p *Palette) RuntimeInfoLine(g *stack.Goroutine) string {
+	if len(g.RuntimeInfo) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("    #%d %s\n", g.ID, runtimeInfoString(g.RuntimeInfo))
+}
+
+// runtimeIDs are the runtime header keys that are ids, not pointers.
+var runtimeIDs = map[string]bool{"m": true, "lockedm": true, "lockedg": true}
+
+// runtimeInfoString returns the key=value pairs sorted by key.
+func runtimeInfoString(ri map[string]uint64) string {
+	keys := make([]string, 0, len(ri))
+	for k := range ri {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		if runtimeIDs[k] {
+			out[i] = k + "=" + strconv.FormatUint(ri[k], 10)
+		} else {
+			out[i] = fmt.Sprintf("%s=0x%x", k, ri[k])
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// LineOpts are the options to format the calls of a stack trace.
+type LineOpts struct {
+	// AnnotateDefer annotates the calls executed as part of a deferred
+	// function call.
+	AnnotateDefer bool
+	// NoStdlibArgs omits the arguments of the calls in the standard library.
+	// They are rarely useful and make the lines much longer.
+	NoStdlibArgs bool
+	// ArgsLimits limits how much of the aggregate arguments are printed.
+	ArgsLimits stack.ArgsLimits
+	// MarkUncertain appends "?" to the arguments rebuilt from the sources
+	// whose value is uncertain. See stack.Uncertain.
+	MarkUncertain bool
+	// Blame returns the annotation for a call, e.g. the last commit that
+	// modified the line. When nil, calls are not annotated.
+	//
+	// Only the culprit call of the first goroutine is annotated, unless
+	// BlameAll is set, in which case all the calls outside the standard
+	// library are.
+	Blame    func(c *stack.Call) string
+	BlameAll bool
+	// AnnotateCall returns the annotation to append to each call line, e.g.
+	// its owner or a link to an internal code search. When nil or when it
+	// returns an empty string, the call is not annotated.
+	AnnotateCall func(c *stack.Call) string
+}
+
+// formatArgs returns the arguments of a call as printed on the call line.
+func formatArgs(a *stack.Args, lo *LineOpts) string {
+	if !lo.MarkUncertain || len(a.Processed) == 0 {
+		return a.Format(&lo.ArgsLimits)
+	}
+	m := stack.Args{Processed: make([]string, len(a.Processed)), Elided: a.Elided}
+	for i, p := range a.Processed {
+		if i < len(a.Confidence) && a.Confidence[i] == stack.Uncertain {
+			p += "?"
+		}
+		m.Processed[i] = p
+	}
+	return m.Format(&lo.ArgsLimits)
+}
+
+// callLine prints one stack line.
+func (p *Palette) callLine(line *stack.Call, srcLen, pkgLen int, pf PathFormat, lo *LineOpts, funcColor string, deferred bool) string {
+	suffix := ""
+	if deferred {
+		suffix = " [deferred]"
+	}
+	if line.Inlined {
+		suffix += " [inlined]"
+	}
+	args := formatArgs(&line.Args, lo)
+	if lo.NoStdlibArgs && line.Location == stack.Stdlib && args != "" {
+		args = "..."
+	}
+	if lo.AnnotateCall != nil {
+		if a := lo.AnnotateCall(line); a != "" {
+			suffix += " " + a
+		}
+	}
+	return fmt.Sprintf(
+		"    %s%-*s %s%-*s %s%s%s(%s)%s%s",
+		p.Package, pkgLen, line.Func.DirName,
+		p.SrcFile, srcLen, pf.FormatCall(line),
+		funcColor, line.Func.Name,
+		p.Arguments, args, suffix,
+		p.EOLReset)
+}
+
+// StackLines prints one complete stack trace, without the header.
+//
+// first must be true for the goroutine that panicked, so the calls around the
+// panic boundary are highlighted.
+func (p *Palette) StackLines(signature *stack.Signature, srcLen, pkgLen int, pf PathFormat, lo *LineOpts, first bool) string {
+	var deferred []bool
+	if lo.AnnotateDefer {
+		deferred = signature.Stack.Deferred()
+	}
+	panicIndex := -1
+	if first {
+		panicIndex = signature.Stack.PanicIndex()
+	}
+	out := make([]string, len(signature.Stack.Calls))
+	for i := range signature.Stack.Calls {
+		c := p.FunctionColor(&signature.Stack.Calls[i])
+		if i < panicIndex && p.FuncAbovePanic != "" {
+			c = p.FuncAbovePanic
+		} else if panicIndex != -1 && i == panicIndex+1 && p.FuncPanicking != "" {
+			c = p.FuncPanicking
+		}
+		out[i] = p.callLine(&signature.Stack.Calls[i], srcLen, pkgLen, pf, lo, c, deferred != nil && deferred[i])
+	}
+	if lo.Blame != nil {
+		culprit := -1
+		if first {
+			culprit = signature.Stack.CulpritIndex()
+		}
+		for i := range signature.Stack.Calls {
+			c := &signature.Stack.Calls[i]
+			if i != culprit && (!lo.BlameAll || c.Location == stack.Stdlib) {
+				continue
+			}
+			if b := lo.Blame(c); b != "" {
+				out[i] += p.CreatedBy + " [" + b + "]" + p.EOLReset
+			}
+		}
+	}
+	if signature.Stack.Elided {
+		out = append(out, "    (...)")
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// GrepLines prints the calls matching re with one call of context above and
+// below, without the header.
+//
+// A call matches if either its fully qualified function name or its source
+// location matches. The matching calls are prefixed with ">". Returns an empty
+// string if no call matched.
+func (p *Palette) GrepLines(signature *stack.Signature, re *regexp.Regexp, srcLen, pkgLen int, pf PathFormat, lo *LineOpts) string {
+	calls := signature.Stack.Calls
+	matched := make([]bool, len(calls))
+	shown := make([]bool, len(calls))
+	found := false
+	for i := range calls {
+		if re.MatchString(calls[i].Func.Complete) || re.MatchString(pf.FormatCall(&calls[i])) {
+			found = true
+			matched[i] = true
+			for j := i - 1; j <= i+1; j++ {
+				if j >= 0 && j < len(calls) {
+					shown[j] = true
+				}
+			}
+		}
+	}
+	if !found {
+		return ""
+	}
+	var out []string
+	for i := range calls {
+		if !shown[i] {
+			continue
+		}
+		if i > 0 && !shown[i-1] && len(out) != 0 {
+			out = append(out, "    --")
+		}
+		l := p.callLine(&calls[i], srcLen, pkgLen, pf, lo, p.FunctionColor(&calls[i]), false)
+		if matched[i] {
+			l = "  >" + l[3:]
+		}
+		out = append(out, l)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
@@ -0,0 +1,398 @@
+// Copyright 2016 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package render
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+var testPalette = &Palette{
+	EOLReset:                    "A",
+	RoutineFirst:                "B",
+	Routine:                     "C",
+	CreatedBy:                   "D",
+	Package:                     "E",
+	SrcFile:                     "F",
+	FuncMain:                    "G",
+	FuncLocationUnknown:         "H",
+	FuncLocationUnknownExported: "I",
+	FuncGoMod:                   "J",
+	FuncGoModExported:           "K",
+	FuncGOPATH:                  "L",
+	FuncGOPATHExported:          "M",
+	FuncGoPkg:                   "N",
+	FuncGoPkgExported:           "O",
+	FuncStdLib:                  "P",
+	FuncStdLibExported:          "Q",
+	Arguments:                   "R",
+	FuncPanicking:               "S",
+	FuncAbovePanic:              "T",
+}
+
+func TestBucketHeader(t *testing.T) {
+	t.Parallel()
+	b := stack.Bucket{
+		Signature: stack.Signature{
+			State: "chan receive",
+			CreatedBy: stack.Stack{
+				Calls: []stack.Call{
+					newCallLocal("main.mainImpl", stack.Args{}, "/home/user/go/src/github.com/foo/bar/baz.go", 74),
+				},
+			},
+			SleepMax: 6,
+			SleepMin: 2,
+		},
+		IDs:   []int{1, 2},
+		First: true,
+	}
+	// When printing, it prints the remote path, not the transposed local path.
+	compareString(t, "B2: chan receive [2~6 minutes]D [Created by main.mainImpl @ /home/user/go/src/github.com/foo/bar/baz.go:74]A\n", testPalette.BucketHeader(&b, FullPath, true, nil))
+	compareString(t, "C2: chan receive [2~6 minutes]D [Created by main.mainImpl @ /home/user/go/src/github.com/foo/bar/baz.go:74]A\n", testPalette.BucketHeader(&b, FullPath, false, nil))
+	compareString(t, "B2: chan receive [2~6 minutes]D [Created by main.mainImpl @ github.com/foo/bar/baz.go:74]A\n", testPalette.BucketHeader(&b, RelPath, true, nil))
+	compareString(t, "C2: chan receive [2~6 minutes]D [Created by main.mainImpl @ github.com/foo/bar/baz.go:74]A\n", testPalette.BucketHeader(&b, RelPath, false, nil))
+	compareString(t, "B2: chan receive [2~6 minutes]D [Created by main.mainImpl @ baz.go:74]A\n", testPalette.BucketHeader(&b, BasePath, true, nil))
+	compareString(t, "C2: chan receive [2~6 minutes]D [Created by main.mainImpl @ baz.go:74]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+
+	b = stack.Bucket{
+		Signature: stack.Signature{
+			State:    "b0rked",
+			SleepMax: 6,
+			SleepMin: 6,
+			Locked:   true,
+		},
+		IDs:   []int{},
+		First: true,
+	}
+	compareString(t, "C0: b0rked [6 minutes] [locked]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+	compareString(t, "C0: b0rked [6 minutes] [locked] [m=0,3]A\n", testPalette.BucketHeader(&b, BasePath, false, []int{0, 3}))
+	b.Extra = []string{"dedicated"}
+	compareString(t, "C0: b0rked [6 minutes] [locked] [dedicated]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+	b.OnSystemStack = true
+	compareString(t, "C0: b0rked [6 minutes] [locked] [dedicated] [system stack]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+	b.OnSystemStack = false
+	b.Labels = map[string]string{"tier": "1", "team": "payments"}
+	compareString(t, "C0: b0rked [6 minutes] [locked] [dedicated] [team=payments tier=1]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+	b.Labels = nil
+	b.IDs = []int{1, 2}
+	b.Existing = []int{1}
+	compareString(t, "C2: b0rked [6 minutes] [locked] [dedicated] [1 existed, 1 new]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+	b.Existing = nil
+	b.Suspicions = []stack.Suspicion{{Severity: stack.SeverityHigh, Explanation: "blocked forever on a nil channel"}}
+	compareString(t, "C2: b0rked [6 minutes] [locked] [dedicated] [high: blocked forever on a nil channel]A\n", testPalette.BucketHeader(&b, BasePath, false, nil))
+}
+
+func TestFormatter(t *testing.T) {
+	t.Parallel()
+	in := strings.Join([]string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:5 +0x13",
+		"",
+		"goroutine 2 [chan receive]:",
+		"main.foo()",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 3 [chan receive]:",
+		"main.foo()",
+		"\t/a/main.go:9 +0x13",
+		"",
+		"goroutine 4 [select]:",
+		"github.com/foo/bar.Baz()",
+		"\t/a/bar/baz.go:12 +0x13",
+		"",
+	}, "\n")
+	opts := stack.DefaultOpts()
+	opts.GuessPaths = false
+	opts.AnalyzeSources = false
+	s, _, err := stack.ScanSnapshot(strings.NewReader(in), io.Discard, opts)
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	f := Formatter{PathFormat: BasePath}
+	b := strings.Builder{}
+	if err = f.WriteAggregated(&b, s.Aggregate(stack.AnyPointer)); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"1: running\n" +
+		"    main main.go:5 main()\n" +
+		"2: chan receive\n" +
+		"    main main.go:9 foo()\n" +
+		"1: select\n" +
+		"    bar  baz.go:12 Baz()\n"
+	compareString(t, want, b.String())
+
+	b.Reset()
+	f = Formatter{Palette: testPalette, PathFormat: RelPath, NoAlign: true}
+	s.Goroutines = s.Goroutines[2:]
+	if err = f.WriteGoroutines(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	want = "" +
+		"C3: chan receiveA\n" +
+		"    Emain F/a/main.go:9 GfooR()A\n" +
+		"C4: selectA\n" +
+		"    Ebar F/a/bar/baz.go:12 IBazR()A\n"
+	compareString(t, want, b.String())
+}
+
+func TestFormatArgs(t *testing.T) {
+	t.Parallel()
+	a := stack.Args{
+		Values:     []stack.Arg{{Value: 1}, {Value: 2, IsInaccurate: true}},
+		Processed:  []string{"1", "2"},
+		Confidence: []stack.Confidence{stack.Exact, stack.Uncertain},
+		Elided:     true,
+	}
+	compareString(t, "1, 2, ...", formatArgs(&a, &LineOpts{}))
+	compareString(t, "1, 2?, ...", formatArgs(&a, &LineOpts{MarkUncertain: true}))
+}
+
+func TestGoroutineHeader(t *testing.T) {
+	t.Parallel()
+	g := stack.Goroutine{
+		Signature: stack.Signature{State: "running", Locked: true},
+		ID:        1,
+		First:     true,
+		M:         3,
+		MP:        0xc000080008,
+	}
+	compareString(t, "C1: running [locked]A\n", testPalette.GoroutineHeader(&g, BasePath, false, false, false))
+	compareString(t, "C1: running [locked] [m=3]A\n", testPalette.GoroutineHeader(&g, BasePath, false, true, false))
+	compareString(t, "", testPalette.RuntimeInfoLine(&g))
+	g.RuntimeInfo = map[string]uint64{"mp": 0xc000080008, "m": 3, "gp": 0xc000002380}
+	compareString(t, "C1: running [locked] [gp=0xc000002380 m=3 mp=0xc000080008]A\n", testPalette.GoroutineHeader(&g, BasePath, false, false, true))
+	compareString(t, "    #1 gp=0xc000002380 m=3 mp=0xc000080008\n", testPalette.RuntimeInfoLine(&g))
+	g.MP = 0
+	compareString(t, "C1: running [locked]A\n", testPalette.GoroutineHeader(&g, BasePath, false, true, false))
+	g.CreatedBy.Unavailable = true
+	compareString(t, "C1: running [locked]D [Created by unknown, failed to restore the stack]A\n", testPalette.GoroutineHeader(&g, BasePath, false, true, false))
+}
+
+func TestStackLinesBlame(t *testing.T) {
+	t.Parallel()
+	s := &stack.Signature{
+		State: "running",
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				newCallLocal("panic", stack.Args{}, "/goroot/src/runtime/panic.go", 5),
+				newCallLocal("main.a", stack.Args{}, "/home/user/go/src/main.go", 10),
+				newCallLocal("main.main", stack.Args{}, "/home/user/go/src/main.go", 20),
+			},
+		},
+	}
+	lo := LineOpts{Blame: func(c *stack.Call) string { return "blame " + c.Func.Name }}
+	want := "" +
+		"    E           Fpanic.go:5 PpanicR()A\n" +
+		"    Emain       Fmain.go:10 SaR()AD [blame a]A\n" +
+		"    Emain       Fmain.go:20 GmainR()A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &lo, true))
+	lo.BlameAll = true
+	want = "" +
+		"    E           Fpanic.go:5 PpanicR()A\n" +
+		"    Emain       Fmain.go:10 SaR()AD [blame a]A\n" +
+		"    Emain       Fmain.go:20 GmainR()AD [blame main]A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &lo, true))
+}
+
+func TestStackLinesAnnotateCall(t *testing.T) {
+	t.Parallel()
+	s := &stack.Signature{
+		State: "running",
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				newCallLocal("panic", stack.Args{}, "/goroot/src/runtime/panic.go", 5),
+				newCallLocal("main.main", stack.Args{}, "/home/user/go/src/main.go", 20),
+			},
+		},
+	}
+	lo := LineOpts{AnnotateCall: func(c *stack.Call) string {
+		if c.Location == stack.Stdlib {
+			return ""
+		}
+		return "https://cs.example.com/" + c.SrcName + "#L" + strconv.Itoa(c.Line)
+	}}
+	want := "" +
+		"    E           Fpanic.go:5 PpanicR()A\n" +
+		"    Emain       Fmain.go:20 GmainR() https://cs.example.com/main.go#L20A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &lo, false))
+}
+
+func TestGrepLines(t *testing.T) {
+	t.Parallel()
+	s := &stack.Signature{
+		State: "idle",
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				newCallLocal("runtime.Epollwait", stack.Args{}, "/goroot/src/runtime/sys_linux_amd64.s", 400),
+				newCallLocal("runtime.netpoll", stack.Args{}, "/goroot/src/runtime/netpoll_epoll.go", 68),
+				newCallLocal("main.Main", stack.Args{}, "/home/user/go/src/main.go", 1472),
+				newCallLocal("foo.OtherExported", stack.Args{}, "/home/user/go/src/foo/bar.go", 1575),
+				newCallLocal("foo.otherPrivate", stack.Args{}, "/home/user/go/src/foo/bar.go", 10),
+			},
+		},
+	}
+	want := "" +
+		"  > Eruntime    Fsys_linux_amd64.s:400 QEpollwaitR()A\n" +
+		"    Eruntime    Fnetpoll_epoll.go:68 PnetpollR()A\n" +
+		"    --\n" +
+		"    Efoo        Fbar.go:1575 MOtherExportedR()A\n" +
+		"  > Efoo        Fbar.go:10  LotherPrivateR()A\n"
+	compareString(t, want, testPalette.GrepLines(s, regexp.MustCompile(`Epollwait|otherPrivate`), 10, 10, BasePath, &LineOpts{}))
+	// Matches on the source location too.
+	want = "" +
+		"    Eruntime    Fnetpoll_epoll.go:68 PnetpollR()A\n" +
+		"  > Emain       Fmain.go:1472 GMainR()A\n" +
+		"    Efoo        Fbar.go:1575 MOtherExportedR()A\n"
+	compareString(t, want, testPalette.GrepLines(s, regexp.MustCompile(`main\.go:`), 10, 10, BasePath, &LineOpts{}))
+	compareString(t, "", testPalette.GrepLines(s, regexp.MustCompile(`nothing`), 10, 10, BasePath, &LineOpts{}))
+}
+
+func TestStackLines(t *testing.T) {
+	t.Parallel()
+	s := &stack.Signature{
+		State: "idle",
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				newCallLocal(
+					"runtime.Epollwait",
+					stack.Args{
+						Values: []stack.Arg{
+							{Value: 4},
+							{Value: 0x7fff671c7118},
+							{Value: 0xffffffff00000080},
+							{},
+							{Value: 0xffffffff0028c1be},
+							{},
+							{},
+							{},
+							{},
+							{},
+						},
+						Elided: true,
+					},
+					"/goroot/src/runtime/sys_linux_amd64.s",
+					400),
+				newCallLocal(
+					"runtime.netpoll",
+					stack.Args{Values: []stack.Arg{{Value: 0x901b01}, {}}},
+					"/goroot/src/runtime/netpoll_epoll.go",
+					68),
+				newCallLocal(
+					"main.Main",
+					stack.Args{Values: []stack.Arg{{Value: 0xc208012000}}},
+					"/home/user/go/src/main.go",
+					1472),
+				newCallLocal(
+					"foo.OtherExported",
+					stack.Args{},
+					"/home/user/go/src/foo/bar.go",
+					1575),
+				newCallLocal(
+					"foo.otherPrivate",
+					stack.Args{},
+					"/home/user/go/src/foo/bar.go",
+					10),
+			},
+			Elided: true,
+		},
+	}
+	// When printing, it prints the remote path, not the transposed local path.
+	want := "" +
+		"    Eruntime    F/goroot/src/runtime/sys_linux_amd64.s:400 QEpollwaitR(4, 0x7fff671c7118, 0xffffffff00000080, 0, 0xffffffff0028c1be, 0, 0, 0, 0, 0, ...)A\n" +
+		"    Eruntime    F/goroot/src/runtime/netpoll_epoll.go:68 PnetpollR(0x901b01, 0)A\n" +
+		"    Emain       F/home/user/go/src/main.go:1472 GMainR(0xc208012000)A\n" +
+		"    Efoo        F/home/user/go/src/foo/bar.go:1575 MOtherExportedR()A\n" +
+		"    Efoo        F/home/user/go/src/foo/bar.go:10 LotherPrivateR()A\n" +
+		"    (...)\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, FullPath, &LineOpts{}, false))
+	want = "" +
+		"    Eruntime    Fsys_linux_amd64.s:400 QEpollwaitR(4, 0x7fff671c7118, 0xffffffff00000080, 0, 0xffffffff0028c1be, 0, 0, 0, 0, 0, ...)A\n" +
+		"    Eruntime    Fnetpoll_epoll.go:68 PnetpollR(0x901b01, 0)A\n" +
+		"    Emain       Fmain.go:1472 GMainR(0xc208012000)A\n" +
+		"    Efoo        Fbar.go:1575 MOtherExportedR()A\n" +
+		"    Efoo        Fbar.go:10  LotherPrivateR()A\n" +
+		"    (...)\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &LineOpts{}, false))
+	want = "" +
+		"    Eruntime    Fsys_linux_amd64.s:400 QEpollwaitR(...)A\n" +
+		"    Eruntime    Fnetpoll_epoll.go:68 PnetpollR(...)A\n" +
+		"    Emain       Fmain.go:1472 GMainR(0xc208012000)A\n" +
+		"    Efoo        Fbar.go:1575 MOtherExportedR()A\n" +
+		"    Efoo        Fbar.go:10  LotherPrivateR()A\n" +
+		"    (...)\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &LineOpts{NoStdlibArgs: true}, false))
+
+	s = &stack.Signature{
+		State: "running",
+		Stack: stack.Stack{
+			Calls: []stack.Call{
+				newCallLocal("main.main.func1", stack.Args{}, "/home/user/go/src/main.go", 5),
+				newCallLocal("panic", stack.Args{}, "/goroot/src/runtime/panic.go", 770),
+				newCallLocal("main.main", stack.Args{}, "/home/user/go/src/main.go", 8),
+			},
+		},
+	}
+	want = "" +
+		"    Emain       Fmain.go:5  Gmain.func1R() [deferred]A\n" +
+		"    E           Fpanic.go:770 PpanicR()A\n" +
+		"    Emain       Fmain.go:8  GmainR()A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &LineOpts{AnnotateDefer: true}, false))
+	want = "" +
+		"    Emain       Fmain.go:5  Tmain.func1R()A\n" +
+		"    E           Fpanic.go:770 PpanicR()A\n" +
+		"    Emain       Fmain.go:8  SmainR()A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &LineOpts{}, true))
+
+	s.Stack.Calls[0].Inlined = true
+	want = "" +
+		"    Emain       Fmain.go:5  Gmain.func1R() [inlined]A\n" +
+		"    E           Fpanic.go:770 PpanicR()A\n" +
+		"    Emain       Fmain.go:8  GmainR()A\n"
+	compareString(t, want, testPalette.StackLines(s, 10, 10, BasePath, &LineOpts{}, false))
+}
+
+//
+
+func newFunc(s string) stack.Func {
+	f := stack.Func{}
+	if err := f.Init(s); err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func newCallLocal(f string, a stack.Args, s string, l int) stack.Call {
+	c := stack.Call{Func: newFunc(f), Args: a, RemoteSrcPath: s, Line: l}
+	// Do the equivalent of Call.init().
+	c.SrcName = filepath.Base(c.RemoteSrcPath)
+	c.DirSrc = path.Join(filepath.Base(c.RemoteSrcPath[:len(c.RemoteSrcPath)-len(c.SrcName)-1]), c.SrcName)
+	const goroot = "/goroot/src/"
+	const gopath = "/home/user/go/src/"
+	const gopathmod = "/home/user/go/pkg/mod/"
+	// Do the equivalent of Call.updateLocations().
+	if strings.HasPrefix(s, goroot) {
+		c.LocalSrcPath = s
+		c.RelSrcPath = s[len(goroot):]
+		c.Location = stack.Stdlib
+	} else if strings.HasPrefix(s, gopath) {
+		c.LocalSrcPath = s
+		c.RelSrcPath = s[len(gopath):]
+		c.Location = stack.GOPATH
+	} else if strings.HasPrefix(s, gopathmod) {
+		c.LocalSrcPath = s
+		c.RelSrcPath = s[len(gopathmod):]
+		c.Location = stack.GoPkg
+	}
+	return c
+}
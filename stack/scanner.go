@@ -0,0 +1,311 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Scanner incrementally parses stack traces from data that is provided in
+// chunks, like a log file that keeps growing.
+//
+// Unlike ScanSnapshot, it keeps the parsing state and the incomplete trailing
+// line across calls to Scan, so the data never has to be read twice.
+type Scanner struct {
+	prefix  io.Writer
+	opts    *Opts
+	s       scanningState
+	partial []byte
+	sched   []*SchedSample
+}
+
+// NewScanner returns a Scanner.
+//
+// Anything not detected as a stack trace is written to prefix, which can be
+// io.Discard.
+func NewScanner(prefix io.Writer, opts *Opts) (*Scanner, error) {
+	if opts == nil || !opts.isValid() {
+		return nil, errors.New("invalid Opts")
+	}
+	sc := &Scanner{prefix: prefix, opts: opts}
+	sc.reset()
+	return sc, nil
+}
+
+// Scan processes more data and returns the snapshots that were completed.
+//
+// A snapshot is only known to be complete once the line following it was
+// received. Call Flush once the stream is known to be finished to retrieve the
+// last one.
+//
+// Like ScanSnapshot, a snapshot is returned even if an error occurred while
+// parsing it.
+func (sc *Scanner) Scan(moreData []byte) ([]*Snapshot, error) {
+	sc.partial = append(sc.partial, moreData...)
+	var out []*Snapshot
+	var err error
+	d := sc.partial
+	for {
+		i := bytes.IndexByte(d, '\n')
+		if i == -1 {
+			break
+		}
+		s, err1 := sc.scanLine(d[:i+1])
+		if s != nil {
+			out = append(out, s)
+		}
+		if err1 != nil && err == nil {
+			err = err1
+		}
+		d = d[i+1:]
+	}
+	// Keep the incomplete line for the next call. Copy it to not hold onto
+	// the whole buffer.
+	sc.partial = append(sc.partial[:0], d...)
+	return out, err
+}
+
+// SchedSamples returns the scheduler traces printed with
+// GODEBUG=schedtrace=X found since the previous call.
+//
+// These lines are also written to prefix, including when they are printed in
+// the middle of a stack trace, which they don't interrupt.
+func (sc *Scanner) SchedSamples() []*SchedSample {
+	out := sc.sched
+	sc.sched = nil
+	return out
+}
+
+// Flush processes the incomplete trailing line, if any, and returns the
+// pending snapshots, if any.
+//
+// The Scanner is reset and can be reused afterward.
+func (sc *Scanner) Flush() ([]*Snapshot, error) {
+	var out []*Snapshot
+	var err error
+	if len(sc.partial) != 0 {
+		var s *Snapshot
+		if s, err = sc.scanLine(sc.partial); s != nil {
+			out = append(out, s)
+		}
+		sc.partial = sc.partial[:0]
+	}
+	if s := sc.complete(); s != nil {
+		out = append(out, s)
+	} else if len(sc.s.held) != 0 {
+		// The stream ended in the middle of a race report header.
+		if _, err1 := sc.prefix.Write(sc.s.held); err == nil {
+			err = err1
+		}
+	}
+	sc.reset()
+	return out, err
+}
+
+// Private stuff.
+
+// scanLine processes one line and returns the snapshot if it was completed by
+// this line.
+func (sc *Scanner) scanLine(d []byte) (*Snapshot, error) {
+	if isSchedLine(d) {
+		if s := parseSchedTrace(d); s != nil {
+			sc.sched = append(sc.sched, s)
+		}
+		_, err := sc.prefix.Write(d)
+		return nil, err
+	}
+	l, err := sc.s.scan(d)
+	if l {
+		return nil, err
+	}
+	var out *Snapshot
+	if sc.s.state != looking {
+		// The stack trace ended; the line may be the start of something else,
+		// including another stack trace, so process it again from a clean
+		// state.
+		out = sc.complete()
+		sc.reset()
+		var err1 error
+		if l, err1 = sc.s.scan(d); err == nil {
+			err = err1
+		}
+		if l || sc.s.state != looking {
+			return out, err
+		}
+	}
+	if _, err1 := sc.prefix.Write(sc.s.unhold(d)); err == nil {
+		err = err1
+	}
+	return out, err
+}
+
+// complete returns the snapshot being parsed, if any.
+func (sc *Scanner) complete() *Snapshot {
+	if sc.s.Goroutines == nil {
+		return nil
+	}
+	sc.s.postProcess(sc.opts)
+	return sc.s.Snapshot
+}
+
+func (sc *Scanner) reset() {
+	sc.s = scanningState{
+		Snapshot: &Snapshot{
+			LocalGOROOT:  sc.opts.LocalGOROOT,
+			LocalGOPATHs: sc.opts.LocalGOPATHs,
+		},
+		state: looking,
+	}
+}
+
+// GoroutineScanner parses the goroutines from a reader and returns each one
+// as soon as it is complete, instead of waiting for the end of the snapshot
+// like ScanSnapshot.
+//
+// This is useful to tail a live log: a goroutine is returned as soon as the
+// empty line following it is read. Successive snapshots are parsed one after
+// the other. Anything that is not a stack trace is discarded.
+//
+// The goroutines of a race detector report or a goroutine profile are only
+// returned once the whole report was read, since later lines modify the
+// goroutines already parsed.
+//
+// Each batch of goroutines is processed with opts on its own, as if it was a
+// separate snapshot. This means that Opts.NameArguments only names the
+// pointers found in the same goroutine, and that the warnings that would be
+// reported in Snapshot.Warnings are lost.
+type GoroutineScanner struct {
+	r    reader
+	opts *Opts
+	s    scanningState
+	// emitted is the number of goroutines of s already returned.
+	emitted int
+	pending []*Goroutine
+	err     error
+	bin     *binaryInfo
+	binErr  error
+}
+
+// NewGoroutineScanner returns a GoroutineScanner reading from in.
+func NewGoroutineScanner(in io.Reader, opts *Opts) (*GoroutineScanner, error) {
+	if opts == nil || !opts.isValid() {
+		return nil, errors.New("invalid Opts")
+	}
+	gs := &GoroutineScanner{r: reader{rd: limitReader(in, opts.MaxBytes)}, opts: opts}
+	gs.reset()
+	return gs, nil
+}
+
+// Next returns the next goroutine.
+//
+// It blocks until a goroutine is complete or the reader returns an error.
+// Returns io.EOF once the input is exhausted and all the goroutines were
+// returned.
+func (gs *GoroutineScanner) Next() (*Goroutine, error) {
+	for len(gs.pending) == 0 {
+		if gs.err != nil {
+			return nil, gs.err
+		}
+		d, err := gs.r.readLine()
+		if len(d) != 0 {
+			if err1 := gs.scanLine(d); err1 != nil {
+				err = err1
+			}
+		}
+		if err != nil {
+			gs.complete()
+			gs.err = err
+		}
+	}
+	g := gs.pending[0]
+	gs.pending = gs.pending[1:]
+	return g, nil
+}
+
+// scanLine processes one line and queues the goroutines it completed.
+func (gs *GoroutineScanner) scanLine(d []byte) error {
+	if isSchedLine(d) {
+		return nil
+	}
+	l, err := gs.s.scan(d)
+	if gs.s.state == betweenRoutine {
+		gs.emit()
+	}
+	if !l && gs.s.state != looking {
+		// The stack trace ended; the line may be the start of another one, so
+		// process it again from a clean state.
+		gs.complete()
+		_, err1 := gs.s.scan(d)
+		if err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+// emit queues the goroutines parsed but not yet returned.
+func (gs *GoroutineScanner) emit() {
+	if gs.emitted == len(gs.s.Goroutines) {
+		return
+	}
+	gs.process(gs.s.Goroutines[gs.emitted:])
+	// Release the goroutines already returned, only the count is needed.
+	for i := gs.emitted; i < len(gs.s.Goroutines); i++ {
+		gs.s.Goroutines[i] = nil
+	}
+	gs.emitted = len(gs.s.Goroutines)
+}
+
+// complete queues the remaining goroutines of the current snapshot and
+// resets the state.
+func (gs *GoroutineScanner) complete() {
+	if gs.s.profileCount != 0 {
+		// The goroutine profile was truncated.
+		gs.s.expandProfileSample()
+	}
+	gs.emit()
+	gs.reset()
+}
+
+// process post-processes the goroutines as a snapshot of their own and
+// queues them.
+func (gs *GoroutineScanner) process(goroutines []*Goroutine) {
+	t := scanningState{
+		Snapshot: &Snapshot{
+			Goroutines:    append([]*Goroutine(nil), goroutines...),
+			LocalGOROOT:   gs.opts.LocalGOROOT,
+			LocalGOPATHs:  gs.opts.LocalGOPATHs,
+			StackOverflow: gs.s.StackOverflow && goroutines[0].First,
+		},
+	}
+	if gs.opts.Binary != "" && gs.s.offsets != nil {
+		// Load the executable only once.
+		if gs.bin == nil && gs.binErr == nil {
+			gs.bin, gs.binErr = openBinary(gs.opts.Binary)
+		}
+		if gs.bin != nil {
+			for _, g := range t.Goroutines {
+				_ = gs.bin.expandInlined(g, gs.s.offsets[g])
+				delete(gs.s.offsets, g)
+			}
+		}
+	}
+	t.postProcess(gs.opts)
+	gs.pending = append(gs.pending, t.Goroutines...)
+}
+
+func (gs *GoroutineScanner) reset() {
+	gs.s = scanningState{
+		Snapshot: &Snapshot{
+			LocalGOROOT:  gs.opts.LocalGOROOT,
+			LocalGOPATHs: gs.opts.LocalGOPATHs,
+		},
+		state:       looking,
+		keepOffsets: gs.opts.Binary != "",
+	}
+	gs.emitted = 0
+}
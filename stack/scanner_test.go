@@ -0,0 +1,213 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestScanner(t *testing.T) {
+	t.Parallel()
+	trace := internaltest.StaticPanicwebOutput()
+	in := []byte("junk\n")
+	in = append(in, trace...)
+	in = append(in, "middle\n"...)
+	in = append(in, trace...)
+	in = append(in, "end"...)
+
+	// Reference with ScanSnapshot.
+	opts := &Opts{}
+	var want []*Snapshot
+	wantPrefix := bytes.Buffer{}
+	r := io.Reader(bytes.NewReader(in))
+	for {
+		s, suffix, err := ScanSnapshot(r, &wantPrefix, opts)
+		if s != nil {
+			want = append(want, s)
+		}
+		if err == io.EOF {
+			wantPrefix.Write(suffix)
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = io.MultiReader(bytes.NewReader(suffix), r)
+	}
+	if len(want) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(want))
+	}
+
+	// Feed small chunks that split lines.
+	for _, size := range []int{1, 7, 4096, len(in)} {
+		prefix := bytes.Buffer{}
+		sc, err := NewScanner(&prefix, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []*Snapshot
+		for i := 0; i < len(in); i += size {
+			end := i + size
+			if end > len(in) {
+				end = len(in)
+			}
+			s, err := sc.Scan(in[i:end])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, s...)
+		}
+		if len(got) != 1 {
+			t.Fatalf("size %d: the second snapshot must not be completed yet; got %d", size, len(got))
+		}
+		s, err := sc.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s...)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("size %d: -want, +got:\n%s", size, diff)
+		}
+		compareString(t, wantPrefix.String(), prefix.String())
+	}
+}
+
+func TestScannerErr(t *testing.T) {
+	t.Parallel()
+	if _, err := NewScanner(io.Discard, nil); err == nil {
+		t.Fatal("expected error")
+	}
+	sc, err := NewScanner(io.Discard, &Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := sc.Flush(); s != nil || err != nil {
+		t.Fatal(s, err)
+	}
+}
+
+func TestScannerSchedTrace(t *testing.T) {
+	t.Parallel()
+	sched := "" +
+		"SCHED 1004ms: gomaxprocs=4 idleprocs=3 threads=5 spinningthreads=0 needspinning=0 idlethreads=2 runqueue=1 [ 0 2 0 0 ] schedticks=[ 5 6 7 8 ]\n"
+	detail := "" +
+		"SCHED 2004ms: gomaxprocs=4 idleprocs=4 threads=5 spinningthreads=0 needspinning=0 idlethreads=3 runqueue=0 gcwaiting=false nmidlelocked=0 stopwait=0 sysmonwait=false\n" +
+		"  P0: status=0 schedtick=3 syscalltick=0 m=nil runqsize=0 gfreecnt=0 timerslen=0\n" +
+		"  M0: p=nil curg=nil mallocing=0 throwing=0 preemptoff= locks=0 dying=0 spinning=false blocked=true lockedg=nil\n" +
+		"  G1: status=4(chan receive) m=nil lockedm=nil\n"
+	old := "SCHED 0ms: gomaxprocs=2 idleprocs=1 threads=3 spinningthreads=1 idlethreads=0 runqueue=0 [0 1]\n"
+	in := old +
+		"goroutine 1 [chan receive]:\n" +
+		"main.main()\n" +
+		"\t/home/user/go/src/main.go:12 +0x20\n" +
+		sched +
+		"\n" +
+		"goroutine 6 [chan receive]:\n" +
+		detail +
+		"main.worker()\n" +
+		"\t/home/user/go/src/main.go:20 +0x40\n" +
+		"\n" +
+		"end\n"
+	prefix := bytes.Buffer{}
+	sc, err := NewScanner(&prefix, &Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := sc.Scan([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The scheduler traces don't interrupt the stack trace.
+	if len(got) != 1 || len(got[0].Goroutines) != 2 {
+		t.Fatalf("unexpected %v", got)
+	}
+	if g := got[0].Goroutines[1]; len(g.Stack.Calls) != 1 || g.Stack.Calls[0].Func.Complete != "main.worker" {
+		t.Fatalf("unexpected %v", g)
+	}
+	compareString(t, old+sched+detail+"end\n", prefix.String())
+	want := []*SchedSample{
+		{GOMAXPROCS: 2, IdleProcs: 1, Threads: 3, SpinningThreads: 1, LocalRunQueues: []int{0, 1}},
+		{Uptime: 1004 * time.Millisecond, GOMAXPROCS: 4, IdleProcs: 3, Threads: 5, IdleThreads: 2, RunQueue: 1, LocalRunQueues: []int{0, 2, 0, 0}},
+		{Uptime: 2004 * time.Millisecond, GOMAXPROCS: 4, IdleProcs: 4, Threads: 5, IdleThreads: 3},
+	}
+	if diff := cmp.Diff(want, sc.SchedSamples(), cmp.AllowUnexported(SchedSample{})); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if s := sc.SchedSamples(); s != nil {
+		t.Fatalf("unexpected %v", s)
+	}
+}
+
+func TestGoroutineScanner(t *testing.T) {
+	t.Parallel()
+	trace := internaltest.StaticPanicwebOutput()
+	in := []byte("junk\n")
+	in = append(in, trace...)
+	in = append(in, "middle\n"...)
+	in = append(in, trace...)
+	in = append(in, "end"...)
+
+	// Reference with ScanSnapshot.
+	opts := &Opts{}
+	s, _, err := ScanSnapshot(bytes.NewReader(trace), io.Discard, opts)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	want := append(append([]*Goroutine{}, s.Goroutines...), s.Goroutines...)
+
+	gs, err := NewGoroutineScanner(bytes.NewReader(in), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*Goroutine
+	for {
+		g, err := gs.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, g)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
+
+func TestGoroutineScannerLive(t *testing.T) {
+	t.Parallel()
+	r, w := io.Pipe()
+	gs, err := NewGoroutineScanner(r, &Opts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_, _ = io.WriteString(w, "panic: oh no\n\ngoroutine 1 [running]:\nmain.main()\n\t/a/main.go:5 +0x13\n\n")
+	}()
+	// The goroutine must be returned without waiting for the end of the
+	// snapshot.
+	g, err := gs.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.ID != 1 || !g.First || g.Stack.Calls[0].Func.Name != "main" {
+		t.Fatalf("unexpected goroutine %#v", g)
+	}
+	_ = w.Close()
+	if g, err = gs.Next(); g != nil || err != io.EOF {
+		t.Fatal(g, err)
+	}
+
+	if _, err := NewGoroutineScanner(r, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// SchedSample is a scheduler trace line printed periodically by the runtime
+// when GODEBUG=schedtrace=X is set, e.g.:
+//
+//	SCHED 1004ms: gomaxprocs=8 idleprocs=8 threads=5 spinningthreads=0 needspinning=0 idlethreads=3 runqueue=0 [ 0 0 0 0 0 0 0 0 ] schedticks=[ 1 2 3 4 5 6 7 8 ]
+//
+// See Scanner.SchedSamples.
+type SchedSample struct {
+	// Uptime is the time since the first scheduler trace of the process.
+	Uptime time.Duration
+	// GOMAXPROCS is the number of Ps.
+	GOMAXPROCS int
+	// IdleProcs is the number of idle Ps.
+	IdleProcs int
+	// Threads is the number of OS threads.
+	Threads int
+	// SpinningThreads is the number of OS threads looking for work.
+	SpinningThreads int
+	// IdleThreads is the number of idle OS threads.
+	IdleThreads int
+	// RunQueue is the length of the global run queue.
+	RunQueue int
+	// LocalRunQueues is the length of the run queue of each P. It is nil with
+	// GODEBUG=scheddetail=1, which prints one line per P instead.
+	LocalRunQueues []int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Private stuff.
+
+var (
+	// reSchedTrace matches the first line of a scheduler trace.
+	reSchedTrace = regexp.MustCompile(`^SCHED (\d+)ms: gomaxprocs=\d+ `)
+	// reSchedDetail matches the lines that follow it with
+	// GODEBUG=scheddetail=1.
+	reSchedDetail = regexp.MustCompile(`^  (?:P\d+: status=|M\d+: p=|G\d+: status=)`)
+)
+
+// isSchedLine returns true if line was printed by GODEBUG=schedtrace or
+// scheddetail.
+//
+// The runtime prints these lines at any time, including in the middle of a
+// goroutine dump, so they must not be interpreted as the end of it.
+func isSchedLine(line []byte) bool {
+	return reSchedTrace.Match(line) || reSchedDetail.Match(line)
+}
+
+// parseSchedTrace parses the first line of a scheduler trace. Returns nil if
+// line is not one.
+func parseSchedTrace(line []byte) *SchedSample {
+	m := reSchedTrace.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	ms, _ := strconv.Atoi(string(m[1]))
+	out := &SchedSample{Uptime: time.Duration(ms) * time.Millisecond}
+	// Skip "SCHED 1004ms:".
+	fields := bytes.Fields(line)[2:]
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if bytes.HasPrefix(f, []byte("[")) {
+			// The per-P run queues, "[0 0]" or "[ 0 0 ]" depending on the Go
+			// version.
+			if out.LocalRunQueues != nil {
+				continue
+			}
+			out.LocalRunQueues = []int{}
+			for ; i < len(fields); i++ {
+				v := bytes.Trim(fields[i], "[]")
+				if n, err := strconv.Atoi(string(v)); err == nil {
+					out.LocalRunQueues = append(out.LocalRunQueues, n)
+				}
+				if bytes.HasSuffix(fields[i], []byte("]")) {
+					break
+				}
+			}
+			continue
+		}
+		j := bytes.IndexByte(f, '=')
+		if j == -1 {
+			continue
+		}
+		n, err := strconv.Atoi(string(f[j+1:]))
+		if err != nil {
+			continue
+		}
+		switch string(f[:j]) {
+		case "gomaxprocs":
+			out.GOMAXPROCS = n
+		case "idleprocs":
+			out.IdleProcs = n
+		case "threads":
+			out.Threads = n
+		case "spinningthreads":
+			out.SpinningThreads = n
+		case "idlethreads":
+			out.IdleThreads = n
+		case "runqueue":
+			out.RunQueue = n
+		}
+	}
+	return out
+}
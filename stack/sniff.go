@@ -0,0 +1,83 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/maruel/panicparse/v2/stack/rawparse"
+)
+
+// Dialect is a kind of stack dump.
+type Dialect int
+
+const (
+	// DialectUnknown means no known stack dump was detected.
+	DialectUnknown Dialect = iota
+	// DialectPanic is the output of the Go runtime on a panic, a fatal error or
+	// a SIGQUIT.
+	DialectPanic
+	// DialectPprof is the output of /debug/pprof/goroutine?debug=2 or of
+	// runtime.Stack().
+	DialectPprof
+	// DialectRace is a race detector report.
+	DialectRace
+	// DialectDelve is the output of delve's stack or goroutines -t commands.
+	DialectDelve
+)
+
+// Sniff cheaply determines if data contains a stack dump, and of which kind.
+//
+// It only looks for markers and doesn't parse the dump, so it is suitable to
+// decide whether a payload should be routed to ScanSnapshot. A true value
+// doesn't guarantee ScanSnapshot will succeed.
+func Sniff(data []byte) (bool, Dialect) {
+	sawPanic := false
+	first := true
+	for len(data) != 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line = data[:i]
+			data = data[i+1:]
+		} else {
+			data = nil
+		}
+		line = bytes.TrimSuffix(line, cr)
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if t, ok := rawparse.Parse(rawparse.RaceMarker, line); ok && len(t.Value) != 0 {
+			return true, DialectRace
+		}
+		if _, ok := rawparse.Parse(rawparse.GoroutineHeader, line); ok {
+			if first && !sawPanic {
+				return true, DialectPprof
+			}
+			return true, DialectPanic
+		}
+		if reDelveFrame.Match(line) || reDelveGoroutine.Match(line) {
+			return true, DialectDelve
+		}
+		if bytes.HasPrefix(line, panicPrefix) || bytes.HasPrefix(line, fatalErrorPrefix) {
+			sawPanic = true
+		}
+		first = false
+	}
+	return false, DialectUnknown
+}
+
+// Private stuff.
+
+var (
+	cr               = []byte("\r")
+	panicPrefix      = []byte("panic: ")
+	fatalErrorPrefix = []byte("fatal error: ")
+
+	// "0  0x000000000045c5f2 in main.main"
+	reDelveFrame = regexp.MustCompile(`^\s*\d+\s+0x[0-9a-f]+ in \S+$`)
+	// "  Goroutine 1 - User: ./main.go:10 main.main (0x45c5f2) [chan receive]"
+	reDelveGoroutine = regexp.MustCompile(`^[\s*]*Goroutine \d+ - `)
+)
@@ -0,0 +1,43 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"testing"
+
+	"github.com/maruel/panicparse/v2/internal/internaltest"
+)
+
+func TestSniff(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		in   string
+		want Dialect
+	}{
+		{"", DialectUnknown},
+		{"hello\nworld\n", DialectUnknown},
+		{"goroutine 1 [running]:\nmain.main()\n", DialectPprof},
+		{"\ngoroutine 1 [running]:\n", DialectPprof},
+		{"panic: boo\n\ngoroutine 1 [running]:\n", DialectPanic},
+		{"log line\ngoroutine 1 [running]:\n", DialectPanic},
+		{"fatal error: all goroutines are asleep - deadlock!\r\n\r\ngoroutine 1 [chan receive]:\r\n", DialectPanic},
+		{"==================\nWARNING: DATA RACE\n", DialectRace},
+		{"(dlv) stack\n0  0x000000000045c5f2 in main.main\n   at ./main.go:10\n", DialectDelve},
+		{"* Goroutine 1 - User: ./main.go:10 main.main (0x45c5f2) [chan receive]\n", DialectDelve},
+	}
+	for i, line := range data {
+		ok, got := Sniff([]byte(line.in))
+		if ok != (line.want != DialectUnknown) || got != line.want {
+			t.Fatalf("#%d: want %s, got %t %s", i, line.want, ok, got)
+		}
+	}
+	if ok, got := Sniff(internaltest.StaticPanicRaceOutput()); !ok || got != DialectRace {
+		t.Fatalf("unexpected %t %s", ok, got)
+	}
+	// It is a /debug/pprof/goroutine?debug=2 output.
+	if ok, got := Sniff(internaltest.StaticPanicwebOutput()); !ok || got != DialectPprof {
+		t.Fatalf("unexpected %t %s", ok, got)
+	}
+}
@@ -8,23 +8,27 @@
 package stack
 
 import (
+	"archive/zip"
 	"bytes"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io"
 	"math"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Private stuff.
 
-// cacheAST is a cache of parsed Go sources.
+// cacheAST is a cache of parsed Go sources for one snapshot. It is backed by
+// fileCache.
 type cacheAST struct {
-	files  map[string][]byte
 	parsed map[string]*parsedFile
+	limits *ArgsLimits
 }
 
 // augmentGoroutine processes source files to improve call to be more
@@ -38,6 +42,12 @@ func (c *cacheAST) augmentGoroutine(g *Goroutine) error {
 		if len(call.Args.Values) == 0 {
 			continue
 		}
+		// The arguments of a generic function instantiation, printed as
+		// "f[...]", don't match its declaration: the types are type parameters
+		// and the compiler may pass a hidden dictionary. Keep the raw values.
+		if strings.Contains(call.Func.Name, "[...]") {
+			continue
+		}
 		if err1 := c.loadFile(g.Stack.Calls[i].LocalSrcPath); err1 != nil {
 			//log.Printf("%s", err)
 			err = err1
@@ -49,7 +59,7 @@ func (c *cacheAST) augmentGoroutine(g *Goroutine) error {
 				continue
 			}
 			if f != nil {
-				augmentCall(&g.Stack.Calls[i], f)
+				augmentCall(&g.Stack.Calls[i], f, c.limits)
 			}
 		}
 	}
@@ -71,24 +81,78 @@ func (c *cacheAST) loadFile(fileName string) error {
 		// Ignore C and assembly.
 		return fmt.Errorf("cannot load non-go file %q", fileName)
 	}
+	key := statFile(fileName)
+	if p := fileCache.get(key); p != nil {
+		c.parsed[fileName] = p
+		return nil
+	}
 	/* #nosec G304 */
 	src, err := os.ReadFile(fileName)
 	if err != nil {
-		return err
+		// The module may only be present as a zip in the module cache.
+		var err1 error
+		if src, err1 = readFromModZip(fileName); err1 != nil {
+			return err
+		}
 	}
-	c.files[fileName] = src
 	fset := token.NewFileSet()
 	parsed, err := parser.ParseFile(fset, fileName, src, 0)
 	if err != nil {
 		return fmt.Errorf("failed to parse %w", err)
 	}
-	c.parsed[fileName] = &parsedFile{
+	p := &parsedFile{
 		lineToByteOffset: lineToByteOffsets(src),
 		parsed:           parsed,
 	}
+	c.parsed[fileName] = p
+	fileCache.add(key, p, len(src))
 	return nil
 }
 
+// readFromModZip reads a file from a module zip in the module cache download
+// directory.
+//
+// fileName must be in the form <root>/pkg/mod/<module>@<version>/<path>. This
+// happens when the module was downloaded but never extracted, e.g. in
+// GOFLAGS=-mod=mod environments.
+func readFromModZip(fileName string) ([]byte, error) {
+	const mod = "/pkg/mod/"
+	i := strings.LastIndex(fileName, mod)
+	if i == -1 {
+		return nil, fmt.Errorf("%q is not in the module cache", fileName)
+	}
+	root := fileName[:i+len(mod)-1]
+	rel := fileName[i+len(mod):]
+	at := strings.IndexByte(rel, '@')
+	if at == -1 {
+		return nil, fmt.Errorf("%q is not in the module cache", fileName)
+	}
+	slash := strings.IndexByte(rel[at:], '/')
+	if slash == -1 {
+		return nil, fmt.Errorf("%q is not in the module cache", fileName)
+	}
+	// Both the directory and the cache use the same case-encoded module path.
+	module, version := rel[:at], rel[at+1:at+slash]
+	/* #nosec G304 */
+	z, err := zip.OpenReader(root + "/cache/download/" + module + "/@v/" + version + ".zip")
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name != rel {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("%q not found in module zip", rel)
+}
+
 // lineToByteOffsets extract the line number into raw file offset.
 //
 // Inserts a dummy 0 at offset 0 so line offsets can be 1 based.
@@ -106,14 +170,52 @@ func lineToByteOffsets(src []byte) []int {
 }
 
 // parsedFile is a processed Go source file.
+//
+// It is shared across snapshots via fileCache so it must not be modified
+// after creation, except for funcs.
 type parsedFile struct {
 	lineToByteOffset []int
 	parsed           *ast.File
+
+	// mu protects funcs.
+	mu sync.Mutex
+	// funcs is the memoized results of getFuncAST.
+	funcs map[funcLine]*ast.FuncDecl
+}
+
+// funcLine is a function name and a line inside it.
+type funcLine struct {
+	name string
+	line int
 }
 
 // getFuncAST gets the callee site function AST representation for the code
 // inside the function f at line l.
-func (p *parsedFile) getFuncAST(f string, l int) (d *ast.FuncDecl, err error) {
+//
+// The result is memoized.
+func (p *parsedFile) getFuncAST(f string, l int) (*ast.FuncDecl, error) {
+	k := funcLine{f, l}
+	p.mu.Lock()
+	d, ok := p.funcs[k]
+	p.mu.Unlock()
+	if ok {
+		return d, nil
+	}
+	d, err := p.findFuncAST(f, l)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.funcs == nil {
+		p.funcs = map[funcLine]*ast.FuncDecl{}
+	}
+	p.funcs[k] = d
+	p.mu.Unlock()
+	return d, nil
+}
+
+// findFuncAST is the implementation of getFuncAST.
+func (p *parsedFile) findFuncAST(f string, l int) (d *ast.FuncDecl, err error) {
 	if len(p.lineToByteOffset) <= l {
 		// The line number in the stack trace line does not exist in the file. That
 		// can only mean that the sources on disk do not match the sources used to
@@ -239,18 +341,28 @@ func extractArgumentsType(f *ast.FuncDecl) ([]string, bool) {
 }
 
 // augmentCall walks the function and populate call accordingly.
-func augmentCall(call *Call, f *ast.FuncDecl) {
+//
+// The fields of aggregate arguments are flattened, so l.MaxElements applies to
+// the total number of fields printed for each argument.
+func augmentCall(call *Call, f *ast.FuncDecl, l *ArgsLimits) {
 	flatArgs := make([]*Arg, 0, len(call.Args.Values))
 	call.Args.walk(func(arg *Arg) {
 		flatArgs = append(flatArgs, arg)
 	})
 
+	// uncertain is set when one of the values used for the current argument
+	// is missing or was not accurately printed by the runtime.
+	uncertain := false
 	pop := func() *Arg {
 		if len(flatArgs) == 0 {
+			uncertain = true
 			return nil
 		}
 		a := flatArgs[0]
 		flatArgs = flatArgs[1:]
+		if a.IsOffsetTooLarge || a.IsInaccurate {
+			uncertain = true
+		}
 		return a
 	}
 	popFmt := func(fmtFn func(v uint64) string) string {
@@ -279,14 +391,19 @@ func augmentCall(call *Call, f *ast.FuncDecl) {
 
 	types, extra := extractArgumentsType(f)
 	for i := 0; len(flatArgs) != 0; i++ {
+		uncertain = false
+		confidence := Exact
 		var t string
 		if i >= len(types) {
 			if !extra {
 				// These are unexpected value! Print them as hex.
 				call.Args.Processed = append(call.Args.Processed, popName())
+				call.Args.Confidence = append(call.Args.Confidence, Uncertain)
 				continue
 			}
+			// Assumes the extra values are part of the variadic argument.
 			t = types[len(types)-1]
+			confidence = Inferred
 		} else {
 			t = types[i]
 		}
@@ -357,10 +474,16 @@ func augmentCall(call *Call, f *ast.FuncDecl) {
 					// of its sub-arguments.
 					v := &call.Args.Values[i].Fields
 					var fields []string
-					v.walk(func(arg *Arg) {
-						fields = append(fields, popName())
+					elided := v.Elided
+					v.walkDepth(1, func(arg *Arg, depth int) {
+						name := popName()
+						if l != nil && ((l.MaxDepth > 0 && depth > l.MaxDepth) || (l.MaxElements > 0 && len(fields) >= l.MaxElements)) {
+							elided = true
+							return
+						}
+						fields = append(fields, name)
 					})
-					if v.Elided {
+					if elided {
 						fields = append(fields, "...")
 					}
 					str = fmt.Sprintf("%s{%s}", t, strings.Join(fields, ", "))
@@ -369,9 +492,14 @@ func augmentCall(call *Call, f *ast.FuncDecl) {
 					// value, which is probably not a good idea.
 					str = fmt.Sprintf("%s(%s)", t, popName())
 					pop()
+					confidence = Inferred
 				}
 			}
 		}
+		if uncertain {
+			confidence = Uncertain
+		}
 		call.Args.Processed = append(call.Args.Processed, str)
+		call.Args.Confidence = append(call.Args.Confidence, confidence)
 	}
 }
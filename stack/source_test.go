@@ -5,8 +5,12 @@
 package stack
 
 import (
+	"archive/zip"
 	"bytes"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"math/bits"
 	"os"
 	"os/exec"
@@ -33,7 +37,7 @@ func TestAugment(t *testing.T) {
 	newCallSrc := func(f string, a Args, s string, l int) Call {
 		c := newCall(f, a, s, l)
 		// Simulate findRoots().
-		if !c.updateLocations(goroot, goroot, gm, gopaths) {
+		if !c.updateLocations(goroot, goroot, newRoots(gm), newRoots(gopaths), nil) {
 			t.Fatalf("c.updateLocations(%v, %v, %v, %v) failed on %s", goroot, goroot, gm, gopaths, s)
 		}
 		return c
@@ -1431,11 +1435,19 @@ func testAugmentCommon(t *testing.T, content []byte, mayBeInlined bool, want Sta
 		t.Error("expected success")
 	}
 
-	if err := s.augment(); err != nil {
+	if err := s.augment(nil); err != nil {
 		t.Errorf("augment() returned %v", err)
 	}
 	got := s.Goroutines[0].Signature.Stack
 	zapPointers(t, &want, &got)
+	// Confidence depends on the registers used by the toolchain; it is tested
+	// in TestAugmentCallConfidence.
+	for j := range got.Calls {
+		if len(got.Calls[j].Args.Confidence) != len(got.Calls[j].Args.Processed) {
+			t.Fatalf("#%d: Confidence and Processed mismatch: %v; %v", j, got.Calls[j].Args.Confidence, got.Calls[j].Args.Processed)
+		}
+		got.Calls[j].Args.Confidence = nil
+	}
 
 	// With a non-pointer method, elided argument can be shown. It's only for
 	// test case "non-pointer method".
@@ -1565,7 +1577,7 @@ func TestAugmentErr(t *testing.T) {
 						{LocalSrcPath: filepath.Join(root, line.src), Args: line.args, Line: l},
 					}}}},
 				}}
-			if err := s.augment(); (line.errRe == "") != (err == nil) {
+			if err := s.augment(nil); (line.errRe == "") != (err == nil) {
 				t.Fatalf("want: %q; got:  %q", line.errRe, err)
 			} else if err != nil {
 				if m, err2 := regexp.MatchString(line.errRe, err.Error()); err2 != nil {
@@ -1578,6 +1590,164 @@ func TestAugmentErr(t *testing.T) {
 	}
 }
 
+func TestAugmentModZip(t *testing.T) {
+	t.Parallel()
+	root, err := os.MkdirTemp("", "stack")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer func() {
+		if err2 := os.RemoveAll(root); err2 != nil {
+			t.Fatalf("failed to remove temporary directory %q: %v", root, err2)
+		}
+	}()
+	// Only the zip is present in the module cache, the module is not extracted.
+	dir := filepath.Join(root, "pkg", "mod", "cache", "download", "example.com", "!foo", "@v")
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.Buffer{}
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("example.com/!foo@v1.0.0/bar/bar.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("package bar\n\nfunc Bar(s string) {\n\tpanic(s)\n}\n")); err != nil {
+		t.Fatal(err)
+	}
+	compareErr(t, nil, w.Close())
+	compareErr(t, nil, os.WriteFile(filepath.Join(dir, "v1.0.0.zip"), buf.Bytes(), 0o600))
+
+	src := strings.Replace(root, "\\", "/", -1) + "/pkg/mod/example.com/!foo@v1.0.0/bar/bar.go"
+	s := Snapshot{
+		Goroutines: []*Goroutine{
+			{Signature: Signature{Stack: Stack{Calls: []Call{
+				{
+					Func:         newFunc("example.com/Foo/bar.Bar"),
+					LocalSrcPath: src,
+					Args:         Args{Values: []Arg{{Value: 0x1000}, {Value: 3}}},
+					Line:         4,
+				},
+			}}}},
+		}}
+	compareErr(t, nil, s.augment(nil))
+	want := []string{"string(0x1000, len=3)"}
+	if diff := cmp.Diff(want, s.Goroutines[0].Stack.Calls[0].Args.Processed); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+
+	// Missing zip entry.
+	if _, err = readFromModZip(root + "/pkg/mod/example.com/!foo@v1.0.0/missing.go"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err = readFromModZip(root + "/src/foo.go"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestAugmentCallArgsLimits(t *testing.T) {
+	t.Parallel()
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", "package main\n\nfunc f(s S) {\n}\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := f.Decls[0].(*ast.FuncDecl)
+	args := Args{Values: []Arg{{IsAggregate: true, Fields: Args{Values: []Arg{
+		{Value: 1},
+		{IsAggregate: true, Fields: Args{Values: []Arg{{Value: 2}, {Value: 3}}}},
+		{Value: 4},
+	}}}}}
+	data := []struct {
+		l    *ArgsLimits
+		want string
+	}{
+		{nil, "S{0x1, 0x2, 0x3, 0x4}"},
+		{&ArgsLimits{MaxDepth: 1}, "S{0x1, 0x4, ...}"},
+		{&ArgsLimits{MaxElements: 2}, "S{0x1, 0x2, ...}"},
+	}
+	for i, line := range data {
+		c := Call{Args: args}
+		augmentCall(&c, fn, line.l)
+		if diff := cmp.Diff([]string{line.want}, c.Args.Processed); diff != "" {
+			t.Fatalf("#%d: -want, +got:\n%s", i, diff)
+		}
+	}
+}
+
+func TestAugmentGeneric(t *testing.T) {
+	t.Parallel()
+	p := filepath.Join(t.TempDir(), "main.go")
+	src := "package main\n\nfunc f[T any](v T) {\n\tpanic(v)\n}\n"
+	if err := os.WriteFile(p, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	g := Goroutine{Signature: Signature{Stack: Stack{Calls: []Call{
+		newCall("main.f[...]", Args{Values: []Arg{{Value: 1}, {Value: 2}}}, p, 4),
+	}}}}
+	g.Stack.Calls[0].LocalSrcPath = p
+	c := cacheAST{parsed: map[string]*parsedFile{}}
+	if err := c.augmentGoroutine(&g); err != nil {
+		t.Fatal(err)
+	}
+	// The raw values are kept.
+	if got := g.Stack.Calls[0].Args.Processed; got != nil {
+		t.Fatalf("unexpected %q", got)
+	}
+}
+
+func TestAugmentCallConfidence(t *testing.T) {
+	t.Parallel()
+	src := "package main\n\nfunc f(i int, e error, v ...int) {\n}\n\nfunc g(i int) {\n}\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fnF := f.Decls[0].(*ast.FuncDecl)
+	fnG := f.Decls[1].(*ast.FuncDecl)
+	data := []struct {
+		fn   *ast.FuncDecl
+		args Args
+		want []string
+		conf []Confidence
+	}{
+		{
+			fnF,
+			Args{Values: []Arg{{Value: 1}, {Value: 2}, {Value: 3}, {Value: 4}, {Value: 5}}},
+			[]string{"1", "error(0x2)", "4", "5"},
+			[]Confidence{Exact, Inferred, Exact, Inferred},
+		},
+		{
+			fnG,
+			Args{Values: []Arg{{Value: 1, IsInaccurate: true}}},
+			[]string{"1"},
+			[]Confidence{Uncertain},
+		},
+		{
+			fnG,
+			Args{Values: []Arg{{IsOffsetTooLarge: true}}},
+			[]string{"_"},
+			[]Confidence{Uncertain},
+		},
+		{
+			// More values than arguments.
+			fnG,
+			Args{Values: []Arg{{Value: 1}, {Value: 2}}},
+			[]string{"1", "0x2"},
+			[]Confidence{Exact, Uncertain},
+		},
+	}
+	for i, line := range data {
+		c := Call{Args: line.args}
+		augmentCall(&c, line.fn, nil)
+		if diff := cmp.Diff(line.want, c.Args.Processed); diff != "" {
+			t.Fatalf("#%d: -want, +got:\n%s", i, diff)
+		}
+		if diff := cmp.Diff(line.conf, c.Args.Confidence); diff != "" {
+			t.Fatalf("#%d: -want, +got:\n%s", i, diff)
+		}
+	}
+}
+
 func TestLineToByteOffsets(t *testing.T) {
 	src := "\n\n\n"
 	want := []int{0, 0, 1, 2, 3}
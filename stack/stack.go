@@ -6,11 +6,17 @@
 //
 // It is mostly useful on servers will large number of identical goroutines,
 // making the crash dump harder to read than strictly necessary.
+//
+// This package and its subpackages only depend on the standard library and
+// do not require cgo, so they can be vendored in constrained environments and
+// built for WebAssembly. The terminal color support is only part of the pp
+// executable.
 package stack
 
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/url"
 	"os"
 	"sort"
@@ -139,6 +145,17 @@ const zeroToNine = "0123456789"
 
 // String prints the argument as the name if present, otherwise as the value.
 func (a *Arg) String() string {
+	return a.format(nil, 0)
+}
+
+// Format prints the argument like String but limits how much of the nested
+// aggregates are printed.
+func (a *Arg) Format(l *ArgsLimits) string {
+	return a.format(l, 0)
+}
+
+// format prints the argument found at nesting level depth.
+func (a *Arg) format(l *ArgsLimits, depth int) string {
 	if a.Name != "" {
 		return a.Name
 	}
@@ -146,7 +163,10 @@ func (a *Arg) String() string {
 		return "_"
 	}
 	if a.IsAggregate {
-		return "{" + a.Fields.String() + "}"
+		if l != nil && l.MaxDepth > 0 && depth >= l.MaxDepth {
+			return "{...}"
+		}
+		return "{" + a.Fields.format(l, depth+1) + "}"
 	}
 	if a.Value < uint64(len(zeroToNine)) {
 		return zeroToNine[a.Value : a.Value+1]
@@ -215,6 +235,9 @@ type Args struct {
 	// Processed is the arguments generated from processing the source files. It
 	// can have a length lower than Values.
 	Processed []string
+	// Confidence is how much each item of Processed can be trusted. It has
+	// the same length as Processed.
+	Confidence []Confidence
 	// Elided when set means there was a trailing ", ...".
 	Elided bool
 
@@ -222,17 +245,93 @@ type Args struct {
 	_ struct{}
 }
 
+// Confidence is how much an argument generated from processing the source
+// files can be trusted.
+type Confidence int
+
+const (
+	// Exact is when the argument was rebuilt from accurate values with the
+	// type of the function declaration.
+	Exact Confidence = iota
+	// Inferred is when the type of the argument was guessed, e.g. an
+	// interface or a value of a variadic argument.
+	Inferred
+	// Uncertain is when a value used to rebuild the argument is missing or was
+	// printed as inaccurate by the runtime, e.g. for an argument passed in a
+	// register, or when there are more values than the declared arguments.
+	Uncertain
+)
+
+// MarshalText implements encoding.TextMarshaler.
+//
+// It returns "exact", "inferred" or "uncertain".
+func (c Confidence) MarshalText() ([]byte, error) {
+	if c < Exact || c > Uncertain {
+		return nil, fmt.Errorf("invalid confidence %d", int(c))
+	}
+	return []byte(strings.ToLower(c.String())), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Confidence) UnmarshalText(b []byte) error {
+	for i := Exact; i <= Uncertain; i++ {
+		if string(b) == strings.ToLower(i.String()) {
+			*c = i
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid confidence %q", b)
+}
+
+// ArgsLimits limits how much of the aggregate arguments are printed, to keep
+// the lines readable with deeply nested or large structs.
+//
+// The zero value means no limit.
+type ArgsLimits struct {
+	// MaxDepth is the maximum nesting level of aggregates printed. Deeper
+	// aggregates are printed as "{...}". A value of 1 prints the fields of
+	// the top level aggregates only.
+	MaxDepth int
+	// MaxElements is the maximum number of fields printed per aggregate. The
+	// remaining ones are elided with "...".
+	MaxElements int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
 func (a *Args) String() string {
+	return a.format(nil, 0)
+}
+
+// Format prints the arguments like String but limits how much of the
+// aggregates are printed.
+//
+// Processed is printed as-is, since the limits are applied when it is
+// generated. See Opts.ArgsLimits.
+func (a *Args) Format(l *ArgsLimits) string {
+	return a.format(l, 0)
+}
+
+// format prints the arguments found at nesting level depth. Top level
+// arguments are at depth 0.
+func (a *Args) format(l *ArgsLimits, depth int) string {
 	var v []string
+	elided := a.Elided
 	if len(a.Processed) != 0 {
 		v = a.Processed
 	} else {
-		v = make([]string, 0, len(a.Values))
-		for _, item := range a.Values {
-			v = append(v, item.String())
+		values := a.Values
+		if l != nil && l.MaxElements > 0 && depth > 0 && len(values) > l.MaxElements {
+			values = values[:l.MaxElements]
+			elided = true
+		}
+		v = make([]string, 0, len(values)+1)
+		for i := range values {
+			v = append(v, values[i].format(l, depth))
 		}
 	}
-	if a.Elided {
+	if elided {
 		v = append(v, "...")
 	}
 	return strings.Join(v, ", ")
@@ -300,6 +399,19 @@ func (a *Args) walk(visitor func(arg *Arg)) {
 	}
 }
 
+// walkDepth is like walk but also passes the nesting level of each Arg, where
+// the values of a are at depth.
+func (a *Args) walkDepth(depth int, visitor func(arg *Arg, depth int)) {
+	for i := range a.Values {
+		arg := &a.Values[i]
+		if arg.IsAggregate {
+			arg.Fields.walkDepth(depth+1, visitor)
+		} else {
+			visitor(arg, depth)
+		}
+	}
+}
+
 // Location is the source location, if determined.
 type Location int
 
@@ -362,6 +474,18 @@ type Call struct {
 	ImportPath string
 	// Location is the source location, if determined.
 	Location Location
+	// Module is the go module of the source file, if determined. It is only
+	// set when Opts.ResolveModules was set.
+	Module *Module
+
+	// Inlined is true when the call was inserted from the DWARF information of
+	// Opts.Binary because it was inlined in the next call.
+	Inlined bool
+
+	// PC is the program counter of the call when the stack trace printed it
+	// without symbol, as in a goroutine profile of a process that failed to
+	// symbolize it. Func is empty until resolved by Snapshot.Symbolize.
+	PC uint64
 
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
@@ -390,24 +514,42 @@ func (c *Call) init(srcPath string, line int) {
 	c.ImportPath = c.Func.ImportPath
 }
 
+// sanitizeUTF8 replaces the invalid UTF-8 sequences in the function and the
+// source path with U+FFFD.
+//
+// Returns true if anything was replaced.
+func (c *Call) sanitizeUTF8() bool {
+	changed := false
+	for _, v := range []*string{&c.Func.Complete, &c.Func.ImportPath, &c.Func.DirName, &c.Func.Name, &c.RemoteSrcPath, &c.SrcName, &c.DirSrc, &c.ImportPath} {
+		if !utf8.ValidString(*v) {
+			*v = strings.ToValidUTF8(*v, "\uFFFD")
+			changed = true
+		}
+	}
+	return changed
+}
+
 const testMainSrc = "_test" + string(os.PathSeparator) + "_testmain.go"
 
 // updateLocations initializes LocalSrcPath, RelSrcPath, Location and ImportPath.
 //
-// goroot, localgoroot, localgomod, gomodImportPath and gopaths are expected to
-// be in "/" format even on Windows. They must not have a trailing "/".
+// goroot, localgoroot, localgomods and gopaths are expected to be in "/" format
+// even on Windows. They must not have a trailing "/".
+//
+// pc is used to match paths that differ only by symlinks or by case on
+// Windows. It can be nil.
 //
 // Returns true if a match was found.
-func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths map[string]string) bool {
+func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths roots, pc pathCache) bool {
 	// TODO(maruel): Reduce memory allocations.
 	if c.RemoteSrcPath == "" {
 		return false
 	}
 	// Check GOROOT first.
 	if goroot != "" {
-		if prefix := goroot + "/src/"; strings.HasPrefix(c.RemoteSrcPath, prefix) {
+		if rel, ok := pc.rel(c.RemoteSrcPath, goroot+"/src"); ok {
 			// Replace remote GOROOT with local GOROOT.
-			c.RelSrcPath = c.RemoteSrcPath[len(prefix):]
+			c.RelSrcPath = rel
 			c.LocalSrcPath = pathJoin(localgoroot, "src", c.RelSrcPath)
 			if i := strings.LastIndexByte(c.RelSrcPath, '/'); i != -1 {
 				c.ImportPath = c.RelSrcPath[:i]
@@ -418,11 +560,12 @@ func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths
 			return true
 		}
 	}
-	// Check GOPATH.
-	// TODO(maruel): Sort for deterministic behavior?
-	for prefix, dest := range gopaths {
-		if p := prefix + "/src/"; strings.HasPrefix(c.RemoteSrcPath, p) {
-			c.RelSrcPath = c.RemoteSrcPath[len(p):]
+	// Check GOPATH. The most specific root wins so the result doesn't depend on
+	// the map iteration order.
+	for _, prefix := range gopaths.keys {
+		dest := gopaths.m[prefix]
+		if rel, ok := pc.rel(c.RemoteSrcPath, prefix+"/src"); ok {
+			c.RelSrcPath = rel
 			c.LocalSrcPath = pathJoin(dest, "src", c.RelSrcPath)
 			if i := strings.LastIndexByte(c.RelSrcPath, '/'); i != -1 {
 				c.ImportPath = c.RelSrcPath[:i]
@@ -433,8 +576,8 @@ func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths
 			return true
 		}
 		// For modules, the path has to be altered, as it contains the version.
-		if p := prefix + "/pkg/mod/"; strings.HasPrefix(c.RemoteSrcPath, p) {
-			c.RelSrcPath = c.RemoteSrcPath[len(p):]
+		if rel, ok := pc.rel(c.RemoteSrcPath, prefix+"/pkg/mod"); ok {
+			c.RelSrcPath = rel
 			c.LocalSrcPath = pathJoin(dest, "pkg/mod", c.RelSrcPath)
 			if i := strings.LastIndexByte(c.RelSrcPath, '/'); i != -1 {
 				c.ImportPath = c.RelSrcPath[:i]
@@ -448,9 +591,10 @@ func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths
 	// Check Go modules.
 	// Go module path detection only works with stack traces created on the local
 	// file system.
-	for prefix, pkg := range localgomods {
-		if strings.HasPrefix(c.RemoteSrcPath, prefix+"/") {
-			c.RelSrcPath = c.RemoteSrcPath[len(prefix)+1:]
+	for _, prefix := range localgomods.keys {
+		pkg := localgomods.m[prefix]
+		if rel, ok := pc.rel(c.RemoteSrcPath, prefix); ok {
+			c.RelSrcPath = rel
 			c.LocalSrcPath = c.RemoteSrcPath
 			if i := strings.LastIndexByte(c.RelSrcPath, '/'); i != -1 {
 				c.ImportPath = pkg + "/" + c.RelSrcPath[:i]
@@ -467,19 +611,58 @@ func (c *Call) updateLocations(goroot, localgoroot string, localgomods, gopaths
 	return false
 }
 
-// equal returns true only if both calls are exactly equal.
-func (c *Call) equal(r *Call) bool {
-	return c.Line == r.Line && c.Func.Complete == r.Func.Complete && c.RemoteSrcPath == r.RemoteSrcPath && c.Args.equal(&r.Args)
+// roots is a map of root paths along with its keys in the order returned by
+// sortedRoots.
+//
+// It is computed once per snapshot since it is used for each call.
+type roots struct {
+	m    map[string]string
+	keys []string
 }
 
-// similar returns true if the two Call are equal or almost but not quite
+func newRoots(m map[string]string) roots {
+	return roots{m: m, keys: sortedRoots(m)}
+}
+
+// sortedRoots returns the keys of m, longest first, then in lexical order.
+//
+// Iterating in this order ensures that a nested root is tried before its
+// parent.
+func sortedRoots(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i]) != len(out[j]) {
+			return len(out[i]) > len(out[j])
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// EqualTo returns true only if both calls are exactly equal, including the
+// argument values.
+func (c *Call) EqualTo(r *Call) bool {
+	return c.Line == r.Line && c.Func.Complete == r.Func.Complete && c.RemoteSrcPath == r.RemoteSrcPath && c.PC == r.PC && c.Args.equal(&r.Args)
+}
+
+// SimilarTo returns true if the two Call are equal or almost but not quite
 // equal.
-func (c *Call) similar(r *Call, similar Similarity) bool {
-	return c.Line == r.Line && c.Func.Complete == r.Func.Complete && c.RemoteSrcPath == r.RemoteSrcPath && c.Args.similar(&r.Args, similar)
+//
+// The function, source path and line must be equal; the arguments are compared
+// according to similar.
+func (c *Call) SimilarTo(r *Call, similar Similarity) bool {
+	return c.Line == r.Line && c.Func.Complete == r.Func.Complete && c.RemoteSrcPath == r.RemoteSrcPath && c.PC == r.PC && c.Args.similar(&r.Args, similar)
 }
 
-// merge merges two similar Call, zapping out differences.
-func (c *Call) merge(r *Call) Call {
+// Merge merges two similar Call, zapping out differences.
+//
+// The arguments that differ are replaced with a pseudo name, like "*". r must
+// be similar to c as determined by SimilarTo, otherwise the result is
+// undefined.
+func (c *Call) Merge(r *Call) Call {
 	return Call{
 		Func:          c.Func,
 		Args:          c.Args.merge(&r.Args),
@@ -491,9 +674,25 @@ func (c *Call) merge(r *Call) Call {
 		RelSrcPath:    c.RelSrcPath,
 		ImportPath:    c.ImportPath,
 		Location:      c.Location,
+		PC:            c.PC,
 	}
 }
 
+// equal is a thin wrapper around EqualTo.
+func (c *Call) equal(r *Call) bool {
+	return c.EqualTo(r)
+}
+
+// similar is a thin wrapper around SimilarTo.
+func (c *Call) similar(r *Call, similar Similarity) bool {
+	return c.SimilarTo(r, similar)
+}
+
+// merge is a thin wrapper around Merge.
+func (c *Call) merge(r *Call) Call {
+	return c.Merge(r)
+}
+
 // Stack is a call stack.
 type Stack struct {
 	// Calls is the call stack. First is original function, last is leaf
@@ -502,51 +701,265 @@ type Stack struct {
 	// Elided is set when there's >100 items in Stack, currently hardcoded in
 	// package runtime.
 	Elided bool
+	// ElidedFrames is the number of frames skipped by the runtime in the middle
+	// of a very deep stack, as printed by go1.21 and later. Elided is also set
+	// in this case.
+	ElidedFrames int
+	// Folded is the number of calls that were removed because they repeated
+	// the preceding ones, e.g. in a runaway recursion.
+	Folded int
+	// TrailingRuntime is the number of calls at the end of Calls that are
+	// ignored when comparing and hashing stacks, e.g. runtime.goexit. It is
+	// only set with Opts.IgnoreTrailingRuntime.
+	TrailingRuntime int
+	// Unavailable is set when the race detector printed "[failed to restore
+	// the stack]" instead of the stack, e.g. for the creation stack of a
+	// goroutine that started long before the race. Calls is empty.
+	Unavailable bool
 
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
 
-// equal returns true on if both call stacks are exactly equal.
-func (s *Stack) equal(r *Stack) bool {
-	if len(s.Calls) != len(r.Calls) || s.Elided != r.Elided {
+// Depth returns the number of frames in the stack as printed by the runtime,
+// including the elided and folded ones.
+func (s *Stack) Depth() int {
+	return len(s.Calls) + s.ElidedFrames + s.Folded
+}
+
+// EqualTo returns true only if both call stacks are exactly equal, ignoring
+// the trailing calls counted in TrailingRuntime.
+func (s *Stack) EqualTo(r *Stack) bool {
+	l, rl := s.compared(), r.compared()
+	if len(l) != len(rl) || s.Elided != r.Elided || s.Unavailable != r.Unavailable {
 		return false
 	}
-	for i := range s.Calls {
-		if !s.Calls[i].equal(&r.Calls[i]) {
+	for i := range l {
+		if !l[i].equal(&rl[i]) {
 			return false
 		}
 	}
 	return true
 }
 
-// similar returns true if the two Stack are equal or almost but not quite
+// SimilarTo returns true if the two Stack are equal or almost but not quite
 // equal.
-func (s *Stack) similar(r *Stack, similar Similarity) bool {
-	if len(s.Calls) != len(r.Calls) || s.Elided != r.Elided {
+//
+// Both stacks must have the same number of calls and each call must be
+// similar as determined by Call.SimilarTo. The trailing calls counted in
+// TrailingRuntime are ignored.
+func (s *Stack) SimilarTo(r *Stack, similar Similarity) bool {
+	l, rl := s.compared(), r.compared()
+	if len(l) != len(rl) || s.Elided != r.Elided || s.Unavailable != r.Unavailable {
 		return false
 	}
-	for i := range s.Calls {
-		if !s.Calls[i].similar(&r.Calls[i], similar) {
+	for i := range l {
+		if !l[i].similar(&rl[i], similar) {
 			return false
 		}
 	}
 	return true
 }
 
-// merge merges two similar Stack, zapping out differences.
-func (s *Stack) merge(r *Stack) *Stack {
-	// Assumes similar stacks have the same length.
+// Merge merges two similar Stack, zapping out differences.
+//
+// r must be similar to s as determined by SimilarTo. It panics if r has fewer
+// calls than s.
+func (s *Stack) Merge(r *Stack) *Stack {
+	// Assumes similar stacks have the same length, ignoring the trailing
+	// runtime calls. Those of s are kept as-is.
 	out := &Stack{
-		Calls:  make([]Call, len(s.Calls)),
-		Elided: s.Elided,
+		Calls:           make([]Call, len(s.Calls)),
+		Elided:          s.Elided,
+		TrailingRuntime: s.TrailingRuntime,
+		Unavailable:     s.Unavailable,
+	}
+	n := len(s.compared())
+	for i := range s.Calls {
+		if i < n {
+			out.Calls[i] = s.Calls[i].merge(&r.Calls[i])
+		} else {
+			out.Calls[i] = s.Calls[i]
+		}
+	}
+	return out
+}
+
+// compared returns the calls used for comparison, excluding the trailing
+// runtime calls.
+func (s *Stack) compared() []Call {
+	return s.Calls[:len(s.Calls)-s.TrailingRuntime]
+}
+
+// trailingRuntimeFuncs are the calls printed at the bottom of the stacks by
+// some Go versions only.
+var trailingRuntimeFuncs = map[string]bool{
+	"runtime.goexit":  true,
+	"runtime.goexit0": true,
+	"runtime.goexit1": true,
+}
+
+// trailingRuntime returns the number of trailingRuntimeFuncs calls at the end
+// of the stack.
+func (s *Stack) trailingRuntime() int {
+	n := 0
+	for i := len(s.Calls) - 1; i >= 0 && trailingRuntimeFuncs[s.Calls[i].Func.Complete]; i-- {
+		n++
+	}
+	return n
+}
+
+// equal is a thin wrapper around EqualTo.
+func (s *Stack) equal(r *Stack) bool {
+	return s.EqualTo(r)
+}
+
+// similar is a thin wrapper around SimilarTo.
+func (s *Stack) similar(r *Stack, similar Similarity) bool {
+	return s.SimilarTo(r, similar)
+}
+
+// merge is a thin wrapper around Merge.
+func (s *Stack) merge(r *Stack) *Stack {
+	return s.Merge(r)
+}
+
+// maxFoldPeriod is the longest sequence of calls that fold looks for.
+const maxFoldPeriod = 16
+
+// fold removes the consecutive repetitions of sequences of calls, keeping
+// only one occurrence of each sequence.
+//
+// The arguments are ignored, as they normally differ in each recursion.
+func (s *Stack) fold() {
+	same := func(i, j, n int) bool {
+		for k := 0; k < n; k++ {
+			a, b := &s.Calls[i+k], &s.Calls[j+k]
+			if a.Line != b.Line || a.Func.Complete != b.Func.Complete || a.RemoteSrcPath != b.RemoteSrcPath {
+				return false
+			}
+		}
+		return true
+	}
+	out := s.Calls[:0]
+	for i := 0; i < len(s.Calls); {
+		p := 1
+		for ; p <= maxFoldPeriod && i+2*p <= len(s.Calls); p++ {
+			if same(i, i+p, p) {
+				break
+			}
+		}
+		if p > maxFoldPeriod || i+2*p > len(s.Calls) {
+			out = append(out, s.Calls[i])
+			i++
+			continue
+		}
+		// Skip all the repetitions.
+		j := i + p
+		for j+p <= len(s.Calls) && same(i, j, p) {
+			j += p
+		}
+		out = append(out, s.Calls[i:i+p]...)
+		s.Folded += j - i - p
+		i = j
 	}
+	s.Calls = out
+}
+
+// deferTriggers are the functions that run deferred functions. The calls
+// above them in the stack are executed as part of deferred execution.
+var deferTriggers = map[string]bool{
+	"panic":                     true,
+	"runtime.gopanic":           true,
+	"runtime.Goexit":            true,
+	"runtime.deferreturn":       true,
+	"runtime.deferCallSave":     true,
+	"runtime.runOpenDeferFrame": true,
+}
+
+// Deferred returns, for each call in Calls, whether it is executed as part of
+// a deferred function call.
+//
+// Deferred functions are run by a panic, by runtime.Goexit or on function
+// return. When a deferred function panics again, the nested chain is detected
+// too. Returns nil if no call is deferred.
+func (s *Stack) Deferred() []bool {
+	// Calls[0] is the innermost call. Find the outermost trigger; everything
+	// called from it runs as part of deferred execution.
+	last := -1
 	for i := range s.Calls {
-		out.Calls[i] = s.Calls[i].merge(&r.Calls[i])
+		if deferTriggers[s.Calls[i].Func.Complete] {
+			last = i
+		}
+	}
+	if last <= 0 {
+		return nil
+	}
+	out := make([]bool, len(s.Calls))
+	for i := 0; i < last; i++ {
+		out[i] = !deferTriggers[s.Calls[i].Func.Complete]
 	}
 	return out
 }
 
+// panicTriggers are the calls that start the panic machinery.
+var panicTriggers = map[string]bool{
+	"panic":            true,
+	"runtime.gopanic":  true,
+	"runtime.sigpanic": true,
+}
+
+// PanicIndex returns the index in Calls of the outermost call to panic(), or
+// -1 if there is none.
+//
+// The calls before it are the code that actually panicked, including the
+// deferred functions run by the panic. The call after it, if any, is the one
+// that panicked.
+func (s *Stack) PanicIndex() int {
+	last := -1
+	for i := range s.Calls {
+		if panicTriggers[s.Calls[i].Func.Complete] {
+			last = i
+		}
+	}
+	return last
+}
+
+// CulpritIndex returns the index in Calls of the call most likely responsible
+// for the panic: the first call outside the standard library starting at the
+// one that called panic(), or at the top of the stack if there is no call to
+// panic(). Returns -1 if there is none.
+func (s *Stack) CulpritIndex() int {
+	for i := s.PanicIndex() + 1; i < len(s.Calls); i++ {
+		if s.Calls[i].Location != Stdlib {
+			return i
+		}
+	}
+	return -1
+}
+
+// systemStackFuncs are the calls that only happen on a system stack.
+var systemStackFuncs = map[string]bool{
+	"runtime.mcall":      true,
+	"runtime.mstart":     true,
+	"runtime.mstart0":    true,
+	"runtime.mstart1":    true,
+	"runtime.sighandler": true,
+	"runtime.sigtramp":   true,
+	"runtime.sigtrampgo": true,
+}
+
+// isSystemStack returns true if the stack contains C frames or calls that
+// only run on a system stack.
+func (s *Stack) isSystemStack() bool {
+	for i := range s.Calls {
+		if systemStackFuncs[s.Calls[i].Func.Complete] || strings.HasSuffix(s.Calls[i].SrcName, ".c") {
+			return true
+		}
+	}
+	return false
+}
+
 // less compares two Stack, where the ones that are less are more
 // important, so they come up front.
 //
@@ -619,11 +1032,11 @@ func (s *Stack) less(r *Stack) bool {
 
 // updateLocations calls updateLocations on each call frame and returns true if
 // they were all resolved.
-func (s *Stack) updateLocations(goroot, localgoroot string, localgomods, gopaths map[string]string) bool {
+func (s *Stack) updateLocations(goroot, localgoroot string, localgomods, gopaths roots, pc pathCache) bool {
 	// If there were none, it was "resolved".
 	r := true
 	for i := range s.Calls {
-		r = s.Calls[i].updateLocations(goroot, localgoroot, localgomods, gopaths) && r
+		r = s.Calls[i].updateLocations(goroot, localgoroot, localgomods, gopaths, pc) && r
 	}
 	return r
 }
@@ -676,33 +1089,52 @@ type Signature struct {
 	//
 	// Not set when running under the race detector.
 	Locked bool
+	// Extra is the list of unrecognized annotations in the goroutine header,
+	// either after the state or in additional bracketed segments.
+	//
+	// Newer runtimes may add annotations that this package doesn't know about
+	// yet; they are preserved here.
+	Extra []string
+	// Labels are the pprof labels of the goroutine, as set with
+	// runtime/pprof.Do.
+	//
+	// Only the goroutine profile format (debug=1) includes them.
+	Labels map[string]string
 
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
 
-// equal returns true only if both signatures are exactly equal.
-func (s *Signature) equal(r *Signature) bool {
-	if s.State != r.State || !s.CreatedBy.equal(&r.CreatedBy) || s.Locked != r.Locked || s.SleepMin != r.SleepMin || s.SleepMax != r.SleepMax {
+// EqualTo returns true only if both signatures are exactly equal.
+func (s *Signature) EqualTo(r *Signature) bool {
+	if s.State != r.State || !s.CreatedBy.equal(&r.CreatedBy) || s.Locked != r.Locked || s.SleepMin != r.SleepMin || s.SleepMax != r.SleepMax || !equalStrings(s.Extra, r.Extra) || !equalLabels(s.Labels, r.Labels) {
 		return false
 	}
 	return s.Stack.equal(&r.Stack)
 }
 
-// similar returns true if the two Signature are equal or almost but not quite
-// equal.
-func (s *Signature) similar(r *Signature, similar Similarity) bool {
-	if s.State != r.State || !s.CreatedBy.similar(&r.CreatedBy, similar) {
+// SimilarTo returns true if the two Signature are equal or almost but not
+// quite equal.
+//
+// This is the comparison used by Aggregate to put goroutines in the same
+// Bucket. The sleep duration is always ignored. The locked state and the
+// extra annotations are only compared with ExactFlags. The labels are always
+// compared.
+func (s *Signature) SimilarTo(r *Signature, similar Similarity) bool {
+	if s.State != r.State || !equalLabels(s.Labels, r.Labels) || !s.CreatedBy.similar(&r.CreatedBy, similar) {
 		return false
 	}
-	if similar == ExactFlags && s.Locked != r.Locked {
+	if similar == ExactFlags && (s.Locked != r.Locked || !equalStrings(s.Extra, r.Extra)) {
 		return false
 	}
 	return s.Stack.similar(&r.Stack, similar)
 }
 
-// merge merges two similar Signature, zapping out differences.
-func (s *Signature) merge(r *Signature) *Signature {
+// Merge merges two similar Signature, zapping out differences.
+//
+// The sleep duration range is widened to cover both. r must be similar to s as
+// determined by SimilarTo, otherwise the result is undefined.
+func (s *Signature) Merge(r *Signature) *Signature {
 	min := s.SleepMin
 	if r.SleepMin < min {
 		min = r.SleepMin
@@ -711,16 +1143,38 @@ func (s *Signature) merge(r *Signature) *Signature {
 	if r.SleepMax > max {
 		max = r.SleepMax
 	}
+	// The arguments of the creator are merged like any other call.
+	createdBy := s.CreatedBy
+	if len(createdBy.Calls) != 0 {
+		createdBy = *s.CreatedBy.merge(&r.CreatedBy)
+	}
 	return &Signature{
-		State:     s.State,     // Drop right side.
-		CreatedBy: s.CreatedBy, // Drop right side.
+		State:     s.State, // Drop right side.
+		CreatedBy: createdBy,
 		SleepMin:  min,
 		SleepMax:  max,
 		Stack:     *s.Stack.merge(&r.Stack),
 		Locked:    s.Locked || r.Locked, // TODO(maruel): This is weirdo.
+		Extra:     s.Extra,              // Drop right side.
+		Labels:    s.Labels,
 	}
 }
 
+// equal is a thin wrapper around EqualTo.
+func (s *Signature) equal(r *Signature) bool {
+	return s.EqualTo(r)
+}
+
+// similar is a thin wrapper around SimilarTo.
+func (s *Signature) similar(r *Signature, similar Similarity) bool {
+	return s.SimilarTo(r, similar)
+}
+
+// merge is a thin wrapper around Merge.
+func (s *Signature) merge(r *Signature) *Signature {
+	return s.Merge(r)
+}
+
 // less compares two Signature, where the ones that are less are more
 // important, so they come up front. A Signature with more private functions is
 // 'less' so it is at the top. Inversely, a Signature with only public
@@ -761,11 +1215,27 @@ func (s *Signature) SleepString() string {
 	return fmt.Sprintf("%d minutes", s.SleepMax)
 }
 
+// Hash returns a stable identifier for the call stack as a 16 characters
+// hexadecimal string.
+//
+// Only the function names and line numbers are used, so the value is the same
+// across hosts, source paths and argument values. It is useful to correlate
+// the same crash across multiple processes. The trailing calls counted in
+// Stack.TrailingRuntime are ignored.
+func (s *Signature) Hash() string {
+	h := fnv.New64a()
+	calls := s.Stack.compared()
+	for i := range calls {
+		fmt.Fprintf(h, "%s:%d\n", calls[i].Func.Complete, calls[i].Line)
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
 // updateLocations calls updateLocations on both CreatedBy and Stack and
 // returns true if they were both resolved.
-func (s *Signature) updateLocations(goroot, localgoroot string, localgomods, gopaths map[string]string) bool {
-	r := s.CreatedBy.updateLocations(goroot, localgoroot, localgomods, gopaths)
-	r = s.Stack.updateLocations(goroot, localgoroot, localgomods, gopaths) && r
+func (s *Signature) updateLocations(goroot, localgoroot string, localgomods, gopaths roots, pc pathCache) bool {
+	r := s.CreatedBy.updateLocations(goroot, localgoroot, localgomods, gopaths, pc)
+	r = s.Stack.updateLocations(goroot, localgoroot, localgomods, gopaths, pc) && r
 	return r
 }
 
@@ -778,6 +1248,24 @@ type Goroutine struct {
 	ID int
 	// First is the goroutine first printed, normally the one that crashed.
 	First bool
+	// CreatedByID is the ID of the goroutine that created this goroutine.
+	//
+	// It is only set with traces generated by go1.21 and later. It is 0 when
+	// unknown.
+	CreatedByID int
+	// GP is the address of the runtime g structure. It is only printed with
+	// GOTRACEBACK=system or higher.
+	GP uint64
+	// M is the id of the OS thread (runtime m) running this goroutine. It is
+	// only meaningful when MP is not 0.
+	M int
+	// MP is the address of the runtime m structure running this goroutine. It
+	// is 0 if the goroutine is not running on an OS thread or if it is unknown.
+	MP uint64
+	// RuntimeInfo is all the key=value pairs printed in the goroutine header,
+	// e.g. "gp", "m" and "mp". Pairs with a "nil" value are skipped. It is nil
+	// if the header had none.
+	RuntimeInfo map[string]uint64
 
 	// RaceWrite is true if a race condition was detected, and this goroutine was
 	// race on a write operation, otherwise it was a read.
@@ -786,37 +1274,79 @@ type Goroutine struct {
 	// Otherwise it is 0.
 	RaceAddr uint64
 
+	// OnSystemStack is true when this is not a user goroutine but a system
+	// stack, like g0 or a signal handler. These are printed when the crash
+	// happens inside the runtime and commonly contain C or assembly frames.
+	//
+	// They are excluded from argument naming and source analysis.
+	OnSystemStack bool
+
 	// Disallow initialization with unnamed parameters.
 	_ struct{}
 }
 
 // Private stuff.
 
+func equalStrings(l, r []string) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for i := range l {
+		if l[i] != r[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalLabels(l, r map[string]string) bool {
+	if len(l) != len(r) {
+		return false
+	}
+	for k, v := range l {
+		if w, ok := r[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
 // nameArguments is a post-processing step where Args are 'named' with numbers.
-func nameArguments(goroutines []*Goroutine) {
+//
+// It returns the goroutine IDs referencing each name.
+func nameArguments(goroutines []*Goroutine) map[string][]int {
 	// Set a name for any pointer occurring more than once.
 	type object struct {
 		args      []*Arg
+		ids       []int
 		inPrimary bool
 	}
 	objects := map[uint64]object{}
 	// Enumerate all the arguments.
 	primary := true
+	id := 0
 	visit := func(arg *Arg) {
 		if arg.IsPtr {
+			o := objects[arg.Value]
+			if len(o.ids) == 0 || o.ids[len(o.ids)-1] != id {
+				o.ids = append(o.ids, id)
+			}
 			objects[arg.Value] = object{
-				args:      append(objects[arg.Value].args, arg),
-				inPrimary: objects[arg.Value].inPrimary || primary,
+				args:      append(o.args, arg),
+				ids:       o.ids,
+				inPrimary: o.inPrimary || primary,
 			}
 		}
 	}
 	for i, g := range goroutines {
 		primary = i == 0
+		id = g.ID
 		for _, c := range g.Stack.Calls {
 			c.Args.walk(visit)
 		}
 		// CreatedBy.Args is never set.
 	}
+	named := map[string][]int{}
 	order := make(uint64Slice, 0, len(objects)/2)
 	for k, obj := range objects {
 		if len(obj.args) > 1 && obj.inPrimary {
@@ -826,9 +1356,11 @@ func nameArguments(goroutines []*Goroutine) {
 	sort.Sort(order)
 	nextID := 1
 	for _, k := range order {
+		name := fmt.Sprintf("#%d", nextID)
 		for _, arg := range objects[k].args {
-			arg.Name = fmt.Sprintf("#%d", nextID)
+			arg.Name = name
 		}
+		named[name] = sortedIDs(objects[k].ids)
 		nextID++
 	}
 
@@ -844,11 +1376,26 @@ func nameArguments(goroutines []*Goroutine) {
 		if objects[k].inPrimary {
 			continue
 		}
+		name := fmt.Sprintf("#%d", nextID)
 		for _, arg := range objects[k].args {
-			arg.Name = fmt.Sprintf("#%d", nextID)
+			arg.Name = name
 		}
+		named[name] = sortedIDs(objects[k].ids)
 		nextID++
 	}
+	return named
+}
+
+// sortedIDs sorts and deduplicates goroutine IDs in place.
+func sortedIDs(ids []int) []int {
+	sort.Ints(ids)
+	out := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			out = append(out, id)
+		}
+	}
+	return out
 }
 
 func pathJoin(s ...string) string {
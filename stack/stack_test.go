@@ -5,11 +5,14 @@
 package stack
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -215,7 +218,7 @@ func TestCallPkg(t *testing.T) {
 			// Equivalent of calling GuessPaths().
 			gp := map[string]string{"/gpremote": "/gplocal"}
 			gm := map[string]string{"/gomod": "example.com/foo"}
-			if !c.updateLocations("/grremote", "/grlocal", gm, gp) {
+			if !c.updateLocations("/grremote", "/grlocal", newRoots(gm), newRoots(gp), nil) {
 				t.Error("Unexpected")
 			}
 			compareString(t, line.ImportPath, c.ImportPath)
@@ -228,6 +231,79 @@ func TestCallPkg(t *testing.T) {
 	}
 }
 
+func TestCallUpdateLocationsNested(t *testing.T) {
+	t.Parallel()
+	gm := map[string]string{
+		"/gomod":              "example.com/foo",
+		"/gomod/sub":          "example.com/sub",
+		"/gomod/sub/internal": "example.com/sub/internal",
+	}
+	gp := map[string]string{"/gp": "/gplocal", "/gp/src/vendor": "/vendorlocal"}
+	// Repeat to catch a dependency on the map iteration order.
+	for i := 0; i < 20; i++ {
+		c := newCall("example.com/sub/pkg.Func", Args{}, "/gomod/sub/pkg/a.go", 12)
+		if !c.updateLocations("", "", newRoots(gm), roots{}, nil) {
+			t.Fatal("expected match")
+		}
+		compareString(t, "example.com/sub/pkg", c.ImportPath)
+		c = newCall("pkg.Func", Args{}, "/gp/src/vendor/src/pkg/a.go", 12)
+		if !c.updateLocations("", "", roots{}, newRoots(gp), nil) {
+			t.Fatal("expected match")
+		}
+		compareString(t, "/vendorlocal/src/pkg/a.go", c.LocalSrcPath)
+	}
+	want := []string{"/gomod/sub/internal", "/gomod/sub", "/gomod"}
+	if diff := cmp.Diff(want, sortedRoots(gm)); diff != "" {
+		t.Fatalf("sortedRoots() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCallUpdateLocationsSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require elevated privileges on Windows")
+	}
+	t.Parallel()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(root, "target")
+	if err := os.MkdirAll(filepath.Join(target, "src", "fmt"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "src", "fmt", "print.go"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	// The root was found through the symlink but the trace has the target path.
+	c := newCall("fmt.Println", Args{}, target+"/src/fmt/print.go", 12)
+	pc := pathCache{}
+	if !c.updateLocations(link, link, roots{}, roots{}, pc) {
+		t.Fatal("expected match")
+	}
+	compareString(t, "fmt/print.go", c.RelSrcPath)
+	compareString(t, link+"/src/fmt/print.go", c.LocalSrcPath)
+	compareString(t, "fmt", c.ImportPath)
+	if c.Location != Stdlib {
+		t.Fatalf("want %s, got %s", Stdlib, c.Location)
+	}
+	compareString(t, target+"/src", pc[link+"/src"])
+
+	// Without the cache, it still works, just slower.
+	c = newCall("fmt.Println", Args{}, target+"/src/fmt/print.go", 12)
+	if !c.updateLocations(link, link, roots{}, roots{}, nil) {
+		t.Fatal("expected match")
+	}
+	// Unrelated paths are not matched.
+	c = newCall("fmt.Println", Args{}, root+"/other/src/fmt/print.go", 12)
+	if c.updateLocations(link, link, roots{}, roots{}, pc) {
+		t.Fatal("unexpected match")
+	}
+}
+
 func TestArgs(t *testing.T) {
 	t.Parallel()
 	a := Args{
@@ -267,8 +343,126 @@ func TestArgs(t *testing.T) {
 	compareString(t, "4, 0x7fff671c7118, 0xffffffff00000080, 0, 0xffffffff0028c1be, foo, "+
 		"0, 0, _, {}, {{{}}}, {1, 0x7fff671c7118}, {{5, ...}}, {...}, {{_, _}}, ...", a.String())
 
+	compareString(t, "4, 0x7fff671c7118, 0xffffffff00000080, 0, 0xffffffff0028c1be, foo, "+
+		"0, 0, _, {}, {{...}}, {1, ...}, {{...}}, {...}, {{...}}, ...", a.Format(&ArgsLimits{MaxDepth: 1, MaxElements: 1}))
+	compareString(t, a.String(), a.Format(&ArgsLimits{}))
+
 	a = Args{Processed: []string{"yo"}}
 	compareString(t, "yo", a.String())
+	compareString(t, "yo", a.Format(&ArgsLimits{MaxDepth: 1}))
+}
+
+func TestConfidence_JSON(t *testing.T) {
+	t.Parallel()
+	a := Args{Processed: []string{"1", "2", "3"}, Confidence: []Confidence{Exact, Uncertain, Inferred}}
+	b, err := json.Marshal(a.Confidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareString(t, `["exact","uncertain","inferred"]`, string(b))
+	var got []Confidence
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(a.Confidence, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	if err := json.Unmarshal([]byte(`["sure"]`), &got); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := json.Marshal(Confidence(3)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestStack_Deferred(t *testing.T) {
+	t.Parallel()
+	// main.main -> panic -> main.main.func1 (deferred) -> panic -> main.main.func1.1 (deferred)
+	s := Stack{
+		Calls: []Call{
+			newCall("main.main.func1.1", Args{}, "/a/main.go", 6),
+			newCall("panic", Args{}, "/goroot/src/runtime/panic.go", 770),
+			newCall("main.main.func1", Args{}, "/a/main.go", 9),
+			newCall("panic", Args{}, "/goroot/src/runtime/panic.go", 770),
+			newCall("main.main", Args{}, "/a/main.go", 12),
+		},
+	}
+	want := []bool{true, false, true, false, false}
+	if diff := cmp.Diff(want, s.Deferred()); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	s = Stack{
+		Calls: []Call{
+			newCall("main.cleanup", Args{}, "/a/main.go", 6),
+			newCall("runtime.Goexit", Args{}, "/goroot/src/runtime/panic.go", 600),
+			newCall("main.worker", Args{}, "/a/main.go", 12),
+		},
+	}
+	if diff := cmp.Diff([]bool{true, false, false}, s.Deferred()); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+	s.Calls = s.Calls[1:]
+	if d := s.Deferred(); d != nil {
+		t.Fatalf("unexpected %v", d)
+	}
+}
+
+func TestStack_PanicIndex(t *testing.T) {
+	t.Parallel()
+	s := Stack{
+		Calls: []Call{
+			newCall("main.main.func1", Args{}, "/a/main.go", 6),
+			newCall("panic", Args{}, "/goroot/src/runtime/panic.go", 770),
+			newCall("main.main", Args{}, "/a/main.go", 12),
+			newCall("panic", Args{}, "/goroot/src/runtime/panic.go", 770),
+			newCall("main.run", Args{}, "/a/main.go", 20),
+		},
+	}
+	if i := s.PanicIndex(); i != 3 {
+		t.Fatalf("unexpected %d", i)
+	}
+	s = Stack{
+		Calls: []Call{
+			newCall("runtime.panicmem", Args{}, "/goroot/src/runtime/panic.go", 260),
+			newCall("runtime.sigpanic", Args{}, "/goroot/src/runtime/signal_unix.go", 839),
+			newCall("main.deref", Args{}, "/a/main.go", 6),
+		},
+	}
+	if i := s.PanicIndex(); i != 1 {
+		t.Fatalf("unexpected %d", i)
+	}
+	s.Calls = s.Calls[2:]
+	if i := s.PanicIndex(); i != -1 {
+		t.Fatalf("unexpected %d", i)
+	}
+}
+
+func TestStack_CulpritIndex(t *testing.T) {
+	t.Parallel()
+	s := Stack{
+		Calls: []Call{
+			newCall("main.main.func1", Args{}, "/a/main.go", 6),
+			newCall("panic", Args{}, "/goroot/src/runtime/panic.go", 770),
+			newCall("sync.(*Mutex).Unlock", Args{}, "/goroot/src/sync/mutex.go", 190),
+			newCall("main.main", Args{}, "/a/main.go", 12),
+		},
+	}
+	s.Calls[1].Location = Stdlib
+	s.Calls[2].Location = Stdlib
+	// The standard library call to panic() is skipped.
+	if i := s.CulpritIndex(); i != 3 {
+		t.Fatalf("unexpected %d", i)
+	}
+	s.Calls = s.Calls[:3]
+	if i := s.CulpritIndex(); i != -1 {
+		t.Fatalf("unexpected %d", i)
+	}
+	// Without panic(), the first call outside the standard library.
+	s.Calls = s.Calls[2:]
+	s.Calls = append(s.Calls, newCall("main.run", Args{}, "/a/main.go", 20))
+	if i := s.CulpritIndex(); i != 1 {
+		t.Fatalf("unexpected %d", i)
+	}
 }
 
 func TestSignature(t *testing.T) {
@@ -281,6 +475,24 @@ func TestSignature(t *testing.T) {
 	compareString(t, "10 minutes", s.SleepString())
 }
 
+func TestSignature_Hash(t *testing.T) {
+	t.Parallel()
+	s1 := getSignature()
+	s2 := getSignature()
+	h := s1.Hash()
+	if len(h) != 16 {
+		t.Fatalf("unexpected %q", h)
+	}
+	// Neither the state nor the arguments are taken into account.
+	s2.State = "foo"
+	s2.Stack.Calls[0].Args = Args{}
+	compareString(t, h, s2.Hash())
+	s2.Stack.Calls[0].Line++
+	if s2.Hash() == h {
+		t.Fatal("expected different hash")
+	}
+}
+
 func TestSignature_Equal(t *testing.T) {
 	t.Parallel()
 	s1 := getSignature()
@@ -307,6 +519,49 @@ func TestSignature_Similar(t *testing.T) {
 	}
 }
 
+func TestSignature_Exported(t *testing.T) {
+	t.Parallel()
+	s1 := getSignature()
+	s2 := getSignature()
+	s2.Stack.Calls[0].Args.Values[0].Value = 0x12000000
+	s2.Stack.Calls[0].Args.Values[0].IsPtr = true
+	if !s1.EqualTo(getSignature()) || s1.EqualTo(s2) {
+		t.Fatal("EqualTo")
+	}
+	if s1.SimilarTo(s2, ExactLines) || !s1.SimilarTo(s2, AnyValue) {
+		t.Fatal("SimilarTo")
+	}
+	if !s1.Stack.SimilarTo(&s2.Stack, AnyValue) || s1.Stack.EqualTo(&s2.Stack) {
+		t.Fatal("Stack")
+	}
+	if !s1.Stack.Calls[1].EqualTo(&s2.Stack.Calls[1]) || !s1.Stack.Calls[0].SimilarTo(&s2.Stack.Calls[0], AnyValue) {
+		t.Fatal("Call")
+	}
+	m := s1.Merge(s2)
+	compareString(t, "*, 2", m.Stack.Calls[0].Args.String())
+	c := s1.Stack.Calls[0].Merge(&s2.Stack.Calls[0])
+	compareString(t, "*, 2", c.Args.String())
+	compareString(t, "*, 2", s1.Stack.Merge(&s2.Stack).Calls[0].Args.String())
+}
+
+func TestSignature_CreatedByArgs(t *testing.T) {
+	t.Parallel()
+	s1 := getSignature()
+	s1.CreatedBy = Stack{Calls: []Call{newCall("main.start", Args{Values: []Arg{{Value: 0xc000010000, IsPtr: true}, {Value: 1}}}, "/gopath/src/foo/bar.go", 10)}}
+	s2 := getSignature()
+	s2.CreatedBy = Stack{Calls: []Call{newCall("main.start", Args{Values: []Arg{{Value: 0xc000020000, IsPtr: true}, {Value: 1}}}, "/gopath/src/foo/bar.go", 10)}}
+	if s1.similar(s2, ExactLines) {
+		t.Fatal("different pointers")
+	}
+	if !s1.similar(s2, AnyPointer) {
+		t.Fatal("similar")
+	}
+	want := Args{Values: []Arg{{Name: "*", Value: 0xc000010000, IsPtr: true}, {Value: 1}}}
+	if diff := cmp.Diff(want, s1.merge(s2).CreatedBy.Calls[0].Args); diff != "" {
+		t.Fatalf("+want/-got: %s", diff)
+	}
+}
+
 func TestSignature_Less(t *testing.T) {
 	t.Parallel()
 	s1 := getSignature()
@@ -386,7 +641,7 @@ func newCall(f string, a Args, s string, l int) Call {
 
 func newCallLocal(f string, a Args, s string, l int) Call {
 	c := newCall(f, a, s, l)
-	r := c.updateLocations(goroot, goroot, gomods, gopaths)
+	r := c.updateLocations(goroot, goroot, newRoots(gomods), newRoots(gopaths), nil)
 	if !r {
 		panic("Unexpected")
 	}
@@ -575,6 +830,31 @@ func getSignature() *Signature {
 	}
 }
 
+// TestDependencies ensures that the library packages only depend on the
+// standard library, so they can be vendored in constrained environments, and
+// that they build for WebAssembly without cgo.
+func TestDependencies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow")
+	}
+	t.Parallel()
+	c := exec.Command("go", "list", "-deps", "./...")
+	c.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm", "CGO_ENABLED=0")
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out)
+	}
+	for _, pkg := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if strings.HasPrefix(pkg, "github.com/maruel/panicparse/") {
+			continue
+		}
+		// Standard library packages don't have a dot in their first element.
+		if first := strings.SplitN(pkg, "/", 2)[0]; strings.Contains(first, ".") {
+			t.Errorf("unexpected dependency %s", pkg)
+		}
+	}
+}
+
 // TestMain manages a temporary directory to build on first use ../cmd/panic
 // and clean up at the end.
 func TestMain(m *testing.M) {
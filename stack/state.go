@@ -0,0 +1,228 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "strings"
+
+// Goroutine statuses, as found in Signature.State.
+//
+// These are from gStatusStrings in runtime/traceback.go.
+const (
+	StateIdle      = "idle"
+	StateRunnable  = "runnable"
+	StateRunning   = "running"
+	StateSyscall   = "syscall"
+	StateWaiting   = "waiting"
+	StateDead      = "dead"
+	StateCopyStack = "copystack"
+	StateLeaked    = "leaked"
+	StatePreempted = "preempted"
+	// StateWaitingCgo is printed for the extra goroutines kept for cgo
+	// callbacks.
+	StateWaitingCgo = "waiting for cgo callback"
+)
+
+// Reasons a goroutine is waiting, as found in Signature.State.
+//
+// These are from waitReasonStrings in runtime/runtime2.go. The runtime prints
+// them instead of StateWaiting when set.
+const (
+	StateGCAssistMarking       = "GC assist marking"
+	StateIOWait                = "IO wait"
+	StateChanReceiveNilChan    = "chan receive (nil chan)"
+	StateChanSendNilChan       = "chan send (nil chan)"
+	StateDumpingHeap           = "dumping heap"
+	StateGarbageCollection     = "garbage collection"
+	StateGarbageCollectionScan = "garbage collection scan"
+	StatePanicWait             = "panicwait"
+	StateSelect                = "select"
+	StateSelectNoCases         = "select (no cases)"
+	StateGCAssistWait          = "GC assist wait"
+	StateGCSweepWait           = "GC sweep wait"
+	StateGCScavengeWait        = "GC scavenge wait"
+	StateChanReceive           = "chan receive"
+	StateChanSend              = "chan send"
+	StateFinalizerWait         = "finalizer wait"
+	StateForceGCIdle           = "force gc (idle)"
+	StateUpdateGOMAXPROCSIdle  = "GOMAXPROCS updater (idle)"
+	StateSemacquire            = "semacquire"
+	StateSleep                 = "sleep"
+	StateSyncCondWait          = "sync.Cond.Wait"
+	StateSyncMutexLock         = "sync.Mutex.Lock"
+	StateSyncRWMutexRLock      = "sync.RWMutex.RLock"
+	StateSyncRWMutexLock       = "sync.RWMutex.Lock"
+	StateSyncWaitGroupWait     = "sync.WaitGroup.Wait"
+	StateTraceReaderBlocked    = "trace reader (blocked)"
+	StateWaitForGCCycle        = "wait for GC cycle"
+	StateGCWorkerIdle          = "GC worker (idle)"
+	StateGCWorkerActive        = "GC worker (active)"
+	StateDebugCall             = "debug call"
+	StateGCMarkTermination     = "GC mark termination"
+	StateStoppingTheWorld      = "stopping the world"
+	StateFlushProcCaches       = "flushing proc caches"
+	StateTraceGoroutineStatus  = "trace goroutine status"
+	StateTraceProcStatus       = "trace proc status"
+	StatePageTraceFlush        = "page trace flush"
+	StateCoroutine             = "coroutine"
+	StateGCWeakToStrongWait    = "GC weak to strong wait"
+	StateSynctestRun           = "synctest.Run"
+	StateSynctestWait          = "synctest.Wait"
+	StateChanReceiveDurable    = "chan receive (durable)"
+	StateChanSendDurable       = "chan send (durable)"
+	StateSelectDurable         = "select (durable)"
+	StateWaitGroupWaitDurable  = "sync.WaitGroup.Wait (durable)"
+	StateCleanupWait           = "cleanup wait"
+
+	// The following were only printed by older Go versions.
+
+	// StateTimerGoroutineIdle was printed up to go1.13.
+	StateTimerGoroutineIdle = "timer goroutine (idle)"
+	// StateChanReceiveSynctest was printed in go1.24, replaced by
+	// StateChanReceiveDurable.
+	StateChanReceiveSynctest = "chan receive (synctest)"
+	// StateChanSendSynctest was printed in go1.24, replaced by
+	// StateChanSendDurable.
+	StateChanSendSynctest = "chan send (synctest)"
+	// StateSelectSynctest was printed in go1.24, replaced by
+	// StateSelectDurable.
+	StateSelectSynctest = "select (synctest)"
+	// StateWaitGroupWaitSynctest was printed in go1.24, replaced by
+	// StateWaitGroupWaitDurable.
+	StateWaitGroupWaitSynctest = "sync.WaitGroup.Wait (synctest)"
+)
+
+// KnownStates returns all the goroutine states known to this package, in
+// the order of the constants.
+func KnownStates() []string {
+	out := make([]string, 0, len(knownStates))
+	for _, s := range knownStates {
+		out = append(out, s.name)
+	}
+	return out
+}
+
+// IsKnownState returns true if the state is one of the known states.
+//
+// A suffix " (scan)", printed while the garbage collector scans the stack, is
+// ignored.
+func IsKnownState(state string) bool {
+	_, ok := stateKinds[trimScan(state)]
+	return ok
+}
+
+// IsRunnable returns true if the goroutine is running or ready to run, as
+// opposed to waiting on something.
+//
+// A goroutine in a system call is considered runnable since it is not blocked
+// by the Go runtime.
+func IsRunnable(state string) bool {
+	return stateKinds[trimScan(state)] == stateRunnable
+}
+
+// IsBlocked returns true if the goroutine is blocked on a synchronization
+// primitive, I/O, a timer or a channel operation, i.e. waiting on the
+// program itself. This is where deadlocks and leaks are found.
+//
+// It returns false for the goroutines waiting on the runtime, like the
+// garbage collector; see IsGCRelated.
+func IsBlocked(state string) bool {
+	return stateKinds[trimScan(state)] == stateBlocked
+}
+
+// IsGCRelated returns true if the goroutine is a garbage collector worker or
+// is waiting on the garbage collector, including the finalizer and cleanup
+// goroutines.
+func IsGCRelated(state string) bool {
+	return stateKinds[trimScan(state)] == stateGC
+}
+
+// Private stuff.
+
+type stateKind int
+
+const (
+	stateOther stateKind = iota
+	stateRunnable
+	stateBlocked
+	stateGC
+)
+
+var knownStates = []struct {
+	name string
+	kind stateKind
+}{
+	{StateIdle, stateOther},
+	{StateRunnable, stateRunnable},
+	{StateRunning, stateRunnable},
+	{StateSyscall, stateRunnable},
+	{StateWaiting, stateOther},
+	{StateDead, stateOther},
+	{StateCopyStack, stateRunnable},
+	{StateLeaked, stateOther},
+	{StatePreempted, stateRunnable},
+	{StateWaitingCgo, stateOther},
+	{StateGCAssistMarking, stateGC},
+	{StateIOWait, stateBlocked},
+	{StateChanReceiveNilChan, stateBlocked},
+	{StateChanSendNilChan, stateBlocked},
+	{StateDumpingHeap, stateGC},
+	{StateGarbageCollection, stateGC},
+	{StateGarbageCollectionScan, stateGC},
+	{StatePanicWait, stateOther},
+	{StateSelect, stateBlocked},
+	{StateSelectNoCases, stateBlocked},
+	{StateGCAssistWait, stateGC},
+	{StateGCSweepWait, stateGC},
+	{StateGCScavengeWait, stateGC},
+	{StateChanReceive, stateBlocked},
+	{StateChanSend, stateBlocked},
+	{StateFinalizerWait, stateGC},
+	{StateForceGCIdle, stateGC},
+	{StateUpdateGOMAXPROCSIdle, stateOther},
+	{StateSemacquire, stateBlocked},
+	{StateSleep, stateBlocked},
+	{StateSyncCondWait, stateBlocked},
+	{StateSyncMutexLock, stateBlocked},
+	{StateSyncRWMutexRLock, stateBlocked},
+	{StateSyncRWMutexLock, stateBlocked},
+	{StateSyncWaitGroupWait, stateBlocked},
+	{StateTraceReaderBlocked, stateOther},
+	{StateWaitForGCCycle, stateGC},
+	{StateGCWorkerIdle, stateGC},
+	{StateGCWorkerActive, stateGC},
+	{StateDebugCall, stateOther},
+	{StateGCMarkTermination, stateGC},
+	{StateStoppingTheWorld, stateOther},
+	{StateFlushProcCaches, stateOther},
+	{StateTraceGoroutineStatus, stateOther},
+	{StateTraceProcStatus, stateOther},
+	{StatePageTraceFlush, stateOther},
+	{StateCoroutine, stateBlocked},
+	{StateGCWeakToStrongWait, stateGC},
+	{StateSynctestRun, stateBlocked},
+	{StateSynctestWait, stateBlocked},
+	{StateChanReceiveDurable, stateBlocked},
+	{StateChanSendDurable, stateBlocked},
+	{StateSelectDurable, stateBlocked},
+	{StateWaitGroupWaitDurable, stateBlocked},
+	{StateCleanupWait, stateGC},
+	{StateTimerGoroutineIdle, stateOther},
+	{StateChanReceiveSynctest, stateBlocked},
+	{StateChanSendSynctest, stateBlocked},
+	{StateSelectSynctest, stateBlocked},
+	{StateWaitGroupWaitSynctest, stateBlocked},
+}
+
+var stateKinds = func() map[string]stateKind {
+	m := make(map[string]stateKind, len(knownStates))
+	for _, s := range knownStates {
+		m[s.name] = s.kind
+	}
+	return m
+}()
+
+func trimScan(state string) string {
+	return strings.TrimSuffix(state, " (scan)")
+}
@@ -27,11 +27,15 @@ func _() {
 	_ = x[gotRaceGoroutineFunc-16]
 	_ = x[gotRaceGoroutineFile-17]
 	_ = x[betweenRaceGoroutines-18]
+	_ = x[gotProfileHeader-19]
+	_ = x[gotProfileSample-20]
+	_ = x[gotProfileCall-21]
+	_ = x[betweenProfileSamples-22]
 }
 
-const _state_name = "lookingdonebetweenRoutinegotRoutineHeadergotFuncgotCreatedgotFileFuncgotFileCreatedgotUnavailgotRaceHeader1gotRaceHeader2gotRaceOperationHeadergotRaceOperationFuncgotRaceOperationFilebetweenRaceOperationsgotRaceGoroutineHeadergotRaceGoroutineFuncgotRaceGoroutineFilebetweenRaceGoroutines"
+const _state_name = "lookingdonebetweenRoutinegotRoutineHeadergotFuncgotCreatedgotFileFuncgotFileCreatedgotUnavailgotRaceHeader1gotRaceHeader2gotRaceOperationHeadergotRaceOperationFuncgotRaceOperationFilebetweenRaceOperationsgotRaceGoroutineHeadergotRaceGoroutineFuncgotRaceGoroutineFilebetweenRaceGoroutinesgotProfileHeadergotProfileSamplegotProfileCallbetweenProfileSamples"
 
-var _state_index = [...]uint16{0, 7, 11, 25, 41, 48, 58, 69, 83, 93, 107, 121, 143, 163, 183, 204, 226, 246, 266, 287}
+var _state_index = [...]uint16{0, 7, 11, 25, 41, 48, 58, 69, 83, 93, 107, 121, 143, 163, 183, 204, 226, 246, 266, 287, 303, 319, 333, 354}
 
 func (i state) String() string {
 	if i < 0 || i >= state(len(_state_index)-1) {
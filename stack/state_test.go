@@ -0,0 +1,69 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+)
+
+func TestStateHelpers(t *testing.T) {
+	t.Parallel()
+	data := []struct {
+		state               string
+		known, runnable, gc bool
+		blocked             bool
+	}{
+		{state: "running", known: true, runnable: true},
+		{state: "running (scan)", known: true, runnable: true},
+		{state: "syscall", known: true, runnable: true},
+		{state: "chan receive", known: true, blocked: true},
+		{state: "sync.Mutex.Lock", known: true, blocked: true},
+		{state: "IO wait", known: true, blocked: true},
+		{state: "GC worker (idle)", known: true, gc: true},
+		{state: "finalizer wait", known: true, gc: true},
+		{state: "idle", known: true},
+		{state: "foo", known: false},
+	}
+	for i, line := range data {
+		if got := IsKnownState(line.state); got != line.known {
+			t.Errorf("#%d: IsKnownState(%q) = %t", i, line.state, got)
+		}
+		if got := IsRunnable(line.state); got != line.runnable {
+			t.Errorf("#%d: IsRunnable(%q) = %t", i, line.state, got)
+		}
+		if got := IsBlocked(line.state); got != line.blocked {
+			t.Errorf("#%d: IsBlocked(%q) = %t", i, line.state, got)
+		}
+		if got := IsGCRelated(line.state); got != line.gc {
+			t.Errorf("#%d: IsGCRelated(%q) = %t", i, line.state, got)
+		}
+	}
+	if s := KnownStates(); len(s) != len(knownStates) || s[0] != StateIdle {
+		t.Fatalf("unexpected %v", s)
+	}
+}
+
+// TestStatesRuntime is a change detector against the runtime sources of the
+// local toolchain, so new states are added when Go is updated.
+func TestStatesRuntime(t *testing.T) {
+	t.Parallel()
+	re := regexp.MustCompile(`(?m)^\s+(?:waitReason\w+|_G\w+):\s+"(.*)",$`)
+	for _, f := range []string{"runtime2.go", "traceback.go"} {
+		/* #nosec G304 */
+		b, err := os.ReadFile(filepath.Join(runtime.GOROOT(), "src", "runtime", f))
+		if err != nil {
+			t.Skip(err)
+		}
+		for _, m := range re.FindAllSubmatch(b, -1) {
+			if s := string(m[1]); s != "" && !IsKnownState(s) {
+				t.Errorf("%s: unknown state %q", f, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// This file contains the code to resolve the calls that only have a program
+// counter with the symbol table of the executable.
+
+package stack
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+)
+
+// Symbolize resolves the calls that only have a program counter, e.g. the
+// frames printed without symbol in a goroutine profile, with the Go symbol
+// table of the executable at path. Call.Func, the source path and the line
+// are filled in.
+//
+// It supports the ELF, Mach-O and PE executables built by the Go toolchain,
+// including stripped ones. The executable must be the exact one that
+// generated the stack trace. Calls that cannot be resolved are left as-is.
+//
+// ScanSnapshot already does it when Opts.Binary is set, which is preferable
+// since the paths of the calls resolved afterward are not processed according
+// to Opts.GuessPaths.
+func (s *Snapshot) Symbolize(path string) error {
+	if !s.hasUnresolvedCalls() {
+		return nil
+	}
+	t, err := openSymTable(path)
+	if err != nil {
+		return err
+	}
+	for _, g := range s.Goroutines {
+		for i := range g.Stack.Calls {
+			c := &g.Stack.Calls[i]
+			if c.PC == 0 || c.Func.Complete != "" {
+				continue
+			}
+			file, line, fn := t.PCToLine(c.PC)
+			if fn == nil {
+				continue
+			}
+			if err := c.Func.Init(fn.Name); err != nil {
+				return err
+			}
+			c.Args.Elided = true
+			c.init(file, line)
+		}
+	}
+	return nil
+}
+
+// Private stuff.
+
+// symbolize resolves the calls that only have a program counter with the
+// executable at path, if set.
+func (s *scanningState) symbolize(path string) {
+	if path == "" {
+		return
+	}
+	if err := s.Symbolize(path); err != nil {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("failed to symbolize: %v", err))
+	}
+}
+
+// hasUnresolvedCalls returns true if any call only has a program counter.
+func (s *Snapshot) hasUnresolvedCalls() bool {
+	for _, g := range s.Goroutines {
+		for i := range g.Stack.Calls {
+			if c := &g.Stack.Calls[i]; c.PC != 0 && c.Func.Complete == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// openSymTable loads the Go symbol table of an ELF, Mach-O or PE executable.
+func openSymTable(path string) (*gosym.Table, error) {
+	var textStart uint64
+	var pclntab []byte
+	var err error
+	if f, err1 := elf.Open(path); err1 == nil {
+		defer f.Close()
+		if s := f.Section(".text"); s != nil {
+			textStart = s.Addr
+		}
+		if s := f.Section(".gopclntab"); s != nil {
+			pclntab, err = s.Data()
+		}
+	} else if f, err1 := macho.Open(path); err1 == nil {
+		defer f.Close()
+		if s := f.Section("__text"); s != nil {
+			textStart = s.Addr
+		}
+		if s := f.Section("__gopclntab"); s != nil {
+			pclntab, err = s.Data()
+		}
+	} else if f, err1 := pe.Open(path); err1 == nil {
+		defer f.Close()
+		textStart, pclntab, err = loadPETable(f)
+	} else {
+		return nil, fmt.Errorf("%s: unsupported executable format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(pclntab) == 0 {
+		return nil, fmt.Errorf("%s: no Go symbol table", path)
+	}
+	t, err := gosym.NewTable(nil, gosym.NewLineTable(pclntab, textStart))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// loadPETable returns the start of the text section and the pclntab of a PE
+// executable.
+//
+// Unlike ELF and Mach-O, the pclntab is not in its own section; it is
+// delimited by the runtime.pclntab and runtime.epclntab symbols.
+func loadPETable(f *pe.File) (uint64, []byte, error) {
+	var imageBase uint64
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(oh.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = oh.ImageBase
+	}
+	var textStart uint64
+	if s := f.Section(".text"); s != nil {
+		textStart = imageBase + uint64(s.VirtualAddress)
+	}
+	start, end := findPESymbol(f, "runtime.pclntab"), findPESymbol(f, "runtime.epclntab")
+	if start == nil || end == nil || start.SectionNumber != end.SectionNumber || start.Value > end.Value {
+		return 0, nil, errors.New("no Go symbol table")
+	}
+	if start.SectionNumber <= 0 || int(start.SectionNumber) > len(f.Sections) {
+		return 0, nil, errors.New("invalid runtime.pclntab section")
+	}
+	data, err := f.Sections[start.SectionNumber-1].Data()
+	if err != nil {
+		return 0, nil, err
+	}
+	if int(end.Value) > len(data) {
+		return 0, nil, errors.New("invalid runtime.epclntab offset")
+	}
+	return textStart, data[start.Value:end.Value], nil
+}
+
+func findPESymbol(f *pe.File, name string) *pe.Symbol {
+	for _, s := range f.Symbols {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanSnapshotBinarySymbolize(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	// Print a goroutine profile without symbols.
+	const content = "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"fmt\"\n" +
+		"\t\"runtime\"\n" +
+		")\n" +
+		"\n" +
+		"//go:noinline\n" +
+		"func dump() {\n" +
+		"\tpcs := make([]uintptr, 16)\n" +
+		"\tn := runtime.Callers(1, pcs)\n" +
+		"\tfmt.Println(\"goroutine profile: total 1\")\n" +
+		"\tfmt.Print(\"1 @\")\n" +
+		"\tfor _, pc := range pcs[:n] {\n" +
+		"\t\tfmt.Printf(\" %#x\", pc)\n" +
+		"\t}\n" +
+		"\tfmt.Println()\n" +
+		"\tframes := runtime.CallersFrames(pcs[:n])\n" +
+		"\tfor {\n" +
+		"\t\tf, more := frames.Next()\n" +
+		"\t\tfmt.Printf(\"#\\t%#x\\n\", f.PC)\n" +
+		"\t\tif !more {\n" +
+		"\t\t\tbreak\n" +
+		"\t\t}\n" +
+		"\t}\n" +
+		"\tfmt.Println()\n" +
+		"}\n" +
+		"\n" +
+		"func main() { dump() }\n"
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module symbolize\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(root, "symbolize")
+	// Strip the DWARF information; the Go symbol table is still there.
+	c := exec.Command("go", "build", "-ldflags=-w", "-o", exe)
+	c.Dir = root
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	out, err := exec.Command(exe).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, _, err := ScanSnapshot(bytes.NewReader(out), &bytes.Buffer{}, &Opts{})
+	if err != nil && s == nil {
+		t.Fatal(err)
+	}
+	calls := s.Goroutines[0].Stack.Calls
+	if len(calls) == 0 || calls[0].PC == 0 || calls[0].Func.Complete != "" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if err = s.Symbolize(exe); err != nil {
+		t.Fatal(err)
+	}
+	if got := callLocations(s.Goroutines[0].Stack.Calls[:2]); got[0] != "main.dump main.go:11" || got[1] != "main.main main.go:29" {
+		t.Fatalf("unexpected %v", got)
+	}
+
+	s, _, err = ScanSnapshot(bytes.NewReader(out), &bytes.Buffer{}, &Opts{Binary: exe})
+	if err != nil && s == nil {
+		t.Fatal(err)
+	}
+	if len(s.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", s.Warnings)
+	}
+	if got := callLocations(s.Goroutines[0].Stack.Calls[:2]); got[0] != "main.dump main.go:11" || got[1] != "main.main main.go:29" {
+		t.Fatalf("unexpected %v", got)
+	}
+
+	if err = s.Symbolize(filepath.Join(root, "main.go")); err != nil {
+		t.Fatalf("nothing to symbolize, got %v", err)
+	}
+	s.Goroutines[0].Stack.Calls = append(s.Goroutines[0].Stack.Calls, Call{PC: 1})
+	if err = s.Symbolize(filepath.Join(root, "main.go")); err == nil {
+		t.Fatal("expected error")
+	}
+}
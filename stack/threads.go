@@ -0,0 +1,90 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "sort"
+
+// Thread is an OS thread (runtime m) and the goroutines on it, as returned by
+// Snapshot.Threads.
+type Thread struct {
+	// M is the id of the OS thread. It is -1 when unknown, for a goroutine
+	// locked to a thread that was not printed in its header.
+	M int
+	// MP is the address of the runtime m structure. It is 0 when unknown.
+	MP uint64
+	// Goroutines are the goroutines on this thread, sorted by ID. A goroutine
+	// on the system stack, like g0, shares the thread of the goroutine it runs
+	// on behalf of.
+	Goroutines []*Goroutine
+	// Locked is true when one of the goroutines is locked to the thread with
+	// runtime.LockOSThread, so the thread is pinned and cannot run any other
+	// goroutine.
+	Locked bool
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// LockedBy returns the goroutines locked to the thread.
+func (t *Thread) LockedBy() []*Goroutine {
+	var out []*Goroutine
+	for _, g := range t.Goroutines {
+		if g.Locked {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// Threads returns the goroutines grouped by the OS thread they are on, the
+// pinned threads first, then sorted by thread id.
+//
+// The thread is only known with GOTRACEBACK=system or higher, which prints
+// "m=" in the goroutine headers, and only for the goroutines currently on a
+// thread. A locked goroutine is always on its own thread even when it is not
+// printed; each one without a known thread is then returned as a separate
+// Thread with M set to -1. The other goroutines without a known thread are
+// skipped.
+//
+// This is the view needed to debug the exhaustion of OS threads, e.g. by cgo
+// calls or goroutines that forgot to call runtime.UnlockOSThread.
+func (s *Snapshot) Threads() []Thread {
+	m := map[int]int{}
+	var out []Thread
+	for _, g := range s.Goroutines {
+		if g.MP == 0 {
+			if g.Locked {
+				out = append(out, Thread{M: -1, Goroutines: []*Goroutine{g}, Locked: true})
+			}
+			continue
+		}
+		i, ok := m[g.M]
+		if !ok {
+			i = len(out)
+			m[g.M] = i
+			out = append(out, Thread{M: g.M, MP: g.MP})
+		}
+		t := &out[i]
+		t.Goroutines = append(t.Goroutines, g)
+		t.Locked = t.Locked || g.Locked
+	}
+	for i := range out {
+		gs := out[i].Goroutines
+		sort.Slice(gs, func(i, j int) bool { return gs[i].ID < gs[j].ID })
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Locked != out[j].Locked {
+			return out[i].Locked
+		}
+		if (out[i].M == -1) != (out[j].M == -1) {
+			return out[i].M != -1
+		}
+		if out[i].M != out[j].M {
+			return out[i].M < out[j].M
+		}
+		return out[i].Goroutines[0].ID < out[j].Goroutines[0].ID
+	})
+	return out
+}
@@ -0,0 +1,67 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot_Threads(t *testing.T) {
+	t.Parallel()
+	in := "" +
+		"goroutine 1 gp=0xc000002380 m=0 mp=0x5b1b20 [running]:\n" +
+		"main.main()\n" +
+		"\t/a/main.go:5 +0x13\n" +
+		"\n" +
+		"goroutine 0 gp=0xc000006a80 m=3 mp=0xc000080008 [idle]:\n" +
+		"runtime.systemstack_switch()\n" +
+		"\t/goroot/src/runtime/asm_amd64.s:474 +0x8\n" +
+		"\n" +
+		"goroutine 7 gp=0xc000007000 m=3 mp=0xc000080008 [syscall, locked to thread]:\n" +
+		"main.cgoCall()\n" +
+		"\t/a/main.go:20 +0x13\n" +
+		"\n" +
+		"goroutine 8 gp=0xc000007380 m=nil [chan receive, locked to thread]:\n" +
+		"main.render()\n" +
+		"\t/a/main.go:30 +0x13\n" +
+		"\n" +
+		"goroutine 9 gp=0xc000007700 m=nil [chan receive]:\n" +
+		"main.worker()\n" +
+		"\t/a/main.go:40 +0x13\n" +
+		"\n"
+	s, _, err := ScanSnapshot(bytes.NewBufferString(in), io.Discard, defaultOpts())
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	type thread struct {
+		M      int
+		IDs    []int
+		Locked []int
+	}
+	var got []thread
+	for _, th := range s.Threads() {
+		r := thread{M: th.M}
+		for _, g := range th.Goroutines {
+			r.IDs = append(r.IDs, g.ID)
+		}
+		for _, g := range th.LockedBy() {
+			r.Locked = append(r.Locked, g.ID)
+		}
+		got = append(got, r)
+	}
+	// Goroutine 9 is not on a thread.
+	want := []thread{
+		{M: 3, IDs: []int{0, 7}, Locked: []int{7}},
+		{M: -1, IDs: []int{8}, Locked: []int{8}},
+		{M: 0, IDs: []int{1}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("-want, +got:\n%s", diff)
+	}
+}
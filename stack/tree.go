@@ -0,0 +1,92 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import "sort"
+
+// GoroutineNode is a goroutine in the creation tree as returned by
+// Snapshot.Tree.
+type GoroutineNode struct {
+	*Goroutine
+	// Children are the goroutines created by this goroutine, sorted by ID.
+	Children []*GoroutineNode
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Tree returns the goroutines organized by which goroutine created them,
+// sorted by ID at each level.
+//
+// This relies on Goroutine.CreatedByID, which is only set by go1.21 and
+// later. Goroutines whose creator is unknown or not part of the snapshot are
+// roots, so with older traces all the goroutines are roots.
+//
+// With corrupted input, the creators of some goroutines can form a cycle that
+// never reaches a root. The goroutine with the lowest ID of each such cycle
+// becomes an additional root, so every goroutine is in the tree exactly once.
+//
+// Aggregated.CreationTree is built from this tree, with the goroutines
+// grouped by bucket.
+func (s *Snapshot) Tree() []*GoroutineNode {
+	nodes := make(map[int]*GoroutineNode, len(s.Goroutines))
+	for _, g := range s.Goroutines {
+		nodes[g.ID] = &GoroutineNode{Goroutine: g}
+	}
+	// children maps each goroutine ID to the goroutines it created.
+	children := make(map[int][]*GoroutineNode, len(s.Goroutines))
+	var roots []*GoroutineNode
+	for _, g := range s.Goroutines {
+		n := nodes[g.ID]
+		if g.CreatedByID != 0 && g.CreatedByID != g.ID && nodes[g.CreatedByID] != nil {
+			children[g.CreatedByID] = append(children[g.CreatedByID], n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	byID := func(n []*GoroutineNode) {
+		sort.SliceStable(n, func(i, j int) bool { return n[i].ID < n[j].ID })
+	}
+	// attach links the goroutines reachable from n. It is iterative, since the
+	// tree can be as deep as the number of goroutines.
+	seen := make(map[int]bool, len(s.Goroutines))
+	attach := func(n *GoroutineNode) {
+		seen[n.ID] = true
+		stack := []*GoroutineNode{n}
+		for len(stack) != 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, c := range children[n.ID] {
+				if !seen[c.ID] {
+					seen[c.ID] = true
+					n.Children = append(n.Children, c)
+					stack = append(stack, c)
+				}
+			}
+			byID(n.Children)
+		}
+	}
+	for _, n := range roots {
+		attach(n)
+	}
+	// The goroutines left are in a creation cycle.
+	if len(seen) != len(nodes) {
+		ids := make([]int, 0, len(nodes)-len(seen))
+		for id := range nodes {
+			if !seen[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Ints(ids)
+		for _, id := range ids {
+			if !seen[id] {
+				roots = append(roots, nodes[id])
+				attach(nodes[id])
+			}
+		}
+	}
+	byID(roots)
+	return roots
+}
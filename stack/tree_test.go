@@ -0,0 +1,90 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package stack
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSnapshot_Tree(t *testing.T) {
+	t.Parallel()
+	data := []string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/a/main.go:10 +0x1",
+		"",
+		"goroutine 8 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+		"goroutine 6 [IO wait]:",
+		"main.accept()",
+		"\t/a/main.go:20 +0x1",
+		"created by main.main in goroutine 1",
+		"\t/a/main.go:11 +0x1",
+		"",
+		"goroutine 7 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 6",
+		"\t/a/main.go:21 +0x1",
+		"",
+		"goroutine 9 [chan receive]:",
+		"main.serve()",
+		"\t/a/main.go:30 +0x1",
+		"created by main.accept in goroutine 42",
+		"\t/a/main.go:21 +0x1",
+		"",
+	}
+	s, _, err := ScanSnapshot(bytes.NewBufferString(strings.Join(data, "\n")), io.Discard, defaultOpts())
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	// Goroutine 9 was created by a goroutine that is not in the snapshot.
+	want := "1(6(7 8)) 9"
+	if got := formatTree(s.Tree()); got != want {
+		t.Fatalf("-want, +got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestSnapshot_Tree_Cycle(t *testing.T) {
+	t.Parallel()
+	s := &Snapshot{
+		Goroutines: []*Goroutine{
+			{ID: 1},
+			{ID: 2, CreatedByID: 3},
+			{ID: 3, CreatedByID: 2},
+			{ID: 4, CreatedByID: 4},
+			{ID: 5, CreatedByID: 3},
+		},
+	}
+	// The lowest ID of the cycle becomes a root and the goroutines created in
+	// the cycle are kept.
+	want := "1 2(3(5)) 4"
+	if got := formatTree(s.Tree()); got != want {
+		t.Fatalf("-want, +got:\n%s", cmp.Diff(want, got))
+	}
+}
+
+// formatTree returns the goroutine IDs with their children in parenthesis.
+func formatTree(nodes []*GoroutineNode) string {
+	var out []string
+	for _, n := range nodes {
+		s := strconv.Itoa(n.ID)
+		if len(n.Children) != 0 {
+			s += "(" + formatTree(n.Children) + ")"
+		}
+		out = append(out, s)
+	}
+	return strings.Join(out, " ")
+}
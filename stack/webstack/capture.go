@@ -0,0 +1,166 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// CaptureStats are metrics about the captures of the goroutines done by
+// SnapshotHandler and Recorder.
+type CaptureStats struct {
+	// Captures is the number of captures done.
+	Captures int64
+	// Grows is the number of times the buffer was too small and runtime.Stack
+	// had to be called again with a larger one.
+	Grows int64
+	// Duration is the cumulative time spent in runtime.Stack.
+	Duration time.Duration
+	// LastDuration is the time spent in runtime.Stack for the last capture.
+	LastDuration time.Duration
+	// LastSize is the size in bytes of the last capture.
+	LastSize int
+	// HighWaterMark is the largest buffer size needed so far, in bytes. The
+	// following captures start with a buffer at least this large.
+	HighWaterMark int
+
+	// Disallow initialization with unnamed parameters.
+	_ struct{}
+}
+
+// Stats returns the metrics about the captures done so far.
+//
+// It can be exported to a monitoring system to keep an eye on the cost of
+// polling the handlers.
+func Stats() CaptureStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	return stats
+}
+
+// Private stuff.
+
+var (
+	statsMu sync.Mutex
+	stats   CaptureStats
+
+	// bufPool are the buffers used to call runtime.Stack. Each one is as large
+	// as the high water mark was when it was allocated.
+	bufPool sync.Pool
+)
+
+// snapshot returns a Context based on the snapshot of the stacks of the
+// current process.
+//
+// The capture starts with a buffer of initmem bytes, or the high water mark if
+// larger. It is doubled until it fits, up to maxmem bytes.
+func snapshot(initmem, maxmem int, opts *stack.Opts) (*stack.Snapshot, error) {
+	buf, now := capture(initmem, maxmem)
+	s, _, err := stack.ScanSnapshot(bytes.NewReader(buf), io.Discard, opts)
+	// The snapshot doesn't reference the buffer, it can be reused.
+	release(buf)
+	// That's expected.
+	if err == io.EOF {
+		err = nil
+	}
+	if s != nil {
+		s.CapturedAt = now
+	}
+	return s, err
+}
+
+// snapshotProfile returns a Context based on the goroutine profile of the
+// current process, which includes the pprof labels of the goroutines but not
+// their arguments.
+//
+// The profile is truncated if it is larger than maxmem bytes. It is not
+// accounted for in Stats.
+func snapshotProfile(maxmem int, opts *stack.Opts) (*stack.Snapshot, error) {
+	now := time.Now()
+	buf := limitedBuffer{max: maxmem}
+	_ = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	s, _, err := stack.ScanSnapshot(&buf, io.Discard, opts)
+	if err == io.EOF {
+		err = nil
+	}
+	if s != nil {
+		s.CapturedAt = now
+	}
+	return s, err
+}
+
+// capture returns the stack traces of all the goroutines and when they were
+// collected.
+//
+// The buffer is truncated if maxmem is not enough. It must be released with
+// release once done.
+func capture(initmem, maxmem int) ([]byte, time.Time) {
+	statsMu.Lock()
+	size := stats.HighWaterMark
+	statsMu.Unlock()
+	if size < initmem {
+		size = initmem
+	}
+	if maxmem < initmem {
+		maxmem = initmem
+	}
+	if size > maxmem {
+		size = maxmem
+	}
+	var buf []byte
+	if b, ok := bufPool.Get().(*[]byte); ok && cap(*b) >= size {
+		buf = (*b)[:cap(*b)]
+	} else {
+		buf = make([]byte, size)
+	}
+	// We don't know how big the buffer needs to be to collect all the
+	// goroutines. Try a few times, doubling each time. Give up and use a
+	// truncated trace if maxmem is not enough.
+	start := time.Now()
+	now := start
+	grows := int64(0)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		if len(buf) >= maxmem {
+			break
+		}
+		l := len(buf) * 2
+		if l > maxmem {
+			l = maxmem
+		}
+		buf = make([]byte, l)
+		grows++
+		now = time.Now()
+	}
+	d := time.Since(start)
+
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats.Captures++
+	stats.Grows += grows
+	stats.Duration += d
+	stats.LastDuration = d
+	stats.LastSize = len(buf)
+	if c := cap(buf); c > stats.HighWaterMark {
+		stats.HighWaterMark = c
+	}
+	return buf, now
+}
+
+// release returns the buffer to the pool.
+func release(buf []byte) {
+	bufPool.Put(&buf)
+}
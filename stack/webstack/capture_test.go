@@ -0,0 +1,37 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"testing"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+func TestSnapshotStats(t *testing.T) {
+	before := Stats()
+	// Start too small so the buffer has to grow.
+	s, err := snapshot(1, 64<<20, stack.DefaultOpts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Goroutines) == 0 {
+		t.Fatal("expected goroutines")
+	}
+	after := Stats()
+	if after.Captures <= before.Captures || after.Grows <= before.Grows {
+		t.Fatalf("unexpected stats %+v", after)
+	}
+	if after.LastSize == 0 || after.HighWaterMark < after.LastSize || after.Duration < after.LastDuration {
+		t.Fatalf("unexpected stats %+v", after)
+	}
+
+	// The next capture starts at the high water mark.
+	buf, _ := capture(1, 64<<20)
+	defer release(buf)
+	if cap(buf) < after.HighWaterMark {
+		t.Fatalf("expected a buffer of at least %d bytes, got %d", after.HighWaterMark, cap(buf))
+	}
+}
@@ -78,3 +78,13 @@ func ExampleSnapshotHandler_complex() {
 	// Access as http://localhost:6060/debug/panicparse
 	log.Println(http.ListenAndServe("localhost:6060", nil))
 }
+
+func ExampleNewRecorder() {
+	// Take a snapshot every minute and keep one day of history.
+	r := webstack.NewRecorder(time.Minute, 24*time.Hour)
+	defer r.Close()
+	http.Handle("/debug/panicparse/history", r)
+
+	// Access as http://localhost:6060/debug/panicparse/history
+	log.Println(http.ListenAndServe("localhost:6060", nil))
+}
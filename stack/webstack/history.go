@@ -0,0 +1,367 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Capture is one snapshot kept by SnapshotRecorder.
+type Capture struct {
+	// ID is the sequence number of the capture, starting at 1. It is never
+	// reused.
+	ID int `json:"id"`
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Snapshot is the goroutines captured.
+	Snapshot *stack.Snapshot `json:"-"`
+}
+
+// SnapshotRecorder keeps the last snapshots of the current process goroutines
+// in memory, to view them and compare any two of them.
+//
+// It implements http.Handler, meant to be registered at a path like
+// "/debug/panicparse/history". Arguments are passed as form values:
+//
+// No argument: the list of the captures, with a form to compare two of them.
+// A POST request takes a capture right away.
+//
+// id: render this capture like SnapshotHandler does.
+//
+// from, to: compare the two captures with stack.Diff and render the
+// goroutines that persisted, moved, appeared and disappeared in between,
+// aggregated in buckets.
+//
+// format: (default: "html") "html" or "json". With "json", the list of
+// captures, a capture or the goroutine IDs of each group of a comparison are
+// returned.
+//
+// similarity: (default: "anypointer") Same as SnapshotHandler.
+//
+// Whole snapshots are kept, so the memory used grows with the number of
+// goroutines of the process times the number of captures kept.
+type SnapshotRecorder struct {
+	size int
+	stop chan struct{}
+	done chan struct{}
+
+	mu       sync.Mutex
+	captures []Capture
+	nextID   int
+	// renderMu serializes the rendering, since aggregating a snapshot names
+	// the arguments of its goroutines.
+	renderMu sync.Mutex
+}
+
+// NewSnapshotRecorder returns a SnapshotRecorder that keeps the last size
+// snapshots.
+//
+// When interval is not 0, a snapshot is taken right away and then every
+// interval. Otherwise snapshots are only taken by Capture or a POST request.
+// Call Close to stop it.
+func NewSnapshotRecorder(size int, interval time.Duration) *SnapshotRecorder {
+	if size < 2 {
+		size = 2
+	}
+	r := &SnapshotRecorder{
+		size:   size,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		nextID: 1,
+	}
+	if interval != 0 {
+		go r.run(interval)
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+// Close stops the periodic captures.
+func (r *SnapshotRecorder) Close() error {
+	close(r.stop)
+	<-r.done
+	return nil
+}
+
+// ErrBusy is returned by SnapshotRecorder.Capture when a snapshot is already
+// being generated, by it or by SnapshotHandler.
+var ErrBusy = errors.New("a snapshot is already being generated")
+
+// Capture takes a snapshot of the goroutines now and keeps it, evicting the
+// oldest one when full.
+//
+// It returns ErrBusy instead of waiting when another snapshot is being
+// generated, since each one stops the world.
+func (r *SnapshotRecorder) Capture() (Capture, error) {
+	select {
+	case inflight <- struct{}{}:
+	default:
+		return Capture{}, ErrBusy
+	}
+	defer func() { <-inflight }()
+	return r.capture()
+}
+
+// capture takes a snapshot. The caller must hold inflight.
+func (r *SnapshotRecorder) capture() (Capture, error) {
+	opts := stack.DefaultOpts()
+	// Keep it cheap, the captures can be frequent.
+	opts.AnalyzeSources = false
+	c, err := snapshot(1<<20, 64<<20, opts)
+	if err != nil {
+		return Capture{}, err
+	}
+	if c == nil {
+		return Capture{}, errors.New("no goroutine found")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := Capture{ID: r.nextID, Time: c.CapturedAt, Snapshot: c}
+	r.nextID++
+	if len(r.captures) == r.size {
+		copy(r.captures, r.captures[1:])
+		r.captures = r.captures[:r.size-1]
+	}
+	r.captures = append(r.captures, cp)
+	return cp, nil
+}
+
+// Captures returns the captures kept, oldest first.
+func (r *SnapshotRecorder) Captures() []Capture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Capture(nil), r.captures...)
+}
+
+// ServeHTTP implements http.Handler.
+func (r *SnapshotRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		if _, err := r.Capture(); err == ErrBusy {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "a snapshot is already being generated, retry later", http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			http.Error(w, "failed to capture the goroutines", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, req, req.URL.Path, http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		return
+	}
+	format := req.FormValue("format")
+	switch format {
+	case "":
+		format = "html"
+	case "html", "json":
+	default:
+		http.Error(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+	similar, ok := parseSimilarity(req.FormValue("similarity"))
+	if !ok {
+		http.Error(w, "invalid similarity value", http.StatusBadRequest)
+		return
+	}
+	r.renderMu.Lock()
+	defer r.renderMu.Unlock()
+	captures := r.Captures()
+	find := func(name string) *Capture {
+		id, err := strconv.Atoi(req.FormValue(name))
+		if err != nil {
+			return nil
+		}
+		for i := range captures {
+			if captures[i].ID == id {
+				return &captures[i]
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case req.FormValue("id") != "":
+		c := find("id")
+		if c == nil {
+			http.Error(w, "unknown capture", http.StatusNotFound)
+			return
+		}
+		a := c.Snapshot.Aggregate(similar)
+		a.Analyze(nil)
+		w.Header().Set("Content-Type", contentTypes[format])
+		_ = write(w, format, a, "")
+
+	case req.FormValue("from") != "" || req.FormValue("to") != "":
+		from, to := find("from"), find("to")
+		if from == nil || to == nil {
+			http.Error(w, "unknown capture", http.StatusNotFound)
+			return
+		}
+		cmp := newComparison(from, to, similar)
+		w.Header().Set("Content-Type", contentTypes[format])
+		if format == "json" {
+			_ = json.NewEncoder(w).Encode(cmp.ids())
+			return
+		}
+		_ = compareTmpl.Execute(w, cmp)
+
+	default:
+		w.Header().Set("Content-Type", contentTypes[format])
+		if format == "json" {
+			_ = json.NewEncoder(w).Encode(captures)
+			return
+		}
+		_ = historyTmpl.Execute(w, captures)
+	}
+}
+
+// Private stuff.
+
+func (r *SnapshotRecorder) run(interval time.Duration) {
+	defer close(r.done)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		// Wait for the snapshot in progress, if any, instead of skipping the
+		// capture.
+		select {
+		case <-r.stop:
+			return
+		case inflight <- struct{}{}:
+		}
+		_, _ = r.capture()
+		<-inflight
+		select {
+		case <-r.stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// parseSimilarity parses the similarity form value of the handlers.
+func parseSimilarity(s string) (stack.Similarity, bool) {
+	switch s {
+	case "exactflags":
+		return stack.ExactFlags, true
+	case "exactlines":
+		return stack.ExactLines, true
+	case "anypointer", "":
+		return stack.AnyPointer, true
+	case "anyvalue":
+		return stack.AnyValue, true
+	default:
+		return 0, false
+	}
+}
+
+// comparison is the data for compareTmpl.
+type comparison struct {
+	From, To *Capture
+	Groups   []group
+}
+
+// group is one list of stack.SnapshotDiff, aggregated.
+type group struct {
+	Name    string
+	Buckets []*stack.Bucket
+	Total   int
+}
+
+func newComparison(from, to *Capture, similar stack.Similarity) *comparison {
+	d := stack.Diff(from.Snapshot, to.Snapshot, similar)
+	c := &comparison{From: from, To: to}
+	for _, g := range []struct {
+		name       string
+		goroutines []*stack.Goroutine
+	}{
+		{"appeared", d.Appeared},
+		{"disappeared", d.Disappeared},
+		{"moved", d.Moved},
+		{"persisted", d.Persisted},
+	} {
+		s := &stack.Snapshot{Goroutines: g.goroutines}
+		c.Groups = append(c.Groups, group{Name: g.name, Buckets: s.Aggregate(similar).Buckets, Total: len(g.goroutines)})
+	}
+	return c
+}
+
+// ids returns the goroutine IDs of each group.
+func (c *comparison) ids() map[string]interface{} {
+	out := map[string]interface{}{"from": c.From.ID, "to": c.To.ID}
+	for _, g := range c.Groups {
+		ids := []int{}
+		for _, b := range g.Buckets {
+			ids = append(ids, b.IDs...)
+		}
+		out[g.Name] = ids
+	}
+	return out
+}
+
+var historyFuncs = template.FuncMap{"label": bucketLabel}
+
+var historyTmpl = template.Must(template.New("history").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Goroutine snapshots</title>
+<style>
+  body { font-family: sans-serif; }
+  td { font-family: monospace; padding: 0.1em 0.5em; }
+</style>
+<h1>Goroutine snapshots</h1>
+<form method="post"><button>Capture now</button> <a href="?format=json">JSON</a></form>
+{{- if .}}
+<form method="get">
+<table>
+  <tr><th>From</th><th>To</th><th>Capture</th><th>Time</th><th>Goroutines</th></tr>
+{{- range $i, $c := .}}
+  <tr>
+    <td><input type="radio" name="from" value="{{$c.ID}}"{{if eq $i 0}} checked{{end}}></td>
+    <td><input type="radio" name="to" value="{{$c.ID}}" checked></td>
+    <td><a href="?id={{$c.ID}}">#{{$c.ID}}</a></td>
+    <td>{{$c.Time.Format "2006-01-02 15:04:05"}}</td>
+    <td>{{len $c.Snapshot.Goroutines}}</td>
+  </tr>
+{{- end}}
+</table>
+<button>Compare</button>
+</form>
+{{- else}}
+<p>No capture yet.</p>
+{{- end}}
+`))
+
+var compareTmpl = template.Must(template.New("compare").Funcs(historyFuncs).Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Goroutines between #{{.From.ID}} and #{{.To.ID}}</title>
+<style>
+  body { font-family: sans-serif; }
+  td { font-family: monospace; padding: 0.1em 0.5em; }
+</style>
+<h1>Goroutines between <a href="?id={{.From.ID}}">#{{.From.ID}}</a> and <a href="?id={{.To.ID}}">#{{.To.ID}}</a></h1>
+<p>{{.To.Time.Sub .From.Time}} apart. <a href="?">All captures</a></p>
+{{- range .Groups}}
+<h2>{{.Total}} {{.Name}}</h2>
+{{- if .Buckets}}
+<table>
+{{- range .Buckets}}
+  <tr><td>{{len .IDs}}</td><td>{{label .}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,120 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRecorder(t *testing.T) {
+	r := NewSnapshotRecorder(2, 0)
+	defer r.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := r.Capture(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Start a goroutine between the captures so it appears.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		<-stop
+	}()
+	// Evicts the first capture.
+	last, err := r.Capture()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != 3 {
+		t.Fatal(last.ID)
+	}
+	if c := r.Captures(); len(c) != 2 || c[0].ID != 2 || c[1].ID != 3 {
+		t.Fatalf("unexpected %#v", c)
+	}
+
+	get := func(url string) (int, string) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w.Code, w.Body.String()
+	}
+	if code, body := get("/"); code != 200 || !strings.Contains(body, `<a href="?id=3">#3</a>`) || strings.Contains(body, "?id=1") {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	if code, body := get("/?format=json"); code != 200 || !strings.HasPrefix(body, `[{"id":2,`) {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	if code, body := get("/?id=3&format=text"); code != 400 {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	if code, body := get("/?id=3"); code != 200 || !strings.Contains(body, "TestSnapshotRecorder") {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	if code, body := get("/?from=2&to=3"); code != 200 || !strings.Contains(body, "appeared") {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	code, body := get("/?from=2&to=3&format=json")
+	if code != 200 {
+		t.Fatalf("%d\n%s", code, body)
+	}
+	var got struct {
+		From     int
+		To       int
+		Appeared []int
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.From != 2 || got.To != 3 || len(got.Appeared) == 0 {
+		t.Fatalf("expected the new goroutine to appear: %s", body)
+	}
+	for _, url := range []string{"/?id=1", "/?from=1&to=3"} {
+		if code, _ := get(url); code != 404 {
+			t.Fatalf("%s: %d", url, code)
+		}
+	}
+	for _, url := range []string{"/?format=xml", "/?similarity=foo"} {
+		if code, _ := get(url); code != 400 {
+			t.Fatalf("%s: %d", url, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/debug/history", nil))
+	if w.Code != 303 || w.Header().Get("Location") != "/debug/history" {
+		t.Fatalf("%d %s", w.Code, w.Header().Get("Location"))
+	}
+	if c := r.Captures(); c[1].ID != 4 {
+		t.Fatalf("unexpected %#v", c)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/", nil))
+	if w.Code != 405 {
+		t.Fatalf("%d", w.Code)
+	}
+}
+
+func TestSnapshotRecorder_Overload(t *testing.T) {
+	r := NewSnapshotRecorder(2, 0)
+	defer r.Close()
+	inflight <- struct{}{}
+	defer func() { <-inflight }()
+	if _, err := r.Capture(); err != ErrBusy {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != 503 {
+		t.Fatalf("%d\n%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != retryAfter {
+		t.Fatalf("unexpected Retry-After %q", got)
+	}
+	if c := r.Captures(); len(c) != 0 {
+		t.Fatalf("unexpected %#v", c)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/maruel/panicparse/v2/stack"
+)
+
+// Sample is the compacted goroutine counts at one point in time.
+type Sample struct {
+	// Time is when the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Total is the total number of goroutines.
+	Total int `json:"total"`
+	// Counts is the number of goroutines per bucket, keyed by
+	// stack.Signature.Hash().
+	Counts map[string]int `json:"counts"`
+}
+
+// Recorder takes periodic snapshots of the current process goroutines and
+// keeps the bucket counts for a limited time.
+//
+// It implements http.Handler to serve a chart of the bucket counts over time.
+// With the form value "format=json", the time series is returned as JSON
+// instead.
+//
+// Detecting slow goroutine leaks needs history, not just a point-in-time
+// view.
+type Recorder struct {
+	interval  time.Duration
+	retention time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+
+	mu      sync.Mutex
+	samples []Sample
+	// labels maps a bucket hash to a human readable description.
+	labels map[string]string
+}
+
+// NewRecorder starts a Recorder that takes a snapshot every interval and
+// keeps samples for retention.
+//
+// Call Close to stop it.
+func NewRecorder(interval, retention time.Duration) *Recorder {
+	r := &Recorder{
+		interval:  interval,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		labels:    map[string]string{},
+	}
+	go r.run()
+	return r
+}
+
+// Close stops the recording.
+func (r *Recorder) Close() error {
+	close(r.stop)
+	<-r.done
+	return nil
+}
+
+// Samples returns a copy of the samples recorded so far, oldest first.
+func (r *Recorder) Samples() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Sample(nil), r.samples...)
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
+		return
+	}
+	r.mu.Lock()
+	samples := append([]Sample(nil), r.samples...)
+	labels := make(map[string]string, len(r.labels))
+	for k, v := range r.labels {
+		labels[k] = v
+	}
+	r.mu.Unlock()
+
+	switch req.FormValue("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"labels":  labels,
+			"samples": samples,
+		})
+	case "", "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = chartTmpl.Execute(w, newChart(samples, labels))
+	default:
+		http.Error(w, "invalid format value", http.StatusBadRequest)
+	}
+}
+
+// Private stuff.
+
+func (r *Recorder) run() {
+	defer close(r.done)
+	t := time.NewTicker(r.interval)
+	defer t.Stop()
+	r.record(time.Now())
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-t.C:
+			r.record(now)
+		}
+	}
+}
+
+// record takes one snapshot and trims the samples older than retention.
+func (r *Recorder) record(now time.Time) {
+	opts := stack.DefaultOpts()
+	// Keep it cheap, only the signatures are needed.
+	opts.NameArguments = false
+	opts.GuessPaths = false
+	opts.AnalyzeSources = false
+	c, err := snapshot(1<<20, 64<<20, opts)
+	if err != nil || c == nil {
+		return
+	}
+	a := c.Aggregate(stack.AnyValue)
+	sample := Sample{Time: now, Total: len(c.Goroutines), Counts: make(map[string]int, len(a.Buckets))}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range a.Buckets {
+		h := b.Hash()
+		sample.Counts[h] += len(b.IDs)
+		if _, ok := r.labels[h]; !ok {
+			r.labels[h] = bucketLabel(b)
+		}
+	}
+	r.samples = append(r.samples, sample)
+	cutoff := now.Add(-r.retention)
+	i := 0
+	for i < len(r.samples) && r.samples[i].Time.Before(cutoff) {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	r.samples = r.samples[i:]
+	// Forget the buckets that are not in any sample kept anymore.
+	for h := range r.labels {
+		found := false
+		for _, s := range r.samples {
+			if _, found = s.Counts[h]; found {
+				break
+			}
+		}
+		if !found {
+			delete(r.labels, h)
+		}
+	}
+}
+
+// bucketLabel returns a short description of a bucket.
+func bucketLabel(b *stack.Bucket) string {
+	if len(b.Stack.Calls) == 0 {
+		return b.State
+	}
+	c := &b.Stack.Calls[0]
+	return fmt.Sprintf("%s.%s [%s]", c.Func.DirName, c.Func.Name, b.State)
+}
+
+// maxSeries is the maximum number of buckets drawn on the chart.
+const maxSeries = 8
+
+// chart is the data for chartTmpl.
+type chart struct {
+	Width, Height int
+	Max           int
+	Series        []series
+	Samples       int
+}
+
+type series struct {
+	Label  string
+	Color  string
+	Points string
+	Last   int
+}
+
+var colors = []string{"#000", "#c00", "#080", "#00c", "#c80", "#808", "#088", "#888", "#444"}
+
+func newChart(samples []Sample, labels map[string]string) *chart {
+	c := &chart{Width: 800, Height: 300, Samples: len(samples)}
+	// Pick the buckets with the highest peak.
+	peak := map[string]int{}
+	for _, s := range samples {
+		if s.Total > c.Max {
+			c.Max = s.Total
+		}
+		for h, n := range s.Counts {
+			if n > peak[h] {
+				peak[h] = n
+			}
+		}
+	}
+	hashes := make([]string, 0, len(peak))
+	for h := range peak {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		if peak[hashes[i]] != peak[hashes[j]] {
+			return peak[hashes[i]] > peak[hashes[j]]
+		}
+		return hashes[i] < hashes[j]
+	})
+	if len(hashes) > maxSeries {
+		hashes = hashes[:maxSeries]
+	}
+	c.Series = append(c.Series, c.series("total", colors[0], samples, func(s *Sample) int { return s.Total }))
+	for i, h := range hashes {
+		h := h
+		c.Series = append(c.Series, c.series(labels[h], colors[(i+1)%len(colors)], samples, func(s *Sample) int { return s.Counts[h] }))
+	}
+	return c
+}
+
+func (c *chart) series(label, color string, samples []Sample, get func(s *Sample) int) series {
+	s := series{Label: label, Color: color}
+	max := c.Max
+	if max == 0 {
+		max = 1
+	}
+	n := len(samples) - 1
+	if n < 1 {
+		n = 1
+	}
+	for i := range samples {
+		v := get(&samples[i])
+		s.Points += fmt.Sprintf("%d,%d ", i*c.Width/n, c.Height-v*c.Height/max)
+		s.Last = v
+	}
+	return s
+}
+
+var chartTmpl = template.Must(template.New("chart").Parse(`<!DOCTYPE html>
+<meta charset="utf-8">
+<title>Goroutines over time</title>
+<style>
+  body { font-family: sans-serif; }
+  svg { border: 1px solid #ccc; }
+  td { font-family: monospace; padding: 0.1em 0.5em; }
+</style>
+<h1>Goroutines over time</h1>
+<p>{{.Samples}} samples, peak of {{.Max}} goroutines. <a href="?format=json">JSON</a></p>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+{{- range .Series}}
+  <polyline fill="none" stroke="{{.Color}}" points="{{.Points}}"/>
+{{- end}}
+</svg>
+<table>
+{{- range .Series}}
+  <tr><td style="color:{{.Color}}">&#9632;</td><td>{{.Last}}</td><td>{{.Label}}</td></tr>
+{{- end}}
+</table>
+`))
@@ -0,0 +1,90 @@
+// Copyright 2026 Marc-Antoine Ruel. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package webstack
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(time.Hour, time.Hour)
+	// The first sample is taken right away.
+	for len(r.Samples()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	now := time.Now()
+	r.record(now.Add(2 * time.Hour))
+	defer r.Close()
+	s := r.Samples()
+	// The first sample is past the retention.
+	if len(s) != 1 {
+		t.Fatalf("unexpected %d samples", len(s))
+	}
+	if s[0].Total == 0 || len(s[0].Counts) == 0 {
+		t.Fatalf("unexpected %#v", s[0])
+	}
+
+	req := httptest.NewRequest("GET", "/?format=json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("%d\n%s", w.Code, w.Body.String())
+	}
+	var got struct {
+		Labels  map[string]string
+		Samples []Sample
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Samples) != 1 || len(got.Labels) == 0 {
+		t.Fatalf("unexpected %#v", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "<polyline") {
+		t.Fatalf("%d\n%s", w.Code, w.Body.String())
+	}
+
+	for _, url := range []string{"/?format=xml"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != 400 {
+			t.Fatalf("%s: %d", url, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Code != 405 {
+		t.Fatalf("%d", w.Code)
+	}
+}
+
+func TestRecorder_Retention(t *testing.T) {
+	now := time.Now()
+	r := &Recorder{
+		retention: time.Hour,
+		samples:   []Sample{{Time: now.Add(-2 * time.Hour), Total: 1, Counts: map[string]int{"gone": 1}}},
+		labels:    map[string]string{"gone": "main.gone [running]"},
+	}
+	r.record(now)
+	if len(r.samples) != 1 || len(r.labels) == 0 {
+		t.Fatalf("unexpected %#v", r)
+	}
+	if _, ok := r.labels["gone"]; ok {
+		t.Fatal("the label of a bucket past the retention must be removed")
+	}
+	for h := range r.labels {
+		if _, ok := r.samples[0].Counts[h]; !ok {
+			t.Fatalf("unexpected label %q", h)
+		}
+	}
+}
@@ -10,16 +10,22 @@ package webstack
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
 	"io"
 	"net/http"
-	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/maruel/panicparse/v2/stack"
+	"github.com/maruel/panicparse/v2/stack/render"
 )
 
-// SnapshotHandler implements http.HandlerFunc to returns a panicparse HTML
-// format for a snapshot of the current goroutines.
+// SnapshotHandler implements http.HandlerFunc to returns a panicparse HTML,
+// JSON or plain text format for a snapshot of the current goroutines.
 //
 // For best results, compile the executable with optimization (-N) and inlining
 // (-l) disabled with -gcflags '-N -l'.
@@ -37,12 +43,48 @@ import (
 // disk to improve the display of arguments based on type information. This is
 // slower and should be avoided on high utilization server.
 //
+// initmem: (default: 1048576) initial size in bytes of the buffer to capture
+// the goroutines. The buffer is doubled up to maxmem until the goroutines fit.
+// The largest size needed so far is used when it is larger, and the buffers
+// are reused across requests. See Stats.
+//
+// label: (default: none) Only keep the goroutines with this pprof label,
+// specified as "key=value". It can be repeated to require multiple labels. The
+// runtime only reports the labels in the goroutine profile, so it is used
+// instead of the full stack traces: the arguments are omitted, so similarity
+// has little effect, and the goroutine IDs are made up. The profile is
+// truncated at maxmem bytes; initmem is ignored.
+//
+// maxgoroutines: (default: 100000) maximum number of goroutines to render,
+// after filtering by label. Beyond this, a summarized view is rendered
+// instead; see below.
+//
+// format: (default: "") One of "html", "json" or "text". When empty, the
+// format is selected from the Accept header and defaults to "html". "json" is
+// an object with the buckets, the totals and, when summarized, a "summary"
+// describing what was omitted. "text" is formatted like pp does on the
+// console.
+//
+// maxhtml: (default: 33554432) maximum size in bytes of the generated output.
+// Beyond this, a summarized view is rendered instead.
+//
 // maxmem: (default: 67108864) maximum amount of temporary memory to use to
 // generate a snapshot. In practice at least the double of this is used.
 // Minimum is 1048576.
 //
 // similarity: (default: "anypointer") Can be one of stack.Similarity value in
 // lowercase: "exactflags", "exactlines", "anypointer" or "anyvalue".
+//
+// view: (default: "") When set to "tree", goroutines are organized by which
+// goroutine created them. This requires go1.21 or later and the "html"
+// format.
+//
+// The summarized view aggregates the goroutines with stack.AnyValue and only
+// renders the largest buckets, so a process with hundreds of thousands of
+// goroutines doesn't stall its own debug endpoint.
+//
+// Only one snapshot is generated at a time. Concurrent requests are replied
+// with 503 and a Retry-After header.
 func SnapshotHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "GET" {
 		http.Error(w, "invalid method", http.StatusMethodNotAllowed)
@@ -57,6 +99,30 @@ func SnapshotHandler(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
+	initmem := 1 << 20
+	if s := req.FormValue("initmem"); s != "" {
+		var err error
+		if initmem, err = strconv.Atoi(s); err != nil || initmem < 1 {
+			http.Error(w, "invalid initmem value", http.StatusBadRequest)
+			return
+		}
+	}
+	maxgoroutines := 100000
+	if s := req.FormValue("maxgoroutines"); s != "" {
+		var err error
+		if maxgoroutines, err = strconv.Atoi(s); err != nil || maxgoroutines < 1 {
+			http.Error(w, "invalid maxgoroutines value", http.StatusBadRequest)
+			return
+		}
+	}
+	maxhtml := 32 << 20
+	if s := req.FormValue("maxhtml"); s != "" {
+		var err error
+		if maxhtml, err = strconv.Atoi(s); err != nil || maxhtml < 1 {
+			http.Error(w, "invalid maxhtml value", http.StatusBadRequest)
+			return
+		}
+	}
 	opts := stack.DefaultOpts()
 	if s := req.FormValue("augment"); s != "" {
 		v, err := strconv.Atoi(s)
@@ -68,60 +134,219 @@ func SnapshotHandler(w http.ResponseWriter, req *http.Request) {
 			opts.AnalyzeSources = false
 		}
 	}
-	c, err := snapshot(maxmem, opts)
-	if err != nil {
-		http.Error(w, "failed to process the snapshot, try a larger maxmem value", http.StatusInternalServerError)
+	s, ok := parseSimilarity(req.FormValue("similarity"))
+	if !ok {
+		http.Error(w, "invalid similarity value", http.StatusBadRequest)
 		return
 	}
 
-	var s stack.Similarity
-	switch req.FormValue("similarity") {
-	case "exactflags":
-		s = stack.ExactFlags
-	case "exactlines":
-		s = stack.ExactLines
-	case "anypointer", "":
-		s = stack.AnyPointer
-	case "anyvalue":
-		s = stack.AnyValue
+	format := req.FormValue("format")
+	switch format {
+	case "":
+		format = negotiate(req.Header.Get("Accept"))
+	case "html", "json", "text":
 	default:
-		http.Error(w, "invalid similarity value", http.StatusBadRequest)
+		http.Error(w, "invalid format value", http.StatusBadRequest)
+		return
+	}
+
+	var labels map[string]string
+	for _, l := range req.Form["label"] {
+		i := strings.IndexByte(l, '=')
+		if i <= 0 {
+			http.Error(w, "invalid label value, expected key=value", http.StatusBadRequest)
+			return
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[l[:i]] = l[i+1:]
+	}
+
+	tree := false
+	switch req.FormValue("view") {
+	case "":
+	case "tree":
+		if format != "html" {
+			http.Error(w, "view=tree requires the html format", http.StatusBadRequest)
+			return
+		}
+		tree = true
+	default:
+		http.Error(w, "invalid view value", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case inflight <- struct{}{}:
+		defer func() { <-inflight }()
+	default:
+		w.Header().Set("Retry-After", retryAfter)
+		http.Error(w, "a snapshot is already being generated, retry later", http.StatusServiceUnavailable)
+		return
+	}
+
+	var c *stack.Snapshot
+	var err error
+	if labels != nil {
+		c, err = snapshotProfile(maxmem, opts)
+	} else {
+		c, err = snapshot(initmem, maxmem, opts)
+	}
+	if err != nil || c == nil {
+		http.Error(w, "failed to process the snapshot, try a larger maxmem value", http.StatusInternalServerError)
 		return
 	}
+	if labels != nil {
+		filterLabels(c, labels)
+	}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = c.Aggregate(s).ToHTML(w, "")
+	buf := limitedBuffer{max: maxhtml}
+	if len(c.Goroutines) <= maxgoroutines {
+		a := c.Aggregate(s)
+		a.Analyze(nil)
+		if tree {
+			err = a.ToHTMLTree(&buf, "")
+		} else {
+			err = write(&buf, format, a, "")
+		}
+		if err != nil && err != errTooLarge {
+			http.Error(w, "failed to render the snapshot", http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(c.Goroutines) > maxgoroutines || err == errTooLarge {
+		buf = limitedBuffer{}
+		a, footer := summarize(c)
+		_ = write(&buf, format, a, footer)
+	}
+	w.Header().Set("Content-Type", contentTypes[format])
+	_, _ = w.Write(buf.Bytes())
 }
 
-// snapshot returns a Context based on the snapshot of the stacks of the
-// current process.
-func snapshot(maxmem int, opts *stack.Opts) (*stack.Snapshot, error) {
-	// We don't know how big the buffer needs to be to collect all the
-	// goroutines. Start with 1 MB and try a few times, doubling each time. Give
-	// up and use a truncated trace if maxmem is not enough.
-	buf := make([]byte, 1<<20)
-	if maxmem < len(buf) {
-		maxmem = len(buf)
-	}
-	for i := 0; ; i++ {
-		n := runtime.Stack(buf, true)
-		if n < len(buf) {
-			buf = buf[:n]
-			break
-		}
-		if len(buf) >= maxmem {
-			break
-		}
-		l := len(buf) * 2
-		if l > maxmem {
-			l = maxmem
-		}
-		buf = make([]byte, l)
-	}
-	s, _, err := stack.ScanSnapshot(bytes.NewReader(buf), io.Discard, opts)
-	// That's expected.
-	if err == io.EOF {
-		err = nil
-	}
-	return s, err
+// contentTypes is the Content-Type of each format.
+var contentTypes = map[string]string{
+	"html": "text/html; charset=utf-8",
+	"json": "application/json",
+	"text": "text/plain; charset=utf-8",
+}
+
+// negotiate returns the format to use based on the Accept header.
+//
+// The first media type supported wins; the quality values are ignored. It
+// defaults to "html", including for "*/*".
+func negotiate(accept string) string {
+	for _, m := range strings.Split(accept, ",") {
+		if i := strings.IndexByte(m, ';'); i != -1 {
+			m = m[:i]
+		}
+		switch strings.TrimSpace(m) {
+		case "text/html":
+			return "html"
+		case "application/json":
+			return "json"
+		case "text/plain":
+			return "text"
+		}
+	}
+	return "html"
+}
+
+// write renders a in the format.
+//
+// footer describes what was omitted, if anything.
+func write(w io.Writer, format string, a *stack.Aggregated, footer string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(&jsonAggregated{
+			Buckets:         a.Buckets,
+			TotalBuckets:    a.TotalBuckets,
+			TotalGoroutines: a.TotalGoroutines,
+			Summary:         footer,
+		})
+	case "text":
+		f := render.Formatter{PathFormat: render.BasePath}
+		if err := f.WriteAggregated(w, a); err != nil {
+			return err
+		}
+		if footer != "" {
+			_, err := io.WriteString(w, footer+"\n")
+			return err
+		}
+		return nil
+	default:
+		/* #nosec G203 */
+		return a.ToHTML(w, template.HTML(template.HTMLEscapeString(footer)))
+	}
+}
+
+// jsonAggregated is the "json" format.
+//
+// Unlike stack.Aggregated, it doesn't include the goroutines of the snapshot,
+// so its size is bounded like the other formats.
+type jsonAggregated struct {
+	Buckets         []*stack.Bucket
+	TotalBuckets    int
+	TotalGoroutines int
+	Summary         string `json:"summary,omitempty"`
+}
+
+// filterLabels only keeps the goroutines with all the labels.
+func filterLabels(s *stack.Snapshot, labels map[string]string) {
+	out := s.Goroutines[:0]
+	for _, g := range s.Goroutines {
+		keep := true
+		for k, v := range labels {
+			if w, ok := g.Labels[k]; !ok || w != v {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, g)
+		}
+	}
+	s.Goroutines = out
+}
+
+// inflight serializes the snapshot generation.
+var inflight = make(chan struct{}, 1)
+
+// retryAfter is the number of seconds to wait after an overload.
+const retryAfter = "5"
+
+// summaryBuckets is the maximum number of buckets rendered in the summarized
+// view.
+const summaryBuckets = 100
+
+// summarize returns the largest buckets of c aggregated with stack.AnyValue,
+// along with a footer describing what was omitted.
+func summarize(c *stack.Snapshot) (*stack.Aggregated, string) {
+	a := c.Aggregate(stack.AnyValue)
+	a.Analyze(nil)
+	total := len(a.Buckets)
+	sort.SliceStable(a.Buckets, func(i, j int) bool {
+		return len(a.Buckets[i].IDs) > len(a.Buckets[j].IDs)
+	})
+	if len(a.Buckets) > summaryBuckets {
+		a.Buckets = a.Buckets[:summaryBuckets]
+	}
+	footer := fmt.Sprintf("Summarized view of %d goroutines: showing the %d largest of %d buckets.", len(c.Goroutines), len(a.Buckets), total)
+	return a, footer
+}
+
+var errTooLarge = errors.New("output too large")
+
+// limitedBuffer is a bytes.Buffer that fails writes beyond max bytes. A max of
+// 0 means no limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if l.max != 0 && l.Len()+len(p) > l.max {
+		return 0, errTooLarge
+	}
+	return l.Buffer.Write(p)
 }
@@ -6,7 +6,11 @@ package webstack
 
 import (
 	"context"
+	"encoding/json"
 	"net/http/httptest"
+	"reflect"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -15,12 +19,16 @@ func TestSnapshotHandler(t *testing.T) {
 	data := []string{
 		"/debug",
 		"/debug?augment=1",
+		"/debug?initmem=1",
+		"/debug?maxgoroutines=1000000",
+		"/debug?maxhtml=1073741824",
 		"/debug?maxmem=1",
 		"/debug?maxmem=2097152",
 		"/debug?similarity=exactflags",
 		"/debug?similarity=exactlines",
 		"/debug?similarity=anypointer",
 		"/debug?similarity=anyvalue",
+		"/debug?view=tree",
 	}
 	for _, url := range data {
 		url := url
@@ -31,6 +39,48 @@ func TestSnapshotHandler(t *testing.T) {
 			if w.Code != 200 {
 				t.Fatalf("%s: %d\n%s", url, w.Code, w.Body.String())
 			}
+			if !strings.Contains(w.Body.String(), `class="ago"`) {
+				t.Fatalf("%s: missing capture time", url)
+			}
+		})
+	}
+}
+
+func TestSnapshotHandler_Format(t *testing.T) {
+	data := []struct {
+		url, accept, contentType, want string
+	}{
+		{"/debug?format=html", "", "text/html; charset=utf-8", `class="ago"`},
+		{"/debug?format=json", "", "application/json", `"Buckets":`},
+		{"/debug?format=text", "", "text/plain; charset=utf-8", "TestSnapshotHandler_Format.func1(*T("},
+		{"/debug", "application/json", "application/json", `"Buckets":`},
+		{"/debug", "text/plain;q=0.9, */*;q=0.1", "text/plain; charset=utf-8", "TestSnapshotHandler_Format.func1(*T("},
+		{"/debug", "*/*", "text/html; charset=utf-8", `class="ago"`},
+		{"/debug?format=html", "application/json", "text/html; charset=utf-8", `class="ago"`},
+		{"/debug?format=text&maxgoroutines=1", "", "text/plain; charset=utf-8", "Summarized view of "},
+		{"/debug?format=json&maxgoroutines=1", "", "application/json", `"summary":"Summarized view of `},
+	}
+	for _, line := range data {
+		line := line
+		t.Run(line.url+" "+line.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", line.url, nil)
+			if line.accept != "" {
+				req.Header.Set("Accept", line.accept)
+			}
+			w := httptest.NewRecorder()
+			SnapshotHandler(w, req)
+			if w.Code != 200 {
+				t.Fatalf("%d\n%s", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != line.contentType {
+				t.Fatalf("unexpected Content-Type %q", got)
+			}
+			if !strings.Contains(w.Body.String(), line.want) {
+				t.Fatalf("expected %q\n%s", line.want, w.Body.String())
+			}
+			if line.contentType == "application/json" && strings.Contains(w.Body.String(), `"Goroutines":`) {
+				t.Fatalf("the goroutines are already in the buckets\n%s", w.Body.String())
+			}
 		})
 	}
 }
@@ -39,8 +89,16 @@ func TestSnapshotHandler_Err(t *testing.T) {
 	t.Parallel()
 	data := []string{
 		"/debug?augment=2",
+		"/debug?initmem=0",
+		"/debug?maxgoroutines=0",
+		"/debug?maxhtml=abc",
 		"/debug?maxmem=abc",
 		"/debug?similarity=alike",
+		"/debug?view=graph",
+		"/debug?format=xml",
+		"/debug?format=json&view=tree",
+		"/debug?label=team",
+		"/debug?label==payments",
 	}
 	for _, url := range data {
 		url := url
@@ -56,6 +114,91 @@ func TestSnapshotHandler_Err(t *testing.T) {
 	}
 }
 
+func TestSnapshotHandler_Label(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg := sync.WaitGroup{}
+	for _, team := range []string{"payments", "search"} {
+		wg.Add(1)
+		alive := make(chan struct{})
+		go pprof.Do(ctx, pprof.Labels("team", team, "tier", "1"), func(ctx context.Context) {
+			defer wg.Done()
+			close(alive)
+			<-ctx.Done()
+		})
+		<-alive
+	}
+	data := []struct {
+		url  string
+		want []map[string]string
+	}{
+		{"/debug?format=json&label=team=payments", []map[string]string{{"team": "payments", "tier": "1"}}},
+		{"/debug?format=json&label=team=search&label=tier=1", []map[string]string{{"team": "search", "tier": "1"}}},
+		{"/debug?format=json&label=team=payments&label=tier=2", nil},
+	}
+	for _, line := range data {
+		req := httptest.NewRequest("GET", line.url, nil)
+		w := httptest.NewRecorder()
+		SnapshotHandler(w, req)
+		if w.Code != 200 {
+			t.Fatalf("%s: %d\n%s", line.url, w.Code, w.Body.String())
+		}
+		a := struct {
+			Buckets []struct {
+				Labels map[string]string
+			}
+		}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+			t.Fatal(err)
+		}
+		var got []map[string]string
+		for _, b := range a.Buckets {
+			got = append(got, b.Labels)
+		}
+		if !reflect.DeepEqual(line.want, got) {
+			t.Fatalf("%s: unexpected %v", line.url, got)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestSnapshotHandler_Summarized(t *testing.T) {
+	data := []string{
+		"/debug?maxgoroutines=1",
+		"/debug?maxhtml=1",
+		"/debug?maxhtml=1&view=tree",
+	}
+	for _, url := range data {
+		url := url
+		t.Run(url, func(t *testing.T) {
+			req := httptest.NewRequest("GET", url, nil)
+			w := httptest.NewRecorder()
+			SnapshotHandler(w, req)
+			if w.Code != 200 {
+				t.Fatalf("%s: %d\n%s", url, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), "Summarized view of ") {
+				t.Fatalf("%s: expected summarized view\n%s", url, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSnapshotHandler_Overload(t *testing.T) {
+	inflight <- struct{}{}
+	defer func() { <-inflight }()
+	req := httptest.NewRequest("GET", "/debug", nil)
+	w := httptest.NewRecorder()
+	SnapshotHandler(w, req)
+	if w.Code != 503 {
+		t.Fatalf("%d\n%s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != retryAfter {
+		t.Fatalf("unexpected Retry-After %q", got)
+	}
+}
+
 func TestSnapshotHandler_Method_POST(t *testing.T) {
 	t.Parallel()
 	req := httptest.NewRequest("POST", "/debug", nil)